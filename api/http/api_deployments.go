@@ -966,6 +966,13 @@ func (d *DeploymentsApiHandlers) createDeployment(
 		return
 	}
 
+	if constructor.DryRun {
+		// the simulated deployment is not stored: render a preview
+		// rather than a location
+		d.simulateDeployment(w, r, ctx, l, constructor)
+		return
+	}
+
 	id, err := d.app.CreateDeployment(ctx, constructor)
 	switch err {
 	case nil:
@@ -982,6 +989,26 @@ func (d *DeploymentsApiHandlers) createDeployment(
 	}
 }
 
+func (d *DeploymentsApiHandlers) simulateDeployment(
+	w rest.ResponseWriter,
+	r *rest.Request,
+	ctx context.Context,
+	l *log.Logger,
+	constructor *model.DeploymentConstructor,
+) {
+	deployment, err := d.app.SimulateDeployment(ctx, constructor)
+	switch err {
+	case nil:
+		d.view.RenderSuccessGet(w, deployment)
+	case app.ErrNoArtifact:
+		d.view.RenderError(w, r, err, http.StatusUnprocessableEntity, l)
+	case app.ErrNoDevices:
+		d.view.RenderError(w, r, err, http.StatusBadRequest, l)
+	default:
+		d.view.RenderInternalError(w, r, err, l)
+	}
+}
+
 func (d *DeploymentsApiHandlers) PostDeployment(w rest.ResponseWriter, r *rest.Request) {
 	ctx := r.Context()
 	l := requestlog.GetRequestLogger(r)
@@ -1073,6 +1100,8 @@ func (d *DeploymentsApiHandlers) PostDeviceConfigurationDeployment(
 		d.view.RenderError(w, r, err, http.StatusConflict, l)
 	case app.ErrInvalidDeploymentID:
 		d.view.RenderError(w, r, err, http.StatusBadRequest, l)
+	case model.ErrConfigurationTooLarge:
+		d.view.RenderError(w, r, err, http.StatusRequestEntityTooLarge, l)
 	}
 }
 
@@ -1080,13 +1109,23 @@ func (d *DeploymentsApiHandlers) getDeploymentConstructorFromBody(
 	r *rest.Request,
 	group string,
 ) (*model.DeploymentConstructor, error) {
+	if group != "" {
+		var groupConstructor model.DeploymentToGroupConstructor
+		if err := r.DecodeJsonPayload(&groupConstructor); err != nil {
+			return nil, err
+		}
+		groupConstructor.Group = group
+		if err := groupConstructor.Validate(); err != nil {
+			return nil, err
+		}
+		return groupConstructor.DeploymentConstructor, nil
+	}
+
 	var constructor *model.DeploymentConstructor
 	if err := r.DecodeJsonPayload(&constructor); err != nil {
 		return nil, err
 	}
 
-	constructor.Group = group
-
 	if err := constructor.ValidateNew(); err != nil {
 		return nil, err
 	}
@@ -1131,14 +1170,12 @@ func (d *DeploymentsApiHandlers) GetDeploymentStats(w rest.ResponseWriter, r *re
 	}
 
 	stats, err := d.app.GetDeploymentStats(ctx, id)
-	if err != nil {
-		d.view.RenderInternalError(w, r, err, l)
-		return
-	}
-
-	if stats == nil {
+	if errors.Is(err, app.ErrModelDeploymentNotFound) {
 		d.view.RenderErrorNotFound(w, r, l)
 		return
+	} else if err != nil {
+		d.view.RenderInternalError(w, r, err, l)
+		return
 	}
 
 	d.view.RenderSuccessGet(w, stats)
@@ -1522,13 +1559,35 @@ func ParseLookupQuery(vals url.Values) (model.Query, error) {
 		}
 	}
 
-	switch strings.ToLower(vals.Get("sort")) {
+	updatedBefore := vals.Get("updated_before")
+	if updatedBefore != "" {
+		if updatedBeforeTime, err := parseEpochToTimestamp(updatedBefore); err != nil {
+			return query, errors.Wrap(err, "timestamp parsing failed for updated_before parameter")
+		} else {
+			query.UpdatedBefore = &updatedBeforeTime
+		}
+	}
+
+	updatedAfter := vals.Get("updated_after")
+	if updatedAfter != "" {
+		if updatedAfterTime, err := parseEpochToTimestamp(updatedAfter); err != nil {
+			return query, errors.Wrap(err, "timestamp parsing failed for updated_after parameter")
+		} else {
+			query.UpdatedAfter = &updatedAfterTime
+		}
+	}
+
+	switch sort := strings.ToLower(vals.Get("sort")); sort {
 	case model.SortDirectionAscending:
 		query.Sort = model.SortDirectionAscending
 	case "", model.SortDirectionDescending:
 		query.Sort = model.SortDirectionDescending
 	default:
-		return query, ErrInvalidSortDirection
+		sortFields, err := parseSortFields(sort)
+		if err != nil {
+			return query, err
+		}
+		query.SortFields = sortFields
 	}
 
 	status := vals.Get("status")
@@ -1541,6 +1600,10 @@ func ParseLookupQuery(vals url.Values) (model.Query, error) {
 		query.Status = model.StatusQueryPending
 	case "aborted":
 		query.Status = model.StatusQueryAborted
+	case "cancelling":
+		query.Status = model.StatusQueryCancelling
+	case "scheduled":
+		query.Status = model.StatusQueryScheduled
 	case "":
 		query.Status = model.StatusQueryAny
 	default:
@@ -1548,13 +1611,23 @@ func ParseLookupQuery(vals url.Values) (model.Query, error) {
 
 	}
 
+	for _, tag := range vals["tag"] {
+		key, value, ok := strings.Cut(tag, ":")
+		if !ok {
+			return query, errors.Errorf("invalid tag %s: expected key:value", tag)
+		}
+		if query.Tags == nil {
+			query.Tags = make(map[string]string)
+		}
+		query.Tags[key] = value
+	}
+
 	dType := vals.Get("type")
 	if dType == "" {
 		return query, nil
 	}
-	deploymentType := model.DeploymentType(dType)
-	if deploymentType == model.DeploymentTypeSoftware ||
-		deploymentType == model.DeploymentTypeConfiguration {
+	deploymentType := model.DeploymentType(dType).Normalize()
+	if deploymentType.Validate() == nil {
 		query.Type = deploymentType
 	} else {
 		return query, errors.Errorf("unknown deployment type %s", dType)
@@ -1563,6 +1636,28 @@ func ParseLookupQuery(vals url.Values) (model.Query, error) {
 	return query, nil
 }
 
+// parseSortFields parses a comma separated list of field:direction sort
+// criteria; the direction defaults to ascending.
+func parseSortFields(sort string) ([]model.QuerySortField, error) {
+	parts := strings.Split(sort, ",")
+	sortFields := make([]model.QuerySortField, 0, len(parts))
+	for _, part := range parts {
+		field, direction, _ := strings.Cut(part, ":")
+		sortField := model.QuerySortField{
+			Field:     model.SortField(field),
+			Direction: model.SortOrder(direction),
+		}
+		if direction == "" {
+			sortField.Direction = model.SortOrderAscending
+		}
+		if err := sortField.Validate(); err != nil {
+			return nil, err
+		}
+		sortFields = append(sortFields, sortField)
+	}
+	return sortFields, nil
+}
+
 func parseEpochToTimestamp(epoch string) (time.Time, error) {
 	if epochInt64, err := strconv.ParseInt(epoch, 10, 64); err != nil {
 		return time.Time{}, errors.Errorf("invalid timestamp: " + epoch)
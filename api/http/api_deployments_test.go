@@ -149,6 +149,31 @@ func TestDeploymentsPerTenantHandler(t *testing.T) {
 			responseCode: http.StatusOK,
 			responseBody: []*model.Deployment{},
 		},
+		"ok with tags": {
+			tenant:      "tenantID",
+			queryString: "tag=env:production&tag=team:core",
+			query: &model.Query{
+				Limit: rest_utils.PerPageDefault + 1,
+				Sort:  model.SortDirectionDescending,
+				Tags: map[string]string{
+					"env":  "production",
+					"team": "core",
+				},
+			},
+			deployments:  []*model.Deployment{},
+			count:        0,
+			responseCode: http.StatusOK,
+			responseBody: []*model.Deployment{},
+		},
+		"ko, invalid tag": {
+			tenant:       "tenantID",
+			queryString:  "tag=env",
+			responseCode: http.StatusBadRequest,
+			responseBody: rest_utils.ApiError{
+				Err:   "invalid tag env: expected key:value",
+				ReqId: "test",
+			},
+		},
 		"ko, missing tenant ID": {
 			tenant:       "",
 			responseCode: http.StatusBadRequest,
@@ -586,6 +611,76 @@ func TestPostDeployment(t *testing.T) {
 	}
 }
 
+func TestPostDeploymentDryRun(t *testing.T) {
+	t.Parallel()
+
+	constructor := &model.DeploymentConstructor{
+		Name:         "foo",
+		ArtifactName: "bar",
+		Devices:      []string{"f826484e-1157-4109-af21-304e6d711560"},
+		DryRun:       true,
+	}
+	deployment, err := model.NewDeploymentFromConstructor(constructor)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	testCases := map[string]struct {
+		AppError error
+
+		ResponseCode int
+		ResponseBody interface{}
+	}{
+		"ok": {
+			ResponseCode: http.StatusOK,
+			ResponseBody: deployment,
+		},
+		"error: app error: no artifact": {
+			AppError:     app.ErrNoArtifact,
+			ResponseCode: http.StatusUnprocessableEntity,
+			ResponseBody: rest_utils.ApiError{
+				Err:   app.ErrNoArtifact.Error(),
+				ReqId: "test",
+			},
+		},
+	}
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			app := &mapp.App{}
+			defer app.AssertExpectations(t)
+			var simulated *model.Deployment
+			if tc.AppError == nil {
+				simulated = deployment
+			}
+			app.On("SimulateDeployment",
+				mock.AnythingOfType("*context.valueCtx"),
+				constructor,
+			).Return(simulated, tc.AppError).
+				Once()
+
+			d := NewDeploymentsApiHandlers(nil, new(view.RESTView), app)
+			api := setUpRestTest(
+				ApiUrlManagementDeployments,
+				rest.Post,
+				d.PostDeployment,
+			)
+			req := test.MakeSimpleRequest(
+				"POST",
+				"http://localhost"+ApiUrlManagementDeployments,
+				constructor,
+			)
+			req.Header.Set("X-MEN-RequestID", "test")
+			recorded := test.RunRequest(t, api.MakeHandler(), req)
+			recorded.CodeIs(tc.ResponseCode)
+			recorded.HeaderIs("Location", "")
+			b, _ := json.Marshal(tc.ResponseBody)
+			assert.JSONEq(t, string(b), recorded.Recorder.Body.String())
+		})
+	}
+}
+
 func TestPostDeploymentToGroup(t *testing.T) {
 	t.Parallel()
 
@@ -1897,17 +1992,42 @@ func TestLookupDeployment(t *testing.T) {
 			count:        0,
 			ResponseCode: http.StatusOK,
 		},
+		{
+			Name: "ok, sort fields",
+			query: &model.Query{
+				Limit: rest_utils.PerPageDefault + 1,
+				SortFields: []model.QuerySortField{{
+					Field:     model.SortFieldStatus,
+					Direction: model.SortOrderAscending,
+				}, {
+					Field:     model.SortFieldCreated,
+					Direction: model.SortOrderDescending,
+				}},
+			},
+			deployments:  []*model.Deployment{},
+			count:        0,
+			sort:         "status,created:desc",
+			ResponseCode: http.StatusOK,
+		},
+		{
+			Name:         "error, invalid sort field",
+			sort:         "priority:asc",
+			ResponseCode: http.StatusBadRequest,
+		},
 	}
 
 	for _, tc := range testCases {
 		t.Run(tc.Name, func(t *testing.T) {
 			app := &mapp.App{}
-			app.On("LookupDeployment",
-				mock.MatchedBy(func(ctx context.Context) bool {
-					return true
-				}),
-				*tc.query,
-			).Return(tc.deployments, tc.count, tc.appError)
+			if tc.query != nil {
+				app.On("LookupDeployment",
+					mock.MatchedBy(func(ctx context.Context) bool {
+						return true
+					}),
+					*tc.query,
+				).Return(tc.deployments, tc.count, tc.appError)
+			}
+			defer app.AssertExpectations(t)
 			restView := new(view.RESTView)
 			d := NewDeploymentsApiHandlers(nil, restView, app)
 			api := setUpRestTest(
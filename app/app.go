@@ -21,6 +21,7 @@ import (
 	"io"
 	"path"
 	"reflect"
+	"sort"
 	"strings"
 	"time"
 
@@ -54,6 +55,7 @@ const (
 	PerPageInventoryDevices          = 512
 	InventoryGroupScope              = "system"
 	InventoryIdentityScope           = "identity"
+	InventoryAttributesScope         = "inventory"
 	InventoryGroupAttributeName      = "group"
 	InventoryStatusAttributeName     = "status"
 	InventoryStatusAccepted          = "accepted"
@@ -100,6 +102,9 @@ var (
 	ErrDuplicateDeployment     = errors.New("Deployment with given ID already exists")
 	ErrInvalidDeploymentID     = errors.New("Deployment ID must be a valid UUID")
 	ErrConflictingRequestData  = errors.New("Device provided conflicting request data")
+	ErrDryRunDeployment        = errors.New(
+		"Dry-run deployments are simulated, not created",
+	)
 )
 
 //deployments
@@ -145,9 +150,12 @@ type App interface {
 	// deployments
 	CreateDeployment(ctx context.Context,
 		constructor *model.DeploymentConstructor) (string, error)
+	SimulateDeployment(ctx context.Context,
+		constructor *model.DeploymentConstructor) (*model.Deployment, error)
 	GetDeployment(ctx context.Context, deploymentID string) (*model.Deployment, error)
 	IsDeploymentFinished(ctx context.Context, deploymentID string) (bool, error)
 	AbortDeployment(ctx context.Context, deploymentID string) error
+	AbortDeployments(ctx context.Context, deploymentIDs []string) (int, []error)
 	GetDeploymentStats(ctx context.Context, deploymentID string) (model.Stats, error)
 	GetDeploymentsStats(ctx context.Context,
 		deploymentIDs ...string) ([]*model.DeploymentStats, error)
@@ -1040,6 +1048,31 @@ func (d *Deployments) updateDeploymentConstructor(ctx context.Context,
 				Type:      "$eq",
 				Value:     constructor.Group,
 			})
+	} else if len(constructor.SubgroupNames) > 0 {
+		searchParams.Filters = append(
+			searchParams.Filters,
+			model.FilterPredicate{
+				Scope:     InventoryGroupScope,
+				Attribute: InventoryGroupAttributeName,
+				Type:      "$in",
+				Value:     constructor.SubgroupNames,
+			})
+	}
+	// sort the attributes so that the search is stable
+	tagNames := make([]string, 0, len(constructor.DeviceTagFilter))
+	for name := range constructor.DeviceTagFilter {
+		tagNames = append(tagNames, name)
+	}
+	sort.Strings(tagNames)
+	for _, name := range tagNames {
+		searchParams.Filters = append(
+			searchParams.Filters,
+			model.FilterPredicate{
+				Scope:     InventoryAttributesScope,
+				Attribute: name,
+				Type:      "$eq",
+				Value:     constructor.DeviceTagFilter[name],
+			})
 	}
 
 	for {
@@ -1056,7 +1089,8 @@ func (d *Deployments) updateDeploymentConstructor(ctx context.Context,
 			break
 		}
 		constructor.Devices = append(constructor.Devices, inventoryDevicesToDevicesIds(devices)...)
-		if len(constructor.Devices) == count {
+		if len(constructor.Devices) == count ||
+			constructor.MaxDevices > 0 && len(constructor.Devices) >= constructor.MaxDevices {
 			break
 		}
 		searchParams.Page++
@@ -1100,6 +1134,9 @@ func (d *Deployments) CreateDeviceConfigurationDeployment(
 		if strings.Contains(err.Error(), "id: must be a valid UUID") {
 			return "", ErrInvalidDeploymentID
 		}
+		if errors.Is(err, model.ErrConfigurationTooLarge) {
+			return "", model.ErrConfigurationTooLarge
+		}
 		return "", errors.Wrap(err, "Storing deployment data")
 	}
 
@@ -1107,29 +1144,67 @@ func (d *Deployments) CreateDeviceConfigurationDeployment(
 }
 
 // CreateDeployment precomputes new deployment and schedules it for devices.
+// Dry-run constructors are rejected with ErrDryRunDeployment, see
+// SimulateDeployment.
 func (d *Deployments) CreateDeployment(ctx context.Context,
 	constructor *model.DeploymentConstructor) (string, error) {
+	if constructor != nil && constructor.DryRun {
+		return "", ErrDryRunDeployment
+	}
+	deployment, err := d.prepareDeployment(ctx, constructor)
+	if err != nil {
+		return "", err
+	}
+
+	if err := d.db.InsertDeployment(ctx, deployment); err != nil {
+		return "", errors.Wrap(err, "Storing deployment data")
+	}
+
+	return deployment.Id, nil
+}
+
+// SimulateDeployment computes the deployment that would be created from the
+// constructor without storing it.
+func (d *Deployments) SimulateDeployment(ctx context.Context,
+	constructor *model.DeploymentConstructor) (*model.Deployment, error) {
+	if constructor == nil {
+		return nil, ErrModelMissingInput
+	}
+	simulated := *constructor
+	simulated.DryRun = true
+	return d.prepareDeployment(ctx, &simulated)
+}
+
+func (d *Deployments) prepareDeployment(ctx context.Context,
+	constructor *model.DeploymentConstructor) (*model.Deployment, error) {
 
 	var err error
 
 	if constructor == nil {
-		return "", ErrModelMissingInput
+		return nil, ErrModelMissingInput
 	}
 
 	if err := constructor.Validate(); err != nil {
-		return "", errors.Wrap(err, "Validating deployment")
+		return nil, errors.Wrap(err, "Validating deployment")
 	}
 
-	if len(constructor.Group) > 0 || constructor.AllDevices {
+	if len(constructor.Group) > 0 ||
+		len(constructor.SubgroupNames) > 0 ||
+		len(constructor.DeviceTagFilter) > 0 ||
+		constructor.AllDevices {
 		constructor, err = d.updateDeploymentConstructor(ctx, constructor)
 		if err != nil {
-			return "", err
+			return nil, err
 		}
 	}
 
+	if constructor.MaxDevices > 0 && len(constructor.Devices) > constructor.MaxDevices {
+		constructor.Devices = constructor.Devices[:constructor.MaxDevices]
+	}
+
 	deployment, err := model.NewDeploymentFromConstructor(constructor)
 	if err != nil {
-		return "", errors.Wrap(err, "failed to create deployment")
+		return nil, errors.Wrap(err, "failed to create deployment")
 	}
 
 	// Assign artifacts to the deployment.
@@ -1137,35 +1212,29 @@ func (d *Deployments) CreateDeployment(ctx context.Context,
 	// will be uploaded to the backend, it will also become part of this deployment.
 	artifacts, err := d.db.ImagesByName(ctx, deployment.ArtifactName)
 	if err != nil {
-		return "", errors.Wrap(err, "Finding artifact with given name")
+		return nil, errors.Wrap(err, "Finding artifact with given name")
 	}
 
 	if len(artifacts) == 0 {
-		return "", ErrNoArtifact
+		return nil, ErrNoArtifact
 	}
 
 	deployment.Artifacts = getArtifactIDs(artifacts)
 	deployment.DeviceList = constructor.Devices
 	deployment.MaxDevices = len(constructor.Devices)
 	deployment.Type = model.DeploymentTypeSoftware
-	if len(constructor.Group) > 0 {
-		deployment.Groups = []string{constructor.Group}
-	}
+	deployment.Groups = deployment.TargetGroupNames()
 
 	// single device deployment case
 	if len(deployment.Groups) == 0 && len(constructor.Devices) == 1 {
 		groups, err := d.getDeploymentGroups(ctx, constructor.Devices)
 		if err != nil {
-			return "", err
+			return nil, err
 		}
 		deployment.Groups = groups
 	}
 
-	if err := d.db.InsertDeployment(ctx, deployment); err != nil {
-		return "", errors.Wrap(err, "Storing deployment data")
-	}
-
-	return deployment.Id, nil
+	return deployment, nil
 }
 
 func (d *Deployments) getDeploymentGroups(
@@ -1331,6 +1400,10 @@ func (d *Deployments) getNewDeploymentForDevice(ctx context.Context,
 		}
 
 		for _, deployment := range deployments {
+			// the devices do not get the deployments scheduled in the future
+			if deployment.IsScheduled() {
+				continue
+			}
 			ok, err := d.isDevicePartOfDeployment(ctx, deviceID, deployment)
 			if err != nil {
 				return nil, nil, err
@@ -1449,7 +1522,7 @@ func (d *Deployments) getDeploymentInstructions(
 
 	l := log.FromContext(ctx)
 
-	if deployment.Type == model.DeploymentTypeConfiguration {
+	if deployment.IsConfiguration() {
 		// There's nothing more we need to do, the link must be filled
 		// in by the API layer.
 		return &model.DeploymentInstructions{
@@ -1672,11 +1745,11 @@ func (d *Deployments) GetDeploymentStats(ctx context.Context,
 	deployment, err := d.db.FindDeploymentByID(ctx, deploymentID)
 
 	if err != nil {
-		return nil, errors.Wrap(err, "checking deployment id")
+		return model.Stats{}, errors.Wrap(err, "checking deployment id")
 	}
 
 	if deployment == nil {
-		return nil, nil
+		return model.Stats{}, ErrModelDeploymentNotFound
 	}
 
 	return deployment.Stats, nil
@@ -1804,6 +1877,9 @@ func (d *Deployments) setDeploymentDeviceCountIfUnset(
 
 func (d *Deployments) LookupDeployment(ctx context.Context,
 	query model.Query) ([]*model.Deployment, int64, error) {
+	if err := query.Validate(); err != nil {
+		return nil, 0, err
+	}
 	list, totalCount, err := d.db.Find(ctx, query)
 
 	if err != nil {
@@ -1869,6 +1945,56 @@ func (d *Deployments) HasDeploymentForDevice(ctx context.Context,
 // AbortDeployment aborts deployment for devices and updates deployment stats
 func (d *Deployments) AbortDeployment(ctx context.Context, deploymentID string) error {
 
+	if err := d.abortDeviceDeployments(ctx, deploymentID); err != nil {
+		return err
+	}
+
+	// when aborting the deployment we need to set status directly instead of
+	// using recalcDeploymentStatus method;
+	// it is possible that the deployment does not have any device deployments yet;
+	// in that case, all statistics are 0 and calculating status based on statistics
+	// will not work - the calculated status will be "pending"
+	if err := d.db.SetDeploymentStatus(ctx,
+		deploymentID, model.DeploymentStatusFinished, time.Now()); err != nil {
+		return errors.Wrap(err, "failed to update deployment status")
+	}
+
+	return nil
+}
+
+// AbortDeployments aborts the deployments with the given IDs, see
+// AbortDeployment. Failing to abort a deployment does not prevent aborting
+// the others; it returns the number of aborted deployments along with the
+// errors encountered.
+func (d *Deployments) AbortDeployments(
+	ctx context.Context,
+	deploymentIDs []string,
+) (int, []error) {
+	var errs []error
+	aborted := make([]string, 0, len(deploymentIDs))
+	for _, deploymentID := range deploymentIDs {
+		if err := d.abortDeviceDeployments(ctx, deploymentID); err != nil {
+			errs = append(errs, errors.Wrapf(err,
+				"failed to abort deployment %s", deploymentID))
+			continue
+		}
+		aborted = append(aborted, deploymentID)
+	}
+	if len(aborted) == 0 {
+		return 0, errs
+	}
+
+	// set the status directly, see AbortDeployment
+	if err := d.db.SetDeploymentsStatus(ctx,
+		aborted, model.DeploymentStatusFinished, time.Now()); err != nil {
+		return 0, append(errs, errors.Wrap(err, "failed to update deployments status"))
+	}
+	return len(aborted), errs
+}
+
+// abortDeviceDeployments aborts the device deployments of the deployment
+// and updates the deployment stats accordingly.
+func (d *Deployments) abortDeviceDeployments(ctx context.Context, deploymentID string) error {
 	if err := d.db.AbortDeviceDeployments(ctx, deploymentID); err != nil {
 		return err
 	}
@@ -1883,17 +2009,6 @@ func (d *Deployments) AbortDeployment(ctx context.Context, deploymentID string)
 	if err := d.db.UpdateStats(ctx, deploymentID, stats); err != nil {
 		return errors.Wrap(err, "failed to update deployment stats")
 	}
-
-	// when aborting the deployment we need to set status directly instead of
-	// using recalcDeploymentStatus method;
-	// it is possible that the deployment does not have any device deployments yet;
-	// in that case, all statistics are 0 and calculating status based on statistics
-	// will not work - the calculated status will be "pending"
-	if err := d.db.SetDeploymentStatus(ctx,
-		deploymentID, model.DeploymentStatusFinished, time.Now()); err != nil {
-		return errors.Wrap(err, "failed to update deployment status")
-	}
-
 	return nil
 }
 
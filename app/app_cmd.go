@@ -19,6 +19,8 @@ import (
 	"path"
 	"time"
 
+	"github.com/pkg/errors"
+
 	"github.com/mendersoftware/deployments/model"
 	"github.com/mendersoftware/deployments/storage"
 	"github.com/mendersoftware/deployments/store"
@@ -53,7 +55,7 @@ func (d *Deployments) cleanupExpiredLink(
 			objectPath = path.Join(link.TenantID, objectPath)
 		}
 		err = d.objectStorage.DeleteObject(ctx, objectPath)
-		if err != nil && err != storage.ErrObjectNotFound {
+		if err != nil && !errors.Is(err, storage.ErrObjectNotFound) {
 			break
 		}
 		statusNew := link.Status
@@ -181,8 +181,9 @@ func TestDeploymentModelCreateDeployment(t *testing.T) {
 
 		ReportingService bool
 
-		OutputError error
-		OutputBody  bool
+		OutputError      error
+		OutputBody       bool
+		OutputDeviceList []string
 	}{
 		"model missing": {
 			OutputError: ErrModelMissingInput,
@@ -246,6 +247,27 @@ func TestDeploymentModelCreateDeployment(t *testing.T) {
 
 			OutputBody: true,
 		},
+		"ok with group, max devices": {
+			InputConstructor: &model.DeploymentConstructor{
+				Name:         "group",
+				ArtifactName: "App 123",
+				Group:        "group",
+				MaxDevices:   1,
+			},
+
+			InvDevices: []model.InvDevice{
+				{
+					ID: "b532b01a-9313-404f-8d19-e7fcbe5cc347",
+				},
+				{
+					ID: "b532b01a-9313-404f-8d19-e7fcbe5cc348",
+				},
+			},
+			TotalCount: 3,
+
+			OutputBody:       true,
+			OutputDeviceList: []string{"b532b01a-9313-404f-8d19-e7fcbe5cc347"},
+		},
 		"ok with group, reeporting": {
 			InputConstructor: &model.DeploymentConstructor{
 				Name:         "group",
@@ -373,7 +395,10 @@ func TestDeploymentModelCreateDeployment(t *testing.T) {
 						},
 					).Return(testCase.InvDevices, testCase.TotalCount, testCase.SearchError)
 
-					if testCase.TotalCount > len(testCase.InvDevices) {
+					// the second page is not needed if the first one
+					// already exceeds the cap
+					if testCase.TotalCount > len(testCase.InvDevices) &&
+						testCase.InputConstructor.MaxDevices == 0 {
 						mockInventoryClient.On("Search", ctx,
 							"tenant_id",
 							model.SearchParams{
@@ -413,6 +438,12 @@ func TestDeploymentModelCreateDeployment(t *testing.T) {
 			if testCase.OutputBody {
 				assert.NotNil(t, out)
 			}
+			if testCase.OutputDeviceList != nil {
+				assert.Equal(t,
+					testCase.OutputDeviceList,
+					testCase.InputConstructor.Devices,
+				)
+			}
 
 			mockInventoryClient.AssertExpectations(t)
 		})
@@ -420,6 +451,170 @@ func TestDeploymentModelCreateDeployment(t *testing.T) {
 
 }
 
+func TestDeploymentModelCreateDeploymentDeviceTagFilter(t *testing.T) {
+	t.Parallel()
+
+	searchParams := model.SearchParams{
+		Page:    1,
+		PerPage: PerPageInventoryDevices,
+		Filters: []model.FilterPredicate{
+			{
+				Scope:     InventoryIdentityScope,
+				Attribute: InventoryStatusAttributeName,
+				Type:      "$eq",
+				Value:     InventoryStatusAccepted,
+			},
+			{
+				Scope:     InventoryAttributesScope,
+				Attribute: "device_type",
+				Type:      "$eq",
+				Value:     "raspberrypi4",
+			},
+			{
+				Scope:     InventoryAttributesScope,
+				Attribute: "region",
+				Type:      "$eq",
+				Value:     "eu",
+			},
+		},
+	}
+	testCases := map[string]struct {
+		InvDevices []model.InvDevice
+		TotalCount int
+
+		DeviceList []string
+		Error      error
+	}{
+		"ok": {
+			InvDevices: []model.InvDevice{
+				{ID: "b532b01a-9313-404f-8d19-e7fcbe5cc347"},
+				{ID: "b532b01a-9313-404f-8d19-e7fcbe5cc348"},
+			},
+			TotalCount: 2,
+
+			DeviceList: []string{
+				"b532b01a-9313-404f-8d19-e7fcbe5cc347",
+				"b532b01a-9313-404f-8d19-e7fcbe5cc348",
+			},
+		},
+		"error, no matching devices": {
+			Error: ErrNoDevices,
+		},
+	}
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			ctx := identity.WithContext(context.Background(),
+				&identity.Identity{Tenant: "tenant_id"})
+
+			db := &mocks.DataStore{}
+			defer db.AssertExpectations(t)
+			inv := &inventory_mocks.Client{}
+			defer inv.AssertExpectations(t)
+
+			inv.On("Search", ctx, "tenant_id", searchParams).
+				Return(tc.InvDevices, tc.TotalCount, nil).
+				Once()
+			if tc.Error == nil {
+				db.On("ImagesByName", ctx, "App 123").
+					Return([]*model.Image{model.NewImage(
+						validUUIDv4,
+						&model.ImageMeta{},
+						&model.ArtifactMeta{
+							Name:                  "App 123",
+							DeviceTypesCompatible: []string{"hammer"},
+						}, artifactSize)}, nil).
+					Once()
+				db.On("InsertDeployment", ctx,
+					mock.MatchedBy(func(deployment *model.Deployment) bool {
+						return assert.Equal(t, tc.DeviceList, deployment.DeviceList) &&
+							assert.Equal(t, len(tc.DeviceList), deployment.MaxDevices)
+					})).
+					Return(nil).
+					Once()
+			}
+
+			ds := NewDeployments(db, &fs_mocks.ObjectStorage{}, 0, false)
+			ds.SetInventoryClient(inv)
+
+			id, err := ds.CreateDeployment(ctx, &model.DeploymentConstructor{
+				Name:         "tagged",
+				ArtifactName: "App 123",
+				DeviceTagFilter: map[string]string{
+					"region":      "eu",
+					"device_type": "raspberrypi4",
+				},
+			})
+			if tc.Error != nil {
+				assert.ErrorIs(t, err, tc.Error)
+			} else if assert.NoError(t, err) {
+				assert.NotEmpty(t, id)
+			}
+		})
+	}
+}
+
+func TestDeploymentModelSimulateDeployment(t *testing.T) {
+	t.Parallel()
+
+	ctx := identity.WithContext(context.Background(),
+		&identity.Identity{Tenant: "tenant_id"})
+
+	db := &mocks.DataStore{}
+	db.On("ImagesByName", ctx, "App 123").
+		Return([]*model.Image{model.NewImage(
+			validUUIDv4,
+			&model.ImageMeta{},
+			&model.ArtifactMeta{
+				Name:                  "App 123",
+				DeviceTypesCompatible: []string{"hammer"},
+			}, artifactSize)}, nil)
+	defer db.AssertExpectations(t)
+
+	mockInventoryClient := &inventory_mocks.Client{}
+	mockInventoryClient.On("GetDeviceGroups",
+		ctx,
+		"tenant_id",
+		"b532b01a-9313-404f-8d19-e7fcbe5cc347").
+		Return([]string{"foo"}, nil)
+	defer mockInventoryClient.AssertExpectations(t)
+
+	ds := NewDeployments(db, &fs_mocks.ObjectStorage{}, 0, false)
+	ds.SetInventoryClient(mockInventoryClient)
+
+	constructor := &model.DeploymentConstructor{
+		Name:         "NYC Production",
+		ArtifactName: "App 123",
+		Devices:      []string{"b532b01a-9313-404f-8d19-e7fcbe5cc347"},
+	}
+	deployment, err := ds.SimulateDeployment(ctx, constructor)
+	if assert.NoError(t, err) {
+		assert.True(t, deployment.IsSimulated())
+		assert.Equal(t, model.DeploymentStatusSimulated, deployment.Status)
+		assert.Equal(t, []string{validUUIDv4}, deployment.Artifacts)
+		assert.Equal(t, constructor.Devices, deployment.DeviceList)
+		assert.Equal(t, []string{"foo"}, deployment.Groups)
+	}
+	assert.False(t, constructor.DryRun,
+		"SimulateDeployment must not modify the constructor")
+
+	again, err := ds.SimulateDeployment(ctx, constructor)
+	if assert.NoError(t, err) && deployment != nil {
+		assert.Equal(t, deployment.Id, again.Id,
+			"simulated deployment IDs must be deterministic")
+	}
+
+	constructor.DryRun = true
+	_, err = ds.CreateDeployment(ctx, constructor)
+	assert.ErrorIs(t, err, ErrDryRunDeployment)
+	db.AssertNotCalled(t, "InsertDeployment", mock.Anything, mock.Anything)
+
+	_, err = ds.SimulateDeployment(ctx, nil)
+	assert.ErrorIs(t, err, ErrModelMissingInput)
+}
+
 func TestUploadLink(t *testing.T) {
 	t.Parallel()
 
@@ -1043,6 +1238,19 @@ func TestCreateDeviceConfigurationDeployment(t *testing.T) {
 
 			outputError: errors.New("Storing deployment data: insert error"),
 		},
+		"configuration too large": {
+			inputConstructor: &model.ConfigurationDeploymentConstructor{
+				Name:          "foo",
+				Configuration: []byte("bar"),
+			},
+			inputDeploymentStorageInsertError: errors.Wrap(
+				model.ErrConfigurationTooLarge, "validating deployment",
+			),
+			callInventory: true,
+			callDb:        true,
+
+			outputError: model.ErrConfigurationTooLarge,
+		},
 		"inventory error": {
 			inputConstructor: &model.ConfigurationDeploymentConstructor{
 				Name:          "foo",
@@ -1127,19 +1335,23 @@ func TestAbortDeployment(t *testing.T) {
 			OutputError:                            errors.New("AggregateDeviceDeploymentByStatusError"),
 		},
 		"UpdateStats error": {
-			InputDeploymentID:                      "f826484e-1157-4109-af21-304e6d711561",
-			CallAggregateDeviceDeploymentByStatus:  true,
-			AggregateDeviceDeploymentByStatusStats: model.Stats{"aaa": 1},
-			CallUpdateStats:                        true,
-			UpdateStatsError:                       errors.New("UpdateStatsError"),
-			OutputError:                            errors.New("failed to update deployment stats: UpdateStatsError"),
+			InputDeploymentID:                     "f826484e-1157-4109-af21-304e6d711561",
+			CallAggregateDeviceDeploymentByStatus: true,
+			AggregateDeviceDeploymentByStatusStats: model.NewStats(map[model.DeviceDeploymentStatus]int{
+				model.DeviceDeploymentStatusAborted: 1,
+			}),
+			CallUpdateStats:  true,
+			UpdateStatsError: errors.New("UpdateStatsError"),
+			OutputError:      errors.New("failed to update deployment stats: UpdateStatsError"),
 		},
 		"all correct": {
-			InputDeploymentID:                      "f826484e-1157-4109-af21-304e6d711561",
-			CallAggregateDeviceDeploymentByStatus:  true,
-			AggregateDeviceDeploymentByStatusStats: model.Stats{"aaa": 1},
-			CallUpdateStats:                        true,
-			CallSetDeploymentStatus:                true,
+			InputDeploymentID:                     "f826484e-1157-4109-af21-304e6d711561",
+			CallAggregateDeviceDeploymentByStatus: true,
+			AggregateDeviceDeploymentByStatusStats: model.NewStats(map[model.DeviceDeploymentStatus]int{
+				model.DeviceDeploymentStatusAborted: 1,
+			}),
+			CallUpdateStats:         true,
+			CallSetDeploymentStatus: true,
 		},
 	}
 
@@ -1184,6 +1396,103 @@ func TestAbortDeployment(t *testing.T) {
 	}
 }
 
+func TestAbortDeployments(t *testing.T) {
+	t.Parallel()
+
+	const (
+		deploymentOK     = "f826484e-1157-4109-af21-304e6d711561"
+		deploymentFailed = "f826484e-1157-4109-af21-304e6d711562"
+		deploymentStats  = "f826484e-1157-4109-af21-304e6d711563"
+	)
+	stats := model.NewStats(map[model.DeviceDeploymentStatus]int{
+		model.DeviceDeploymentStatusAborted: 1,
+	})
+
+	testCases := map[string]struct {
+		DeploymentIDs []string
+
+		SetDeploymentsStatusIDs   []string
+		SetDeploymentsStatusError error
+
+		Aborted int
+		Errors  []string
+	}{
+		"ok": {
+			DeploymentIDs:           []string{deploymentOK},
+			SetDeploymentsStatusIDs: []string{deploymentOK},
+			Aborted:                 1,
+		},
+		"ok, no deployments": {},
+		"partial failure": {
+			DeploymentIDs: []string{
+				deploymentFailed, deploymentOK, deploymentStats,
+			},
+			SetDeploymentsStatusIDs: []string{deploymentOK},
+			Aborted:                 1,
+			Errors: []string{
+				"failed to abort deployment " + deploymentFailed +
+					": AbortDeviceDeploymentsError",
+				"failed to abort deployment " + deploymentStats +
+					": failed to update deployment stats: UpdateStatsError",
+			},
+		},
+		"all failed": {
+			DeploymentIDs: []string{deploymentFailed},
+			Errors: []string{
+				"failed to abort deployment " + deploymentFailed +
+					": AbortDeviceDeploymentsError",
+			},
+		},
+		"error, setting the status": {
+			DeploymentIDs:             []string{deploymentFailed, deploymentOK},
+			SetDeploymentsStatusIDs:   []string{deploymentOK},
+			SetDeploymentsStatusError: errors.New("SetDeploymentsStatusError"),
+			Errors: []string{
+				"failed to abort deployment " + deploymentFailed +
+					": AbortDeviceDeploymentsError",
+				"failed to update deployments status: SetDeploymentsStatusError",
+			},
+		},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			db := mocks.DataStore{}
+			defer db.AssertExpectations(t)
+			db.On("AbortDeviceDeployments", h.ContextMatcher(), deploymentFailed).
+				Return(errors.New("AbortDeviceDeploymentsError")).Maybe()
+			for _, id := range []string{deploymentOK, deploymentStats} {
+				db.On("AbortDeviceDeployments", h.ContextMatcher(), id).
+					Return(nil).Maybe()
+				db.On("AggregateDeviceDeploymentByStatus", h.ContextMatcher(), id).
+					Return(stats, nil).Maybe()
+			}
+			db.On("UpdateStats", h.ContextMatcher(), deploymentOK, stats).
+				Return(nil).Maybe()
+			db.On("UpdateStats", h.ContextMatcher(), deploymentStats, stats).
+				Return(errors.New("UpdateStatsError")).Maybe()
+			if tc.SetDeploymentsStatusIDs != nil {
+				db.On("SetDeploymentsStatus", h.ContextMatcher(),
+					tc.SetDeploymentsStatusIDs, model.DeploymentStatusFinished,
+					mock.AnythingOfType("time.Time")).
+					Return(tc.SetDeploymentsStatusError)
+			}
+
+			ds := &Deployments{
+				db: &db,
+			}
+			aborted, errs := ds.AbortDeployments(context.Background(), tc.DeploymentIDs)
+			assert.Equal(t, tc.Aborted, aborted)
+			if assert.Len(t, errs, len(tc.Errors)) {
+				for i, err := range errs {
+					assert.EqualError(t, err, tc.Errors[i])
+				}
+			}
+		})
+	}
+}
+
 func TestDeleteDeviceDeploymentsHistory(t *testing.T) {
 	t.Parallel()
 	f := false
@@ -48,6 +48,29 @@ func (_m *App) AbortDeployment(ctx context.Context, deploymentID string) error {
 	return r0
 }
 
+// AbortDeployments provides a mock function with given fields: ctx, deploymentIDs
+func (_m *App) AbortDeployments(ctx context.Context, deploymentIDs []string) (int, []error) {
+	ret := _m.Called(ctx, deploymentIDs)
+
+	var r0 int
+	if rf, ok := ret.Get(0).(func(context.Context, []string) int); ok {
+		r0 = rf(ctx, deploymentIDs)
+	} else {
+		r0 = ret.Get(0).(int)
+	}
+
+	var r1 []error
+	if rf, ok := ret.Get(1).(func(context.Context, []string) []error); ok {
+		r1 = rf(ctx, deploymentIDs)
+	} else {
+		if ret.Get(1) != nil {
+			r1 = ret.Get(1).([]error)
+		}
+	}
+
+	return r0, r1
+}
+
 // AbortDeviceDeployments provides a mock function with given fields: ctx, deviceID
 func (_m *App) AbortDeviceDeployments(ctx context.Context, deviceID string) error {
 	ret := _m.Called(ctx, deviceID)
@@ -323,9 +346,7 @@ func (_m *App) GetDeploymentStats(ctx context.Context, deploymentID string) (mod
 	if rf, ok := ret.Get(0).(func(context.Context, string) model.Stats); ok {
 		r0 = rf(ctx, deploymentID)
 	} else {
-		if ret.Get(0) != nil {
-			r0 = ret.Get(0).(model.Stats)
-		}
+		r0 = ret.Get(0).(model.Stats)
 	}
 
 	var r1 error
@@ -782,6 +803,29 @@ func (_m *App) SetStorageSettings(ctx context.Context, storageSettings *model.St
 	return r0
 }
 
+// SimulateDeployment provides a mock function with given fields: ctx, constructor
+func (_m *App) SimulateDeployment(ctx context.Context, constructor *model.DeploymentConstructor) (*model.Deployment, error) {
+	ret := _m.Called(ctx, constructor)
+
+	var r0 *model.Deployment
+	if rf, ok := ret.Get(0).(func(context.Context, *model.DeploymentConstructor) *model.Deployment); ok {
+		r0 = rf(ctx, constructor)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.Deployment)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, *model.DeploymentConstructor) error); ok {
+		r1 = rf(ctx, constructor)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // UpdateDeploymentsWithArtifactName provides a mock function with given fields: ctx, artifactName
 func (_m *App) UpdateDeploymentsWithArtifactName(ctx context.Context, artifactName string) error {
 	ret := _m.Called(ctx, artifactName)
@@ -263,7 +263,7 @@ func TestDecommissionDevice(t *testing.T) {
 			findDeploymentByIDDeployment: &model.Deployment{
 				Id:         "bar",
 				MaxDevices: 1,
-				Stats:      model.Stats{"decommissioned": 1},
+				Stats:      model.NewStats(map[model.DeviceDeploymentStatus]int{model.DeviceDeploymentStatusDecommissioned: 1}),
 			},
 		},
 		"ok 1": {
@@ -443,7 +443,7 @@ func TestAbortDeviceDeployments(t *testing.T) {
 			findDeploymentByIDDeployment: &model.Deployment{
 				Id:         "bar",
 				MaxDevices: 1,
-				Stats:      model.Stats{"decommissioned": 1},
+				Stats:      model.NewStats(map[model.DeviceDeploymentStatus]int{model.DeviceDeploymentStatusDecommissioned: 1}),
 			},
 		},
 		"ok 1": {
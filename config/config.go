@@ -87,6 +87,11 @@ const (
 	SettingAzureSharedKeyAccountKey = SettingAzureSharedKey + ".account_key"
 	SettingAzureSharedKeyURI        = SettingAzureSharedKey + ".uri"
 
+	SettingAzureManagedIdentity            = SettingAzureAuth + ".managed_identity"
+	SettingAzureManagedIdentityEnabled     = SettingAzureManagedIdentity + ".enabled"
+	SettingAzureManagedIdentityAccountName = SettingAzureManagedIdentity + ".account_name"
+	SettingAzureManagedIdentityURI         = SettingAzureManagedIdentity + ".uri"
+
 	SettingMongo        = "mongo-url"
 	SettingMongoDefault = "mongodb://mongo-deployments:27017"
 
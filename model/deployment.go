@@ -16,6 +16,10 @@ package model
 
 import (
 	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/pkg/errors"
@@ -40,6 +44,90 @@ var (
 		"The deployment for group constructor should have neither list of devices" +
 			" nor all_devices flag set",
 	)
+	ErrInvalidDeploymentToGroupDefinitionNoGroup = errors.New(
+		"The deployment for group constructor requires a group",
+	)
+	ErrInvalidTagKey = errors.New(
+		"tag keys must not be empty nor contain '.' or '$' characters",
+	)
+	ErrDuplicateDeviceID    = errors.New("the devices must not be listed twice")
+	ErrInvalidAnnotationKey = errors.New(
+		"annotation keys must have the domain/key format",
+	)
+	ErrInvalidDeploymentToSubgroupsDefinitionConflict = errors.New(
+		"The deployment for multiple groups should have neither group, list of devices" +
+			" nor all_devices flag set",
+	)
+	ErrInvalidDeploymentToTagFilterDefinitionConflict = errors.New(
+		"The deployment for a device tag filter should have neither group," +
+			" list of devices nor all_devices flag set",
+	)
+	ErrInvalidDeploymentMaxDevicesConflict = errors.New(
+		"The deployment with max_devices should have a list of devices" +
+			" at least as long as max_devices",
+	)
+	ErrRollbackArtifactConflict = errors.New(
+		"The rollback artifact name must differ from the artifact name",
+	)
+	ErrScheduledAtInPast = errors.New(
+		"The deployment must be scheduled in the future",
+	)
+	ErrPhasesOverlap = errors.New(
+		"The deployment phases must not overlap",
+	)
+	ErrPhasesExceedMaxDevices = errors.New(
+		"The batch sizes of the deployment phases exceed max_devices",
+	)
+	ErrInvalidArtifactID      = errors.New("artifact ID must be a valid UUID")
+	ErrArtifactNotFound       = errors.New("artifact not found in the deployment")
+	ErrConfigurationTooLarge  = errors.New("deployment configuration is too large")
+	ErrScriptPayloadNotScript = errors.New(
+		"script payload is only allowed for script deployments",
+	)
+	ErrScriptPayloadMissing     = errors.New("script deployment requires a script payload")
+	ErrBasedOnDeploymentMissing = errors.New(
+		"configuration delta deployment requires the ID of the deployment it is based on",
+	)
+	ErrBasedOnDeploymentNotDelta = errors.New(
+		"only configuration delta deployments can be based on another deployment",
+	)
+	ErrForceInstallationConfiguration = errors.New(
+		"configuration deployments cannot force the installation",
+	)
+	ErrBootstrapTTLNotBootstrap = errors.New(
+		"only bootstrap deployments can have a bootstrap TTL",
+	)
+	ErrBootstrapTTLNegative    = errors.New("bootstrap TTL must not be negative")
+	ErrQueryIsActiveWithStatus = errors.New(
+		"query: is_active and status filters are mutually exclusive",
+	)
+	ErrQueryInvalidSortField           = errors.New("query: invalid sort field")
+	ErrQueryInvalidDeviceID            = errors.New("query: device ID must be a valid UUID")
+	ErrQueryArtifactNameWithSearchText = errors.New(
+		"query: artifact name and search text filters are mutually exclusive",
+	)
+	ErrQueryInvalidCursor   = errors.New("query: cursor must be a valid deployment ID")
+	ErrQueryCursorExclusive = errors.New(
+		"query: after and before cursors are mutually exclusive",
+	)
+	ErrQueryCursorWithSkip = errors.New(
+		"query: cursor pagination cannot be combined with skip",
+	)
+	ErrQueryCursorWithSortFields = errors.New(
+		"query: cursor pagination only supports sorting by creation date",
+	)
+)
+
+// ValidationMaxConfigurationSize is the maximum size in bytes of the
+// configuration of a configuration deployment.
+const ValidationMaxConfigurationSize int64 = 1 << 20
+
+const (
+	// DeploymentTagsMax is the maximum number of tags of a deployment.
+	DeploymentTagsMax = 32
+	// DeploymentTagMaxLength is the maximum size in bytes of the keys and
+	// values of the deployment tags.
+	DeploymentTagMaxLength = 256
 )
 
 type DeploymentStatus string
@@ -49,9 +137,26 @@ const (
 	DeploymentStatusFinished   DeploymentStatus = "finished"
 	DeploymentStatusInProgress DeploymentStatus = "inprogress"
 	DeploymentStatusPending    DeploymentStatus = "pending"
+	// DeploymentStatusCancelling is the status of aborted deployments
+	// while some devices have not yet reached a terminal status.
+	DeploymentStatusCancelling DeploymentStatus = "cancelling"
+	// DeploymentStatusScheduled is the status of the deployments scheduled
+	// in the future which no device got yet.
+	DeploymentStatusScheduled DeploymentStatus = "scheduled"
+	// DeploymentStatusSimulated is the status of dry-run deployments, which
+	// are never persisted.
+	DeploymentStatusSimulated DeploymentStatus = "simulated"
 
 	DeploymentTypeSoftware      DeploymentType = "software"
 	DeploymentTypeConfiguration DeploymentType = "configuration"
+	DeploymentTypeScript        DeploymentType = "script"
+	// DeploymentTypeConfigurationDelta is the type of the configuration
+	// deployments sending only the changes to the configuration of the
+	// deployment they are based on (BasedOnDeploymentID).
+	DeploymentTypeConfigurationDelta DeploymentType = "configuration_delta"
+	// DeploymentTypeBootstrap is the type of the deployments provisioning
+	// devices which never checked in; they may expire (BootstrapTTL).
+	DeploymentTypeBootstrap DeploymentType = "bootstrap"
 )
 
 func (stat DeploymentStatus) Validate() error {
@@ -59,22 +164,62 @@ func (stat DeploymentStatus) Validate() error {
 		DeploymentStatusFinished,
 		DeploymentStatusInProgress,
 		DeploymentStatusPending,
+		DeploymentStatusCancelling,
+		DeploymentStatusScheduled,
 	).Validate(stat)
 }
 
+// Validate checks that the type is one of the known deployment types. The
+// canonical form of a deployment type is lowercase, Normalize must be called
+// before validating user input.
 func (typ DeploymentType) Validate() error {
-	return validation.In(DeploymentTypeSoftware,
-		DeploymentTypeConfiguration).Validate(typ)
+	known := KnownDeploymentTypes()
+	types := make([]interface{}, len(known))
+	for i, t := range known {
+		types[i] = t
+	}
+	return validation.In(types...).Validate(typ)
+}
+
+// KnownDeploymentTypes returns the list of the supported deployment types.
+func KnownDeploymentTypes() []DeploymentType {
+	return []DeploymentType{
+		DeploymentTypeSoftware,
+		DeploymentTypeConfiguration,
+		DeploymentTypeScript,
+		DeploymentTypeConfigurationDelta,
+		DeploymentTypeBootstrap,
+	}
+}
+
+// Normalize returns the canonical (lowercase) form of the deployment type.
+func (typ DeploymentType) Normalize() DeploymentType {
+	return DeploymentType(strings.ToLower(string(typ)))
+}
+
+func (typ *DeploymentType) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+	*typ = DeploymentType(s).Normalize()
+	return nil
 }
 
 // DeploymentConstructor represent input data needed for creating new Deployment (they differ in
 // fields)
+//
+// The constructor is stored in the "deploymentconstructor" subdocument of
+// the deployment. The targeting fields (Devices, AllDevices, Group,
+// SubgroupNames) and the DryRun option are only used
+// when creating the deployment and are not persisted: the devices are
+// stored in Deployment.DeviceList and the groups in Deployment.Groups.
 type DeploymentConstructor struct {
 	// Deployment name, required
-	Name string `json:"name,omitempty"`
+	Name string `json:"name,omitempty" bson:"name"`
 
 	// Artifact name to be installed required, associated with image
-	ArtifactName string `json:"artifact_name,omitempty"`
+	ArtifactName string `json:"artifact_name,omitempty" bson:"artifactname"`
 
 	// List of device id's targeted for deployments, required
 	Devices []string `json:"devices,omitempty" bson:"-"`
@@ -83,20 +228,193 @@ type DeploymentConstructor struct {
 	AllDevices bool `json:"all_devices,omitempty" bson:"-"`
 
 	// ForceInstallation forces the installation of the artifact and disables the
-	// `already-installed` check
+	// `already-installed` check: the devices are sent the artifact even if
+	// they report it as installed, and the device client is expected to
+	// skip its own already-installed check
 	ForceInstallation bool `json:"force_installation,omitempty" bson:"force_installation"`
 
 	// When set the deployment will be created for all accepted devices from a given group
 	Group string `json:"-" bson:"-"`
+
+	// When set the deployment will be created for all accepted devices from the
+	// given groups
+	SubgroupNames []string `json:"subgroup_names,omitempty" bson:"-"`
+
+	// Tags are arbitrary key/value labels attached to the deployment
+	Tags map[string]string `json:"tags,omitempty" bson:"tags,omitempty"`
+
+	// DeviceTagFilter targets the devices whose inventory attributes match
+	// all the given values; the devices are resolved by the service layer.
+	//nolint:lll
+	DeviceTagFilter map[string]string `json:"device_tag_filter,omitempty" bson:"device_tag_filter,omitempty"`
+
+	// DryRun simulates the deployment without scheduling it for the devices
+	DryRun bool `json:"dry_run,omitempty" bson:"-"`
+
+	// Comment is a free-form rationale for the deployment, e.g. the
+	// reference to the change request
+	Comment string `json:"comment,omitempty" bson:"comment,omitempty"`
+
+	// MaxDevices caps the number of devices targeted by the deployment
+	// (0 disables the cap). It is shadowed by Deployment.MaxDevices, use
+	// Deployment.DeviceCap to read it from a deployment.
+	MaxDevices int `json:"max_devices,omitempty" bson:"max_devices,omitempty"`
+
+	// Phases splits the rollout into consecutive phases, e.g. a canary
+	// phase followed by the remaining devices
+	Phases []Phase `json:"phases,omitempty" bson:"phases,omitempty"`
+
+	// ScheduledAt delays the start of the deployment: the devices do not
+	// get the deployment before the given time
+	ScheduledAt *time.Time `json:"scheduled_at,omitempty" bson:"scheduled_at,omitempty"`
+
+	// RollbackArtifactName is the artifact to roll back to when the
+	// percentage of failed devices exceeds FailureThresholdPercent
+	//nolint:lll
+	RollbackArtifactName string `json:"rollback_artifact_name,omitempty" bson:"rollback_artifact_name,omitempty"`
+
+	// FailureThresholdPercent is the percentage (0-100) of failed devices
+	// above which the deployment should be rolled back
+	//nolint:lll
+	FailureThresholdPercent float64 `json:"failure_threshold_percent,omitempty" bson:"failure_threshold_percent,omitempty"`
+
+	// BasedOnDeploymentID is the configuration deployment a configuration
+	// delta deployment applies its changes to
+	//nolint:lll
+	BasedOnDeploymentID string `json:"based_on_deployment_id,omitempty" bson:"based_on_deployment_id,omitempty"`
+}
+
+// Copy returns a deep copy of the constructor.
+func (c *DeploymentConstructor) Copy() *DeploymentConstructor {
+	if c == nil {
+		return nil
+	}
+	constructor := *c
+	if c.Devices != nil {
+		constructor.Devices = make([]string, len(c.Devices))
+		copy(constructor.Devices, c.Devices)
+	}
+	if c.SubgroupNames != nil {
+		constructor.SubgroupNames = make([]string, len(c.SubgroupNames))
+		copy(constructor.SubgroupNames, c.SubgroupNames)
+	}
+	if c.Tags != nil {
+		constructor.Tags = make(map[string]string, len(c.Tags))
+		for key, value := range c.Tags {
+			constructor.Tags[key] = value
+		}
+	}
+	if c.ScheduledAt != nil {
+		scheduledAt := *c.ScheduledAt
+		constructor.ScheduledAt = &scheduledAt
+	}
+	if c.Phases != nil {
+		constructor.Phases = make([]Phase, len(c.Phases))
+		for i, phase := range c.Phases {
+			if phase.StartTs != nil {
+				startTs := *phase.StartTs
+				phase.StartTs = &startTs
+			}
+			constructor.Phases[i] = phase
+		}
+	}
+	if c.DeviceTagFilter != nil {
+		constructor.DeviceTagFilter = make(map[string]string, len(c.DeviceTagFilter))
+		for key, value := range c.DeviceTagFilter {
+			constructor.DeviceTagFilter[key] = value
+		}
+	}
+	return &constructor
+}
+
+// IsValidTagKey checks if the key can be used as a tag key. Tags are stored
+// as MongoDB document keys, hence keys must not be empty nor contain the
+// '.' and '$' characters.
+func IsValidTagKey(key string) bool {
+	return key != "" && !strings.ContainsAny(key, ".$")
+}
+
+// IsValidAnnotationKey checks if the key can be used as an annotation key:
+// annotation keys have the domain/key format, where both the domain and the
+// key are valid tag keys (see IsValidTagKey).
+func IsValidAnnotationKey(key string) bool {
+	parts := strings.Split(key, "/")
+	return len(parts) == 2 && IsValidTagKey(parts[0]) && IsValidTagKey(parts[1])
+}
+
+// urlEscapeRegexp matches a URL-encoded (percent-encoded) character.
+var urlEscapeRegexp = regexp.MustCompile("%[0-9A-Fa-f]{2}")
+
+// IsValidArtifactName checks if the name can be used as an artifact or
+// deployment name. Names are used to generate storage paths, hence they must
+// not be empty, contain path separators, parent directory references ("..")
+// null characters or URL-encoded characters, nor start or end with
+// whitespace. The rule is mirrored by the "name" pattern of
+// deploymentConstructorSchema.
+func IsValidArtifactName(name string) bool {
+	return name != "" && strings.TrimSpace(name) == name &&
+		!strings.ContainsAny(name, "/\\\x00") &&
+		!strings.Contains(name, "..") &&
+		!urlEscapeRegexp.MatchString(name)
+}
+
+type tagKeysValidator struct {
+	maxLength int
+}
+
+func validateAnnotationKeys(annotations map[string]string) error {
+	for key := range annotations {
+		if !IsValidAnnotationKey(key) {
+			return errors.Wrapf(ErrInvalidAnnotationKey,
+				"invalid annotation key %q", key)
+		}
+	}
+	return nil
+}
+
+func (t tagKeysValidator) Validate(v interface{}) error {
+	tags, _ := v.(map[string]string)
+	for key := range tags {
+		if len(key) > t.maxLength {
+			return errors.Errorf("tag key %q is too long", key)
+		} else if !IsValidTagKey(key) {
+			return errors.Wrapf(ErrInvalidTagKey, "invalid tag key %q", key)
+		}
+	}
+	return nil
 }
 
 // Validate checks structure according to valid tags
-// TODO: Add custom validator to check devices array content (such us UUID formatting)
 func (c DeploymentConstructor) Validate() error {
 	return validation.ValidateStruct(&c,
-		validation.Field(&c.Name, validation.Required, lengthIn1To4096),
-		validation.Field(&c.ArtifactName, validation.Required, lengthIn1To4096),
-		validation.Field(&c.Devices, validation.Each(validation.Required)),
+		validation.Field(&c.Name,
+			validation.Required, lengthIn1To4096, validArtifactName),
+		validation.Field(&c.ArtifactName,
+			validation.Required, lengthIn1To4096, validArtifactName),
+		validation.Field(&c.Devices,
+			validation.Each(validation.Required, is.UUID),
+			uniqueStrings{err: ErrDuplicateDeviceID},
+		),
+		validation.Field(&c.SubgroupNames,
+			validation.Each(validation.Required, validation.Length(1, 256)),
+		),
+		validation.Field(&c.Tags,
+			validation.Length(0, DeploymentTagsMax),
+			tagKeysValidator{maxLength: DeploymentTagMaxLength},
+			validation.Each(validation.Length(0, DeploymentTagMaxLength)),
+		),
+		validation.Field(&c.DeviceTagFilter,
+			tagKeysValidator{maxLength: 4096},
+			validation.Each(lengthLessThan4096),
+		),
+		validation.Field(&c.Comment, runeLengthLessThan10000),
+		validation.Field(&c.MaxDevices, validation.Min(0)),
+		validation.Field(&c.Phases),
+		validation.Field(&c.RollbackArtifactName,
+			lengthLessThan4096, validArtifactName),
+		validation.Field(&c.FailureThresholdPercent,
+			validation.Min(0.0), validation.Max(100.0)),
+		validation.Field(&c.BasedOnDeploymentID, is.UUID),
 	)
 }
 
@@ -104,8 +422,29 @@ func (c DeploymentConstructor) ValidateNew() error {
 	if err := c.Validate(); err != nil {
 		return err
 	}
+	if c.MaxDevices > 0 && len(c.Devices) > 0 && len(c.Devices) < c.MaxDevices {
+		return ErrInvalidDeploymentMaxDevicesConflict
+	}
+	if err := c.validatePhases(); err != nil {
+		return err
+	}
+	if c.ScheduledAt != nil && !c.ScheduledAt.After(time.Now()) {
+		return ErrScheduledAtInPast
+	}
+	if c.RollbackArtifactName != "" && c.RollbackArtifactName == c.ArtifactName {
+		return ErrRollbackArtifactConflict
+	}
 
-	if len(c.Group) == 0 {
+	if len(c.DeviceTagFilter) > 0 {
+		if len(c.Group) > 0 || len(c.SubgroupNames) > 0 ||
+			len(c.Devices) > 0 || c.AllDevices {
+			return ErrInvalidDeploymentToTagFilterDefinitionConflict
+		}
+	} else if len(c.SubgroupNames) > 0 {
+		if len(c.Group) > 0 || len(c.Devices) > 0 || c.AllDevices {
+			return ErrInvalidDeploymentToSubgroupsDefinitionConflict
+		}
+	} else if len(c.Group) == 0 {
 		if len(c.Devices) == 0 && !c.AllDevices {
 			return ErrInvalidDeploymentDefinitionNoDevices
 		}
@@ -120,18 +459,111 @@ func (c DeploymentConstructor) ValidateNew() error {
 	return nil
 }
 
+// DeploymentToGroupConstructor is the constructor of a deployment to the
+// devices of a group. Unlike DeploymentConstructor, it serializes the group.
+type DeploymentToGroupConstructor struct {
+	*DeploymentConstructor
+	Group string `json:"group" bson:"group"`
+}
+
+// Validate sets the group of the embedded constructor and validates it as a
+// new deployment.
+func (c *DeploymentToGroupConstructor) Validate() error {
+	if c.DeploymentConstructor == nil {
+		return ErrInvalidDeploymentDefinition
+	}
+	if c.Group == "" {
+		return ErrInvalidDeploymentToGroupDefinitionNoGroup
+	}
+	c.DeploymentConstructor.Group = c.Group
+	return c.DeploymentConstructor.ValidateNew()
+}
+
+// Phase is a stage of a phased rollout: the BatchSize devices of the phase
+// are updated once the previous phases completed and the phase started.
+type Phase struct {
+	// BatchSize is the number of devices updated in the phase
+	BatchSize int `json:"batch_size" bson:"batch_size"`
+
+	// DelayHours is the pause after the start of the phase before the
+	// next phase can start
+	DelayHours float64 `json:"delay_hours,omitempty" bson:"delay_hours,omitempty"`
+
+	// StartTs is the earliest start of the phase; if not set, the phase
+	// starts once the delay of the previous phase elapsed
+	StartTs *time.Time `json:"start_ts,omitempty" bson:"start_ts,omitempty"`
+}
+
+func (p Phase) Validate() error {
+	return validation.ValidateStruct(&p,
+		validation.Field(&p.BatchSize, validation.Required, validation.Min(1)),
+		validation.Field(&p.DelayHours, validation.Min(0.0)),
+	)
+}
+
+func (p Phase) delay() time.Duration {
+	return time.Duration(p.DelayHours * float64(time.Hour))
+}
+
+// phaseStarts returns the start time of each phase for a deployment
+// created at created.
+func phaseStarts(phases []Phase, created time.Time) []time.Time {
+	starts := make([]time.Time, len(phases))
+	next := created
+	for i, phase := range phases {
+		if phase.StartTs != nil && phase.StartTs.After(next) {
+			next = *phase.StartTs
+		}
+		starts[i] = next
+		next = next.Add(phase.delay())
+	}
+	return starts
+}
+
+// validatePhases checks that the phase windows (from StartTs to StartTs +
+// DelayHours) do not overlap and that the phases fit in MaxDevices.
+func (c DeploymentConstructor) validatePhases() error {
+	var (
+		total   int
+		prevEnd *time.Time
+	)
+	for _, phase := range c.Phases {
+		total += phase.BatchSize
+		if phase.StartTs == nil {
+			prevEnd = nil
+			continue
+		}
+		if prevEnd != nil && phase.StartTs.Before(*prevEnd) {
+			return ErrPhasesOverlap
+		}
+		end := phase.StartTs.Add(phase.delay())
+		prevEnd = &end
+	}
+	if c.MaxDevices > 0 && total > c.MaxDevices {
+		return ErrPhasesExceedMaxDevices
+	}
+	return nil
+}
+
 type DeploymentStatistics struct {
 	Status    Stats `json:"status" bson:"-"`
 	TotalSize int   `json:"total_size" bson:"total_size"`
 }
 
+// Deployment describes a deployment and its progress.
+// NOTE: The embedded DeploymentConstructor may be nil (e.g. when decoded from
+// a partial document), use EnsureConstructor before accessing its fields.
 type Deployment struct {
 	// User provided field set
-	*DeploymentConstructor
+	*DeploymentConstructor `bson:"deploymentconstructor"`
 
 	// Auto set on create, required
 	Created *time.Time `json:"created"`
 
+	// Time of the last change of the device deployment counters; set to
+	// the creation time on create
+	UpdatedAt *time.Time `json:"updated_at,omitempty" bson:"updated_at,omitempty"`
+
 	// Finished deployment time
 	Finished *time.Time `json:"finished,omitempty"`
 
@@ -166,9 +598,7 @@ type Deployment struct {
 	// list of devices
 	DeviceList []string `json:"-" bson:"device_list"`
 
-	// deployment type
-	// currently we are supporting two types of deployments:
-	// software and configuration
+	// deployment type, see KnownDeploymentTypes
 	Type DeploymentType `json:"type,omitempty" bson:"type"`
 
 	// A field containing a configuration object.
@@ -177,6 +607,56 @@ type Deployment struct {
 	// The artifact will be generated when the device will ask
 	// for an update.
 	Configuration deploymentConfiguration `json:"configuration,omitempty" bson:"configuration"`
+
+	// The script executed on the devices by a script deployment, and
+	// the way to execute it.
+	ScriptPayload []byte            `json:"-" bson:"script_payload,omitempty"`
+	Script        *DeploymentScript `json:"script,omitempty" bson:"script,omitempty"`
+
+	// Priority orders the deployments, see DeploymentList.SortByPriority
+	Priority int `json:"priority,omitempty" bson:"priority,omitempty"`
+
+	// BootstrapTTL is the lifetime of a bootstrap deployment: once older
+	// than the TTL the deployment is finished whatever the device stats.
+	BootstrapTTL time.Duration `json:"bootstrap_ttl,omitempty" bson:"bootstrap_ttl,omitempty"`
+
+	// Annotations hold metadata attached by the system components, keyed
+	// by "domain/key" (e.g. "rollback/source"). Unlike the tags, they are
+	// not part of the JSON representation, see WithAnnotations.
+	Annotations map[string]string `json:"annotations,omitempty" bson:"annotations,omitempty"`
+
+	// Periodic snapshots of the device status counters. The history is
+	// not part of the deployment document and is loaded separately.
+	StatsHistory []StatsSnapshot `json:"-" bson:"-"`
+}
+
+// DeploymentScript describes how the devices execute the script payload of
+// a script deployment.
+type DeploymentScript struct {
+	// Command interpreting the script, e.g. /bin/sh or python3
+	Command string `json:"command" bson:"command"`
+	// Additional arguments passed to the command
+	Args []string `json:"args,omitempty" bson:"args,omitempty"`
+	// Maximum execution time; zero means no timeout
+	TimeoutSeconds int `json:"timeout_seconds,omitempty" bson:"timeout_seconds,omitempty"`
+	// User running the command on the device
+	RunAsUser string `json:"run_as_user,omitempty" bson:"run_as_user,omitempty"`
+}
+
+func (s DeploymentScript) Validate() error {
+	return validation.ValidateStruct(&s,
+		validation.Field(&s.Command, validation.Required),
+		validation.Field(&s.Args, validation.Each(validation.Required)),
+		validation.Field(&s.TimeoutSeconds, validation.Min(0)),
+	)
+}
+
+// StatsSnapshot holds the device status counters of a deployment at a
+// given point in time.
+type StatsSnapshot struct {
+	Timestamp   time.Time `json:"timestamp" bson:"timestamp"`
+	Stats       Stats     `json:"stats" bson:"stats"`
+	DeviceCount int       `json:"device_count" bson:"device_count"`
 }
 
 type DeploymentArtifactsUpdate struct {
@@ -184,12 +664,48 @@ type DeploymentArtifactsUpdate struct {
 	Artifacts []string `bson:"artifacts"`
 }
 
+// IsUUIDv4 checks if s is a version 4 (random) UUID in the canonical
+// 8-4-4-4-12 hexadecimal form.
+func IsUUIDv4(s string) bool {
+	if len(s) != 36 {
+		return false
+	}
+	uid, err := uuid.Parse(s)
+	return err == nil && uid.Version() == 4
+}
+
+// NewDeploymentID generates a new random (v4) UUID to be used as a
+// deployment ID. Deployment.Validate rejects the IDs of other UUID versions,
+// as external systems rely on deployment IDs being v4 UUIDs.
+func NewDeploymentID() (string, error) {
+	uid, err := uuid.NewRandom()
+	if err != nil {
+		return "", errors.Wrap(err, "failed to generate random uuid (v4)")
+	}
+	return uid.String(), nil
+}
+
+// MustNewDeploymentID is like NewDeploymentID but panics on error.
+func MustNewDeploymentID() string {
+	id, err := NewDeploymentID()
+	if err != nil {
+		panic(err)
+	}
+	return id
+}
+
+// IDGenerator generates the IDs of the deployments created by NewDeployment;
+// tests can replace it to obtain deterministic IDs.
+var IDGenerator = NewDeploymentID
+
 // NewDeployment creates new deployment object, sets create data by default.
 func NewDeployment() (*Deployment, error) {
 	now := time.Now()
 
-	uid, _ := uuid.NewRandom()
-	id := uid.String()
+	id, err := IDGenerator()
+	if err != nil {
+		return nil, err
+	}
 
 	return &Deployment{
 		Created:               &now,
@@ -209,22 +725,330 @@ func NewDeploymentFromConstructor(constructor *DeploymentConstructor) (*Deployme
 
 	deployment.DeploymentConstructor = constructor
 	deployment.Status = DeploymentStatusPending
+	updatedAt := *deployment.Created
+	deployment.UpdatedAt = &updatedAt
+	if constructor != nil && constructor.DryRun {
+		deployment.Id, err = simulatedDeploymentID(constructor)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to create deployment from constructor")
+		}
+		deployment.Status = DeploymentStatusSimulated
+	} else if deployment.IsScheduled() {
+		deployment.Status = DeploymentStatusScheduled
+	}
 
 	deviceCount := 0
 	deployment.DeviceCount = &deviceCount
+	deployment.MaxDevices = deployment.DeviceCap()
 
 	return deployment, nil
 }
 
-// Validate checks structure validation rules
+// Clone creates a new deployment with the same parameters as d, e.g. to
+// re-run a failed deployment. The clone gets a new ID and creation time,
+// and starts with no device deployment counters nor annotations; a simulated
+// deployment is cloned as a regular one.
+func (d *Deployment) Clone() (*Deployment, error) {
+	constructor := d.ToConstructor()
+	if constructor == nil {
+		return nil, ErrInvalidDeploymentDefinition
+	}
+	constructor.DryRun = false
+	clone, err := NewDeploymentFromConstructor(constructor)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to clone deployment")
+	}
+	clone.MaxDevices = d.MaxDevices
+	clone.Type = d.Type
+	clone.Priority = d.Priority
+	clone.BootstrapTTL = d.BootstrapTTL
+	if d.Artifacts != nil {
+		clone.Artifacts = append([]string{}, d.Artifacts...)
+	}
+	if d.Groups != nil {
+		clone.Groups = append([]string{}, d.Groups...)
+	}
+	if d.DeviceList != nil {
+		clone.DeviceList = append([]string{}, d.DeviceList...)
+	}
+	if d.Configuration != nil {
+		clone.Configuration = append(deploymentConfiguration{}, d.Configuration...)
+	}
+	if d.ScriptPayload != nil {
+		clone.ScriptPayload = append([]byte{}, d.ScriptPayload...)
+	}
+	if d.Script != nil {
+		script := *d.Script
+		if d.Script.Args != nil {
+			script.Args = append([]string{}, d.Script.Args...)
+		}
+		clone.Script = &script
+	}
+	return clone, nil
+}
+
+// simulatedDeploymentNamespace is the UUID namespace of the identifiers
+// assigned to simulated deployments.
+var simulatedDeploymentNamespace = uuid.MustParse("6e6fd0f2-2ef4-4b41-9e0b-8e1c3c1a7c4d")
+
+// simulatedDeploymentID derives a deterministic (v5) UUID from the
+// constructor fields so that simulating the same deployment twice yields the
+// same identifier.
+func simulatedDeploymentID(constructor *DeploymentConstructor) (string, error) {
+	b, err := json.Marshal(DeploymentToGroupConstructor{
+		DeploymentConstructor: constructor,
+		Group:                 constructor.Group,
+	})
+	if err != nil {
+		return "", err
+	}
+	return uuid.NewSHA1(simulatedDeploymentNamespace, b).String(), nil
+}
+
+// String returns a compact single-line summary of the deployment.
+func (d *Deployment) String() string {
+	if d == nil {
+		return "<nil>"
+	}
+	name := "<nil>"
+	if d.DeploymentConstructor != nil {
+		name = d.Name
+	}
+	deviceCount := "<nil>"
+	if d.DeviceCount != nil {
+		deviceCount = strconv.Itoa(*d.DeviceCount)
+	}
+	created := "<nil>"
+	if d.Created != nil {
+		created = d.Created.Format(time.RFC3339)
+	}
+	return fmt.Sprintf(
+		"Deployment{id=%s, name=%s, status=%s, devices=%s/%d, created=%s}",
+		d.Id, name, d.Status, deviceCount, d.MaxDevices, created,
+	)
+}
+
+// IsConfiguration returns true for configuration deployments.
+func (d *Deployment) IsConfiguration() bool {
+	return d.Type == DeploymentTypeConfiguration
+}
+
+// IsConfigurationDelta returns true for configuration delta deployments.
+func (d *Deployment) IsConfigurationDelta() bool {
+	return d.Type == DeploymentTypeConfigurationDelta
+}
+
+// IsScript returns true for script deployments.
+func (d *Deployment) IsScript() bool {
+	return d.Type == DeploymentTypeScript
+}
+
+// IsBootstrap returns true for bootstrap deployments.
+func (d *Deployment) IsBootstrap() bool {
+	return d.Type == DeploymentTypeBootstrap
+}
+
+// IsSoftware returns true for software deployments. Deployments created
+// before the type was introduced have no type and are software deployments.
+func (d *Deployment) IsSoftware() bool {
+	return d.Type == DeploymentTypeSoftware || d.Type == ""
+}
+
+// IsSimulated returns true for dry-run deployments.
+func (d *Deployment) IsSimulated() bool {
+	return d.Status == DeploymentStatusSimulated
+}
+
+// EnsureConstructor initializes the embedded DeploymentConstructor to an
+// empty constructor if it is nil.
+func (d *Deployment) EnsureConstructor() {
+	if d.DeploymentConstructor == nil {
+		d.DeploymentConstructor = &DeploymentConstructor{}
+	}
+}
+
+// IsZeroValue returns true if the deployment has not been initialized.
+func (d *Deployment) IsZeroValue() bool {
+	return d.Id == ""
+}
+
+// ToConstructor returns a copy of the constructor the deployment was created
+// from. The returned constructor does not share any memory with the
+// deployment, so it can be modified freely (e.g. for re-running the
+// deployment).
+func (d *Deployment) ToConstructor() *DeploymentConstructor {
+	return d.DeploymentConstructor.Copy()
+}
+
+// ToConstructorWithoutDevices returns a copy of the constructor with the
+// device targeting (Devices, AllDevices and DeviceTagFilter) cleared, so
+// that the caller can re-target the deployment at a different set of
+// devices.
+func (d *Deployment) ToConstructorWithoutDevices() *DeploymentConstructor {
+	constructor := d.ToConstructor()
+	if constructor != nil {
+		constructor.Devices = nil
+		constructor.AllDevices = false
+		constructor.DeviceTagFilter = nil
+	}
+	return constructor
+}
+
+// IsMultiGroupDeployment returns true if the deployment targets more than
+// one group.
+func (d *Deployment) IsMultiGroupDeployment() bool {
+	return d.DeploymentConstructor != nil && len(d.SubgroupNames) > 1
+}
+
+// IsTagFiltered returns true if the deployment targets the devices matching
+// a device tag filter.
+func (d *Deployment) IsTagFiltered() bool {
+	return d.DeploymentConstructor != nil && len(d.DeviceTagFilter) > 0
+}
+
+// TargetGroupNames returns the names of the groups targeted by the
+// deployment.
+func (d *Deployment) TargetGroupNames() []string {
+	if d.DeploymentConstructor == nil {
+		return nil
+	} else if len(d.SubgroupNames) > 0 {
+		return d.SubgroupNames
+	} else if d.Group != "" {
+		return []string{d.Group}
+	}
+	return nil
+}
+
+// AddStatsSnapshot appends a copy of the current stats to the history.
+func (d *Deployment) AddStatsSnapshot(now time.Time) {
+	snapshot := StatsSnapshot{
+		Timestamp: now,
+		Stats:     d.Stats.Copy(),
+	}
+	if d.DeviceCount != nil {
+		snapshot.DeviceCount = *d.DeviceCount
+	}
+	d.StatsHistory = append(d.StatsHistory, snapshot)
+}
+
+// StatsAt returns the stats from the snapshot closest in time to t; the
+// second return value is false if the history is empty.
+func (d *Deployment) StatsAt(t time.Time) (Stats, bool) {
+	var (
+		closest *StatsSnapshot
+		minDist time.Duration
+	)
+	for i := range d.StatsHistory {
+		dist := d.StatsHistory[i].Timestamp.Sub(t)
+		if dist < 0 {
+			dist = -dist
+		}
+		if closest == nil || dist < minDist {
+			closest = &d.StatsHistory[i]
+			minDist = dist
+		}
+	}
+	if closest == nil {
+		return Stats{}, false
+	}
+	return closest.Stats, true
+}
+
+// AllArtifactNames returns the names of the artifacts targeted by the
+// deployment.
+func (d *Deployment) AllArtifactNames() []string {
+	if d.DeploymentConstructor == nil || d.ArtifactName == "" {
+		return nil
+	}
+	return []string{d.ArtifactName}
+}
+
+// HasArtifact returns true if the artifact is part of the deployment.
+func (d *Deployment) HasArtifact(artifactID string) bool {
+	for _, id := range d.Artifacts {
+		if id == artifactID {
+			return true
+		}
+	}
+	return false
+}
+
+// AddArtifact adds the artifact to the deployment, unless already present.
+func (d *Deployment) AddArtifact(artifactID string) error {
+	if err := is.UUID.Validate(artifactID); err != nil || artifactID == "" {
+		return ErrInvalidArtifactID
+	}
+	if !d.HasArtifact(artifactID) {
+		d.Artifacts = append(d.Artifacts, artifactID)
+	}
+	return nil
+}
+
+// RemoveArtifact removes the artifact from the deployment.
+func (d *Deployment) RemoveArtifact(artifactID string) error {
+	for i, id := range d.Artifacts {
+		if id == artifactID {
+			d.Artifacts = append(d.Artifacts[:i], d.Artifacts[i+1:]...)
+			return nil
+		}
+	}
+	return ErrArtifactNotFound
+}
+
+// Validate checks structure validation rules; a nil DeploymentConstructor
+// is reported as a validation error.
 func (d Deployment) Validate() error {
-	return validation.ValidateStruct(&d,
+	err := validation.ValidateStruct(&d,
 		validation.Field(&d.DeploymentConstructor, validation.NotNil),
 		validation.Field(&d.Created, validation.Required),
-		validation.Field(&d.Id, validation.Required, is.UUID),
+		validation.Field(&d.Id, validation.Required,
+			// simulated deployments have deterministic (v5) IDs
+			validation.When(d.IsSimulated(), is.UUID).Else(uuidV4),
+		),
 		validation.Field(&d.Artifacts, validation.Each(validation.Required)),
 		validation.Field(&d.DeviceList, validation.Each(validation.Required)),
+		validation.Field(&d.Script),
 	)
+	if err != nil {
+		return err
+	}
+	if err := validateAnnotationKeys(d.Annotations); err != nil {
+		return err
+	}
+	if d.IsScript() && len(d.ScriptPayload) == 0 {
+		return ErrScriptPayloadMissing
+	} else if !d.IsScript() && len(d.ScriptPayload) > 0 {
+		return ErrScriptPayloadNotScript
+	}
+	if (d.IsConfiguration() || d.IsConfigurationDelta()) &&
+		d.IsConfigurationTooLarge(ValidationMaxConfigurationSize) {
+		return ErrConfigurationTooLarge
+	}
+	if (d.IsConfiguration() || d.IsConfigurationDelta()) && d.ForceInstallation {
+		return ErrForceInstallationConfiguration
+	}
+	if d.IsConfigurationDelta() && d.BasedOnDeploymentID == "" {
+		return ErrBasedOnDeploymentMissing
+	} else if !d.IsConfigurationDelta() && d.BasedOnDeploymentID != "" {
+		return ErrBasedOnDeploymentNotDelta
+	}
+	if d.BootstrapTTL < 0 {
+		return ErrBootstrapTTLNegative
+	} else if d.BootstrapTTL > 0 && !d.IsBootstrap() {
+		return ErrBootstrapTTLNotBootstrap
+	}
+	return nil
+}
+
+// ConfigurationSize returns the size in bytes of the configuration.
+func (d *Deployment) ConfigurationSize() int64 {
+	return int64(len(d.Configuration))
+}
+
+// IsConfigurationTooLarge returns true if the configuration is larger than
+// maxBytes.
+func (d *Deployment) IsConfigurationTooLarge(maxBytes int64) bool {
+	return d.ConfigurationSize() > maxBytes
 }
 
 func (r *Deployment) MarshalBSON() ([]byte, error) {
@@ -233,41 +1057,88 @@ func (r *Deployment) MarshalBSON() ([]byte, error) {
 	return bson.Marshal((*Alias)(r))
 }
 
+func (r *Deployment) UnmarshalBSON(b []byte) error {
+	type Alias Deployment
+	if err := bson.Unmarshal(b, (*Alias)(r)); err != nil {
+		return err
+	}
+	r.EnsureConstructor()
+	return nil
+}
+
 // To be able to hide devices field, from API output provide custom marshaler
 func (d *Deployment) MarshalJSON() ([]byte, error) {
+	return d.MarshalWithOptions()
+}
+
+// MarshalOption configures the JSON representation of a deployment, see
+// MarshalWithOptions.
+type MarshalOption func(*marshalOptions)
+
+type marshalOptions struct {
+	annotations bool
+}
+
+// WithAnnotations includes the deployment annotations in the JSON
+// representation; they are left out by default.
+func WithAnnotations() MarshalOption {
+	return func(opts *marshalOptions) {
+		opts.annotations = true
+	}
+}
+
+// MarshalWithOptions returns the JSON representation of the deployment
+// configured by the given options.
+func (d *Deployment) MarshalWithOptions(options ...MarshalOption) ([]byte, error) {
+	var opts marshalOptions
+	for _, option := range options {
+		option(&opts)
+	}
+	d.EnsureConstructor()
 
 	//Prevents from inheriting original MarshalJSON (if would, infinite loop)
 	type Alias Deployment
 
 	slim := struct {
 		*Alias
-		Devices []string       `json:"devices,omitempty"`
-		Type    DeploymentType `json:"type,omitempty"`
+		Devices       []string          `json:"devices,omitempty"`
+		Type          DeploymentType    `json:"type,omitempty"`
+		ScriptPayload string            `json:"script_payload,omitempty"`
+		Status        DeploymentStatus  `json:"status"`
+		Annotations   map[string]string `json:"annotations,omitempty"`
 	}{
 		Alias:   (*Alias)(d),
 		Devices: nil,
 		Type:    d.Type,
+		// the stored status may lag behind the stats
+		Status: d.GetStatus(),
 	}
 	if slim.Type == "" {
 		slim.Type = DeploymentTypeSoftware
 	}
+	if d.IsScript() {
+		slim.ScriptPayload = string(d.ScriptPayload)
+	}
+	if opts.annotations {
+		slim.Annotations = d.Annotations
+	}
 	slim.Statistics.Status = slim.Stats
 
 	return json.Marshal(&slim)
 }
 
 func (d *Deployment) IsNotPending() bool {
-	if d.Stats[DeviceDeploymentStatusDownloadingStr] > 0 ||
-		d.Stats[DeviceDeploymentStatusInstallingStr] > 0 ||
-		d.Stats[DeviceDeploymentStatusRebootingStr] > 0 ||
-		d.Stats[DeviceDeploymentStatusSuccessStr] > 0 ||
-		d.Stats[DeviceDeploymentStatusAlreadyInstStr] > 0 ||
-		d.Stats[DeviceDeploymentStatusFailureStr] > 0 ||
-		d.Stats[DeviceDeploymentStatusAbortedStr] > 0 ||
-		d.Stats[DeviceDeploymentStatusNoArtifactStr] > 0 ||
-		d.Stats[DeviceDeploymentStatusPauseBeforeInstallStr] > 0 ||
-		d.Stats[DeviceDeploymentStatusPauseBeforeCommitStr] > 0 ||
-		d.Stats[DeviceDeploymentStatusPauseBeforeRebootStr] > 0 {
+	if d.Stats.Get(DeviceDeploymentStatusDownloading) > 0 ||
+		d.Stats.Get(DeviceDeploymentStatusInstalling) > 0 ||
+		d.Stats.Get(DeviceDeploymentStatusRebooting) > 0 ||
+		d.Stats.Get(DeviceDeploymentStatusSuccess) > 0 ||
+		d.Stats.Get(DeviceDeploymentStatusAlreadyInst) > 0 ||
+		d.Stats.Get(DeviceDeploymentStatusFailure) > 0 ||
+		d.Stats.Get(DeviceDeploymentStatusAborted) > 0 ||
+		d.Stats.Get(DeviceDeploymentStatusNoArtifact) > 0 ||
+		d.Stats.Get(DeviceDeploymentStatusPauseBeforeInstall) > 0 ||
+		d.Stats.Get(DeviceDeploymentStatusPauseBeforeCommit) > 0 ||
+		d.Stats.Get(DeviceDeploymentStatusPauseBeforeReboot) > 0 {
 
 		return true
 	}
@@ -275,23 +1146,228 @@ func (d *Deployment) IsNotPending() bool {
 	return false
 }
 
+// finishedDeviceCount returns the number of devices which reached a
+// terminal state.
+func (d *Deployment) finishedDeviceCount() int {
+	return d.Stats.finishedCount()
+}
+
+// DeviceCap returns the cap on the number of devices set by the
+// constructor, or 0 if the number of devices is not capped.
+func (d *Deployment) DeviceCap() int {
+	if d.DeploymentConstructor == nil {
+		return 0
+	}
+	return d.DeploymentConstructor.MaxDevices
+}
+
+// maxDevices returns the number of devices which must reach a terminal
+// state for the deployment to finish: the number of targeted devices
+// limited by the device cap.
+func (d *Deployment) maxDevices() int {
+	if limit := d.DeviceCap(); limit > 0 &&
+		(d.MaxDevices <= 0 || limit < d.MaxDevices) {
+		return limit
+	}
+	return d.MaxDevices
+}
+
+// IsFinished returns true if the deployment finished, either explicitly or
+// because all the devices reached a terminal state. A scheduled deployment
+// is not finished until it is explicitly finished, while a bootstrap
+// deployment is finished once it outlived its BootstrapTTL.
 func (d *Deployment) IsFinished() bool {
-	if d.Finished != nil ||
-		d.MaxDevices > 0 && ((d.Stats[DeviceDeploymentStatusAlreadyInstStr]+
-			d.Stats[DeviceDeploymentStatusSuccessStr]+
-			d.Stats[DeviceDeploymentStatusFailureStr]+
-			d.Stats[DeviceDeploymentStatusNoArtifactStr]+
-			d.Stats[DeviceDeploymentStatusDecommissionedStr]+
-			d.Stats[DeviceDeploymentStatusAbortedStr]) >= d.MaxDevices) {
+	if d.Finished != nil {
+		return true
+	} else if d.IsScheduled() {
+		return false
+	} else if d.isBootstrapExpired(time.Now()) {
 		return true
 	}
+	maxDevices := d.maxDevices()
+	return maxDevices > 0 && d.finishedDeviceCount() >= maxDevices
+}
 
-	return false
+func (d *Deployment) isBootstrapExpired(now time.Time) bool {
+	return d.IsBootstrap() && d.BootstrapTTL > 0 &&
+		d.Created != nil && now.Sub(*d.Created) > d.BootstrapTTL
+}
+
+// IsScheduled returns true if the deployment is scheduled in the future
+// and no device got it yet.
+func (d *Deployment) IsScheduled() bool {
+	return d.isScheduled(time.Now())
+}
+
+func (d *Deployment) isScheduled(now time.Time) bool {
+	return d.DeploymentConstructor != nil &&
+		d.ScheduledAt != nil && d.ScheduledAt.After(now) &&
+		d.Stats.Total() == 0
+}
+
+// ShouldRollback returns true if a rollback artifact is set and the
+// percentage of failed devices exceeds the failure threshold.
+func (d *Deployment) ShouldRollback() bool {
+	if d.DeploymentConstructor == nil || d.RollbackArtifactName == "" {
+		return false
+	}
+	maxDevices := d.maxDevices()
+	if maxDevices <= 0 {
+		return false
+	}
+	failed := d.Stats.Get(DeviceDeploymentStatusFailure)
+	return float64(failed)*100/float64(maxDevices) > d.FailureThresholdPercent
+}
+
+// Progress returns the ratio of the devices in a terminal state to the
+// number of devices of the deployment, between 0 and 1. It returns 0 if
+// the deployment has no devices.
+func (d *Deployment) Progress() float64 {
+	return d.deviceRatio(d.finishedDeviceCount())
+}
+
+// DeviceSuccessRate returns the ratio of the devices updated successfully
+// to the number of devices of the deployment, between 0 and 1. It returns 0
+// if the deployment has no devices.
+func (d *Deployment) DeviceSuccessRate() float64 {
+	return d.deviceRatio(d.Stats.Get(DeviceDeploymentStatusSuccess))
+}
+
+// DeviceFailureRate returns the ratio of the devices that failed to update
+// to the number of devices of the deployment, between 0 and 1. It returns 0
+// if the deployment has no devices.
+func (d *Deployment) DeviceFailureRate() float64 {
+	return d.deviceRatio(d.Stats.Get(DeviceDeploymentStatusFailure))
+}
+
+// deviceRatio returns count divided by the number of devices of the
+// deployment, clamped to [0, 1].
+func (d *Deployment) deviceRatio(count int) float64 {
+	maxDevices := d.maxDevices()
+	if maxDevices <= 0 {
+		return 0
+	}
+	ratio := float64(count) / float64(maxDevices)
+	if ratio > 1 {
+		return 1
+	} else if ratio < 0 {
+		return 0
+	}
+	return ratio
+}
+
+// StatsJSON returns the device deployment counters keyed by the status
+// names (e.g. "downloading"), as exposed in the API responses.
+func (d *Deployment) StatsJSON() map[string]int {
+	return d.Stats.toMap()
+}
+
+// UnmarshalStatsJSON replaces the device deployment counters with the ones
+// keyed by the status names in m; the counters are left untouched if m
+// holds an unknown status or a negative count.
+func (d *Deployment) UnmarshalStatsJSON(m map[string]int) error {
+	stats, err := statsFromMap(m)
+	if err != nil {
+		return err
+	}
+	d.Stats = stats
+	return nil
+}
+
+// ProgressPercent returns Progress as a percentage rounded down, so that
+// 100 means that all the devices are in a terminal state.
+func (d *Deployment) ProgressPercent() int {
+	maxDevices := d.maxDevices()
+	if maxDevices <= 0 {
+		return 0
+	}
+	finished := d.finishedDeviceCount()
+	if finished > maxDevices {
+		finished = maxDevices
+	}
+	return finished * 100 / maxDevices
+}
+
+// EstimatedCompletionTime extrapolates the time the deployment will finish
+// from the average time it took for the devices to finish so far.
+// It returns nil if the deployment is finished, if no time elapsed since it
+// was created, or if less than 5% of the devices have finished, as early
+// estimates are misleading.
+func (d *Deployment) EstimatedCompletionTime() *time.Time {
+	return d.estimatedCompletionTime(time.Now())
+}
+
+func (d *Deployment) estimatedCompletionTime(now time.Time) *time.Time {
+	maxDevices := d.maxDevices()
+	if d.IsFinished() || d.Created == nil || maxDevices <= 0 {
+		return nil
+	}
+	finished := d.finishedDeviceCount()
+	if finished == 0 || finished*20 < maxDevices {
+		return nil
+	}
+	elapsed := now.Sub(*d.Created)
+	if elapsed <= 0 {
+		return nil
+	}
+	avgTime := elapsed / time.Duration(finished)
+	eta := now.Add(time.Duration(maxDevices-finished) * avgTime)
+	return &eta
+}
+
+// activeDeviceCount returns the number of devices which did not reach a
+// terminal state yet.
+func (d *Deployment) activeDeviceCount() int {
+	var count int
+	for _, status := range ActiveDeploymentStatuses() {
+		count += d.Stats.Get(status)
+	}
+	return count
 }
 
+// CurrentPhase returns the index of the phase the deployment is in: a phase
+// is reached once it started and the devices of all the previous phases
+// reached a terminal state. It returns 0 if the deployment has no phases.
+func (d *Deployment) CurrentPhase() int {
+	return d.currentPhase(time.Now())
+}
+
+func (d *Deployment) currentPhase(now time.Time) int {
+	if d.DeploymentConstructor == nil || len(d.Phases) == 0 {
+		return 0
+	}
+	var created time.Time
+	if d.Created != nil {
+		created = *d.Created
+	}
+	var (
+		current  int
+		finished = d.finishedDeviceCount()
+		done     int
+	)
+	for i, start := range phaseStarts(d.Phases, created) {
+		if i > 0 && (start.After(now) || finished < done) {
+			break
+		}
+		current = i
+		done += d.Phases[i].BatchSize
+	}
+	return current
+}
+
+// GetStatus computes the status of the deployment from the device
+// statistics. An aborted deployment is cancelling while some devices are
+// still active.
 func (d *Deployment) GetStatus() DeploymentStatus {
-	if d.IsFinished() {
+	if d.IsSimulated() {
+		return DeploymentStatusSimulated
+	} else if d.IsFinished() {
 		return DeploymentStatusFinished
+	} else if d.IsScheduled() {
+		return DeploymentStatusScheduled
+	} else if d.Stats.Get(DeviceDeploymentStatusAborted) > 0 &&
+		d.activeDeviceCount() > 0 {
+		return DeploymentStatusCancelling
 	} else if d.IsNotPending() {
 		return DeploymentStatusInProgress
 	} else {
@@ -299,6 +1375,55 @@ func (d *Deployment) GetStatus() DeploymentStatus {
 	}
 }
 
+// ErrInvalidStatusTransition is returned by Deployment.SetStatus when the
+// deployment cannot move from the current to the requested status.
+type ErrInvalidStatusTransition struct {
+	From DeploymentStatus
+	To   DeploymentStatus
+}
+
+func (err ErrInvalidStatusTransition) Error() string {
+	return fmt.Sprintf("invalid deployment status transition from %q to %q",
+		err.From, err.To)
+}
+
+// statusTransitions lists the statuses each status can transition to.
+// Aborting a deployment moves it to cancelling until all the devices reached
+// a terminal status.
+var statusTransitions = map[DeploymentStatus][]DeploymentStatus{
+	DeploymentStatusScheduled: {
+		DeploymentStatusPending,
+		DeploymentStatusInProgress,
+		DeploymentStatusCancelling,
+	},
+	DeploymentStatusPending: {
+		DeploymentStatusInProgress,
+		DeploymentStatusCancelling,
+	},
+	DeploymentStatusInProgress: {
+		DeploymentStatusFinished,
+		DeploymentStatusCancelling,
+	},
+	DeploymentStatusCancelling: {DeploymentStatusFinished},
+}
+
+// SetStatus sets the status of the deployment after checking that the
+// transition from the current status is allowed. A cancelling deployment
+// can only finish once IsFinished returns true.
+func (d *Deployment) SetStatus(status DeploymentStatus) error {
+	if d.Status == DeploymentStatusCancelling &&
+		status == DeploymentStatusFinished && !d.IsFinished() {
+		return ErrInvalidStatusTransition{From: d.Status, To: status}
+	}
+	for _, next := range statusTransitions[d.Status] {
+		if next == status {
+			d.Status = status
+			return nil
+		}
+	}
+	return ErrInvalidStatusTransition{From: d.Status, To: status}
+}
+
 type StatusQuery int
 
 const (
@@ -307,6 +1432,8 @@ const (
 	StatusQueryInProgress
 	StatusQueryFinished
 	StatusQueryAborted
+	StatusQueryCancelling
+	StatusQueryScheduled
 
 	SortDirectionAscending  = "asc"
 	SortDirectionDescending = "desc"
@@ -319,25 +1446,142 @@ type Query struct {
 
 	// match deployments by text by looking at deployment name and artifact name
 	SearchText string
+	// ArtifactName matches the deployments of exactly the given artifact;
+	// mutually exclusive with SearchText
+	ArtifactName string
 
 	// deployment type
 	Type DeploymentType
 
 	// deployment status
 	Status StatusQuery
-	Limit  int
-	Skip   int
+	// IsActive, if set, matches the deployments which are (true) or are
+	// not (false) finished; mutually exclusive with Status
+	IsActive *bool
+	// HasComment, if set, matches the deployments with (true) or
+	// without (false) a comment
+	HasComment *bool
+	// Tags matches the deployments having all the given tags
+	Tags map[string]string
+	// DeviceID matches the deployments the given device is part of
+	DeviceID string
+
+	Limit int
+	Skip  int
+	// AfterID and BeforeID paginate with a cursor instead of Skip: they
+	// match the deployments sorted after (respectively before) the
+	// deployment with the given ID. They are mutually exclusive with each
+	// other, with Skip and with SortFields.
+	AfterID  string
+	BeforeID string
 	// only return deployments between timestamp range
 	CreatedAfter  *time.Time
 	CreatedBefore *time.Time
+	// only return deployments last updated in the timestamp range
+	UpdatedAfter  *time.Time
+	UpdatedBefore *time.Time
 
-	// sort values by creation date
+	// sort values by creation date; ignored if SortFields is set
 	Sort string
+	// SortFields sorts the values by each of the fields in turn
+	SortFields []QuerySortField
 
 	// disable the counting
 	DisableCount bool
 }
 
+// Validate checks that the query does not combine exclusive filters
+// and that the filter values are well-formed.
+func (q Query) Validate() error {
+	if q.IsActive != nil && q.Status != StatusQueryAny {
+		return ErrQueryIsActiveWithStatus
+	}
+	if q.ArtifactName != "" && q.SearchText != "" {
+		return ErrQueryArtifactNameWithSearchText
+	}
+	if err := validation.Validate(q.DeviceID, is.UUID); err != nil {
+		return ErrQueryInvalidDeviceID
+	}
+	if err := q.validateCursor(); err != nil {
+		return err
+	}
+	for _, field := range q.SortFields {
+		if err := field.Validate(); err != nil {
+			return err
+		}
+	}
+	for key := range q.Tags {
+		if !IsValidTagKey(key) {
+			return errors.Wrapf(ErrInvalidTagKey, "invalid tag key %q", key)
+		}
+	}
+	return nil
+}
+
+func (q Query) validateCursor() error {
+	if !q.IsCursor() {
+		return nil
+	} else if q.AfterID != "" && q.BeforeID != "" {
+		return ErrQueryCursorExclusive
+	} else if q.Skip > 0 {
+		return ErrQueryCursorWithSkip
+	} else if len(q.SortFields) > 0 {
+		return ErrQueryCursorWithSortFields
+	}
+	if err := validation.Validate(q.AfterID+q.BeforeID, is.UUID); err != nil {
+		return ErrQueryInvalidCursor
+	}
+	return nil
+}
+
+// IsCursor returns true if the query paginates with a cursor
+// (AfterID or BeforeID) rather than with Skip.
+func (q Query) IsCursor() bool {
+	return q.Pagination().IsCursor()
+}
+
+// QuerySortField is a sort criterion of a deployments Query.
+type QuerySortField struct {
+	Field     SortField
+	Direction SortOrder
+}
+
+// Validate checks that the field can be used to sort the deployments
+// and that the direction is either ascending or descending.
+func (f QuerySortField) Validate() error {
+	switch f.Field {
+	case SortFieldName, SortFieldArtifactName, SortFieldStatus,
+		SortFieldCreated, SortFieldFinished, SortFieldDeviceCount:
+	default:
+		return errors.Wrapf(ErrQueryInvalidSortField, "unknown field %q", f.Field)
+	}
+	switch f.Direction {
+	case SortOrderAscending, SortOrderDescending:
+	default:
+		return errors.Wrapf(ErrQueryInvalidSortField,
+			"unknown direction %q for field %q", f.Direction, f.Field)
+	}
+	return nil
+}
+
+// GetSortFields returns the sort criteria of the query, see
+// Pagination.GetSortFields.
+func (q Query) GetSortFields() []QuerySortField {
+	return q.Pagination().GetSortFields()
+}
+
+// IsActiveStatuses returns the deployment statuses matched by the IsActive
+// filter, see DeploymentFilter.IsActiveStatuses.
+func (q Query) IsActiveStatuses() []DeploymentStatus {
+	return q.Filter().IsActiveStatuses()
+}
+
+// DeploymentCountByType holds the number of deployments for each type.
+type DeploymentCountByType map[DeploymentType]int
+
+// DeploymentCountByStatus holds the number of deployments for each status.
+type DeploymentCountByStatus map[DeploymentStatus]int
+
 type DeploymentIDs struct {
 	IDs []string `json:"deployment_ids"`
 }
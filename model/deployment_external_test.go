@@ -15,15 +15,63 @@
 package model
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
 	"math/rand"
+	"strconv"
+	"strings"
 	"testing"
 	"time"
 
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+	"github.com/go-ozzo/ozzo-validation/v4/is"
 	"github.com/stretchr/testify/assert"
 	"go.mongodb.org/mongo-driver/bson"
+
+	. "github.com/mendersoftware/deployments/utils/pointers"
 )
 
+func TestDeploymentTypeNormalize(t *testing.T) {
+
+	t.Parallel()
+
+	testCases := map[string]struct {
+		Input    string
+		Expected DeploymentType
+	}{
+		"all caps": {
+			Input:    "CONFIGURATION",
+			Expected: DeploymentTypeConfiguration,
+		},
+		"mixed case": {
+			Input:    "Software",
+			Expected: DeploymentTypeSoftware,
+		},
+		"lowercase": {
+			Input:    "software",
+			Expected: DeploymentTypeSoftware,
+		},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			typ := DeploymentType(tc.Input)
+			if typ != tc.Expected {
+				assert.Error(t, typ.Validate())
+			}
+			assert.Equal(t, tc.Expected, typ.Normalize())
+			assert.NoError(t, typ.Normalize().Validate())
+
+			var unmarshaled DeploymentType
+			err := json.Unmarshal([]byte(`"`+tc.Input+`"`), &unmarshaled)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.Expected, unmarshaled)
+		})
+	}
+}
+
 func TestDeploymentConstructorValidate(t *testing.T) {
 
 	t.Parallel()
@@ -76,7 +124,7 @@ func TestDeploymentConstructorValidate(t *testing.T) {
 			InputName:         "f826484e-1157-4109-af21-304e6d711560",
 			InputArtifactName: "f826484e-1157-4109-af21-304e6d711560",
 			InputDevices:      []string{"lala"},
-			IsValid:           true,
+			IsValid:           false,
 		},
 		{
 			InputName:         "f826484e-1157-4109-af21-304e6d711560",
@@ -101,21 +149,21 @@ func TestDeploymentConstructorValidate(t *testing.T) {
 		{
 			InputName:         "f826484e-1157-4109-af21-304e6d711560",
 			InputArtifactName: "f826484e-1157-4109-af21-304e6d711560",
-			InputDevices:      []string{"lala"},
+			InputDevices:      []string{"f826484e-1157-4109-af21-304e6d711560"},
 			InputAllDevices:   true,
 			IsValid:           false,
 		},
 		{
 			InputName:         "f826484e-1157-4109-af21-304e6d711560",
 			InputArtifactName: "f826484e-1157-4109-af21-304e6d711560",
-			InputDevices:      []string{"lala"},
+			InputDevices:      []string{"f826484e-1157-4109-af21-304e6d711560"},
 			InputGroup:        "foo",
 			IsValid:           false,
 		},
 		{
 			InputName:         "f826484e-1157-4109-af21-304e6d711560",
 			InputArtifactName: "f826484e-1157-4109-af21-304e6d711560",
-			InputDevices:      []string{"lala"},
+			InputDevices:      []string{"f826484e-1157-4109-af21-304e6d711560"},
 			InputAllDevices:   true,
 			IsValid:           false,
 		},
@@ -141,347 +189,2989 @@ func TestDeploymentConstructorValidate(t *testing.T) {
 
 }
 
-func TestNewDeploymentFromConstructor(t *testing.T) {
+func TestDeploymentConstructorValidateDeviceIDs(t *testing.T) {
 
 	t.Parallel()
 
-	dep, err := NewDeploymentFromConstructor(nil)
-	assert.NoError(t, err)
-	assert.NotNil(t, dep)
-
-	con := &DeploymentConstructor{}
-
-	dep, err = NewDeploymentFromConstructor(con)
-	assert.NoError(t, err)
-
-	assert.NotNil(t, dep)
-	assert.Equal(t, con, dep.DeploymentConstructor)
-}
-
-func TestDeploymentValidate(t *testing.T) {
+	testCases := map[string]struct {
+		Devices []string
 
-	t.Parallel()
-
-	testCases := []struct {
-		InputName         string
-		InputArtifactName string
-		InputDevices      []string
-		IsValid           bool
+		InvalidIndexes []string
 	}{
-		{
-			InputName:         "",
-			InputArtifactName: "",
-			InputDevices:      nil,
-			IsValid:           false,
+		"ok": {
+			Devices: []string{
+				"f826484e-1157-4109-af21-304e6d711560",
+				"b532b01a-9313-404f-8d19-e7fcbe5cc347",
+			},
 		},
-		{
-			InputName:         "f826484e-1157-4109-af21-304e6d711560",
-			InputArtifactName: "f826484e-1157-4109-af21-304e6d711560",
-			InputDevices:      []string{"f826484e-1157-4109-af21-304e6d711560"},
-			IsValid:           true,
+		"error, empty string": {
+			Devices: []string{
+				"f826484e-1157-4109-af21-304e6d711560",
+				"",
+			},
+			InvalidIndexes: []string{"1"},
+		},
+		"error, arbitrary strings": {
+			Devices: []string{
+				"lala",
+				"f826484e-1157-4109-af21-304e6d711560",
+				"F826484E-1157-4109-AF21-304E6D711560",
+			},
+			InvalidIndexes: []string{"0", "2"},
+		},
+		"error, mixed": {
+			Devices: []string{
+				"",
+				"f826484e-1157-4109-af21-304e6d711560",
+				"device 123",
+			},
+			InvalidIndexes: []string{"0", "2"},
 		},
 	}
 
-	for i, test := range testCases {
-		t.Run(fmt.Sprintf("test #%d", i), func(t *testing.T) {
-			pub := &DeploymentConstructor{}
-			pub.Name = test.InputName
-			pub.ArtifactName = test.InputArtifactName
-			pub.Devices = test.InputDevices
-
-			dep, err := NewDeploymentFromConstructor(pub)
-			assert.NoError(t, err)
-
-			err = dep.Validate()
-
-			if !test.IsValid {
-				assert.Error(t, err)
-			} else {
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			constructor := DeploymentConstructor{
+				Name:         "foo",
+				ArtifactName: "bar",
+				Devices:      tc.Devices,
+			}
+			err := constructor.Validate()
+			if len(tc.InvalidIndexes) == 0 {
 				assert.NoError(t, err)
+				return
+			}
+			var errs validation.Errors
+			if !assert.ErrorAs(t, err, &errs) {
+				return
 			}
+			var deviceErrs validation.Errors
+			if !assert.ErrorAs(t, errs["devices"], &deviceErrs) {
+				return
+			}
+			indexes := make([]string, 0, len(deviceErrs))
+			for index := range deviceErrs {
+				indexes = append(indexes, index)
+			}
+			assert.ElementsMatch(t, tc.InvalidIndexes, indexes)
 		})
 	}
-
 }
 
-func TestDeploymentMarshalJSON(t *testing.T) {
-
+func TestDeploymentConstructorValidateDuplicateDevices(t *testing.T) {
 	t.Parallel()
 
-	dep, err := NewDeployment()
-	assert.NoError(t, err)
-	dep.Name = "Region: NYC"
-	dep.ArtifactName = "App 123"
-	dep.Devices = []string{"Device 123"}
-	dep.Id = "14ddec54-30be-49bf-aa6b-97ce271d71f5"
-	deviceCount := 1337
-	dep.DeviceCount = &deviceCount
-	dep.Status = DeploymentStatusInProgress
-	dep.Statistics = DeploymentStatistics{
-		TotalSize: 10,
-	}
-	dep.Stats = Stats{"foo": 1}
-
-	j, err := dep.MarshalJSON()
-	assert.NoError(t, err)
-
-	// date format may be slightly different on different platforms
-	expectedJSON := `
-	{
-		"name":"Region: NYC",
-		"artifact_name":"App 123",
-        "created":"` + dep.Created.Format(time.RFC3339Nano) + `",
-		"id":"14ddec54-30be-49bf-aa6b-97ce271d71f5",
-		"statistics":{"status":{"foo":1},"total_size":10},
-		"status":"inprogress",
-		"device_count":1337,
-		"type":"software"
-	}`
-
-	assert.JSONEq(t, expectedJSON, string(j))
-}
-
-func TestDeploymentMarshalBSON(t *testing.T) {
-	dep, err := NewDeployment()
-	assert.NoError(t, err)
-	dep.Name = "Region: NYC"
-	dep.ArtifactName = "App 123"
-	dep.Devices = []string{"Device 123"}
-	dep.Id = "14ddec54-30be-49bf-aa6b-97ce271d71f5"
-	deviceCount := 1337
-	dep.DeviceCount = &deviceCount
-	dep.Status = DeploymentStatusInProgress
-
-	j, err := dep.MarshalBSON()
-	assert.NoError(t, err)
-
-	deployment := &Deployment{}
-	bson.Unmarshal(j, deployment)
-
-	assert.True(t, deployment.Active)
-}
-
-func TestDeploymentIs(t *testing.T) {
-	d, err := NewDeployment()
-	assert.NoError(t, err)
-	d.MaxDevices = 1
-
-	assert.False(t, d.IsNotPending())
-	assert.False(t, d.IsFinished())
-
-	// check all active statuses
-	active := []DeviceDeploymentStatus{
-		DeviceDeploymentStatusRebooting,
-		DeviceDeploymentStatusInstalling,
-		DeviceDeploymentStatusDownloading,
-		DeviceDeploymentStatusPauseBeforeInstall,
-		DeviceDeploymentStatusPauseBeforeReboot,
-		DeviceDeploymentStatusPauseBeforeCommit,
-	}
-	for _, as := range active {
-		t.Logf("checking in-progress deployment stat %s", as)
-		d.Stats = NewDeviceDeploymentStats()
-		d.Stats.Set(as, 1)
-		assert.True(t, d.IsNotPending())
-		assert.False(t, d.IsFinished())
-	}
+	const (
+		deviceA = "f826484e-1157-4109-af21-304e6d711560"
+		deviceB = "b532b01a-9313-404f-8d19-e7fcbe5cc347"
+	)
+	testCases := map[string]struct {
+		Devices []string
 
-	finished := []DeviceDeploymentStatus{
-		DeviceDeploymentStatusSuccess,
-		DeviceDeploymentStatusFailure,
-		DeviceDeploymentStatusNoArtifact,
-		DeviceDeploymentStatusAlreadyInst,
-		DeviceDeploymentStatusAborted,
-	}
-	for _, as := range finished {
-		t.Logf("checking finished deployment stat %s", as)
-		d.Stats = NewDeviceDeploymentStats()
-		d.Stats.Set(as, 1)
-		assert.True(t, d.IsFinished())
-		assert.True(t, d.IsNotPending())
+		Error error
+	}{
+		"ok, all unique": {
+			Devices: []string{deviceA, deviceB},
+		},
+		"error, one duplicate": {
+			Devices: []string{deviceA, deviceB, deviceA},
+			Error:   ErrDuplicateDeviceID,
+		},
+		"error, all duplicates": {
+			Devices: []string{deviceA, deviceA, deviceA},
+			Error:   ErrDuplicateDeviceID,
+		},
 	}
 
-	pending := []DeviceDeploymentStatus{
-		DeviceDeploymentStatusPending,
-	}
-	for _, as := range pending {
-		t.Logf("checking pending deployment stat %s", as)
-		d.Stats = NewDeviceDeploymentStats()
-		d.Stats.Set(as, 1)
-		assert.False(t, d.IsFinished())
-		assert.False(t, d.IsNotPending())
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			constructor := DeploymentConstructor{
+				Name:         "foo",
+				ArtifactName: "bar",
+				Devices:      tc.Devices,
+			}
+			err := constructor.Validate()
+			if tc.Error == nil {
+				assert.NoError(t, err)
+				return
+			}
+			var errs validation.Errors
+			if assert.ErrorAs(t, err, &errs) {
+				assert.ErrorIs(t, errs["devices"], tc.Error)
+			}
+		})
 	}
 }
 
-func TestDeploymentGetStatus(t *testing.T) {
+func TestDeploymentConstructorSubgroupNames(t *testing.T) {
 
-	tests := map[string]struct {
-		Stats        Stats
-		OutputStatus DeploymentStatus
+	t.Parallel()
+
+	testCases := map[string]struct {
+		Constructor DeploymentConstructor
+		Error       error
+
+		MultiGroup bool
+		Groups     []string
 	}{
-		"Single NoArtifact": {
-			Stats: Stats{
-				DeviceDeploymentStatusNoArtifactStr: 1,
+		"ok": {
+			Constructor: DeploymentConstructor{
+				SubgroupNames: []string{"foo", "bar"},
 			},
-			OutputStatus: "finished",
+			MultiGroup: true,
+			Groups:     []string{"foo", "bar"},
 		},
-		"Single Success": {
-			Stats: Stats{
-				DeviceDeploymentStatusSuccessStr: 1,
+		"ok, single group": {
+			Constructor: DeploymentConstructor{
+				SubgroupNames: []string{"foo"},
 			},
-			OutputStatus: "finished",
+			Groups: []string{"foo"},
 		},
-		"Success + NoArtifact": {
-			Stats: Stats{
-				DeviceDeploymentStatusSuccessStr:    1,
-				DeviceDeploymentStatusNoArtifactStr: 1,
+		"ok, group": {
+			Constructor: DeploymentConstructor{
+				Group: "foo",
 			},
-			OutputStatus: "finished",
+			Groups: []string{"foo"},
 		},
-		"Failed + NoArtifact": {
-			Stats: Stats{
-				DeviceDeploymentStatusFailureStr:    1,
-				DeviceDeploymentStatusNoArtifactStr: 1,
+		"error, empty group name": {
+			Constructor: DeploymentConstructor{
+				SubgroupNames: []string{"foo", ""},
 			},
-			OutputStatus: "finished",
 		},
-		"Failed + AlreadyInst": {
-			Stats: Stats{
-				DeviceDeploymentStatusFailureStr:     1,
-				DeviceDeploymentStatusAlreadyInstStr: 1,
+		"error, group name too long": {
+			Constructor: DeploymentConstructor{
+				SubgroupNames: []string{strings.Repeat("a", 257)},
 			},
-			OutputStatus: "finished",
 		},
-		"Failed + Aborted": {
-			Stats: Stats{
-				DeviceDeploymentStatusFailureStr: 1,
-				DeviceDeploymentStatusAbortedStr: 1,
+		"error, group set": {
+			Constructor: DeploymentConstructor{
+				Group:         "foo",
+				SubgroupNames: []string{"bar"},
 			},
-			OutputStatus: "finished",
+			Error: ErrInvalidDeploymentToSubgroupsDefinitionConflict,
 		},
-		"Rebooting + NoArtifact": {
-			Stats: Stats{
-				DeviceDeploymentStatusRebootingStr:  1,
-				DeviceDeploymentStatusNoArtifactStr: 1,
+		"error, devices set": {
+			Constructor: DeploymentConstructor{
+				Devices:       []string{"f826484e-1157-4109-af21-304e6d711560"},
+				SubgroupNames: []string{"bar"},
 			},
-			OutputStatus: "inprogress",
+			Error: ErrInvalidDeploymentToSubgroupsDefinitionConflict,
 		},
-		"Rebooting + Installing": {
-			Stats: Stats{
-				DeviceDeploymentStatusRebootingStr:  1,
-				DeviceDeploymentStatusInstallingStr: 1,
+		"error, all devices set": {
+			Constructor: DeploymentConstructor{
+				AllDevices:    true,
+				SubgroupNames: []string{"bar"},
 			},
-			OutputStatus: "inprogress",
+			Error: ErrInvalidDeploymentToSubgroupsDefinitionConflict,
 		},
-		"Rebooting + Pending": {
-			Stats: Stats{
-				DeviceDeploymentStatusRebootingStr: 1,
-				DeviceDeploymentStatusPendingStr:   1,
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			constructor := tc.Constructor
+			constructor.Name = "foo"
+			constructor.ArtifactName = "bar"
+			err := constructor.ValidateNew()
+			if tc.Error != nil {
+				assert.ErrorIs(t, err, tc.Error)
+				return
+			} else if tc.Groups == nil {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+
+			dep, err := NewDeploymentFromConstructor(&constructor)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.MultiGroup, dep.IsMultiGroupDeployment())
+			assert.Equal(t, tc.Groups, dep.TargetGroupNames())
+		})
+	}
+}
+
+func TestDeploymentConstructorDeviceTagFilter(t *testing.T) {
+
+	t.Parallel()
+
+	filter := map[string]string{"model": "RPi4", "firmware_version": "1.x"}
+	testCases := map[string]struct {
+		Constructor DeploymentConstructor
+		Invalid     bool
+		Error       error
+
+		TagFiltered bool
+	}{
+		"ok": {
+			Constructor: DeploymentConstructor{
+				DeviceTagFilter: filter,
 			},
-			OutputStatus: "inprogress",
+			TagFiltered: true,
 		},
-		"All paused states": {
-			Stats: Stats{
-				DeviceDeploymentStatusPauseBeforeInstallStr: 1,
-				DeviceDeploymentStatusPauseBeforeCommitStr:  1,
-				DeviceDeploymentStatusPauseBeforeRebootStr:  1,
+		"ok, empty filter": {
+			Constructor: DeploymentConstructor{
+				DeviceTagFilter: map[string]string{},
+				AllDevices:      true,
 			},
-			OutputStatus: "inprogress",
 		},
-		"Some paused states": {
-			Stats: Stats{
-				DeviceDeploymentStatusInstallingStr:         1,
-				DeviceDeploymentStatusPauseBeforeInstallStr: 1,
-				DeviceDeploymentStatusPauseBeforeCommitStr:  0,
-				DeviceDeploymentStatusPauseBeforeRebootStr:  1,
+		"error, invalid key": {
+			Constructor: DeploymentConstructor{
+				DeviceTagFilter: map[string]string{"a.b": "c"},
 			},
-			OutputStatus: "inprogress",
+			Invalid: true,
 		},
-		"Pending": {
-			Stats: Stats{
-				DeviceDeploymentStatusPendingStr: 1,
+		"error, devices set": {
+			Constructor: DeploymentConstructor{
+				DeviceTagFilter: filter,
+				Devices:         []string{"f826484e-1157-4109-af21-304e6d711560"},
 			},
-			OutputStatus: "pending",
+			Error: ErrInvalidDeploymentToTagFilterDefinitionConflict,
 		},
-		"Empty": {
-			OutputStatus: "pending",
+		"error, all devices set": {
+			Constructor: DeploymentConstructor{
+				DeviceTagFilter: filter,
+				AllDevices:      true,
+			},
+			Error: ErrInvalidDeploymentToTagFilterDefinitionConflict,
 		},
-		//verify we count 'already-installed' towards 'inprogress'
-		"pending + already-installed": {
-			Stats: Stats{
-				DeviceDeploymentStatusPendingStr:     1,
-				DeviceDeploymentStatusAlreadyInstStr: 1,
+		"error, group set": {
+			Constructor: DeploymentConstructor{
+				DeviceTagFilter: filter,
+				Group:           "foo",
 			},
-			OutputStatus: "inprogress",
+			Error: ErrInvalidDeploymentToTagFilterDefinitionConflict,
 		},
-		//verify we count 'already-installed' towards 'finished'
-		"already-installed + finished": {
-			Stats: Stats{
-				DeviceDeploymentStatusPendingStr:     0,
-				DeviceDeploymentStatusAlreadyInstStr: 1,
+		"error, subgroups set": {
+			Constructor: DeploymentConstructor{
+				DeviceTagFilter: filter,
+				SubgroupNames:   []string{"foo"},
 			},
-			OutputStatus: "finished",
+			Error: ErrInvalidDeploymentToTagFilterDefinitionConflict,
 		},
 	}
 
-	for name, test := range tests {
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			constructor := tc.Constructor
+			constructor.Name = "foo"
+			constructor.ArtifactName = "bar"
+			err := constructor.ValidateNew()
+			if tc.Error != nil {
+				assert.ErrorIs(t, err, tc.Error)
+				return
+			} else if tc.Invalid {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
 
-		t.Log(name)
+			dep, err := NewDeploymentFromConstructor(&constructor)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.TagFiltered, dep.IsTagFiltered())
+		})
+	}
 
-		dep, err := NewDeployment()
-		assert.NoError(t, err)
+	dep := &Deployment{}
+	assert.False(t, dep.IsTagFiltered())
+}
 
-		dep.Stats = test.Stats
-		for _, n := range dep.Stats {
-			dep.MaxDevices += n
-		}
+func TestDeploymentConstructorMaxDevices(t *testing.T) {
 
-		assert.Equal(t, test.OutputStatus, dep.GetStatus())
+	t.Parallel()
+
+	testCases := map[string]struct {
+		Constructor DeploymentConstructor
+		Invalid     bool
+		Error       error
+	}{
+		"ok, all devices": {
+			Constructor: DeploymentConstructor{
+				AllDevices: true,
+				MaxDevices: 10,
+			},
+		},
+		"ok, group": {
+			Constructor: DeploymentConstructor{
+				Group:      "foo",
+				MaxDevices: 10,
+			},
+		},
+		"ok, devices longer than the cap": {
+			Constructor: DeploymentConstructor{
+				Devices:    []string{"f826484e-1157-4109-af21-304e6d711560", "b532b01a-9313-404f-8d19-e7fcbe5cc347"},
+				MaxDevices: 1,
+			},
+		},
+		"error, negative": {
+			Constructor: DeploymentConstructor{
+				AllDevices: true,
+				MaxDevices: -1,
+			},
+			Invalid: true,
+		},
+		"error, devices shorter than the cap": {
+			Constructor: DeploymentConstructor{
+				Devices:    []string{"f826484e-1157-4109-af21-304e6d711560"},
+				MaxDevices: 2,
+			},
+			Error: ErrInvalidDeploymentMaxDevicesConflict,
+		},
 	}
 
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			constructor := tc.Constructor
+			constructor.Name = "foo"
+			constructor.ArtifactName = "bar"
+			err := constructor.ValidateNew()
+			if tc.Error != nil {
+				assert.ErrorIs(t, err, tc.Error)
+				return
+			} else if tc.Invalid {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+
+			dep, err := NewDeploymentFromConstructor(&constructor)
+			if assert.NoError(t, err) {
+				assert.Equal(t, constructor.MaxDevices, dep.DeviceCap())
+				assert.Equal(t, constructor.MaxDevices, dep.MaxDevices)
+			}
+		})
+	}
 }
 
-func TestFuzzyGetStatus(t *testing.T) {
+func TestDeploymentProgress(t *testing.T) {
+	t.Parallel()
 
-	rand := func(min int, max int) int {
-		rand.Seed(time.Now().UTC().UnixNano())
-		return min + rand.Intn(max-min)
+	testCases := map[string]struct {
+		MaxDevices int
+		Stats      map[DeviceDeploymentStatus]int
+
+		Progress float64
+		Percent  int
+	}{
+		"no devices": {},
+		"fully pending": {
+			MaxDevices: 4,
+			Stats: map[DeviceDeploymentStatus]int{
+				DeviceDeploymentStatusPending: 4,
+			},
+		},
+		"in flight": {
+			MaxDevices: 100,
+			Stats: map[DeviceDeploymentStatus]int{
+				DeviceDeploymentStatusSuccess:     20,
+				DeviceDeploymentStatusFailure:     9,
+				DeviceDeploymentStatusDownloading: 71,
+			},
+			Progress: 0.29,
+			Percent:  29,
+		},
+		"fully finished": {
+			MaxDevices: 3,
+			Stats: map[DeviceDeploymentStatus]int{
+				DeviceDeploymentStatusSuccess:     1,
+				DeviceDeploymentStatusNoArtifact:  1,
+				DeviceDeploymentStatusAlreadyInst: 1,
+			},
+			Progress: 1,
+			Percent:  100,
+		},
+		"clamped": {
+			MaxDevices: 1,
+			Stats: map[DeviceDeploymentStatus]int{
+				DeviceDeploymentStatusSuccess: 2,
+			},
+			Progress: 1,
+			Percent:  100,
+		},
 	}
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			dep := &Deployment{
+				MaxDevices: tc.MaxDevices,
+				Stats:      NewStats(tc.Stats),
+			}
+			assert.InDelta(t, tc.Progress, dep.Progress(), 1e-9)
+			assert.Equal(t, tc.Percent, dep.ProgressPercent())
+		})
+	}
+}
 
-	max := 3
+func TestDeploymentDeviceRates(t *testing.T) {
+	t.Parallel()
 
-	for i := 0; i < 1000; i++ {
-		dep, err := NewDeployment()
-		assert.NoError(t, err)
+	testCases := map[string]struct {
+		MaxDevices int
+		Stats      map[DeviceDeploymentStatus]int
+
+		SuccessRate float64
+		FailureRate float64
+	}{
+		"no devices": {
+			Stats: map[DeviceDeploymentStatus]int{
+				DeviceDeploymentStatusSuccess: 1,
+				DeviceDeploymentStatusFailure: 1,
+			},
+		},
+		"fully pending": {
+			MaxDevices: 4,
+			Stats: map[DeviceDeploymentStatus]int{
+				DeviceDeploymentStatusPending: 4,
+			},
+		},
+		"in flight": {
+			MaxDevices: 100,
+			Stats: map[DeviceDeploymentStatus]int{
+				DeviceDeploymentStatusSuccess:     20,
+				DeviceDeploymentStatusFailure:     9,
+				DeviceDeploymentStatusDownloading: 71,
+			},
+			SuccessRate: 0.2,
+			FailureRate: 0.09,
+		},
+		"all succeeded": {
+			MaxDevices: 3,
+			Stats: map[DeviceDeploymentStatus]int{
+				DeviceDeploymentStatusSuccess: 3,
+			},
+			SuccessRate: 1,
+		},
+		"all failed": {
+			MaxDevices: 3,
+			Stats: map[DeviceDeploymentStatus]int{
+				DeviceDeploymentStatusFailure: 3,
+			},
+			FailureRate: 1,
+		},
+		"clamped": {
+			MaxDevices: 1,
+			Stats: map[DeviceDeploymentStatus]int{
+				DeviceDeploymentStatusSuccess: 2,
+				DeviceDeploymentStatusFailure: 3,
+			},
+			SuccessRate: 1,
+			FailureRate: 1,
+		},
+	}
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			dep := &Deployment{
+				MaxDevices: tc.MaxDevices,
+				Stats:      NewStats(tc.Stats),
+			}
+			assert.InDelta(t, tc.SuccessRate, dep.DeviceSuccessRate(), 1e-9)
+			assert.InDelta(t, tc.FailureRate, dep.DeviceFailureRate(), 1e-9)
+		})
+	}
+}
 
-		dep.Stats[DeviceDeploymentStatusDownloadingStr] = rand(0, max)
-		dep.Stats[DeviceDeploymentStatusInstallingStr] = rand(0, max)
-		dep.Stats[DeviceDeploymentStatusRebootingStr] = rand(0, max)
-		dep.Stats[DeviceDeploymentStatusPendingStr] = rand(0, max)
-		dep.Stats[DeviceDeploymentStatusSuccessStr] = rand(0, max)
-		dep.Stats[DeviceDeploymentStatusFailureStr] = rand(0, max)
-		dep.Stats[DeviceDeploymentStatusNoArtifactStr] = rand(0, max)
-		dep.Stats[DeviceDeploymentStatusAlreadyInstStr] = rand(0, max)
-		dep.Stats[DeviceDeploymentStatusAbortedStr] = rand(0, max)
-		dep.Stats[DeviceDeploymentStatusDecommissionedStr] = rand(0, max)
+func TestDeploymentConstructorRollback(t *testing.T) {
+	t.Parallel()
 
-		pending := 0
-		inprogress := 0
-		finished := 0
+	testCases := map[string]struct {
+		RollbackArtifactName    string
+		FailureThresholdPercent float64
 
-		if dep.GetStatus() == "pending" {
-			pending++
-		}
+		Invalid bool
+		Error   error
+	}{
+		"ok": {
+			RollbackArtifactName:    "bar-1.0",
+			FailureThresholdPercent: 10,
+		},
+		"ok, no rollback": {},
+		"error, same artifact": {
+			RollbackArtifactName: "bar",
+			Error:                ErrRollbackArtifactConflict,
+		},
+		"error, invalid artifact name": {
+			RollbackArtifactName: "bar/1.0",
+			Invalid:              true,
+		},
+		"error, negative threshold": {
+			RollbackArtifactName:    "bar-1.0",
+			FailureThresholdPercent: -1,
+			Invalid:                 true,
+		},
+		"error, threshold above 100": {
+			RollbackArtifactName:    "bar-1.0",
+			FailureThresholdPercent: 100.5,
+			Invalid:                 true,
+		},
+	}
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			constructor := DeploymentConstructor{
+				Name:                    "foo",
+				ArtifactName:            "bar",
+				AllDevices:              true,
+				RollbackArtifactName:    tc.RollbackArtifactName,
+				FailureThresholdPercent: tc.FailureThresholdPercent,
+			}
+			err := constructor.ValidateNew()
+			if tc.Error != nil {
+				assert.ErrorIs(t, err, tc.Error)
+			} else if tc.Invalid {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
 
-		if dep.GetStatus() == "finished" {
-			finished++
-		}
+func TestDeploymentShouldRollback(t *testing.T) {
+	t.Parallel()
 
-		if dep.GetStatus() == "inprogress" {
-			inprogress++
+	testCases := map[string]struct {
+		RollbackArtifactName    string
+		FailureThresholdPercent float64
+		MaxDevices              int
+		Stats                   map[DeviceDeploymentStatus]int
+
+		ShouldRollback bool
+	}{
+		"no rollback artifact": {
+			FailureThresholdPercent: 10,
+			MaxDevices:              10,
+			Stats: map[DeviceDeploymentStatus]int{
+				DeviceDeploymentStatusFailure: 5,
+			},
+		},
+		"no devices": {
+			RollbackArtifactName:    "bar-1.0",
+			FailureThresholdPercent: 10,
+		},
+		"below threshold": {
+			RollbackArtifactName:    "bar-1.0",
+			FailureThresholdPercent: 10,
+			MaxDevices:              10,
+			Stats: map[DeviceDeploymentStatus]int{
+				DeviceDeploymentStatusFailure: 1,
+				DeviceDeploymentStatusSuccess: 9,
+			},
+		},
+		"above threshold": {
+			RollbackArtifactName:    "bar-1.0",
+			FailureThresholdPercent: 10,
+			MaxDevices:              10,
+			Stats: map[DeviceDeploymentStatus]int{
+				DeviceDeploymentStatusFailure: 2,
+				DeviceDeploymentStatusPending: 8,
+			},
+			ShouldRollback: true,
+		},
+		"zero threshold": {
+			RollbackArtifactName: "bar-1.0",
+			MaxDevices:           10,
+			Stats: map[DeviceDeploymentStatus]int{
+				DeviceDeploymentStatusFailure: 1,
+				DeviceDeploymentStatusPending: 9,
+			},
+			ShouldRollback: true,
+		},
+	}
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			dep, err := NewDeploymentFromConstructor(&DeploymentConstructor{
+				Name:                    "foo",
+				ArtifactName:            "bar",
+				AllDevices:              true,
+				RollbackArtifactName:    tc.RollbackArtifactName,
+				FailureThresholdPercent: tc.FailureThresholdPercent,
+			})
+			if !assert.NoError(t, err) {
+				return
+			}
+			assert.Equal(t, tc.RollbackArtifactName, dep.RollbackArtifactName)
+			assert.Equal(t, tc.FailureThresholdPercent, dep.FailureThresholdPercent)
+			dep.MaxDevices = tc.MaxDevices
+			dep.Stats = NewStats(tc.Stats)
+			assert.Equal(t, tc.ShouldRollback, dep.ShouldRollback())
+		})
+	}
+}
+
+func TestDeploymentIsFinishedDeviceCap(t *testing.T) {
+
+	t.Parallel()
+
+	dep, err := NewDeploymentFromConstructor(&DeploymentConstructor{
+		Name:         "foo",
+		ArtifactName: "bar",
+		AllDevices:   true,
+		MaxDevices:   2,
+	})
+	if !assert.NoError(t, err) {
+		return
+	}
+	dep.MaxDevices = 5
+	dep.Stats.Set(DeviceDeploymentStatusSuccess, 1)
+	assert.False(t, dep.IsFinished())
+	dep.Stats.Set(DeviceDeploymentStatusFailure, 1)
+	assert.True(t, dep.IsFinished(), "finished once the capped devices finished")
+
+	dep.DeploymentConstructor.MaxDevices = 0
+	assert.False(t, dep.IsFinished())
+
+	dep.DeploymentConstructor = nil
+	assert.Zero(t, dep.DeviceCap())
+}
+
+func TestDeploymentConstructorPhases(t *testing.T) {
+
+	t.Parallel()
+
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	at := func(hours int) *time.Time {
+		ts := start.Add(time.Duration(hours) * time.Hour)
+		return &ts
+	}
+
+	testCases := map[string]struct {
+		Phases     []Phase
+		MaxDevices int
+		Invalid    bool
+		Error      error
+	}{
+		"ok": {
+			Phases: []Phase{
+				{BatchSize: 1, DelayHours: 2, StartTs: at(0)},
+				{BatchSize: 10, StartTs: at(2)},
+			},
+		},
+		"ok, no start timestamps": {
+			Phases: []Phase{
+				{BatchSize: 1, DelayHours: 1.5},
+				{BatchSize: 10},
+			},
+			MaxDevices: 11,
+		},
+		"error, invalid batch size": {
+			Phases:  []Phase{{BatchSize: 0}},
+			Invalid: true,
+		},
+		"error, negative delay": {
+			Phases:  []Phase{{BatchSize: 1, DelayHours: -1}},
+			Invalid: true,
+		},
+		"error, overlap": {
+			Phases: []Phase{
+				{BatchSize: 1, DelayHours: 2, StartTs: at(0)},
+				{BatchSize: 10, StartTs: at(1)},
+			},
+			Error: ErrPhasesOverlap,
+		},
+		"error, exceeds max devices": {
+			Phases: []Phase{
+				{BatchSize: 1},
+				{BatchSize: 10},
+			},
+			MaxDevices: 10,
+			Error:      ErrPhasesExceedMaxDevices,
+		},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			constructor := DeploymentConstructor{
+				Name:         "foo",
+				ArtifactName: "bar",
+				AllDevices:   true,
+				MaxDevices:   tc.MaxDevices,
+				Phases:       tc.Phases,
+			}
+			err := constructor.ValidateNew()
+			if tc.Error != nil {
+				assert.ErrorIs(t, err, tc.Error)
+			} else if tc.Invalid {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestDeploymentCurrentPhase(t *testing.T) {
+
+	t.Parallel()
+
+	created := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	dep, err := NewDeploymentFromConstructor(&DeploymentConstructor{
+		Name:         "foo",
+		ArtifactName: "bar",
+		AllDevices:   true,
+		Phases: []Phase{
+			{BatchSize: 2, DelayHours: 1},
+			{BatchSize: 5, DelayHours: 24},
+			{BatchSize: 10},
+		},
+	})
+	if !assert.NoError(t, err) {
+		return
+	}
+	dep.Created = &created
+
+	assert.Equal(t, 0, dep.currentPhase(created.Add(2*time.Hour)),
+		"the devices of the first phase are not done")
+
+	dep.Stats.Set(DeviceDeploymentStatusSuccess, 1)
+	dep.Stats.Set(DeviceDeploymentStatusFailure, 1)
+	assert.Equal(t, 0, dep.currentPhase(created.Add(30*time.Minute)),
+		"the delay of the first phase did not elapse")
+	assert.Equal(t, 1, dep.currentPhase(created.Add(2*time.Hour)))
+
+	dep.Stats.Set(DeviceDeploymentStatusSuccess, 6)
+	assert.Equal(t, 1, dep.currentPhase(created.Add(2*time.Hour)))
+	assert.Equal(t, 2, dep.currentPhase(created.Add(25*time.Hour)))
+
+	dep.DeploymentConstructor = nil
+	assert.Equal(t, 0, dep.CurrentPhase())
+}
+
+func TestDeploymentConstructorValidateTags(t *testing.T) {
+
+	t.Parallel()
+
+	testCases := map[string]struct {
+		Tags    map[string]string
+		IsValid bool
+	}{
+		"ok": {
+			Tags:    map[string]string{"env": "production"},
+			IsValid: true,
+		},
+		"ok, no tags": {
+			IsValid: true,
+		},
+		"error, key contains dot": {
+			Tags:    map[string]string{"a.b": "value"},
+			IsValid: false,
+		},
+		"error, key contains dollar sign": {
+			Tags:    map[string]string{"$ref": "value"},
+			IsValid: false,
+		},
+		"error, empty key": {
+			Tags:    map[string]string{"": "value"},
+			IsValid: false,
+		},
+	}
+	tooMany := make(map[string]string, DeploymentTagsMax+1)
+	for i := 0; i <= DeploymentTagsMax; i++ {
+		tooMany["key"+strconv.Itoa(i)] = "value"
+	}
+	limits := map[string]map[string]string{
+		"error, too many tags": tooMany,
+		"error, value too long": {
+			"env": strings.Repeat("a", DeploymentTagMaxLength+1),
+		},
+		"error, key too long": {
+			strings.Repeat("a", DeploymentTagMaxLength+1): "value",
+		},
+	}
+	for name, tags := range limits {
+		constructor := DeploymentConstructor{
+			Name:         "foo",
+			ArtifactName: "bar",
+			Tags:         tags,
 		}
+		assert.Error(t, constructor.Validate(), name)
+	}
 
-		exp_stats := pending + inprogress + finished
-		assert.Equal(t, 1, exp_stats, dep.Stats)
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			constructor := DeploymentConstructor{
+				Name:         "foo",
+				ArtifactName: "bar",
+				Tags:         tc.Tags,
+			}
+			err := constructor.Validate()
+			if tc.IsValid {
+				assert.NoError(t, err)
+			} else {
+				assert.Error(t, err)
+			}
+			for key := range tc.Tags {
+				assert.Equal(t, tc.IsValid, IsValidTagKey(key))
+			}
+		})
+	}
+}
+
+func TestDeploymentConstructorValidateComment(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		Comment string
+		IsValid bool
+	}{
+		"ok, no comment": {
+			IsValid: true,
+		},
+		"ok": {
+			Comment: "CHG-1234: approved by the release manager",
+			IsValid: true,
+		},
+		"ok, at the limit": {
+			Comment: strings.Repeat("x", 10000),
+			IsValid: true,
+		},
+		"ok, multi-byte characters at the limit": {
+			Comment: strings.Repeat("ü", 10000),
+			IsValid: true,
+		},
+		"error, too long": {
+			Comment: strings.Repeat("x", 10001),
+			IsValid: false,
+		},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			constructor := DeploymentConstructor{
+				Name:         "foo",
+				ArtifactName: "bar",
+				Comment:      tc.Comment,
+			}
+			err := constructor.Validate()
+			if tc.IsValid {
+				assert.NoError(t, err)
+			} else {
+				assert.Error(t, err)
+			}
+
+			dep, err := NewDeploymentFromConstructor(&constructor)
+			if !assert.NoError(t, err) {
+				return
+			}
+			assert.Equal(t, tc.Comment, dep.Comment)
+			assert.Equal(t, tc.Comment, dep.ToConstructor().Comment)
+
+			b, err := json.Marshal(dep)
+			if !assert.NoError(t, err) {
+				return
+			}
+			var res map[string]interface{}
+			if assert.NoError(t, json.Unmarshal(b, &res)) && tc.Comment != "" {
+				assert.Equal(t, tc.Comment, res["comment"])
+			} else {
+				assert.NotContains(t, res, "comment")
+			}
+		})
+	}
+}
+
+func TestDeploymentConstructorValidateArtifactName(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		Name    string
+		IsValid bool
+	}{
+		"ok": {
+			Name:    "release-1.0 (beta)",
+			IsValid: true,
+		},
+		"error, slash": {
+			Name: "release/1.0",
+		},
+		"error, backslash": {
+			Name: "release\\1.0",
+		},
+		"error, null byte": {
+			Name: "release\x001.0",
+		},
+		"error, trailing space": {
+			Name: "release-1.0 ",
+		},
+		"error, leading tab": {
+			Name: "\trelease-1.0",
+		},
+		"error, empty": {
+			Name: "",
+		},
+		"ok, single dots": {
+			Name:    "release.1.0.",
+			IsValid: true,
+		},
+		"ok, percent sign": {
+			Name:    "release-100%",
+			IsValid: true,
+		},
+		"error, parent directory": {
+			Name: "..",
+		},
+		"error, dot dot in the name": {
+			Name: "release..1.0",
+		},
+		"error, traversal": {
+			Name: "../etc/passwd",
+		},
+		"error, windows traversal": {
+			Name: "..\\..\\windows\\win.ini",
+		},
+		"error, encoded dots": {
+			Name: "%2e%2e",
+		},
+		"error, encoded dots, upper case": {
+			Name: "%2E%2E",
+		},
+		"error, mixed encoded dots": {
+			Name: ".%2e",
+		},
+		"error, encoded slash": {
+			Name: "release%2f1.0",
+		},
+		"error, encoded traversal": {
+			Name: "%2e%2e%2fetc%2fpasswd",
+		},
+		"error, double encoded traversal": {
+			Name: "%252e%252e%252fetc%252fpasswd",
+		},
+		"error, encoded backslash": {
+			Name: "release%5c1.0",
+		},
+		"error, encoded null byte": {
+			Name: "release%001.0",
+		},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.IsValid, IsValidArtifactName(tc.Name))
+
+			constructor := DeploymentConstructor{
+				Name:         "foo",
+				ArtifactName: tc.Name,
+			}
+			err := constructor.Validate()
+			if tc.IsValid {
+				assert.NoError(t, err)
+			} else {
+				assert.Error(t, err)
+			}
+
+			constructor = DeploymentConstructor{
+				Name:         tc.Name,
+				ArtifactName: "bar",
+			}
+			err = constructor.Validate()
+			if tc.IsValid {
+				assert.NoError(t, err)
+			} else {
+				assert.Error(t, err)
+			}
+		})
+	}
+}
+
+func TestNewDeploymentID(t *testing.T) {
+
+	t.Parallel()
+
+	id, err := NewDeploymentID()
+	assert.NoError(t, err)
+	assert.NoError(t, is.UUIDv4.Validate(id))
+	assert.NotEqual(t, id, MustNewDeploymentID())
+}
+
+func TestNewDeploymentFromConstructor(t *testing.T) {
+
+	t.Parallel()
+
+	dep, err := NewDeploymentFromConstructor(nil)
+	assert.NoError(t, err)
+	assert.NotNil(t, dep)
+
+	con := &DeploymentConstructor{}
+
+	dep, err = NewDeploymentFromConstructor(con)
+	assert.NoError(t, err)
+
+	assert.NotNil(t, dep)
+	assert.Equal(t, con, dep.DeploymentConstructor)
+}
+
+func TestDeploymentToConstructor(t *testing.T) {
+
+	t.Parallel()
+
+	dep, err := NewDeployment()
+	assert.NoError(t, err)
+	dep.DeploymentConstructor = nil
+	assert.Nil(t, dep.ToConstructor())
+	assert.Nil(t, dep.ToConstructorWithoutDevices())
+
+	con := &DeploymentConstructor{
+		Name:              "foo",
+		ArtifactName:      "bar",
+		Devices:           []string{"dev-1", "dev-2"},
+		AllDevices:        true,
+		ForceInstallation: true,
+		Group:             "baz",
+		Tags:              map[string]string{"env": "production"},
+		DeviceTagFilter:   map[string]string{"model": "RPi4"},
+	}
+	dep, err = NewDeploymentFromConstructor(con)
+	assert.NoError(t, err)
+
+	clone := dep.ToConstructor()
+	assert.Equal(t, con, clone)
+	if assert.NotSame(t, con, clone) {
+		clone.Devices[0] = "dev-3"
+		clone.Tags["env"] = "staging"
+		clone.DeviceTagFilter["model"] = "RPi3"
+		assert.Equal(t, "RPi4", con.DeviceTagFilter["model"],
+			"modifying the copy must not alter the original constructor")
+		assert.Equal(t, "dev-1", con.Devices[0],
+			"modifying the copy must not alter the original constructor")
+		assert.Equal(t, "production", con.Tags["env"],
+			"modifying the copy must not alter the original constructor")
+	}
+
+	clone = dep.ToConstructorWithoutDevices()
+	assert.Nil(t, clone.Devices)
+	assert.False(t, clone.AllDevices)
+	assert.Nil(t, clone.DeviceTagFilter)
+	assert.Equal(t, con.Name, clone.Name)
+	assert.Equal(t, con.ArtifactName, clone.ArtifactName)
+	assert.Equal(t, con.Group, clone.Group)
+	assert.True(t, clone.ForceInstallation)
+	assert.Len(t, con.Devices, 2)
+	assert.True(t, con.AllDevices)
+}
+
+func TestDeploymentClone(t *testing.T) {
+	t.Parallel()
+
+	dep, err := NewDeploymentFromConstructor(&DeploymentConstructor{
+		Name:         "foo",
+		ArtifactName: "bar",
+		Devices: []string{
+			"b532b01a-9313-404f-8d19-e7fcbe5cc399",
+			"d4f4a4b0-0a34-4d4b-a7f4-2a3c1e1f2b01",
+		},
+	})
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	finished := time.Now()
+	dep.Finished = &finished
+	dep.Status = DeploymentStatusFinished
+	dep.Artifacts = []string{"f826484e-1157-4109-af21-304e6d711560"}
+	dep.DeviceList = append([]string{}, dep.Devices...)
+	dep.MaxDevices = len(dep.DeviceList)
+	dep.Stats = NewStats(map[DeviceDeploymentStatus]int{
+		DeviceDeploymentStatusFailure: 2,
+	})
+
+	clone, err := dep.Clone()
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.NotEqual(t, dep.Id, clone.Id)
+	assert.True(t, IsUUIDv4(clone.Id))
+	assert.NotSame(t, dep.Created, clone.Created)
+	assert.Nil(t, clone.Finished)
+	assert.Equal(t, DeploymentStatusPending, clone.Status)
+	assert.Zero(t, clone.Stats.Total())
+	assert.Equal(t, 0, clone.Stats.Get(DeviceDeploymentStatusFailure))
+	assert.Equal(t, dep.DeploymentConstructor, clone.DeploymentConstructor)
+	assert.NotSame(t, dep.DeploymentConstructor, clone.DeploymentConstructor)
+	assert.Equal(t, dep.Artifacts, clone.Artifacts)
+	assert.Equal(t, dep.MaxDevices, clone.MaxDevices)
+	if assert.Equal(t, dep.DeviceList, clone.DeviceList) {
+		clone.DeviceList[0] = "modified"
+		assert.Equal(t, "b532b01a-9313-404f-8d19-e7fcbe5cc399", dep.DeviceList[0],
+			"modifying the clone must not alter the original deployment")
+	}
+	assert.NoError(t, clone.Validate())
+
+	dep.DeploymentConstructor = nil
+	_, err = dep.Clone()
+	assert.ErrorIs(t, err, ErrInvalidDeploymentDefinition)
+}
+
+func TestDeploymentArtifacts(t *testing.T) {
+
+	t.Parallel()
+
+	const (
+		artifactID      = "f826484e-1157-4109-af21-304e6d711560"
+		otherArtifactID = "a108ae14-bb4e-455f-9b40-2ef4bab97bb7"
+	)
+
+	dep, err := NewDeployment()
+	assert.NoError(t, err)
+
+	assert.ErrorIs(t, dep.AddArtifact(""), ErrInvalidArtifactID)
+	assert.ErrorIs(t, dep.AddArtifact("not-an-uuid"), ErrInvalidArtifactID)
+	assert.Empty(t, dep.Artifacts)
+
+	assert.NoError(t, dep.AddArtifact(artifactID))
+	assert.NoError(t, dep.AddArtifact(artifactID))
+	assert.NoError(t, dep.AddArtifact(otherArtifactID))
+	assert.Equal(t, []string{artifactID, otherArtifactID}, dep.Artifacts)
+	assert.True(t, dep.HasArtifact(artifactID))
+
+	assert.NoError(t, dep.RemoveArtifact(artifactID))
+	assert.False(t, dep.HasArtifact(artifactID))
+	assert.ErrorIs(t, dep.RemoveArtifact(artifactID), ErrArtifactNotFound)
+	assert.Equal(t, []string{otherArtifactID}, dep.Artifacts)
+}
+
+func TestDeploymentValidate(t *testing.T) {
+
+	t.Parallel()
+
+	testCases := []struct {
+		InputName         string
+		InputArtifactName string
+		InputDevices      []string
+		IsValid           bool
+	}{
+		{
+			InputName:         "",
+			InputArtifactName: "",
+			InputDevices:      nil,
+			IsValid:           false,
+		},
+		{
+			InputName:         "f826484e-1157-4109-af21-304e6d711560",
+			InputArtifactName: "f826484e-1157-4109-af21-304e6d711560",
+			InputDevices:      []string{"f826484e-1157-4109-af21-304e6d711560"},
+			IsValid:           true,
+		},
+	}
+
+	for i, test := range testCases {
+		t.Run(fmt.Sprintf("test #%d", i), func(t *testing.T) {
+			pub := &DeploymentConstructor{}
+			pub.Name = test.InputName
+			pub.ArtifactName = test.InputArtifactName
+			pub.Devices = test.InputDevices
+
+			dep, err := NewDeploymentFromConstructor(pub)
+			assert.NoError(t, err)
+
+			err = dep.Validate()
+
+			if !test.IsValid {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+
+}
+
+func TestDeploymentValidateUUIDVersion(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		ID      string
+		IsValid bool
+	}{
+		"ok, v4": {
+			ID:      "f826484e-1157-4109-af21-304e6d711560",
+			IsValid: true,
+		},
+		"error, v1": {
+			ID: "6ba7b810-9dad-11d1-80b4-00c04fd430c8",
+		},
+		"error, v5": {
+			ID: "886313e1-3b8a-5372-9b90-0c9aee199e5d",
+		},
+		"error, nil UUID": {
+			ID: "00000000-0000-0000-0000-000000000000",
+		},
+		"error, v4 not in canonical form": {
+			ID: "{f826484e-1157-4109-af21-304e6d711560}",
+		},
+		"error, not a UUID": {
+			ID: "foo",
+		},
+	}
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.IsValid, IsUUIDv4(tc.ID))
+
+			dep, err := NewDeploymentFromConstructor(&DeploymentConstructor{
+				Name:         "foo",
+				ArtifactName: "bar",
+			})
+			if !assert.NoError(t, err) {
+				return
+			}
+			assert.True(t, IsUUIDv4(dep.Id))
+			dep.Id = tc.ID
+			if tc.IsValid {
+				assert.NoError(t, dep.Validate())
+			} else {
+				assert.Error(t, dep.Validate())
+			}
+		})
+	}
+
+	// simulated deployments have deterministic v5 IDs
+	dep, err := NewDeploymentFromConstructor(&DeploymentConstructor{
+		Name:         "foo",
+		ArtifactName: "bar",
+		DryRun:       true,
+	})
+	if assert.NoError(t, err) {
+		assert.False(t, IsUUIDv4(dep.Id))
+		assert.NoError(t, dep.Validate())
+	}
+}
+
+func TestNewDeploymentIDGenerator(t *testing.T) {
+	// not parallel: replaces the package-level generator
+	const id = "b8ea97d3-9dd8-4b5b-9c4c-5a0b2c3f8c17"
+	defer func(generator func() (string, error)) {
+		IDGenerator = generator
+	}(IDGenerator)
+	IDGenerator = func() (string, error) {
+		return id, nil
+	}
+
+	dep, err := NewDeployment()
+	if assert.NoError(t, err) {
+		assert.Equal(t, id, dep.Id)
+	}
+
+	IDGenerator = func() (string, error) {
+		return "", errors.New("no entropy")
+	}
+	_, err = NewDeployment()
+	assert.EqualError(t, err, "no entropy")
+}
+
+func TestDeploymentMarshalJSON(t *testing.T) {
+
+	t.Parallel()
+
+	dep, err := NewDeployment()
+	assert.NoError(t, err)
+	dep.Name = "Region: NYC"
+	dep.ArtifactName = "App 123"
+	dep.Devices = []string{"Device 123"}
+	dep.Id = "14ddec54-30be-49bf-aa6b-97ce271d71f5"
+	deviceCount := 1337
+	dep.DeviceCount = &deviceCount
+	dep.Status = DeploymentStatusInProgress
+	dep.Statistics = DeploymentStatistics{
+		TotalSize: 10,
+	}
+	dep.Stats = NewStats(map[DeviceDeploymentStatus]int{
+		DeviceDeploymentStatusSuccess: 1,
+	})
+
+	j, err := dep.MarshalJSON()
+	assert.NoError(t, err)
+
+	// date format may be slightly different on different platforms
+	expectedJSON := `
+	{
+		"name":"Region: NYC",
+		"artifact_name":"App 123",
+        "created":"` + dep.Created.Format(time.RFC3339Nano) + `",
+		"id":"14ddec54-30be-49bf-aa6b-97ce271d71f5",
+		"statistics":{"status":{"success":1},"total_size":10},
+		"status":"inprogress",
+		"device_count":1337,
+		"type":"software"
+	}`
+
+	assert.JSONEq(t, expectedJSON, string(j))
+}
+
+func TestDeploymentMarshalJSONUpdatedAt(t *testing.T) {
+	t.Parallel()
+
+	dep, err := NewDeploymentFromConstructor(&DeploymentConstructor{
+		Name:         "foo",
+		ArtifactName: "bar",
+	})
+	if !assert.NoError(t, err) {
+		return
+	}
+	if assert.NotNil(t, dep.UpdatedAt) {
+		assert.Equal(t, *dep.Created, *dep.UpdatedAt)
+		assert.NotSame(t, dep.Created, dep.UpdatedAt)
+	}
+
+	b, err := json.Marshal(dep)
+	if !assert.NoError(t, err) {
+		return
+	}
+	var decoded map[string]interface{}
+	if assert.NoError(t, json.Unmarshal(b, &decoded)) {
+		assert.Contains(t, decoded, "updated_at")
+		assert.Equal(t, decoded["created"], decoded["updated_at"])
+	}
+}
+
+func TestDeploymentMarshalJSONComputedStatus(t *testing.T) {
+	t.Parallel()
+
+	dep, err := NewDeployment()
+	assert.NoError(t, err)
+	dep.Name = "foo"
+	dep.ArtifactName = "bar"
+	deviceCount := 2
+	dep.DeviceCount = &deviceCount
+	dep.Status = DeploymentStatusPending
+	dep.Stats = NewStats(map[DeviceDeploymentStatus]int{
+		DeviceDeploymentStatusDownloading: 1,
+		DeviceDeploymentStatusPending:     1,
+	})
+
+	b, err := json.Marshal(dep)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	var out struct {
+		Status DeploymentStatus `json:"status"`
+	}
+	if !assert.NoError(t, json.Unmarshal(b, &out)) {
+		t.FailNow()
+	}
+	assert.Equal(t, DeploymentStatusInProgress, out.Status)
+	assert.Equal(t, DeploymentStatusPending, dep.Status,
+		"marshalling must not modify the stored status")
+}
+
+func TestDeploymentMarshalJSONSimulatedStatus(t *testing.T) {
+	t.Parallel()
+
+	dep, err := NewDeploymentFromConstructor(&DeploymentConstructor{
+		Name:         "foo",
+		ArtifactName: "bar",
+		Devices:      []string{"b532b01a-9313-404f-8d19-e7fcbe5cc347"},
+		DryRun:       true,
+	})
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Equal(t, DeploymentStatusSimulated, dep.GetStatus())
+
+	b, err := json.Marshal(dep)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	var out struct {
+		Status DeploymentStatus `json:"status"`
+	}
+	if assert.NoError(t, json.Unmarshal(b, &out)) {
+		assert.Equal(t, DeploymentStatusSimulated, out.Status)
+	}
+}
+
+func TestDeploymentMarshalBSON(t *testing.T) {
+	dep, err := NewDeployment()
+	assert.NoError(t, err)
+	dep.Name = "Region: NYC"
+	dep.ArtifactName = "App 123"
+	dep.Devices = []string{"Device 123"}
+	dep.Id = "14ddec54-30be-49bf-aa6b-97ce271d71f5"
+	deviceCount := 1337
+	dep.DeviceCount = &deviceCount
+	dep.Status = DeploymentStatusInProgress
+
+	j, err := dep.MarshalBSON()
+	assert.NoError(t, err)
+
+	deployment := &Deployment{}
+	bson.Unmarshal(j, deployment)
+
+	assert.True(t, deployment.Active)
+}
+
+func TestDeploymentBSONRoundTrip(t *testing.T) {
+	t.Parallel()
+
+	dep, err := NewDeploymentFromConstructor(&DeploymentConstructor{
+		Name:              "Region: NYC",
+		ArtifactName:      "App 123",
+		Devices:           []string{"Device 123"},
+		AllDevices:        true,
+		ForceInstallation: true,
+		Group:             "group",
+		SubgroupNames:     []string{"subgroup"},
+		Tags:              map[string]string{"env": "prod"},
+		DryRun:            true,
+		Comment:           "CHG-1234",
+	})
+	if !assert.NoError(t, err) {
+		return
+	}
+	dep.DeviceList = []string{"Device 123"}
+	dep.Status = DeploymentStatusInProgress
+
+	b, err := bson.Marshal(dep)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	var doc bson.M
+	if !assert.NoError(t, bson.Unmarshal(b, &doc)) {
+		return
+	}
+	assert.NotContains(t, doc, "name", "constructor fields must not be promoted")
+	constructorDoc, ok := doc["deploymentconstructor"].(bson.M)
+	if !assert.True(t, ok) {
+		return
+	}
+	keys := make([]string, 0, len(constructorDoc))
+	for key := range constructorDoc {
+		keys = append(keys, key)
+	}
+	assert.ElementsMatch(t, []string{
+		"name", "artifactname", "force_installation",
+		"tags", "comment",
+	}, keys)
+
+	var res Deployment
+	if !assert.NoError(t, bson.Unmarshal(b, &res)) {
+		return
+	}
+	assert.Equal(t, &DeploymentConstructor{
+		Name:              "Region: NYC",
+		ArtifactName:      "App 123",
+		ForceInstallation: true,
+		Tags:              map[string]string{"env": "prod"},
+		Comment:           "CHG-1234",
+	}, res.DeploymentConstructor)
+	assert.Equal(t, dep.Id, res.Id)
+	assert.Equal(t, dep.DeviceList, res.DeviceList)
+	assert.Equal(t, dep.Status, res.Status)
+	assert.True(t, res.Active)
+	assert.True(t, dep.Created.Truncate(time.Millisecond).Equal(*res.Created))
+}
+
+func TestDeploymentPartialBSON(t *testing.T) {
+	b, err := bson.Marshal(bson.M{
+		"_id":    "14ddec54-30be-49bf-aa6b-97ce271d71f5",
+		"status": DeploymentStatusPending,
+	})
+	assert.NoError(t, err)
+
+	var dep Deployment
+	err = bson.Unmarshal(b, &dep)
+	assert.NoError(t, err)
+
+	assert.NotPanics(t, func() {
+		assert.NotNil(t, dep.DeploymentConstructor)
+		assert.Empty(t, dep.Name)
+		assert.False(t, dep.IsZeroValue())
+		assert.Error(t, dep.Validate(), "created timestamp is missing")
+		_, err = dep.MarshalJSON()
+		assert.NoError(t, err)
+		_, err = dep.MarshalBSON()
+		assert.NoError(t, err)
+		assert.False(t, dep.IsNotPending())
+		assert.False(t, dep.IsFinished())
+		assert.Equal(t, DeploymentStatusPending, dep.GetStatus())
+		assert.NotNil(t, dep.ToConstructor())
+		assert.NotNil(t, dep.ToConstructorWithoutDevices())
+		assert.Nil(t, dep.EstimatedCompletionTime())
+		assert.False(t, dep.HasArtifact("foo"))
+	})
+
+	var zero Deployment
+	assert.True(t, zero.IsZeroValue())
+	assert.NotPanics(t, func() {
+		_, err = zero.MarshalJSON()
+		assert.NoError(t, err)
+	})
+	assert.NotNil(t, zero.DeploymentConstructor)
+}
+
+func TestDeploymentIs(t *testing.T) {
+	d, err := NewDeployment()
+	assert.NoError(t, err)
+	d.MaxDevices = 1
+
+	assert.False(t, d.IsNotPending())
+	assert.False(t, d.IsFinished())
+
+	// check all active statuses
+	active := []DeviceDeploymentStatus{
+		DeviceDeploymentStatusRebooting,
+		DeviceDeploymentStatusInstalling,
+		DeviceDeploymentStatusDownloading,
+		DeviceDeploymentStatusPauseBeforeInstall,
+		DeviceDeploymentStatusPauseBeforeReboot,
+		DeviceDeploymentStatusPauseBeforeCommit,
+	}
+	for _, as := range active {
+		t.Logf("checking in-progress deployment stat %s", as)
+		d.Stats = NewDeviceDeploymentStats()
+		d.Stats.Set(as, 1)
+		assert.True(t, d.IsNotPending())
+		assert.False(t, d.IsFinished())
+	}
+
+	finished := []DeviceDeploymentStatus{
+		DeviceDeploymentStatusSuccess,
+		DeviceDeploymentStatusFailure,
+		DeviceDeploymentStatusNoArtifact,
+		DeviceDeploymentStatusAlreadyInst,
+		DeviceDeploymentStatusAborted,
+	}
+	for _, as := range finished {
+		t.Logf("checking finished deployment stat %s", as)
+		d.Stats = NewDeviceDeploymentStats()
+		d.Stats.Set(as, 1)
+		assert.True(t, d.IsFinished())
+		assert.True(t, d.IsNotPending())
+	}
+
+	pending := []DeviceDeploymentStatus{
+		DeviceDeploymentStatusPending,
+	}
+	for _, as := range pending {
+		t.Logf("checking pending deployment stat %s", as)
+		d.Stats = NewDeviceDeploymentStats()
+		d.Stats.Set(as, 1)
+		assert.False(t, d.IsFinished())
+		assert.False(t, d.IsNotPending())
+	}
+}
+
+func TestDeploymentGetStatus(t *testing.T) {
+
+	tests := map[string]struct {
+		Stats        Stats
+		OutputStatus DeploymentStatus
+	}{
+		"Single NoArtifact": {
+			Stats: NewStats(map[DeviceDeploymentStatus]int{
+				DeviceDeploymentStatusNoArtifact: 1,
+			}),
+			OutputStatus: "finished",
+		},
+		"Single Success": {
+			Stats: NewStats(map[DeviceDeploymentStatus]int{
+				DeviceDeploymentStatusSuccess: 1,
+			}),
+			OutputStatus: "finished",
+		},
+		"Success + NoArtifact": {
+			Stats: NewStats(map[DeviceDeploymentStatus]int{
+				DeviceDeploymentStatusSuccess:    1,
+				DeviceDeploymentStatusNoArtifact: 1,
+			}),
+			OutputStatus: "finished",
+		},
+		"Failed + NoArtifact": {
+			Stats: NewStats(map[DeviceDeploymentStatus]int{
+				DeviceDeploymentStatusFailure:    1,
+				DeviceDeploymentStatusNoArtifact: 1,
+			}),
+			OutputStatus: "finished",
+		},
+		"Failed + AlreadyInst": {
+			Stats: NewStats(map[DeviceDeploymentStatus]int{
+				DeviceDeploymentStatusFailure:     1,
+				DeviceDeploymentStatusAlreadyInst: 1,
+			}),
+			OutputStatus: "finished",
+		},
+		"Failed + Aborted": {
+			Stats: NewStats(map[DeviceDeploymentStatus]int{
+				DeviceDeploymentStatusFailure: 1,
+				DeviceDeploymentStatusAborted: 1,
+			}),
+			OutputStatus: "finished",
+		},
+		"Rebooting + NoArtifact": {
+			Stats: NewStats(map[DeviceDeploymentStatus]int{
+				DeviceDeploymentStatusRebooting:  1,
+				DeviceDeploymentStatusNoArtifact: 1,
+			}),
+			OutputStatus: "inprogress",
+		},
+		"Rebooting + Installing": {
+			Stats: NewStats(map[DeviceDeploymentStatus]int{
+				DeviceDeploymentStatusRebooting:  1,
+				DeviceDeploymentStatusInstalling: 1,
+			}),
+			OutputStatus: "inprogress",
+		},
+		"Rebooting + Pending": {
+			Stats: NewStats(map[DeviceDeploymentStatus]int{
+				DeviceDeploymentStatusRebooting: 1,
+				DeviceDeploymentStatusPending:   1,
+			}),
+			OutputStatus: "inprogress",
+		},
+		"All paused states": {
+			Stats: NewStats(map[DeviceDeploymentStatus]int{
+				DeviceDeploymentStatusPauseBeforeInstall: 1,
+				DeviceDeploymentStatusPauseBeforeCommit:  1,
+				DeviceDeploymentStatusPauseBeforeReboot:  1,
+			}),
+			OutputStatus: "inprogress",
+		},
+		"Some paused states": {
+			Stats: NewStats(map[DeviceDeploymentStatus]int{
+				DeviceDeploymentStatusInstalling:         1,
+				DeviceDeploymentStatusPauseBeforeInstall: 1,
+				DeviceDeploymentStatusPauseBeforeCommit:  0,
+				DeviceDeploymentStatusPauseBeforeReboot:  1,
+			}),
+			OutputStatus: "inprogress",
+		},
+		"Pending": {
+			Stats: NewStats(map[DeviceDeploymentStatus]int{
+				DeviceDeploymentStatusPending: 1,
+			}),
+			OutputStatus: "pending",
+		},
+		"Empty": {
+			OutputStatus: "pending",
+		},
+		//verify we count 'already-installed' towards 'inprogress'
+		"pending + already-installed": {
+			Stats: NewStats(map[DeviceDeploymentStatus]int{
+				DeviceDeploymentStatusPending:     1,
+				DeviceDeploymentStatusAlreadyInst: 1,
+			}),
+			OutputStatus: "inprogress",
+		},
+		//verify we count 'already-installed' towards 'finished'
+		"already-installed + finished": {
+			Stats: NewStats(map[DeviceDeploymentStatus]int{
+				DeviceDeploymentStatusPending:     0,
+				DeviceDeploymentStatusAlreadyInst: 1,
+			}),
+			OutputStatus: "finished",
+		},
+		"aborted + downloading": {
+			Stats: NewStats(map[DeviceDeploymentStatus]int{
+				DeviceDeploymentStatusAborted:     2,
+				DeviceDeploymentStatusDownloading: 1,
+			}),
+			OutputStatus: "cancelling",
+		},
+		"aborted + paused": {
+			Stats: NewStats(map[DeviceDeploymentStatus]int{
+				DeviceDeploymentStatusAborted:           1,
+				DeviceDeploymentStatusPauseBeforeCommit: 1,
+				DeviceDeploymentStatusSuccess:           1,
+			}),
+			OutputStatus: "cancelling",
+		},
+		"aborted + finished": {
+			Stats: NewStats(map[DeviceDeploymentStatus]int{
+				DeviceDeploymentStatusAborted: 2,
+				DeviceDeploymentStatusFailure: 1,
+			}),
+			OutputStatus: "finished",
+		},
+	}
+
+	for name, test := range tests {
+
+		t.Log(name)
+
+		dep, err := NewDeployment()
+		assert.NoError(t, err)
+
+		dep.Stats = test.Stats
+		dep.MaxDevices = dep.Stats.Total()
+
+		assert.Equal(t, test.OutputStatus, dep.GetStatus())
+	}
+
+}
+
+func TestDeploymentScheduled(t *testing.T) {
+
+	t.Parallel()
+
+	past := time.Now().Add(-time.Hour)
+	constructor := &DeploymentConstructor{
+		Name:         "foo",
+		ArtifactName: "bar",
+		AllDevices:   true,
+		ScheduledAt:  &past,
+	}
+	assert.ErrorIs(t, constructor.ValidateNew(), ErrScheduledAtInPast)
+
+	future := time.Now().Add(time.Hour)
+	constructor.ScheduledAt = &future
+	if !assert.NoError(t, constructor.ValidateNew()) {
+		return
+	}
+	dep, err := NewDeploymentFromConstructor(constructor)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, DeploymentStatusScheduled, dep.Status)
+	assert.True(t, dep.IsScheduled())
+	assert.False(t, dep.IsFinished())
+	assert.Equal(t, DeploymentStatusScheduled, dep.GetStatus())
+	assert.False(t, dep.isScheduled(future.Add(time.Minute)))
+
+	assert.NoError(t, dep.SetStatus(DeploymentStatusPending))
+
+	dep.Stats.Set(DeviceDeploymentStatusPending, 1)
+	dep.MaxDevices = 1
+	assert.False(t, dep.IsScheduled(), "a device got the deployment")
+	assert.Equal(t, DeploymentStatusPending, dep.GetStatus())
+
+	dep.Finished = &past
+	assert.Equal(t, DeploymentStatusFinished, dep.GetStatus())
+}
+
+func TestDeploymentEstimatedCompletionTime(t *testing.T) {
+
+	t.Parallel()
+
+	now := time.Now()
+	created := now.Add(-time.Hour)
+
+	testCases := map[string]struct {
+		Stats      Stats
+		MaxDevices int
+		Finished   *time.Time
+		Created    *time.Time
+
+		ETA *time.Time
+	}{
+		"ok": {
+			Stats: NewStats(map[DeviceDeploymentStatus]int{
+				DeviceDeploymentStatusSuccess: 4,
+				DeviceDeploymentStatusFailure: 1,
+				DeviceDeploymentStatusPending: 15,
+			}),
+			MaxDevices: 20,
+			Created:    &created,
+
+			// 12 minutes per device, 15 devices remaining
+			ETA: TimeToPointer(now.Add(3 * time.Hour)),
+		},
+		"ok, exactly 5% of the devices finished": {
+			Stats: NewStats(map[DeviceDeploymentStatus]int{
+				DeviceDeploymentStatusSuccess: 1,
+				DeviceDeploymentStatusPending: 19,
+			}),
+			MaxDevices: 20,
+			Created:    &created,
+
+			ETA: TimeToPointer(now.Add(19 * time.Hour)),
+		},
+		"less than 5% of the devices finished": {
+			Stats: NewStats(map[DeviceDeploymentStatus]int{
+				DeviceDeploymentStatusSuccess: 1,
+				DeviceDeploymentStatusPending: 20,
+			}),
+			MaxDevices: 21,
+			Created:    &created,
+		},
+		"no devices finished": {
+			Stats: NewStats(map[DeviceDeploymentStatus]int{
+				DeviceDeploymentStatusInstalling: 1,
+			}),
+			MaxDevices: 1,
+			Created:    &created,
+		},
+		"deployment finished": {
+			Stats: NewStats(map[DeviceDeploymentStatus]int{
+				DeviceDeploymentStatusSuccess: 1,
+			}),
+			MaxDevices: 1,
+			Created:    &created,
+			Finished:   &now,
+		},
+		"no time elapsed": {
+			Stats: NewStats(map[DeviceDeploymentStatus]int{
+				DeviceDeploymentStatusSuccess: 1,
+				DeviceDeploymentStatusPending: 1,
+			}),
+			MaxDevices: 2,
+			Created:    &now,
+		},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			dep, err := NewDeployment()
+			assert.NoError(t, err)
+			dep.Created = tc.Created
+			dep.Finished = tc.Finished
+			dep.MaxDevices = tc.MaxDevices
+			dep.Stats = tc.Stats
+
+			eta := dep.estimatedCompletionTime(now)
+			if tc.ETA == nil {
+				assert.Nil(t, eta)
+			} else if assert.NotNil(t, eta) {
+				assert.WithinDuration(t, *tc.ETA, *eta, time.Millisecond)
+			}
+		})
+	}
+}
+
+func TestFuzzyGetStatus(t *testing.T) {
+
+	rand := func(min int, max int) int {
+		rand.Seed(time.Now().UTC().UnixNano())
+		return min + rand.Intn(max-min)
+	}
+
+	max := 3
+
+	for i := 0; i < 1000; i++ {
+		dep, err := NewDeployment()
+		assert.NoError(t, err)
+
+		dep.Stats.Set(DeviceDeploymentStatusDownloading, rand(0, max))
+		dep.Stats.Set(DeviceDeploymentStatusInstalling, rand(0, max))
+		dep.Stats.Set(DeviceDeploymentStatusRebooting, rand(0, max))
+		dep.Stats.Set(DeviceDeploymentStatusPending, rand(0, max))
+		dep.Stats.Set(DeviceDeploymentStatusSuccess, rand(0, max))
+		dep.Stats.Set(DeviceDeploymentStatusFailure, rand(0, max))
+		dep.Stats.Set(DeviceDeploymentStatusNoArtifact, rand(0, max))
+		dep.Stats.Set(DeviceDeploymentStatusAlreadyInst, rand(0, max))
+		dep.Stats.Set(DeviceDeploymentStatusAborted, rand(0, max))
+		dep.Stats.Set(DeviceDeploymentStatusDecommissioned, rand(0, max))
+
+		pending := 0
+		inprogress := 0
+		cancelling := 0
+		finished := 0
+
+		if dep.GetStatus() == "pending" {
+			pending++
+		}
+
+		if dep.GetStatus() == "finished" {
+			finished++
+		}
+
+		if dep.GetStatus() == "inprogress" {
+			inprogress++
+		}
+
+		if dep.GetStatus() == "cancelling" {
+			cancelling++
+		}
+
+		exp_stats := pending + inprogress + cancelling + finished
+		assert.Equal(t, 1, exp_stats, dep.Stats)
+	}
+}
+
+func TestDeploymentStatsHistory(t *testing.T) {
+	t.Parallel()
+
+	dep, err := NewDeployment()
+	assert.NoError(t, err)
+
+	stats, ok := dep.StatsAt(time.Now())
+	assert.False(t, ok, "empty history")
+	assert.Zero(t, stats)
+
+	base := time.Date(2023, 1, 1, 12, 0, 0, 0, time.UTC)
+	deviceCount := 10
+	dep.DeviceCount = &deviceCount
+	dep.Stats.Set(DeviceDeploymentStatusPending, 10)
+	dep.AddStatsSnapshot(base)
+
+	dep.Stats.Set(DeviceDeploymentStatusPending, 4)
+	dep.Stats.Set(DeviceDeploymentStatusSuccess, 6)
+	dep.AddStatsSnapshot(base.Add(time.Hour))
+
+	dep.Stats.Set(DeviceDeploymentStatusPending, 0)
+	dep.Stats.Set(DeviceDeploymentStatusSuccess, 10)
+
+	if assert.Len(t, dep.StatsHistory, 2) {
+		assert.Equal(t, 10, dep.StatsHistory[0].DeviceCount)
+		assert.Equal(t, base, dep.StatsHistory[0].Timestamp)
+	}
+
+	stats, ok = dep.StatsAt(base)
+	if assert.True(t, ok, "exact match") {
+		assert.Equal(t, 10, stats.Get(DeviceDeploymentStatusPending))
+		assert.Equal(t, 0, stats.Get(DeviceDeploymentStatusSuccess))
+	}
+	stats, ok = dep.StatsAt(base.Add(time.Hour))
+	if assert.True(t, ok, "exact match") {
+		assert.Equal(t, 4, stats.Get(DeviceDeploymentStatusPending))
+		assert.Equal(t, 6, stats.Get(DeviceDeploymentStatusSuccess))
+	}
+	stats, ok = dep.StatsAt(base.Add(40 * time.Minute))
+	if assert.True(t, ok, "closest") {
+		assert.Equal(t, 4, stats.Get(DeviceDeploymentStatusPending))
+	}
+	stats, ok = dep.StatsAt(base.Add(-time.Hour))
+	if assert.True(t, ok, "before first snapshot") {
+		assert.Equal(t, 10, stats.Get(DeviceDeploymentStatusPending))
+	}
+
+	b, err := bson.Marshal(dep)
+	assert.NoError(t, err)
+	assert.NotContains(t, string(b), "StatsHistory")
+	assert.NotContains(t, string(b), "statshistory")
+}
+
+func TestNewDeploymentFromConstructorDryRun(t *testing.T) {
+	t.Parallel()
+
+	newConstructor := func() *DeploymentConstructor {
+		return &DeploymentConstructor{
+			Name:         "foo",
+			ArtifactName: "bar",
+			Devices:      []string{"b532b01a-9313-404f-8d19-e7fcbe5cc347"},
+			DryRun:       true,
+		}
+	}
+	dep, err := NewDeploymentFromConstructor(newConstructor())
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, DeploymentStatusSimulated, dep.Status)
+	assert.True(t, dep.IsSimulated())
+	assert.NoError(t, is.UUID.Validate(dep.Id))
+
+	other, err := NewDeploymentFromConstructor(newConstructor())
+	assert.NoError(t, err)
+	assert.Equal(t, dep.Id, other.Id)
+
+	constructor := newConstructor()
+	constructor.Group = "group"
+	other, err = NewDeploymentFromConstructor(constructor)
+	assert.NoError(t, err)
+	assert.NotEqual(t, dep.Id, other.Id)
+
+	constructor = newConstructor()
+	constructor.DryRun = false
+	other, err = NewDeploymentFromConstructor(constructor)
+	assert.NoError(t, err)
+	assert.Equal(t, DeploymentStatusPending, other.Status)
+	assert.False(t, other.IsSimulated())
+	assert.NotEqual(t, dep.Id, other.Id)
+}
+
+func TestDeploymentTypeConvenienceMethods(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		Type DeploymentType
+
+		IsSoftware      bool
+		IsConfiguration bool
+	}{
+		"software": {
+			Type:       DeploymentTypeSoftware,
+			IsSoftware: true,
+		},
+		"configuration": {
+			Type:            DeploymentTypeConfiguration,
+			IsConfiguration: true,
+		},
+		"empty (legacy software)": {
+			Type:       "",
+			IsSoftware: true,
+		},
+		"unknown": {
+			Type: "foobar",
+		},
+	}
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			dep := &Deployment{Type: tc.Type}
+			assert.Equal(t, tc.IsSoftware, dep.IsSoftware())
+			assert.Equal(t, tc.IsConfiguration, dep.IsConfiguration())
+		})
+	}
+}
+
+func TestDeploymentString(t *testing.T) {
+	t.Parallel()
+
+	created := time.Date(2023, 4, 5, 6, 7, 8, 0, time.UTC)
+	deviceCount := 3
+	testCases := map[string]struct {
+		Deployment *Deployment
+		Expected   string
+	}{
+		"ok": {
+			Deployment: &Deployment{
+				DeploymentConstructor: &DeploymentConstructor{
+					Name: "production",
+				},
+				Id:          "ec6ccc3b-4e3a-4bdc-9d66-0b6b1d4c9d1f",
+				Status:      DeploymentStatusInProgress,
+				DeviceCount: &deviceCount,
+				MaxDevices:  10,
+				Created:     &created,
+			},
+			Expected: "Deployment{id=ec6ccc3b-4e3a-4bdc-9d66-0b6b1d4c9d1f, " +
+				"name=production, status=inprogress, devices=3/10, " +
+				"created=2023-04-05T06:07:08Z}",
+		},
+		"nil fields": {
+			Deployment: &Deployment{
+				Id:     "ec6ccc3b-4e3a-4bdc-9d66-0b6b1d4c9d1f",
+				Status: DeploymentStatusPending,
+			},
+			Expected: "Deployment{id=ec6ccc3b-4e3a-4bdc-9d66-0b6b1d4c9d1f, " +
+				"name=<nil>, status=pending, devices=<nil>/0, created=<nil>}",
+		},
+		"nil deployment": {
+			Expected: "<nil>",
+		},
+	}
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.Expected, tc.Deployment.String())
+			assert.NotContains(t, tc.Deployment.String(), "\n")
+		})
+	}
+}
+
+func TestDeploymentConfigurationSize(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		Type DeploymentType
+		Size int64
+
+		Error error
+	}{
+		"ok, one byte under the limit": {
+			Type: DeploymentTypeConfiguration,
+			Size: ValidationMaxConfigurationSize - 1,
+		},
+		"ok, at the limit": {
+			Type: DeploymentTypeConfiguration,
+			Size: ValidationMaxConfigurationSize,
+		},
+		"error, one byte over the limit": {
+			Type:  DeploymentTypeConfiguration,
+			Size:  ValidationMaxConfigurationSize + 1,
+			Error: ErrConfigurationTooLarge,
+		},
+		"ok, software deployment": {
+			Type: DeploymentTypeSoftware,
+			Size: ValidationMaxConfigurationSize + 1,
+		},
+	}
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			dep, err := NewDeploymentFromConstructor(&DeploymentConstructor{
+				Name:         "foo",
+				ArtifactName: "bar",
+			})
+			if !assert.NoError(t, err) {
+				return
+			}
+			dep.Type = tc.Type
+			dep.Configuration = make([]byte, tc.Size)
+			assert.Equal(t, tc.Size, dep.ConfigurationSize())
+			assert.Equal(t, tc.Size > ValidationMaxConfigurationSize,
+				dep.IsConfigurationTooLarge(ValidationMaxConfigurationSize))
+			err = dep.Validate()
+			if tc.Error != nil {
+				assert.ErrorIs(t, err, tc.Error)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestDeploymentStatsJSON(t *testing.T) {
+	t.Parallel()
+
+	counts := map[DeviceDeploymentStatus]int{}
+	for i, status := range KnownDeviceDeploymentStatuses() {
+		counts[status] = i + 1
+	}
+	dep := &Deployment{Stats: NewStats(counts)}
+
+	m := dep.StatsJSON()
+	if !assert.Len(t, m, len(counts), "no status may be dropped") {
+		t.FailNow()
+	}
+	for status, count := range counts {
+		assert.Equal(t, count, m[status.String()])
+	}
+
+	var out Deployment
+	if !assert.NoError(t, out.UnmarshalStatsJSON(m)) {
+		t.FailNow()
+	}
+	assert.True(t, dep.Stats.Equal(out.Stats), dep.Stats.Diff(out.Stats))
+
+	testCases := map[string]struct {
+		Stats map[string]int
+		Error string
+	}{
+		"error, unknown status": {
+			Stats: map[string]int{"exploded": 1},
+			Error: `stats: unknown status "exploded"`,
+		},
+		"error, negative count": {
+			Stats: map[string]int{"success": -1},
+			Error: `stats: negative count -1 for status "success"`,
+		},
+	}
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			out := Deployment{Stats: dep.Stats.Copy()}
+			err := out.UnmarshalStatsJSON(tc.Stats)
+			assert.EqualError(t, err, tc.Error)
+			assert.True(t, dep.Stats.Equal(out.Stats))
+		})
+	}
+}
+
+func TestDeploymentForceInstallation(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		Type DeploymentType
+
+		Error error
+	}{
+		"ok, software deployment": {
+			Type: DeploymentTypeSoftware,
+		},
+		"ok, script deployment": {
+			Type: DeploymentTypeScript,
+		},
+		"error, configuration deployment": {
+			Type:  DeploymentTypeConfiguration,
+			Error: ErrForceInstallationConfiguration,
+		},
+		"error, configuration delta deployment": {
+			Type:  DeploymentTypeConfigurationDelta,
+			Error: ErrForceInstallationConfiguration,
+		},
+	}
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			dep, err := NewDeploymentFromConstructor(&DeploymentConstructor{
+				Name:              "foo",
+				ArtifactName:      "bar",
+				ForceInstallation: true,
+			})
+			if !assert.NoError(t, err) {
+				return
+			}
+			dep.Type = tc.Type
+			if tc.Type == DeploymentTypeScript {
+				dep.ScriptPayload = []byte("echo hello")
+			} else if tc.Type == DeploymentTypeConfigurationDelta {
+				dep.BasedOnDeploymentID = "f826484e-1157-4109-af21-304e6d711560"
+			}
+			err = dep.Validate()
+			if tc.Error != nil {
+				assert.ErrorIs(t, err, tc.Error)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestDeploymentAnnotations(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		Annotations map[string]string
+
+		Error error
+	}{
+		"ok": {
+			Annotations: map[string]string{
+				"rollback/source": "f826484e-1157-4109-af21-304e6d711560",
+				"retry/count":     "3",
+			},
+		},
+		"ok, no annotations": {},
+		"error, no domain": {
+			Annotations: map[string]string{"count": "3"},
+			Error:       ErrInvalidAnnotationKey,
+		},
+		"error, empty domain": {
+			Annotations: map[string]string{"/count": "3"},
+			Error:       ErrInvalidAnnotationKey,
+		},
+		"error, empty key": {
+			Annotations: map[string]string{"retry/": "3"},
+			Error:       ErrInvalidAnnotationKey,
+		},
+		"error, nested key": {
+			Annotations: map[string]string{"retry/count/max": "3"},
+			Error:       ErrInvalidAnnotationKey,
+		},
+		"error, dotted key": {
+			Annotations: map[string]string{"retry.count": "3"},
+			Error:       ErrInvalidAnnotationKey,
+		},
+		"error, dollar sign": {
+			Annotations: map[string]string{"retry/$count": "3"},
+			Error:       ErrInvalidAnnotationKey,
+		},
+	}
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			dep, err := NewDeploymentFromConstructor(&DeploymentConstructor{
+				Name:         "foo",
+				ArtifactName: "bar",
+			})
+			if !assert.NoError(t, err) {
+				return
+			}
+			dep.Annotations = tc.Annotations
+			err = dep.Validate()
+			if tc.Error != nil {
+				assert.ErrorIs(t, err, tc.Error)
+				return
+			}
+			assert.NoError(t, err)
+
+			b, err := json.Marshal(dep)
+			if !assert.NoError(t, err) {
+				return
+			}
+			assert.NotContains(t, string(b), "annotations")
+
+			b, err = dep.MarshalWithOptions(WithAnnotations())
+			if !assert.NoError(t, err) {
+				return
+			}
+			var decoded struct {
+				Name        string            `json:"name"`
+				Annotations map[string]string `json:"annotations"`
+			}
+			if assert.NoError(t, json.Unmarshal(b, &decoded)) {
+				assert.Equal(t, "foo", decoded.Name)
+				assert.Equal(t, tc.Annotations, decoded.Annotations)
+			}
+
+			clone, err := dep.Clone()
+			if assert.NoError(t, err) {
+				assert.Nil(t, clone.Annotations)
+			}
+		})
+	}
+}
+
+func TestDeploymentBootstrap(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		Type         DeploymentType
+		BootstrapTTL time.Duration
+		Age          time.Duration
+		Stats        map[DeviceDeploymentStatus]int
+
+		Error    error
+		Finished bool
+	}{
+		"ok, not expired": {
+			Type:         DeploymentTypeBootstrap,
+			BootstrapTTL: time.Hour,
+			Age:          time.Minute,
+			Stats:        map[DeviceDeploymentStatus]int{DeviceDeploymentStatusPending: 1},
+		},
+		"ok, expired": {
+			Type:         DeploymentTypeBootstrap,
+			BootstrapTTL: time.Hour,
+			Age:          2 * time.Hour,
+			Stats:        map[DeviceDeploymentStatus]int{DeviceDeploymentStatusPending: 1},
+
+			Finished: true,
+		},
+		"ok, no TTL": {
+			Type:  DeploymentTypeBootstrap,
+			Age:   24 * time.Hour,
+			Stats: map[DeviceDeploymentStatus]int{DeviceDeploymentStatusPending: 1},
+		},
+		"ok, all devices finished": {
+			Type:         DeploymentTypeBootstrap,
+			BootstrapTTL: time.Hour,
+			Stats:        map[DeviceDeploymentStatus]int{DeviceDeploymentStatusSuccess: 1},
+
+			Finished: true,
+		},
+		"error, negative TTL": {
+			Type:         DeploymentTypeBootstrap,
+			BootstrapTTL: -time.Hour,
+
+			Error: ErrBootstrapTTLNegative,
+		},
+		"error, software deployment": {
+			Type:         DeploymentTypeSoftware,
+			BootstrapTTL: time.Hour,
+			Age:          2 * time.Hour,
+			Stats:        map[DeviceDeploymentStatus]int{DeviceDeploymentStatusPending: 1},
+
+			Error: ErrBootstrapTTLNotBootstrap,
+		},
+	}
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			dep, err := NewDeploymentFromConstructor(&DeploymentConstructor{
+				Name:         "foo",
+				ArtifactName: "bar",
+			})
+			if !assert.NoError(t, err) {
+				return
+			}
+			dep.Type = tc.Type
+			dep.BootstrapTTL = tc.BootstrapTTL
+			created := time.Now().Add(-tc.Age)
+			dep.Created = &created
+			dep.MaxDevices = 1
+			dep.Stats = NewStats(tc.Stats)
+
+			err = dep.Validate()
+			if tc.Error != nil {
+				assert.ErrorIs(t, err, tc.Error)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.Finished, dep.IsFinished())
+
+			b, err := json.Marshal(dep)
+			if !assert.NoError(t, err) {
+				return
+			}
+			var decoded map[string]interface{}
+			if !assert.NoError(t, json.Unmarshal(b, &decoded)) {
+				return
+			}
+			assert.Equal(t, string(DeploymentTypeBootstrap), decoded["type"])
+		})
+	}
+}
+
+func TestDeploymentConfigurationDelta(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		Type                DeploymentType
+		BasedOnDeploymentID string
+
+		Invalid bool
+		Error   error
+	}{
+		"ok": {
+			Type:                DeploymentTypeConfigurationDelta,
+			BasedOnDeploymentID: "f826484e-1157-4109-af21-304e6d711560",
+		},
+		"ok, configuration": {
+			Type: DeploymentTypeConfiguration,
+		},
+		"error, missing base deployment": {
+			Type:  DeploymentTypeConfigurationDelta,
+			Error: ErrBasedOnDeploymentMissing,
+		},
+		"error, invalid base deployment": {
+			Type:                DeploymentTypeConfigurationDelta,
+			BasedOnDeploymentID: "foo",
+			Invalid:             true,
+		},
+		"error, base deployment for configuration": {
+			Type:                DeploymentTypeConfiguration,
+			BasedOnDeploymentID: "f826484e-1157-4109-af21-304e6d711560",
+			Error:               ErrBasedOnDeploymentNotDelta,
+		},
+	}
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			dep, err := NewDeploymentFromConstructor(&DeploymentConstructor{
+				Name:                "foo",
+				ArtifactName:        "bar",
+				BasedOnDeploymentID: tc.BasedOnDeploymentID,
+			})
+			if !assert.NoError(t, err) {
+				return
+			}
+			dep.Type = tc.Type
+			err = dep.Validate()
+			if tc.Error != nil {
+				assert.ErrorIs(t, err, tc.Error)
+				return
+			} else if tc.Invalid {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+
+			b, err := json.Marshal(dep)
+			if assert.NoError(t, err) {
+				var res map[string]interface{}
+				assert.NoError(t, json.Unmarshal(b, &res))
+				assert.Equal(t, string(tc.Type), res["type"])
+			}
+		})
+	}
+}
+
+func TestQueryValidate(t *testing.T) {
+	t.Parallel()
+
+	active, inactive := true, false
+	testCases := map[string]struct {
+		IsActive *bool
+		Status   StatusQuery
+		Tags     map[string]string
+		DeviceID string
+
+		ArtifactName string
+		SearchText   string
+
+		Statuses []DeploymentStatus
+		Error    error
+	}{
+		"ok, no filter": {},
+		"ok, active": {
+			IsActive: &active,
+			Statuses: []DeploymentStatus{
+				DeploymentStatusScheduled,
+				DeploymentStatusPending,
+				DeploymentStatusInProgress,
+				DeploymentStatusCancelling,
+			},
+		},
+		"ok, inactive": {
+			IsActive: &inactive,
+			Statuses: []DeploymentStatus{DeploymentStatusFinished},
+		},
+		"ok, status only": {
+			Status: StatusQueryPending,
+		},
+		"error, active with status": {
+			IsActive: &active,
+			Status:   StatusQueryInProgress,
+			Error:    ErrQueryIsActiveWithStatus,
+		},
+		"error, inactive with status": {
+			IsActive: &inactive,
+			Status:   StatusQueryFinished,
+			Error:    ErrQueryIsActiveWithStatus,
+		},
+		"ok, tags": {
+			Tags: map[string]string{"env": "production"},
+		},
+		"error, invalid tag key": {
+			Tags:  map[string]string{"a.b": "production"},
+			Error: ErrInvalidTagKey,
+		},
+		"ok, device ID": {
+			DeviceID: "b532b01a-9313-404f-8d19-e7fcbe5cc399",
+		},
+		"error, device ID not a UUID": {
+			DeviceID: "not-a-uuid",
+			Error:    ErrQueryInvalidDeviceID,
+		},
+		"ok, artifact name": {
+			ArtifactName: "release-1.0",
+		},
+		"error, artifact name with search text": {
+			ArtifactName: "release-1.0",
+			SearchText:   "release",
+			Error:        ErrQueryArtifactNameWithSearchText,
+		},
+	}
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			q := Query{
+				IsActive: tc.IsActive,
+				Status:   tc.Status,
+				Tags:     tc.Tags,
+				DeviceID: tc.DeviceID,
+
+				ArtifactName: tc.ArtifactName,
+				SearchText:   tc.SearchText,
+			}
+			err := q.Validate()
+			if tc.Error != nil {
+				assert.ErrorIs(t, err, tc.Error)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tc.Statuses, q.IsActiveStatuses())
+			}
+		})
+	}
+}
+
+func TestQueryValidateCursor(t *testing.T) {
+	t.Parallel()
+
+	const cursor = "f826484e-1157-4109-af21-304e6d711560"
+	testCases := map[string]struct {
+		Query Query
+
+		Error error
+	}{
+		"ok, after": {
+			Query: Query{AfterID: cursor, Limit: 10},
+		},
+		"ok, before ascending": {
+			Query: Query{BeforeID: cursor, Sort: SortDirectionAscending},
+		},
+		"error, not an ID": {
+			Query: Query{AfterID: "not-an-id"},
+			Error: ErrQueryInvalidCursor,
+		},
+		"error, after and before": {
+			Query: Query{AfterID: cursor, BeforeID: cursor},
+			Error: ErrQueryCursorExclusive,
+		},
+		"error, with skip": {
+			Query: Query{AfterID: cursor, Skip: 10},
+			Error: ErrQueryCursorWithSkip,
+		},
+		"error, with sort fields": {
+			Query: Query{
+				BeforeID: cursor,
+				SortFields: []QuerySortField{
+					{Field: SortFieldName, Direction: SortOrderAscending},
+				},
+			},
+			Error: ErrQueryCursorWithSortFields,
+		},
+	}
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			assert.True(t, tc.Query.IsCursor())
+			err := tc.Query.Validate()
+			if tc.Error != nil {
+				assert.ErrorIs(t, err, tc.Error)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestQuerySortFields(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		Query Query
+
+		SortFields []QuerySortField
+		Error      error
+	}{
+		"ok, default": {
+			SortFields: []QuerySortField{
+				{Field: SortFieldCreated, Direction: SortOrderDescending},
+			},
+		},
+		"ok, legacy ascending": {
+			Query: Query{Sort: SortDirectionAscending},
+			SortFields: []QuerySortField{
+				{Field: SortFieldCreated, Direction: SortOrderAscending},
+			},
+		},
+		"ok, sort fields": {
+			Query: Query{
+				Sort: SortDirectionAscending,
+				SortFields: []QuerySortField{
+					{Field: SortFieldName, Direction: SortOrderAscending},
+					{Field: SortFieldDeviceCount, Direction: SortOrderDescending},
+				},
+			},
+			SortFields: []QuerySortField{
+				{Field: SortFieldName, Direction: SortOrderAscending},
+				{Field: SortFieldDeviceCount, Direction: SortOrderDescending},
+			},
+		},
+		"error, unknown field": {
+			Query: Query{
+				SortFields: []QuerySortField{
+					{Field: SortFieldPriority, Direction: SortOrderAscending},
+				},
+			},
+			Error: ErrQueryInvalidSortField,
+		},
+		"error, unknown direction": {
+			Query: Query{
+				SortFields: []QuerySortField{
+					{Field: SortFieldFinished, Direction: "up"},
+				},
+			},
+			Error: ErrQueryInvalidSortField,
+		},
+	}
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			err := tc.Query.Validate()
+			if tc.Error != nil {
+				assert.ErrorIs(t, err, tc.Error)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tc.SortFields, tc.Query.GetSortFields())
+			}
+		})
+	}
+}
+
+func TestQueryFilterPagination(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now()
+	active := true
+	query := Query{
+		IDs:          []string{"foo"},
+		SearchText:   "bar",
+		ArtifactName: "artifact",
+		Type:         DeploymentTypeConfiguration,
+		Status:       StatusQueryInProgress,
+		IsActive:     &active,
+		Tags:         map[string]string{"env": "prod"},
+		DeviceID:     "device",
+		CreatedAfter: &now,
+		UpdatedAfter: &now,
+		Limit:        10,
+		Skip:         20,
+		Sort:         SortDirectionAscending,
+		SortFields: []QuerySortField{
+			{Field: SortFieldName, Direction: SortOrderAscending},
+		},
+		DisableCount: true,
+	}
+
+	assert.Equal(t, DeploymentFilter{
+		IDs:          []string{"foo"},
+		SearchText:   "bar",
+		ArtifactName: "artifact",
+		Type:         DeploymentTypeConfiguration,
+		Status:       StatusQueryInProgress,
+		IsActive:     &active,
+		Tags:         map[string]string{"env": "prod"},
+		DeviceID:     "device",
+		CreatedAfter: &now,
+		UpdatedAfter: &now,
+	}, query.Filter())
+	assert.Equal(t, Pagination{
+		Limit: 10,
+		Skip:  20,
+		Sort:  SortDirectionAscending,
+		SortFields: []QuerySortField{
+			{Field: SortFieldName, Direction: SortOrderAscending},
+		},
+	}, query.Pagination())
+
+	assert.Equal(t, query.IsActiveStatuses(), query.Filter().IsActiveStatuses())
+	assert.Equal(t, query.GetSortFields(), query.Pagination().GetSortFields())
+	assert.False(t, query.Pagination().IsCursor())
+	assert.True(t, Query{AfterID: "foo"}.Pagination().IsCursor())
+}
+
+func TestDeploymentScript(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		Type          DeploymentType
+		ScriptPayload []byte
+		Script        *DeploymentScript
+
+		Error error
+	}{
+		"ok, script deployment": {
+			Type:          DeploymentTypeScript,
+			ScriptPayload: []byte("echo hello"),
+			Script: &DeploymentScript{
+				Command:        "/bin/sh",
+				Args:           []string{"-e"},
+				TimeoutSeconds: 60,
+				RunAsUser:      "root",
+			},
+		},
+		"ok, software deployment": {
+			Type: DeploymentTypeSoftware,
+		},
+		"error, script deployment without payload": {
+			Type:  DeploymentTypeScript,
+			Error: ErrScriptPayloadMissing,
+		},
+		"error, payload in software deployment": {
+			Type:          DeploymentTypeSoftware,
+			ScriptPayload: []byte("echo hello"),
+			Error:         ErrScriptPayloadNotScript,
+		},
+		"error, payload in configuration deployment": {
+			Type:          DeploymentTypeConfiguration,
+			ScriptPayload: []byte("echo hello"),
+			Error:         ErrScriptPayloadNotScript,
+		},
+		"error, script without command": {
+			Type:          DeploymentTypeScript,
+			ScriptPayload: []byte("echo hello"),
+			Script:        &DeploymentScript{TimeoutSeconds: 60},
+		},
+		"error, negative timeout": {
+			Type:          DeploymentTypeScript,
+			ScriptPayload: []byte("echo hello"),
+			Script: &DeploymentScript{
+				Command:        "/bin/sh",
+				TimeoutSeconds: -1,
+			},
+		},
+	}
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			dep, err := NewDeploymentFromConstructor(&DeploymentConstructor{
+				Name:         "foo",
+				ArtifactName: "bar",
+			})
+			if !assert.NoError(t, err) {
+				return
+			}
+			dep.Type = tc.Type
+			dep.ScriptPayload = tc.ScriptPayload
+			dep.Script = tc.Script
+			assert.Equal(t, tc.Type == DeploymentTypeScript, dep.IsScript())
+
+			err = dep.Validate()
+			if tc.Error != nil {
+				assert.ErrorIs(t, err, tc.Error)
+			} else if strings.HasPrefix(name, "error") {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestDeploymentScriptMarshalJSON(t *testing.T) {
+	t.Parallel()
+
+	dep, err := NewDeploymentFromConstructor(&DeploymentConstructor{
+		Name:         "foo",
+		ArtifactName: "bar",
+	})
+	if !assert.NoError(t, err) {
+		return
+	}
+	dep.Type = DeploymentTypeScript
+	dep.ScriptPayload = []byte("echo hello")
+	dep.Script = &DeploymentScript{Command: "/bin/sh", TimeoutSeconds: 30}
+
+	b, err := json.Marshal(dep)
+	if !assert.NoError(t, err) {
+		return
+	}
+	var res map[string]interface{}
+	if !assert.NoError(t, json.Unmarshal(b, &res)) {
+		return
+	}
+	assert.Equal(t, "script", res["type"])
+	assert.Equal(t, "echo hello", res["script_payload"])
+	assert.Equal(t, map[string]interface{}{
+		"command":         "/bin/sh",
+		"timeout_seconds": float64(30),
+	}, res["script"])
+
+	dep.Type = DeploymentTypeSoftware
+	dep.ScriptPayload = nil
+	dep.Script = nil
+	b, err = json.Marshal(dep)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.NotContains(t, string(b), "script")
+}
+
+func TestKnownDeploymentTypes(t *testing.T) {
+	t.Parallel()
+
+	for _, typ := range KnownDeploymentTypes() {
+		assert.NoError(t, typ.Validate())
+	}
+	assert.Error(t, DeploymentType("rollback").Validate())
+}
+
+func TestDeploymentSetStatus(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		From  DeploymentStatus
+		To    DeploymentStatus
+		Stats Stats
+
+		IsValid bool
+	}{
+		"ok, pending to inprogress": {
+			From:    DeploymentStatusPending,
+			To:      DeploymentStatusInProgress,
+			IsValid: true,
+		},
+		"ok, inprogress to finished": {
+			From:    DeploymentStatusInProgress,
+			To:      DeploymentStatusFinished,
+			IsValid: true,
+		},
+		"error, pending to finished": {
+			From: DeploymentStatusPending,
+			To:   DeploymentStatusFinished,
+		},
+		"error, finished to pending": {
+			From: DeploymentStatusFinished,
+			To:   DeploymentStatusPending,
+		},
+		"error, finished to inprogress": {
+			From: DeploymentStatusFinished,
+			To:   DeploymentStatusInProgress,
+		},
+		"error, inprogress to pending": {
+			From: DeploymentStatusInProgress,
+			To:   DeploymentStatusPending,
+		},
+		"error, same status": {
+			From: DeploymentStatusPending,
+			To:   DeploymentStatusPending,
+		},
+		"error, unknown status": {
+			From: DeploymentStatusPending,
+			To:   "paused",
+		},
+		"ok, inprogress to cancelling": {
+			From:    DeploymentStatusInProgress,
+			To:      DeploymentStatusCancelling,
+			IsValid: true,
+		},
+		"ok, cancelling to finished": {
+			From:    DeploymentStatusCancelling,
+			To:      DeploymentStatusFinished,
+			Stats:   NewStats(map[DeviceDeploymentStatus]int{DeviceDeploymentStatusAborted: 2}),
+			IsValid: true,
+		},
+		"error, cancelling to finished with active devices": {
+			From: DeploymentStatusCancelling,
+			To:   DeploymentStatusFinished,
+			Stats: NewStats(map[DeviceDeploymentStatus]int{
+				DeviceDeploymentStatusAborted:    1,
+				DeviceDeploymentStatusInstalling: 1,
+			}),
+		},
+		"error, cancelling to inprogress": {
+			From: DeploymentStatusCancelling,
+			To:   DeploymentStatusInProgress,
+		},
+	}
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			dep := &Deployment{
+				Status:     tc.From,
+				Stats:      tc.Stats,
+				MaxDevices: tc.Stats.Total(),
+			}
+			err := dep.SetStatus(tc.To)
+			if tc.IsValid {
+				assert.NoError(t, err)
+				assert.Equal(t, tc.To, dep.Status)
+			} else {
+				assert.Equal(t, ErrInvalidStatusTransition{
+					From: tc.From,
+					To:   tc.To,
+				}, err)
+				assert.Equal(t, tc.From, dep.Status)
+				assert.Contains(t, err.Error(), string(tc.From))
+			}
+		})
+	}
+}
+
+func TestDeploymentToGroupConstructor(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		JSON string
+
+		Error error
+	}{
+		"ok": {
+			JSON: `{"name": "foo", "artifact_name": "bar", "group": "baz"}`,
+		},
+		"error, missing group": {
+			JSON: `{"name": "foo", "artifact_name": "bar"}`,
+
+			Error: ErrInvalidDeploymentToGroupDefinitionNoGroup,
+		},
+		"error, group with all devices": {
+			JSON: `{"name": "foo", "artifact_name": "bar", "group": "baz",
+				"all_devices": true}`,
+
+			Error: ErrInvalidDeploymentToGroupDefinitionConflict,
+		},
+		"error, empty constructor": {
+			JSON: `{}`,
+
+			Error: ErrInvalidDeploymentDefinition,
+		},
+	}
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			var constructor DeploymentToGroupConstructor
+			if !assert.NoError(t, json.Unmarshal([]byte(tc.JSON), &constructor)) {
+				t.FailNow()
+			}
+			err := constructor.Validate()
+			if tc.Error != nil {
+				assert.ErrorIs(t, err, tc.Error)
+				return
+			}
+			if !assert.NoError(t, err) {
+				t.FailNow()
+			}
+			assert.Equal(t, "baz", constructor.DeploymentConstructor.Group)
+
+			b, err := json.Marshal(constructor)
+			if assert.NoError(t, err) {
+				var fields map[string]interface{}
+				_ = json.Unmarshal(b, &fields)
+				assert.Equal(t, "baz", fields["group"])
+			}
+		})
 	}
 }
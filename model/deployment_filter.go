@@ -0,0 +1,138 @@
+// Copyright 2023 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model
+
+import "time"
+
+// DeploymentFilter holds the criteria matching the deployments of a Query,
+// regardless of their order and of the page returned.
+type DeploymentFilter struct {
+	// list of IDs
+	IDs []string
+
+	// match deployments by text by looking at deployment name and artifact name
+	SearchText string
+	// ArtifactName matches the deployments of exactly the given artifact
+	ArtifactName string
+
+	// deployment type
+	Type DeploymentType
+
+	// deployment status
+	Status StatusQuery
+	// IsActive, if set, matches the deployments which are (true) or are
+	// not (false) finished
+	IsActive *bool
+	// HasComment, if set, matches the deployments with (true) or
+	// without (false) a comment
+	HasComment *bool
+	// Tags matches the deployments having all the given tags
+	Tags map[string]string
+	// DeviceID matches the deployments the given device is part of
+	DeviceID string
+
+	// only match deployments between timestamp range
+	CreatedAfter  *time.Time
+	CreatedBefore *time.Time
+	// only match deployments last updated in the timestamp range
+	UpdatedAfter  *time.Time
+	UpdatedBefore *time.Time
+}
+
+// IsActiveStatuses returns the deployment statuses matched by the IsActive
+// filter, or nil if the filter is not set. Aborted deployments are
+// cancelling until they finish, so the inactive deployments are the
+// finished ones.
+func (f DeploymentFilter) IsActiveStatuses() []DeploymentStatus {
+	if f.IsActive == nil {
+		return nil
+	} else if *f.IsActive {
+		return []DeploymentStatus{
+			DeploymentStatusScheduled,
+			DeploymentStatusPending,
+			DeploymentStatusInProgress,
+			DeploymentStatusCancelling,
+		}
+	}
+	return []DeploymentStatus{DeploymentStatusFinished}
+}
+
+// Pagination holds the order of the deployments of a Query and the page
+// of deployments returned.
+type Pagination struct {
+	Limit int
+	Skip  int
+	// AfterID and BeforeID select the page with a cursor instead of Skip,
+	// see Query.
+	AfterID  string
+	BeforeID string
+
+	// sort values by creation date; ignored if SortFields is set
+	Sort string
+	// SortFields sorts the values by each of the fields in turn
+	SortFields []QuerySortField
+}
+
+// IsCursor returns true if the page is selected with a cursor (AfterID or
+// BeforeID) rather than with Skip.
+func (p Pagination) IsCursor() bool {
+	return p.AfterID != "" || p.BeforeID != ""
+}
+
+// GetSortFields returns the sort criteria: SortFields if set, otherwise the
+// creation date in the direction of the legacy Sort field (descending by
+// default).
+func (p Pagination) GetSortFields() []QuerySortField {
+	if len(p.SortFields) > 0 {
+		return p.SortFields
+	}
+	direction := SortOrderDescending
+	if p.Sort == SortDirectionAscending {
+		direction = SortOrderAscending
+	}
+	return []QuerySortField{{Field: SortFieldCreated, Direction: direction}}
+}
+
+// Filter returns the criteria matching the deployments of the query.
+func (q Query) Filter() DeploymentFilter {
+	return DeploymentFilter{
+		IDs:           q.IDs,
+		SearchText:    q.SearchText,
+		ArtifactName:  q.ArtifactName,
+		Type:          q.Type,
+		Status:        q.Status,
+		IsActive:      q.IsActive,
+		HasComment:    q.HasComment,
+		Tags:          q.Tags,
+		DeviceID:      q.DeviceID,
+		CreatedAfter:  q.CreatedAfter,
+		CreatedBefore: q.CreatedBefore,
+		UpdatedAfter:  q.UpdatedAfter,
+		UpdatedBefore: q.UpdatedBefore,
+	}
+}
+
+// Pagination returns the order and the page of the deployments of the
+// query.
+func (q Query) Pagination() Pagination {
+	return Pagination{
+		Limit:      q.Limit,
+		Skip:       q.Skip,
+		AfterID:    q.AfterID,
+		BeforeID:   q.BeforeID,
+		Sort:       q.Sort,
+		SortFields: q.SortFields,
+	}
+}
@@ -0,0 +1,365 @@
+// Copyright 2023 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model
+
+import (
+	"sort"
+	"strings"
+	"time"
+)
+
+// DeploymentList is a list of deployments. The methods on DeploymentList
+// never modify the receiver; they return a new list sharing the deployments.
+type DeploymentList []*Deployment
+
+func timeOrZero(t *time.Time) time.Time {
+	if t != nil {
+		return *t
+	}
+	return time.Time{}
+}
+
+// lastUpdated returns the time of the last recorded activity on the
+// deployment.
+func (d *Deployment) lastUpdated() time.Time {
+	if d.UpdatedAt != nil {
+		return *d.UpdatedAt
+	}
+	return timeOrZero(d.Created)
+}
+
+func (l DeploymentList) head(n int) DeploymentList {
+	if n < len(l) {
+		return l[:n:n]
+	}
+	return l
+}
+
+// MostRecentlyUpdated returns the n deployments with the most recent
+// activity, most recent first.
+func (l DeploymentList) MostRecentlyUpdated(n int) DeploymentList {
+	if n <= 0 || len(l) == 0 {
+		return nil
+	}
+	res := make(DeploymentList, 0, len(l))
+	for _, d := range l {
+		if d != nil {
+			res = append(res, d)
+		}
+	}
+	sort.SliceStable(res, func(i, j int) bool {
+		return res[i].lastUpdated().After(res[j].lastUpdated())
+	})
+	return res.head(n)
+}
+
+// OldestPending returns the n oldest deployments in pending status, oldest
+// first.
+func (l DeploymentList) OldestPending(n int) DeploymentList {
+	if n <= 0 {
+		return nil
+	}
+	res := l.filter(func(d *Deployment) bool {
+		return d.Status == DeploymentStatusPending
+	})
+	sort.SliceStable(res, func(i, j int) bool {
+		return timeOrZero(res[i].Created).Before(timeOrZero(res[j].Created))
+	})
+	return res.head(n)
+}
+
+// SortField identifies the field used by DeploymentList.SortBy.
+type SortField string
+
+const (
+	SortFieldCreated      SortField = "created"
+	SortFieldPriority     SortField = "priority"
+	SortFieldStatus       SortField = "status"
+	SortFieldName         SortField = "name"
+	SortFieldArtifactName SortField = "artifact_name"
+	SortFieldFinished     SortField = "finished"
+	SortFieldDeviceCount  SortField = "device_count"
+)
+
+// SortOrder is the direction used by DeploymentList.SortBy.
+type SortOrder string
+
+const (
+	SortOrderAscending  SortOrder = SortDirectionAscending
+	SortOrderDescending SortOrder = SortDirectionDescending
+)
+
+// sortStable returns a copy of the list without nil entries sorted by less,
+// keeping the original order of the equal elements.
+func (l DeploymentList) sortStable(less func(a, b *Deployment) bool) DeploymentList {
+	res := l.filter(func(*Deployment) bool { return true })
+	sort.SliceStable(res, func(i, j int) bool {
+		return less(res[i], res[j])
+	})
+	return res
+}
+
+// SortByPriority returns the deployments sorted by priority; deployments
+// with the same priority keep their order.
+func (l DeploymentList) SortByPriority(ascending bool) DeploymentList {
+	return l.sortStable(func(a, b *Deployment) bool {
+		if ascending {
+			return a.Priority < b.Priority
+		}
+		return a.Priority > b.Priority
+	})
+}
+
+// statusOrder is the canonical order of the deployment statuses; unknown
+// statuses come last.
+var statusOrder = map[DeploymentStatus]int{
+	DeploymentStatusScheduled:  0,
+	DeploymentStatusPending:    1,
+	DeploymentStatusInProgress: 2,
+	DeploymentStatusCancelling: 3,
+	DeploymentStatusFinished:   4,
+}
+
+func statusRank(status DeploymentStatus) int {
+	if rank, ok := statusOrder[status]; ok {
+		return rank
+	}
+	return len(statusOrder)
+}
+
+// SortByStatus returns the deployments sorted by status: scheduled first,
+// then pending, then in progress, then finished (including aborted).
+func (l DeploymentList) SortByStatus() DeploymentList {
+	return l.sortByStatus(true)
+}
+
+func (l DeploymentList) sortByStatus(ascending bool) DeploymentList {
+	return l.sortStable(func(a, b *Deployment) bool {
+		if ascending {
+			return statusRank(a.Status) < statusRank(b.Status)
+		}
+		return statusRank(a.Status) > statusRank(b.Status)
+	})
+}
+
+// SortByCreated returns the deployments sorted by creation time.
+func (l DeploymentList) SortByCreated(ascending bool) DeploymentList {
+	return l.sortStable(func(a, b *Deployment) bool {
+		if ascending {
+			return timeOrZero(a.Created).Before(timeOrZero(b.Created))
+		}
+		return timeOrZero(a.Created).After(timeOrZero(b.Created))
+	})
+}
+
+// SortByFinished returns the deployments sorted by finish time; the
+// unfinished deployments sort as the zero time.
+func (l DeploymentList) SortByFinished(ascending bool) DeploymentList {
+	return l.sortStable(func(a, b *Deployment) bool {
+		if ascending {
+			return timeOrZero(a.Finished).Before(timeOrZero(b.Finished))
+		}
+		return timeOrZero(a.Finished).After(timeOrZero(b.Finished))
+	})
+}
+
+// SortByDeviceCount returns the deployments sorted by device count; an
+// unset device count sorts as zero.
+func (l DeploymentList) SortByDeviceCount(ascending bool) DeploymentList {
+	deviceCount := func(d *Deployment) int {
+		if d.DeviceCount != nil {
+			return *d.DeviceCount
+		}
+		return 0
+	}
+	return l.sortStable(func(a, b *Deployment) bool {
+		if ascending {
+			return deviceCount(a) < deviceCount(b)
+		}
+		return deviceCount(a) > deviceCount(b)
+	})
+}
+
+// sortByConstructor sorts the deployments by the constructor field returned
+// by key; the deployments without a constructor sort as the empty string.
+func (l DeploymentList) sortByConstructor(
+	ascending bool,
+	key func(*DeploymentConstructor) string,
+) DeploymentList {
+	value := func(d *Deployment) string {
+		if d.DeploymentConstructor != nil {
+			return key(d.DeploymentConstructor)
+		}
+		return ""
+	}
+	return l.sortStable(func(a, b *Deployment) bool {
+		if ascending {
+			return value(a) < value(b)
+		}
+		return value(a) > value(b)
+	})
+}
+
+// SortBy returns the deployments sorted by the given field and order. The
+// order of the deployments is kept for unknown fields.
+func (l DeploymentList) SortBy(field SortField, order SortOrder) DeploymentList {
+	ascending := order != SortOrderDescending
+	switch field {
+	case SortFieldCreated:
+		return l.SortByCreated(ascending)
+	case SortFieldPriority:
+		return l.SortByPriority(ascending)
+	case SortFieldStatus:
+		return l.sortByStatus(ascending)
+	case SortFieldName:
+		return l.sortByConstructor(ascending, func(c *DeploymentConstructor) string {
+			return c.Name
+		})
+	case SortFieldArtifactName:
+		return l.sortByConstructor(ascending, func(c *DeploymentConstructor) string {
+			return c.ArtifactName
+		})
+	case SortFieldFinished:
+		return l.SortByFinished(ascending)
+	case SortFieldDeviceCount:
+		return l.SortByDeviceCount(ascending)
+	default:
+		return l.sortStable(func(*Deployment, *Deployment) bool { return false })
+	}
+}
+
+// filter returns the deployments for which match returns true.
+func (l DeploymentList) filter(match func(*Deployment) bool) DeploymentList {
+	var res DeploymentList
+	for _, d := range l {
+		if d != nil && match(d) {
+			res = append(res, d)
+		}
+	}
+	return res
+}
+
+// FindByArtifactName returns the deployments targeting the artifact with
+// the given name (case-sensitive).
+func (l DeploymentList) FindByArtifactName(name string) DeploymentList {
+	return l.filter(func(d *Deployment) bool {
+		for _, artifactName := range d.AllArtifactNames() {
+			if artifactName == name {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// FindByArtifactNamePrefix returns the deployments targeting an artifact
+// with a name starting with prefix (case-sensitive).
+func (l DeploymentList) FindByArtifactNamePrefix(prefix string) DeploymentList {
+	return l.filter(func(d *Deployment) bool {
+		for _, artifactName := range d.AllArtifactNames() {
+			if strings.HasPrefix(artifactName, prefix) {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// FindByDeviceID returns the deployments including the device.
+func (l DeploymentList) FindByDeviceID(deviceID string) DeploymentList {
+	return l.filter(func(d *Deployment) bool {
+		return d.hasDevice(deviceID)
+	})
+}
+
+func (d *Deployment) hasDevice(deviceID string) bool {
+	for _, id := range d.DeviceList {
+		if id == deviceID {
+			return true
+		}
+	}
+	return false
+}
+
+// targetsDevice returns true if the device is in the list of devices of the
+// deployment or if the deployment targets all the devices.
+func (d *Deployment) targetsDevice(deviceID string) bool {
+	return (d.DeploymentConstructor != nil && d.AllDevices) ||
+		d.hasDevice(deviceID)
+}
+
+// IntersectByDeviceID returns the deployments targeting the device, either
+// explicitly or through AllDevices. The device lists are scanned linearly,
+// so the cost is O(n*m) for n deployments of m devices: use it for small
+// in-memory lists only.
+func (l DeploymentList) IntersectByDeviceID(deviceID string) DeploymentList {
+	return l.filter(func(d *Deployment) bool {
+		return d.targetsDevice(deviceID)
+	})
+}
+
+// ExcludeDeviceID returns the deployments not targeting the device; it is
+// the complement of IntersectByDeviceID, with the same O(n*m) cost.
+func (l DeploymentList) ExcludeDeviceID(deviceID string) DeploymentList {
+	return l.filter(func(d *Deployment) bool {
+		return !d.targetsDevice(deviceID)
+	})
+}
+
+// GroupByStatus returns the deployments grouped by their current status as
+// computed by Deployment.GetStatus.
+func (l DeploymentList) GroupByStatus() map[DeploymentStatus]DeploymentList {
+	groups := make(map[DeploymentStatus]DeploymentList)
+	for _, d := range l {
+		if d != nil {
+			status := d.GetStatus()
+			groups[status] = append(groups[status], d)
+		}
+	}
+	return groups
+}
+
+// GroupByType returns the deployments grouped by type. Deployments without
+// a type are software deployments.
+func (l DeploymentList) GroupByType() map[DeploymentType]DeploymentList {
+	groups := make(map[DeploymentType]DeploymentList)
+	for _, d := range l {
+		if d != nil {
+			typ := d.Type
+			if d.IsSoftware() {
+				typ = DeploymentTypeSoftware
+			}
+			groups[typ] = append(groups[typ], d)
+		}
+	}
+	return groups
+}
+
+// Partition splits the deployments into those for which predicate returns
+// true and the others, preserving their order.
+func (l DeploymentList) Partition(
+	predicate func(*Deployment) bool,
+) (matched, unmatched DeploymentList) {
+	for _, d := range l {
+		if d == nil {
+			continue
+		} else if predicate(d) {
+			matched = append(matched, d)
+		} else {
+			unmatched = append(unmatched, d)
+		}
+	}
+	return matched, unmatched
+}
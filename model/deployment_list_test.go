@@ -0,0 +1,361 @@
+// Copyright 2023 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	. "github.com/mendersoftware/deployments/utils/pointers"
+)
+
+func newTestDeploymentList() DeploymentList {
+	now := time.Now()
+	return DeploymentList{
+		{Id: "1", Status: DeploymentStatusFinished,
+			Created: TimeToPointer(now.Add(-4 * time.Hour))},
+		{Id: "2", Status: DeploymentStatusPending,
+			Created: TimeToPointer(now.Add(-1 * time.Hour))},
+		{Id: "3", Status: DeploymentStatusInProgress,
+			Created: TimeToPointer(now.Add(-2 * time.Hour))},
+		{Id: "4", Status: DeploymentStatusPending,
+			Created: TimeToPointer(now.Add(-3 * time.Hour))},
+		{Id: "5", Status: DeploymentStatusPending},
+		nil,
+	}
+}
+
+func deploymentListIDs(l DeploymentList) []string {
+	ids := make([]string, len(l))
+	for i, d := range l {
+		ids[i] = d.Id
+	}
+	return ids
+}
+
+func TestDeploymentListMostRecentlyUpdated(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		N   int
+		IDs []string
+	}{
+		"top 2":        {N: 2, IDs: []string{"2", "3"}},
+		"all":          {N: 10, IDs: []string{"2", "3", "4", "1", "5"}},
+		"zero":         {N: 0, IDs: []string{}},
+		"negative":     {N: -1, IDs: []string{}},
+		"exact length": {N: 5, IDs: []string{"2", "3", "4", "1", "5"}},
+	}
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			l := newTestDeploymentList()
+			res := l.MostRecentlyUpdated(tc.N)
+			assert.Equal(t, tc.IDs, deploymentListIDs(res))
+			assert.Equal(t, "1", l[0].Id, "receiver must not be modified")
+		})
+	}
+}
+
+func TestDeploymentListMostRecentlyUpdatedAt(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now()
+	l := DeploymentList{
+		{Id: "1", Created: TimeToPointer(now.Add(-1 * time.Hour))},
+		{Id: "2", Created: TimeToPointer(now.Add(-3 * time.Hour)),
+			UpdatedAt: TimeToPointer(now.Add(-30 * time.Minute))},
+		{Id: "3", Created: TimeToPointer(now.Add(-2 * time.Hour)),
+			UpdatedAt: TimeToPointer(now.Add(-2 * time.Hour))},
+	}
+	assert.Equal(t, []string{"2", "1", "3"},
+		deploymentListIDs(l.MostRecentlyUpdated(3)))
+}
+
+func TestDeploymentListOldestPending(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		N   int
+		IDs []string
+	}{
+		"oldest":   {N: 1, IDs: []string{"5"}},
+		"all":      {N: 10, IDs: []string{"5", "4", "2"}},
+		"zero":     {N: 0, IDs: []string{}},
+		"negative": {N: -1, IDs: []string{}},
+	}
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			l := newTestDeploymentList()
+			res := l.OldestPending(tc.N)
+			assert.Equal(t, tc.IDs, deploymentListIDs(res))
+			assert.Equal(t, "1", l[0].Id, "receiver must not be modified")
+		})
+	}
+
+	assert.Empty(t, DeploymentList(nil).OldestPending(1))
+	assert.Empty(t, DeploymentList(nil).MostRecentlyUpdated(1))
+}
+
+func TestDeploymentListFind(t *testing.T) {
+	t.Parallel()
+
+	l := DeploymentList{
+		{Id: "1", DeploymentConstructor: &DeploymentConstructor{
+			ArtifactName: "release-1.0"},
+			DeviceList: []string{"dev-a", "dev-b"}},
+		{Id: "2", DeploymentConstructor: &DeploymentConstructor{
+			ArtifactName: "release-1.1"},
+			DeviceList: []string{"dev-b"}},
+		{Id: "3", DeploymentConstructor: &DeploymentConstructor{
+			ArtifactName: "Release-1.0"},
+			DeviceList: []string{"dev-c"}},
+		{Id: "4"},
+		nil,
+	}
+
+	assert.Equal(t, []string{"1"},
+		deploymentListIDs(l.FindByArtifactName("release-1.0")))
+	assert.Empty(t, l.FindByArtifactName("release"))
+	assert.Equal(t, []string{"1", "2"},
+		deploymentListIDs(l.FindByArtifactNamePrefix("release-1")))
+	assert.Equal(t, []string{"1", "2", "3"},
+		deploymentListIDs(l.FindByArtifactNamePrefix("")))
+
+	assert.Equal(t, []string{"1", "2"},
+		deploymentListIDs(l.FindByDeviceID("dev-b")))
+	assert.Equal(t, []string{"3"},
+		deploymentListIDs(l.FindByDeviceID("dev-c")))
+	assert.Empty(t, l.FindByDeviceID("dev-d"))
+}
+
+func TestDeploymentListSort(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now()
+	deviceCount := func(n int) *int { return &n }
+	l := DeploymentList{
+		{Id: "1", Priority: 1, Status: DeploymentStatusFinished,
+			Created:  TimeToPointer(now.Add(-3 * time.Hour)),
+			Finished: TimeToPointer(now.Add(-1 * time.Hour)),
+			DeploymentConstructor: &DeploymentConstructor{
+				Name: "charlie", ArtifactName: "release-2"},
+			DeviceCount: deviceCount(20)},
+		{Id: "2", Priority: 5, Status: DeploymentStatusPending,
+			Created: TimeToPointer(now.Add(-1 * time.Hour)),
+			DeploymentConstructor: &DeploymentConstructor{
+				Name: "alpha", ArtifactName: "release-3"},
+			DeviceCount: deviceCount(5)},
+		{Id: "3", Priority: 1, Status: DeploymentStatusInProgress,
+			Created:  TimeToPointer(now.Add(-2 * time.Hour)),
+			Finished: TimeToPointer(now.Add(-30 * time.Minute)),
+			DeploymentConstructor: &DeploymentConstructor{
+				Name: "bravo", ArtifactName: "release-1"},
+			DeviceCount: deviceCount(10)},
+		nil,
+		{Id: "4", Priority: 3, Status: DeploymentStatusPending,
+			Created: TimeToPointer(now.Add(-4 * time.Hour))},
+	}
+
+	testCases := map[string]struct {
+		Field SortField
+		Order SortOrder
+
+		IDs []string
+	}{
+		"priority ascending": {
+			Field: SortFieldPriority,
+			Order: SortOrderAscending,
+			IDs:   []string{"1", "3", "4", "2"},
+		},
+		"priority descending": {
+			Field: SortFieldPriority,
+			Order: SortOrderDescending,
+			IDs:   []string{"2", "4", "1", "3"},
+		},
+		"status ascending": {
+			Field: SortFieldStatus,
+			Order: SortOrderAscending,
+			IDs:   []string{"2", "4", "3", "1"},
+		},
+		"status descending": {
+			Field: SortFieldStatus,
+			Order: SortOrderDescending,
+			IDs:   []string{"1", "3", "2", "4"},
+		},
+		"created ascending": {
+			Field: SortFieldCreated,
+			Order: SortOrderAscending,
+			IDs:   []string{"4", "1", "3", "2"},
+		},
+		"created descending": {
+			Field: SortFieldCreated,
+			Order: SortOrderDescending,
+			IDs:   []string{"2", "3", "1", "4"},
+		},
+		"name ascending": {
+			Field: SortFieldName,
+			Order: SortOrderAscending,
+			IDs:   []string{"4", "2", "3", "1"},
+		},
+		"name descending": {
+			Field: SortFieldName,
+			Order: SortOrderDescending,
+			IDs:   []string{"1", "3", "2", "4"},
+		},
+		"artifact name ascending": {
+			Field: SortFieldArtifactName,
+			Order: SortOrderAscending,
+			IDs:   []string{"4", "3", "1", "2"},
+		},
+		"artifact name descending": {
+			Field: SortFieldArtifactName,
+			Order: SortOrderDescending,
+			IDs:   []string{"2", "1", "3", "4"},
+		},
+		"finished ascending": {
+			Field: SortFieldFinished,
+			Order: SortOrderAscending,
+			IDs:   []string{"2", "4", "1", "3"},
+		},
+		"finished descending": {
+			Field: SortFieldFinished,
+			Order: SortOrderDescending,
+			IDs:   []string{"3", "1", "2", "4"},
+		},
+		"device count ascending": {
+			Field: SortFieldDeviceCount,
+			Order: SortOrderAscending,
+			IDs:   []string{"4", "2", "3", "1"},
+		},
+		"device count descending": {
+			Field: SortFieldDeviceCount,
+			Order: SortOrderDescending,
+			IDs:   []string{"1", "3", "2", "4"},
+		},
+		"unknown field": {
+			Field: "foo",
+			Order: SortOrderAscending,
+			IDs:   []string{"1", "2", "3", "4"},
+		},
+	}
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			res := l.SortBy(tc.Field, tc.Order)
+			assert.Equal(t, tc.IDs, deploymentListIDs(res))
+			assert.Equal(t, "1", l[0].Id, "receiver must not be modified")
+		})
+	}
+
+	assert.Equal(t, []string{"1", "3", "4", "2"},
+		deploymentListIDs(l.SortByPriority(true)))
+	assert.Equal(t, []string{"2", "4", "3", "1"},
+		deploymentListIDs(l.SortByStatus()))
+	assert.Empty(t, DeploymentList(nil).SortByStatus())
+}
+
+func TestDeploymentListGroupBy(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now()
+	pending := NewDeviceDeploymentStats()
+	pending.Set(DeviceDeploymentStatusPending, 1)
+	inProgress := NewDeviceDeploymentStats()
+	inProgress.Set(DeviceDeploymentStatusDownloading, 1)
+
+	l := DeploymentList{
+		{Id: "1", Stats: pending},
+		{Id: "2", Stats: inProgress, Type: DeploymentTypeConfiguration},
+		{Id: "3", Finished: &now, Type: DeploymentTypeSoftware},
+		nil,
+		{Id: "4", Stats: pending, Type: DeploymentTypeConfiguration},
+	}
+
+	byStatus := l.GroupByStatus()
+	assert.Len(t, byStatus, 3)
+	assert.Equal(t, []string{"1", "4"},
+		deploymentListIDs(byStatus[DeploymentStatusPending]))
+	assert.Equal(t, []string{"2"},
+		deploymentListIDs(byStatus[DeploymentStatusInProgress]))
+	assert.Equal(t, []string{"3"},
+		deploymentListIDs(byStatus[DeploymentStatusFinished]))
+
+	byType := l.GroupByType()
+	assert.Len(t, byType, 2)
+	assert.Equal(t, []string{"1", "3"},
+		deploymentListIDs(byType[DeploymentTypeSoftware]))
+	assert.Equal(t, []string{"2", "4"},
+		deploymentListIDs(byType[DeploymentTypeConfiguration]))
+
+	// all deployments with the same status
+	samePending := DeploymentList{l[0], l[4]}
+	assert.Equal(t, map[DeploymentStatus]DeploymentList{
+		DeploymentStatusPending: samePending,
+	}, samePending.GroupByStatus())
+
+	// empty input
+	assert.Empty(t, DeploymentList(nil).GroupByStatus())
+	assert.Empty(t, DeploymentList{}.GroupByType())
+}
+
+func TestDeploymentListPartition(t *testing.T) {
+	t.Parallel()
+
+	l := newTestDeploymentList()
+	matched, unmatched := l.Partition(func(d *Deployment) bool {
+		return d.Status == DeploymentStatusPending
+	})
+	assert.Equal(t, []string{"2", "4", "5"}, deploymentListIDs(matched))
+	assert.Equal(t, []string{"1", "3"}, deploymentListIDs(unmatched))
+
+	matched, unmatched = l.Partition(func(*Deployment) bool { return true })
+	assert.Len(t, matched, 5)
+	assert.Empty(t, unmatched)
+
+	matched, unmatched = DeploymentList(nil).Partition(
+		func(*Deployment) bool { return true })
+	assert.Empty(t, matched)
+	assert.Empty(t, unmatched)
+}
+
+func TestDeploymentListIntersectByDeviceID(t *testing.T) {
+	t.Parallel()
+
+	l := DeploymentList{
+		{Id: "1", DeviceList: []string{"dev-a", "dev-b"}},
+		{Id: "2", DeviceList: []string{"dev-b"}},
+		{Id: "3", DeploymentConstructor: &DeploymentConstructor{
+			AllDevices: true,
+		}},
+		{Id: "4"},
+		nil,
+	}
+
+	assert.Equal(t, []string{"1", "3"},
+		deploymentListIDs(l.IntersectByDeviceID("dev-a")))
+	assert.Equal(t, []string{"2", "4"},
+		deploymentListIDs(l.ExcludeDeviceID("dev-a")))
+	assert.Equal(t, []string{"1", "2", "3"},
+		deploymentListIDs(l.IntersectByDeviceID("dev-b")))
+	assert.Equal(t, []string{"3"},
+		deploymentListIDs(l.IntersectByDeviceID("dev-c")))
+	assert.Equal(t, []string{"1", "2", "4"},
+		deploymentListIDs(l.ExcludeDeviceID("dev-c")))
+	assert.Empty(t, DeploymentList(nil).IntersectByDeviceID("dev-a"))
+}
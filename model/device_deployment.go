@@ -16,18 +16,28 @@ package model
 
 import (
 	"encoding/json"
+	"fmt"
+	"html/template"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	validation "github.com/go-ozzo/ozzo-validation/v4"
 	"github.com/go-ozzo/ozzo-validation/v4/is"
 	"github.com/google/uuid"
 	"github.com/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson"
 )
 
 var (
 	ErrDeviceDeploymentStatusMismatch = errors.New(
 		"model active state does not match status",
 	)
+	ErrStatsNegativeCount = errors.New(
+		"cannot decrement a status counter below zero",
+	)
 )
 
 // DeviceDeploymentStatus is an enumerated type showing the status of a device within a deployment
@@ -108,6 +118,14 @@ var allStatuses = []DeviceDeploymentStatus{
 	// DeviceDeploymentStatusNew
 }
 
+// KnownDeviceDeploymentStatuses returns all the valid device deployment
+// statuses.
+func KnownDeviceDeploymentStatuses() []DeviceDeploymentStatus {
+	statuses := make([]DeviceDeploymentStatus, len(allStatuses))
+	copy(statuses, allStatuses)
+	return statuses
+}
+
 func (stat DeviceDeploymentStatus) MarshalText() ([]byte, error) {
 	switch stat {
 	case DeviceDeploymentStatusFailure:
@@ -292,38 +310,424 @@ func (d DeviceDeployment) Validate() error {
 	return nil
 }
 
-// Deployment statistics wrapper, each value carries a count of deployments
-// aggregated by state.
-type Stats map[string]int
+// Stats holds the number of device deployments in each status. The counters
+// can only be accessed through the methods; the zero value holds no
+// counters and is ready to use. A missing counter reads as zero, so stats
+// without counters and stats with all counters at zero are Equal. Stats is
+// serialized (both to JSON and BSON) as a flat document mapping the status
+// names to the counts.
+type Stats struct {
+	counts map[DeviceDeploymentStatus]int
+}
+
+// NewStats returns stats holding a copy of the given counters.
+func NewStats(counts map[DeviceDeploymentStatus]int) Stats {
+	if len(counts) == 0 {
+		return Stats{}
+	}
+	s := Stats{counts: make(map[DeviceDeploymentStatus]int, len(counts))}
+	for status, count := range counts {
+		s.counts[status] = count
+	}
+	return s
+}
 
 func NewDeviceDeploymentStats() Stats {
 
-	s := make(Stats, len(allStatuses))
+	s := Stats{counts: make(map[DeviceDeploymentStatus]int, len(allStatuses))}
 
 	// populate statuses with 0s
 	for _, k := range allStatuses {
-		s[k.String()] = 0
+		s.counts[k] = 0
 	}
 
 	return s
 }
 
-func (s Stats) Set(status DeviceDeploymentStatus, count int) {
-	key := status.String()
-	s[key] = count
+func (s *Stats) Set(status DeviceDeploymentStatus, count int) {
+	if s.counts == nil {
+		s.counts = make(map[DeviceDeploymentStatus]int)
+	}
+	s.counts[status] = count
 }
 
-func (s Stats) Inc(status DeviceDeploymentStatus) {
-	var count int
-	key := status.String()
-	count = s[key]
-	count++
-	s[key] = count
+func (s *Stats) Inc(status DeviceDeploymentStatus) {
+	s.Set(status, s.Get(status)+1)
+}
+
+// Dec decreases the counter for the given status; it returns
+// ErrStatsNegativeCount if the counter is already zero.
+func (s *Stats) Dec(status DeviceDeploymentStatus) error {
+	count := s.Get(status)
+	if count <= 0 {
+		return ErrStatsNegativeCount
+	}
+	s.Set(status, count-1)
+	return nil
 }
 
 func (s Stats) Get(status DeviceDeploymentStatus) int {
-	key := status.String()
-	return s[key]
+	return s.counts[status]
+}
+
+// Has returns true if the stats hold a counter (possibly zero) for the
+// given status.
+func (s Stats) Has(status DeviceDeploymentStatus) bool {
+	_, ok := s.counts[status]
+	return ok
+}
+
+// Len returns the number of counters held by the stats.
+func (s Stats) Len() int {
+	return len(s.counts)
+}
+
+// Total returns the sum of all the counters.
+func (s Stats) Total() int {
+	var total int
+	for _, count := range s.counts {
+		total += count
+	}
+	return total
+}
+
+// finishedCount returns the number of devices in a terminal status.
+func (s Stats) finishedCount() int {
+	return s.Get(DeviceDeploymentStatusAlreadyInst) +
+		s.Get(DeviceDeploymentStatusSuccess) +
+		s.Get(DeviceDeploymentStatusFailure) +
+		s.Get(DeviceDeploymentStatusNoArtifact) +
+		s.Get(DeviceDeploymentStatusDecommissioned) +
+		s.Get(DeviceDeploymentStatusAborted)
+}
+
+// percentOf returns count as a percentage of total clamped to [0, 100].
+func percentOf(count, total int) float64 {
+	if total <= 0 || count <= 0 {
+		return 0.0
+	} else if count >= total {
+		return 100.0
+	}
+	return float64(count) * 100.0 / float64(total)
+}
+
+// PctComplete returns the percentage of the maxDevices devices that reached
+// a terminal status.
+func (s Stats) PctComplete(maxDevices int) float64 {
+	return percentOf(s.finishedCount(), maxDevices)
+}
+
+// PctSuccess returns the percentage of the maxDevices devices that were
+// successfully updated (including the ones that already had the artifact
+// installed).
+func (s Stats) PctSuccess(maxDevices int) float64 {
+	return percentOf(
+		s.Get(DeviceDeploymentStatusSuccess)+
+			s.Get(DeviceDeploymentStatusAlreadyInst),
+		maxDevices,
+	)
+}
+
+// PctFailure returns the percentage of the maxDevices devices that failed
+// to update.
+func (s Stats) PctFailure(maxDevices int) float64 {
+	return percentOf(s.Get(DeviceDeploymentStatusFailure), maxDevices)
+}
+
+// toMap returns the counters keyed by the status names.
+func (s Stats) toMap() map[string]int {
+	m := make(map[string]int, len(s.counts))
+	for status, count := range s.counts {
+		m[status.String()] = count
+	}
+	return m
+}
+
+// StatsFromJSON decodes stats from a JSON object mapping the status names to
+// the device counts. Unknown statuses and negative or non-integer counts are
+// rejected. A JSON null yields empty stats.
+func StatsFromJSON(data []byte) (Stats, error) {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return Stats{}, errors.Wrap(err, "stats: invalid JSON object")
+	}
+	var stats Stats
+	for key, value := range raw {
+		var status DeviceDeploymentStatus
+		if err := status.UnmarshalText([]byte(key)); err != nil {
+			return Stats{}, errors.Errorf("stats: unknown status %q", key)
+		}
+		var count int
+		if err := json.Unmarshal(value, &count); err != nil {
+			return Stats{}, errors.Errorf(
+				"stats: invalid count %s for status %q", value, key)
+		} else if count < 0 {
+			return Stats{}, errors.Errorf(
+				"stats: negative count %d for status %q", count, key)
+		}
+		stats.Set(status, count)
+	}
+	return stats, nil
+}
+
+// statsFromMap builds stats from the counters keyed by the status names.
+// Unknown statuses and negative counts are rejected.
+func statsFromMap(m map[string]int) (Stats, error) {
+	var stats Stats
+	for key, count := range m {
+		var status DeviceDeploymentStatus
+		if err := status.UnmarshalText([]byte(key)); err != nil {
+			return Stats{}, errors.Errorf("stats: unknown status %q", key)
+		} else if count < 0 {
+			return Stats{}, errors.Errorf(
+				"stats: negative count %d for status %q", count, key)
+		}
+		stats.Set(status, count)
+	}
+	return stats, nil
+}
+
+func (s Stats) MarshalJSON() ([]byte, error) {
+	return json.Marshal(s.toMap())
+}
+
+func (s *Stats) UnmarshalJSON(b []byte) error {
+	stats, err := StatsFromJSON(b)
+	if err != nil {
+		return err
+	}
+	*s = stats
+	return nil
+}
+
+func (s Stats) MarshalBSON() ([]byte, error) {
+	return bson.Marshal(s.toMap())
+}
+
+// UnmarshalBSON decodes the stats from a flat document; a null value yields
+// empty stats. Unlike StatsFromJSON, the statuses unknown to this version
+// are skipped so that the stored deployments always decode, e.g. after a
+// downgrade.
+func (s *Stats) UnmarshalBSON(b []byte) error {
+	var raw map[string]int
+	if len(b) > 0 {
+		if err := bson.Unmarshal(b, &raw); err != nil {
+			return errors.Wrap(err, "stats: invalid BSON document")
+		}
+	}
+	var stats Stats
+	for key, count := range raw {
+		var status DeviceDeploymentStatus
+		if err := status.UnmarshalText([]byte(key)); err != nil {
+			continue
+		}
+		stats.Set(status, count)
+	}
+	*s = stats
+	return nil
+}
+
+// Copy returns a copy of the stats that does not share memory with s.
+func (s Stats) Copy() Stats {
+	return NewStats(s.counts)
+}
+
+// Equal returns true if both stats hold the same counters; a missing
+// counter is equivalent to a zero counter.
+func (s Stats) Equal(other Stats) bool {
+	for status, count := range s.counts {
+		if other.Get(status) != count {
+			return false
+		}
+	}
+	for status, count := range other.counts {
+		if s.Get(status) != count {
+			return false
+		}
+	}
+	return true
+}
+
+// Diff returns a human readable description of the differences between the
+// two stats (sorted by status name), or an empty string if they are equal.
+func (s Stats) Diff(other Stats) string {
+	var diff []string
+	for _, status := range canonicalStatuses {
+		if s.Get(status) != other.Get(status) {
+			diff = append(diff, fmt.Sprintf("%s: %d != %d",
+				status, s.Get(status), other.Get(status)))
+		}
+	}
+	return strings.Join(diff, ", ")
+}
+
+// SyncStats wraps Stats for concurrent use; it is safe to use the methods
+// from multiple goroutines.
+type SyncStats struct {
+	mu    sync.RWMutex
+	inner Stats
+}
+
+// NewSyncStats returns a concurrent-safe copy of stats.
+func NewSyncStats(stats Stats) *SyncStats {
+	return &SyncStats{inner: stats.Copy()}
+}
+
+func (s *SyncStats) Get(status DeviceDeploymentStatus) int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.inner.Get(status)
+}
+
+func (s *SyncStats) Set(status DeviceDeploymentStatus, n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.inner.Set(status, n)
+}
+
+func (s *SyncStats) Increment(status DeviceDeploymentStatus) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.inner.Inc(status)
+}
+
+// Decrement decreases the counter for the given status; it returns
+// ErrStatsNegativeCount if the counter is already zero.
+func (s *SyncStats) Decrement(status DeviceDeploymentStatus) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.inner.Dec(status)
+}
+
+// Stats returns a snapshot copy of the underlying stats.
+func (s *SyncStats) Stats() Stats {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.inner.Copy()
+}
+
+// canonicalStatuses holds all the known statuses sorted alphabetically by
+// name; it defines the iteration order of Stats.
+var canonicalStatuses = func() []DeviceDeploymentStatus {
+	statuses := KnownDeviceDeploymentStatuses()
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].String() < statuses[j].String()
+	})
+	return statuses
+}()
+
+// ForEachStatus calls fn for each status present in the stats in a stable
+// order (alphabetical by status name). Unknown keys are skipped.
+func (s Stats) ForEachStatus(fn func(DeviceDeploymentStatus, int)) {
+	for _, status := range canonicalStatuses {
+		if count, ok := s.counts[status]; ok {
+			fn(status, count)
+		}
+	}
+}
+
+// Statuses returns the statuses present in the stats in the same order as
+// ForEachStatus.
+func (s Stats) Statuses() []DeviceDeploymentStatus {
+	statuses := make([]DeviceDeploymentStatus, 0, len(s.counts))
+	s.ForEachStatus(func(status DeviceDeploymentStatus, _ int) {
+		statuses = append(statuses, status)
+	})
+	return statuses
+}
+
+// ToPrometheusLabels returns the non-zero counters as a map from the status
+// name to the formatted count.
+func (s Stats) ToPrometheusLabels() map[string]string {
+	labels := make(map[string]string, len(s.counts))
+	s.ForEachStatus(func(status DeviceDeploymentStatus, count int) {
+		if count != 0 {
+			labels[status.String()] = strconv.Itoa(count)
+		}
+	})
+	return labels
+}
+
+// GaugeSpec describes a single labeled gauge sample.
+type GaugeSpec struct {
+	Name   string
+	Labels map[string]string
+	Value  float64
+}
+
+// AsLabeledGauges returns one gauge named "<prefix>_device_status_total"
+// per non-zero counter, labeled with the device deployment status.
+func (s Stats) AsLabeledGauges(prefix string) []GaugeSpec {
+	name := "device_status_total"
+	if prefix != "" {
+		name = prefix + "_" + name
+	}
+	gauges := make([]GaugeSpec, 0, len(s.counts))
+	s.ForEachStatus(func(status DeviceDeploymentStatus, count int) {
+		if count != 0 {
+			gauges = append(gauges, GaugeSpec{
+				Name:   name,
+				Labels: map[string]string{"status": status.String()},
+				Value:  float64(count),
+			})
+		}
+	})
+	return gauges
+}
+
+// statusAbbreviations holds the symbols used by Stats.SummaryString.
+var statusAbbreviations = map[DeviceDeploymentStatus]string{
+	DeviceDeploymentStatusFailure:            "✗",
+	DeviceDeploymentStatusAborted:            "⊘",
+	DeviceDeploymentStatusPauseBeforeInstall: "⏸i",
+	DeviceDeploymentStatusPauseBeforeCommit:  "⏸c",
+	DeviceDeploymentStatusPauseBeforeReboot:  "⏸r",
+	DeviceDeploymentStatusDownloading:        "↻",
+	DeviceDeploymentStatusInstalling:         "⚙",
+	DeviceDeploymentStatusRebooting:          "⟳",
+	DeviceDeploymentStatusPending:            "…",
+	DeviceDeploymentStatusSuccess:            "✓",
+	DeviceDeploymentStatusNoArtifact:         "∅",
+	DeviceDeploymentStatusAlreadyInst:        "≡",
+	DeviceDeploymentStatusDecommissioned:     "⌫",
+}
+
+// SummaryString returns the non-zero counters in a compact form, e.g.
+// "2✗ 3… 5✓", ordered by status name.
+func (s Stats) SummaryString() string {
+	var parts []string
+	s.ForEachStatus(func(status DeviceDeploymentStatus, count int) {
+		if count != 0 {
+			parts = append(parts,
+				strconv.Itoa(count)+statusAbbreviations[status])
+		}
+	})
+	return strings.Join(parts, " ")
+}
+
+// VerboseString returns all the counters, including those which are zero
+// or missing, ordered by status name.
+func (s Stats) VerboseString() string {
+	parts := make([]string, len(canonicalStatuses))
+	for i, status := range canonicalStatuses {
+		parts[i] = fmt.Sprintf("%s=%d", status, s.Get(status))
+	}
+	return strings.Join(parts, " ")
+}
+
+// HTMLSummary returns one <span> element per non-zero counter, ordered by
+// status name. The elements have the "status-<name>" class to allow
+// styling each status.
+func (s Stats) HTMLSummary() template.HTML {
+	var b strings.Builder
+	s.ForEachStatus(func(status DeviceDeploymentStatus, count int) {
+		if count != 0 {
+			name := template.HTMLEscapeString(status.String())
+			fmt.Fprintf(&b, `<span class="status-%s" title="%s">%d%s</span>`,
+				name, name, count, statusAbbreviations[status])
+		}
+	})
+	return template.HTML(b.String())
 }
 
 func IsDeviceDeploymentStatusFinished(status DeviceDeploymentStatus) bool {
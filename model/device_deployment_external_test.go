@@ -15,11 +15,16 @@
 package model
 
 import (
+	"encoding/json"
+	"html/template"
+	"math"
 	"strconv"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
 
 	. "github.com/mendersoftware/deployments/utils/pointers"
 )
@@ -130,19 +135,391 @@ func TestDeviceDeploymentValidate(t *testing.T) {
 
 func TestDeviceDeploymentStats(t *testing.T) {
 	ds := NewDeviceDeploymentStats()
-	must := []string{
-		DeviceDeploymentStatusNoArtifactStr,
-		DeviceDeploymentStatusFailureStr,
-		DeviceDeploymentStatusSuccessStr,
-		DeviceDeploymentStatusPendingStr,
-		DeviceDeploymentStatusRebootingStr,
-		DeviceDeploymentStatusInstallingStr,
-		DeviceDeploymentStatusDownloadingStr,
-		DeviceDeploymentStatusAlreadyInstStr,
-		DeviceDeploymentStatusAbortedStr,
+	must := []DeviceDeploymentStatus{
+		DeviceDeploymentStatusNoArtifact,
+		DeviceDeploymentStatusFailure,
+		DeviceDeploymentStatusSuccess,
+		DeviceDeploymentStatusPending,
+		DeviceDeploymentStatusRebooting,
+		DeviceDeploymentStatusInstalling,
+		DeviceDeploymentStatusDownloading,
+		DeviceDeploymentStatusAlreadyInst,
+		DeviceDeploymentStatusAborted,
+		DeviceDeploymentStatusPauseBeforeInstall,
+		DeviceDeploymentStatusPauseBeforeCommit,
+		DeviceDeploymentStatusPauseBeforeReboot,
+		DeviceDeploymentStatusDecommissioned,
 	}
 	for _, f := range must {
-		assert.Contains(t, ds, f, "stats must contain status '%v'", f)
+		if assert.True(t, ds.Has(f), "stats must contain status '%v'", f) {
+			assert.Zero(t, ds.Get(f), "status '%v' must be initialized to 0", f)
+		}
+	}
+	assert.Equal(t, len(must), ds.Len())
+
+	// every status constant, including those missing from allStatuses,
+	// must be present in the initial stats
+	first, last := DeviceDeploymentStatusFailure, DeviceDeploymentStatusDecommissioned
+	for status := first; status <= last; status += 1 << 8 {
+		assert.True(t, ds.Has(status))
+	}
+
+	dep, err := NewDeployment()
+	if assert.NoError(t, err) {
+		assert.Equal(t, ds, dep.Stats)
+	}
+}
+
+func TestStatsForEachStatus(t *testing.T) {
+	stats := NewStats(map[DeviceDeploymentStatus]int{
+		DeviceDeploymentStatusSuccess:     3,
+		DeviceDeploymentStatusAborted:     1,
+		DeviceDeploymentStatusPending:     0,
+		DeviceDeploymentStatusDownloading: 2,
+		DeviceDeploymentStatus(99):        5,
+	})
+	expected := []DeviceDeploymentStatus{
+		DeviceDeploymentStatusAborted,
+		DeviceDeploymentStatusDownloading,
+		DeviceDeploymentStatusPending,
+		DeviceDeploymentStatusSuccess,
+	}
+	for i := 0; i < 10; i++ {
+		var (
+			statuses []DeviceDeploymentStatus
+			counts   []int
+		)
+		stats.ForEachStatus(func(status DeviceDeploymentStatus, count int) {
+			statuses = append(statuses, status)
+			counts = append(counts, count)
+		})
+		assert.Equal(t, expected, statuses)
+		assert.Equal(t, []int{1, 2, 0, 3}, counts)
+		assert.Equal(t, expected, stats.Statuses())
+	}
+
+	all := NewDeviceDeploymentStats().Statuses()
+	assert.Len(t, all, len(KnownDeviceDeploymentStatuses()))
+	for i := 1; i < len(all); i++ {
+		assert.Less(t, all[i-1].String(), all[i].String())
+	}
+}
+
+func TestStatsEqual(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		A, B  Stats
+		Equal bool
+		Diff  string
+	}{
+		"equal": {
+			A:     NewStats(map[DeviceDeploymentStatus]int{DeviceDeploymentStatusSuccess: 1, DeviceDeploymentStatusFailure: 2}),
+			B:     NewStats(map[DeviceDeploymentStatus]int{DeviceDeploymentStatusFailure: 2, DeviceDeploymentStatusSuccess: 1}),
+			Equal: true,
+		},
+		"equal, missing key is zero": {
+			A:     NewDeviceDeploymentStats(),
+			B:     Stats{},
+			Equal: true,
+		},
+		"equal, both nil": {
+			Equal: true,
+		},
+		"different values": {
+			A:    NewStats(map[DeviceDeploymentStatus]int{DeviceDeploymentStatusSuccess: 1, DeviceDeploymentStatusFailure: 2}),
+			B:    NewStats(map[DeviceDeploymentStatus]int{DeviceDeploymentStatusSuccess: 2, DeviceDeploymentStatusFailure: 2}),
+			Diff: "success: 1 != 2",
+		},
+		"different keys": {
+			A:    NewStats(map[DeviceDeploymentStatus]int{DeviceDeploymentStatusSuccess: 1}),
+			B:    NewStats(map[DeviceDeploymentStatus]int{DeviceDeploymentStatusPending: 1}),
+			Diff: "pending: 0 != 1, success: 1 != 0",
+		},
+	}
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.Equal, tc.A.Equal(tc.B))
+			assert.Equal(t, tc.Equal, tc.B.Equal(tc.A))
+			assert.Equal(t, tc.Diff, tc.A.Diff(tc.B))
+		})
+	}
+}
+
+func TestStatsPct(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		Stats      Stats
+		MaxDevices int
+
+		Complete float64
+		Success  float64
+		Failure  float64
+	}{
+		"all zero": {
+			Stats: NewDeviceDeploymentStats(),
+		},
+		"nil stats": {
+			MaxDevices: 10,
+		},
+		"negative max devices": {
+			Stats:      NewStats(map[DeviceDeploymentStatus]int{DeviceDeploymentStatusSuccess: 1}),
+			MaxDevices: -1,
+		},
+		"partial": {
+			Stats: NewStats(map[DeviceDeploymentStatus]int{
+				DeviceDeploymentStatusSuccess:     2,
+				DeviceDeploymentStatusAlreadyInst: 1,
+				DeviceDeploymentStatusFailure:     1,
+				DeviceDeploymentStatusPending:     4,
+			}),
+			MaxDevices: 8,
+			Complete:   50.0,
+			Success:    37.5,
+			Failure:    12.5,
+		},
+		"complete": {
+			Stats: NewStats(map[DeviceDeploymentStatus]int{
+				DeviceDeploymentStatusSuccess: 3,
+				DeviceDeploymentStatusAborted: 1,
+			}),
+			MaxDevices: 4,
+			Complete:   100.0,
+			Success:    75.0,
+		},
+		"more devices than expected": {
+			Stats: NewStats(map[DeviceDeploymentStatus]int{
+				DeviceDeploymentStatusFailure: 5,
+			}),
+			MaxDevices: 4,
+			Complete:   100.0,
+			Failure:    100.0,
+		},
+	}
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.Complete, tc.Stats.PctComplete(tc.MaxDevices))
+			assert.Equal(t, tc.Success, tc.Stats.PctSuccess(tc.MaxDevices))
+			assert.Equal(t, tc.Failure, tc.Stats.PctFailure(tc.MaxDevices))
+		})
+	}
+}
+
+func FuzzStatsPct(f *testing.F) {
+	f.Add(0, 0, 0, 0)
+	f.Add(10, 3, 2, 5)
+	f.Add(-1, 1, 1, 1)
+	f.Add(1, 100, 0, 0)
+	f.Add(math.MaxInt32, 1, math.MaxInt32, 0)
+	f.Fuzz(func(t *testing.T, maxDevices, success, failure, pending int) {
+		stats := NewStats(map[DeviceDeploymentStatus]int{
+			DeviceDeploymentStatusSuccess: success,
+			DeviceDeploymentStatusFailure: failure,
+			DeviceDeploymentStatusPending: pending,
+		})
+		for _, pct := range []float64{
+			stats.PctComplete(maxDevices),
+			stats.PctSuccess(maxDevices),
+			stats.PctFailure(maxDevices),
+		} {
+			if math.IsNaN(pct) || pct < 0.0 || pct > 100.0 {
+				t.Fatalf("percentage out of range: %f", pct)
+			}
+		}
+	})
+}
+
+func TestStatsPrometheus(t *testing.T) {
+	t.Parallel()
+
+	stats := NewDeviceDeploymentStats()
+	stats.Set(DeviceDeploymentStatusSuccess, 12)
+	stats.Set(DeviceDeploymentStatusFailure, 3)
+	stats.Set(DeviceDeploymentStatus(99), 5)
+
+	assert.Equal(t, map[string]string{
+		"success": "12",
+		"failure": "3",
+	}, stats.ToPrometheusLabels())
+
+	assert.Equal(t, []GaugeSpec{{
+		Name:   "deployments_device_status_total",
+		Labels: map[string]string{"status": "failure"},
+		Value:  3,
+	}, {
+		Name:   "deployments_device_status_total",
+		Labels: map[string]string{"status": "success"},
+		Value:  12,
+	}}, stats.AsLabeledGauges("deployments"))
+
+	gauges := stats.AsLabeledGauges("")
+	if assert.Len(t, gauges, 2) {
+		assert.Equal(t, "device_status_total", gauges[0].Name)
+	}
+
+	assert.Empty(t, Stats{}.ToPrometheusLabels())
+	assert.Empty(t, NewDeviceDeploymentStats().AsLabeledGauges("deployments"))
+}
+
+func TestStatsAccessors(t *testing.T) {
+	t.Parallel()
+
+	var stats Stats
+	assert.Zero(t, stats.Get(DeviceDeploymentStatusPending))
+	assert.False(t, stats.Has(DeviceDeploymentStatusPending))
+	assert.ErrorIs(t, stats.Dec(DeviceDeploymentStatusPending), ErrStatsNegativeCount)
+
+	stats.Set(DeviceDeploymentStatusPending, 2)
+	stats.Inc(DeviceDeploymentStatusSuccess)
+	stats.Inc(DeviceDeploymentStatusSuccess)
+	assert.NoError(t, stats.Dec(DeviceDeploymentStatusPending))
+	assert.True(t, stats.Has(DeviceDeploymentStatusPending))
+	assert.Equal(t, 1, stats.Get(DeviceDeploymentStatusPending))
+	assert.Equal(t, 2, stats.Get(DeviceDeploymentStatusSuccess))
+	assert.Equal(t, 2, stats.Len())
+	assert.Equal(t, 3, stats.Total())
+
+	counts := map[DeviceDeploymentStatus]int{DeviceDeploymentStatusFailure: 1}
+	stats = NewStats(counts)
+	counts[DeviceDeploymentStatusFailure] = 2
+	assert.Equal(t, 1, stats.Get(DeviceDeploymentStatusFailure),
+		"the stats must not share memory with the counters")
+	assert.Equal(t, Stats{}, NewStats(map[DeviceDeploymentStatus]int{}))
+}
+
+func TestStatsSerialization(t *testing.T) {
+	t.Parallel()
+
+	stats := NewStats(map[DeviceDeploymentStatus]int{
+		DeviceDeploymentStatusSuccess:     5,
+		DeviceDeploymentStatusAlreadyInst: 0,
+	})
+
+	b, err := json.Marshal(stats)
+	if assert.NoError(t, err) {
+		assert.JSONEq(t, `{"success": 5, "already-installed": 0}`, string(b))
+		var res Stats
+		assert.NoError(t, json.Unmarshal(b, &res))
+		assert.Equal(t, stats, res)
+	}
+	b, err = json.Marshal(Stats{})
+	if assert.NoError(t, err) {
+		assert.Equal(t, `{}`, string(b))
+	}
+
+	type document struct {
+		Stats Stats `bson:"stats"`
+	}
+	b, err = bson.Marshal(document{Stats: stats})
+	if assert.NoError(t, err) {
+		var raw struct {
+			Stats map[string]int `bson:"stats"`
+		}
+		assert.NoError(t, bson.Unmarshal(b, &raw))
+		assert.Equal(t, map[string]int{"success": 5, "already-installed": 0}, raw.Stats)
+
+		var res document
+		assert.NoError(t, bson.Unmarshal(b, &res))
+		assert.Equal(t, stats, res.Stats)
+	}
+
+	b, err = bson.Marshal(bson.M{"stats": nil})
+	if assert.NoError(t, err) {
+		res := document{Stats: stats}
+		assert.NoError(t, bson.Unmarshal(b, &res))
+		assert.Zero(t, res.Stats)
+	}
+	b, err = bson.Marshal(bson.M{"stats": bson.M{"sucess": 1, "success": 2}})
+	if assert.NoError(t, err) {
+		var res document
+		assert.NoError(t, bson.Unmarshal(b, &res))
+		assert.Equal(t, NewStats(map[DeviceDeploymentStatus]int{
+			DeviceDeploymentStatusSuccess: 2,
+		}), res.Stats)
+	}
+}
+
+func TestSyncStats(t *testing.T) {
+	t.Parallel()
+
+	stats := NewDeviceDeploymentStats()
+	stats.Set(DeviceDeploymentStatusPending, 100)
+	syncStats := NewSyncStats(stats)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := syncStats.Decrement(DeviceDeploymentStatusPending)
+			assert.NoError(t, err)
+			syncStats.Increment(DeviceDeploymentStatusSuccess)
+			_ = syncStats.Get(DeviceDeploymentStatusSuccess)
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, 0, syncStats.Get(DeviceDeploymentStatusPending))
+	assert.Equal(t, 100, syncStats.Get(DeviceDeploymentStatusSuccess))
+	assert.ErrorIs(t,
+		syncStats.Decrement(DeviceDeploymentStatusPending),
+		ErrStatsNegativeCount,
+	)
+	assert.Equal(t, 100, stats.Get(DeviceDeploymentStatusPending),
+		"SyncStats must not modify the source stats")
+
+	syncStats.Set(DeviceDeploymentStatusFailure, 3)
+	snapshot := syncStats.Stats()
+	assert.Equal(t, 3, snapshot.Get(DeviceDeploymentStatusFailure))
+	assert.Equal(t, 100, snapshot.Get(DeviceDeploymentStatusSuccess))
+
+	var zero SyncStats
+	zero.Increment(DeviceDeploymentStatusFailure)
+	assert.Equal(t, 1, zero.Get(DeviceDeploymentStatusFailure))
+}
+
+// TestStats_AllStatusesCovered makes sure that every device deployment status
+// is taken into account by Deployment.IsFinished and Deployment.IsNotPending.
+// When adding a new status, add the expected outcome to the table below.
+func TestStats_AllStatusesCovered(t *testing.T) {
+	expected := map[DeviceDeploymentStatus]struct {
+		finished   bool
+		notPending bool
+	}{
+		DeviceDeploymentStatusFailure:            {finished: true, notPending: true},
+		DeviceDeploymentStatusAborted:            {finished: true, notPending: true},
+		DeviceDeploymentStatusPauseBeforeInstall: {finished: false, notPending: true},
+		DeviceDeploymentStatusPauseBeforeCommit:  {finished: false, notPending: true},
+		DeviceDeploymentStatusPauseBeforeReboot:  {finished: false, notPending: true},
+		DeviceDeploymentStatusDownloading:        {finished: false, notPending: true},
+		DeviceDeploymentStatusInstalling:         {finished: false, notPending: true},
+		DeviceDeploymentStatusRebooting:          {finished: false, notPending: true},
+		DeviceDeploymentStatusPending:            {finished: false, notPending: false},
+		DeviceDeploymentStatusSuccess:            {finished: true, notPending: true},
+		DeviceDeploymentStatusNoArtifact:         {finished: true, notPending: true},
+		DeviceDeploymentStatusAlreadyInst:        {finished: true, notPending: true},
+		DeviceDeploymentStatusDecommissioned:     {finished: true, notPending: false},
+	}
+	statuses := KnownDeviceDeploymentStatuses()
+	assert.Len(t, statuses, len(expected))
+	for _, status := range statuses {
+		exp, ok := expected[status]
+		if !assert.Truef(t, ok,
+			"status %q is not covered by the test, please update the table",
+			status) {
+			continue
+		}
+		dep, err := NewDeployment()
+		assert.NoError(t, err)
+		dep.MaxDevices = 1
+		dep.Stats.Set(status, 1)
+		assert.Equalf(t, exp.finished, dep.IsFinished(),
+			"unexpected IsFinished() for status %q", status)
+		assert.Equalf(t, exp.notPending, dep.IsNotPending(),
+			"unexpected IsNotPending() for status %q", status)
 	}
 }
 
@@ -179,3 +556,102 @@ func TestDeviceDeploymentIsFinishedWithFinishedTimestamp(t *testing.T) {
 	deployment = Deployment{Finished: &now}
 	assert.True(t, deployment.IsFinished())
 }
+
+func TestStatsSummary(t *testing.T) {
+	t.Parallel()
+
+	stats := NewDeviceDeploymentStats()
+	stats.Set(DeviceDeploymentStatusSuccess, 5)
+	stats.Set(DeviceDeploymentStatusFailure, 2)
+	stats.Set(DeviceDeploymentStatusDownloading, 1)
+	stats.Set(DeviceDeploymentStatusPending, 3)
+	stats.Set(DeviceDeploymentStatus(99), 7)
+
+	for i := 0; i < 10; i++ {
+		assert.Equal(t, "1↻ 2✗ 3… 5✓", stats.SummaryString())
+		assert.Equal(t,
+			"aborted=0 already-installed=0 decommissioned=0 downloading=1 "+
+				"failure=2 installing=0 noartifact=0 pause_before_committing=0 "+
+				"pause_before_installing=0 pause_before_rebooting=0 pending=3 "+
+				"rebooting=0 success=5",
+			stats.VerboseString())
+		assert.Equal(t, template.HTML(
+			`<span class="status-downloading" title="downloading">1↻</span>`+
+				`<span class="status-failure" title="failure">2✗</span>`+
+				`<span class="status-pending" title="pending">3…</span>`+
+				`<span class="status-success" title="success">5✓</span>`,
+		), stats.HTMLSummary())
+	}
+
+	assert.Empty(t, Stats{}.SummaryString())
+	assert.Empty(t, Stats{}.HTMLSummary())
+	assert.Equal(t, NewDeviceDeploymentStats().VerboseString(),
+		Stats{}.VerboseString())
+}
+
+func TestStatsFromJSON(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		JSON string
+
+		Stats Stats
+		Error string
+	}{
+		"ok": {
+			JSON: `{"success": 5, "failure": 2, "pending": 0}`,
+			Stats: NewStats(map[DeviceDeploymentStatus]int{
+				DeviceDeploymentStatusSuccess: 5,
+				DeviceDeploymentStatusFailure: 2,
+				DeviceDeploymentStatusPending: 0,
+			}),
+		},
+		"ok, empty object": {
+			JSON:  `{}`,
+			Stats: Stats{},
+		},
+		"ok, null": {
+			JSON: `null`,
+		},
+		"error, unknown key": {
+			JSON:  `{"success": 5, "sucess": 1}`,
+			Error: `stats: unknown status "sucess"`,
+		},
+		"error, negative value": {
+			JSON:  `{"failure": -1}`,
+			Error: `stats: negative count -1 for status "failure"`,
+		},
+		"error, non-integer value": {
+			JSON:  `{"failure": 1.5}`,
+			Error: `stats: invalid count 1.5 for status "failure"`,
+		},
+		"error, not an object": {
+			JSON:  `[1, 2]`,
+			Error: "stats: invalid JSON object",
+		},
+	}
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			stats, err := StatsFromJSON([]byte(tc.JSON))
+			if tc.Error != "" {
+				if assert.Error(t, err) {
+					assert.Contains(t, err.Error(), tc.Error)
+				}
+				assert.Zero(t, stats)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tc.Stats, stats)
+			}
+		})
+	}
+
+	stats := NewDeviceDeploymentStats()
+	stats.Set(DeviceDeploymentStatusInstalling, 3)
+	b, err := json.Marshal(stats)
+	if assert.NoError(t, err) {
+		res, err := StatsFromJSON(b)
+		assert.NoError(t, err)
+		assert.Equal(t, stats, res)
+	}
+}
@@ -21,11 +21,20 @@ import (
 )
 
 type Link struct {
-	Uri      string            `json:"uri" bson:"-"`
-	Expire   time.Time         `json:"expire,omitempty" bson:"expire"`
-	Method   string            `json:"method,omitempty" bson:"-"`
-	Header   map[string]string `json:"header,omitempty" bson:"-"`
-	TenantID string            `json:"-" bson:"tenant_id"`
+	Uri    string    `json:"uri" bson:"-"`
+	Expire time.Time `json:"expire,omitempty" bson:"expire"`
+	// NotBefore is the time the signed URL becomes valid.
+	NotBefore *time.Time        `json:"not_before,omitempty" bson:"-"`
+	Method    string            `json:"method,omitempty" bson:"-"`
+	Header    map[string]string `json:"header,omitempty" bson:"-"`
+	TenantID  string            `json:"-" bson:"tenant_id"`
+	// Size of the object in bytes, zero if unknown.
+	Size int64 `json:"size" bson:"-"`
+
+	// SAS token properties, set for Azure Blob signed URLs for auditing
+	// purposes only.
+	SASPermissions string     `json:"-" bson:"-"`
+	SASStartTime   *time.Time `json:"-" bson:"-"`
 }
 
 type UploadLink struct {
@@ -15,8 +15,11 @@
 package model
 
 import (
+	"encoding/json"
 	"testing"
 	"time"
+
+	"github.com/stretchr/testify/assert"
 )
 
 func TestNewLink(t *testing.T) {
@@ -32,3 +35,42 @@ func TestNewLink(t *testing.T) {
 		t.FailNow()
 	}
 }
+
+func TestLinkMarshalJSONNotBefore(t *testing.T) {
+	expire := time.Date(2023, 4, 1, 0, 15, 0, 0, time.UTC)
+	link := NewLink("http://example.com", expire)
+
+	b, err := json.Marshal(link)
+	if assert.NoError(t, err) {
+		assert.JSONEq(t,
+			`{"uri": "http://example.com", "expire": "2023-04-01T00:15:00Z", "size": 0}`,
+			string(b),
+		)
+	}
+
+	notBefore := expire.Add(-15 * time.Minute)
+	link.NotBefore = &notBefore
+	b, err = json.Marshal(link)
+	if assert.NoError(t, err) {
+		assert.JSONEq(t, `{
+			"uri": "http://example.com",
+			"expire": "2023-04-01T00:15:00Z",
+			"not_before": "2023-04-01T00:00:00Z",
+			"size": 0
+		}`, string(b))
+	}
+}
+
+func TestLinkMarshalJSONSize(t *testing.T) {
+	expire := time.Date(2023, 4, 1, 0, 15, 0, 0, time.UTC)
+	link := NewLink("http://example.com", expire)
+	link.Size = 1337
+
+	b, err := json.Marshal(link)
+	if assert.NoError(t, err) {
+		assert.JSONEq(t,
+			`{"uri": "http://example.com", "expire": "2023-04-01T00:15:00Z", "size": 1337}`,
+			string(b),
+		)
+	}
+}
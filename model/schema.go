@@ -0,0 +1,184 @@
+// Copyright 2023 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model
+
+// deploymentConstructorSchema is the JSON Schema (draft-07) of the
+// DeploymentConstructor API representation. It must be kept in sync with
+// DeploymentConstructor.Validate and DeploymentConstructor.ValidateNew; the
+// name patterns mirror IsValidArtifactName.
+const deploymentConstructorSchema = `{
+  "$schema": "http://json-schema.org/draft-07/schema#",
+  "title": "DeploymentConstructor",
+  "type": "object",
+  "properties": {
+    "name": {
+      "type": "string",
+      "minLength": 1,
+      "maxLength": 4096,
+      "pattern": "^[^/\\\\\\x00\\s](?:[^/\\\\\\x00]*[^/\\\\\\x00\\s])?$",
+      "not": {"pattern": "\\.\\.|%[0-9A-Fa-f]{2}"}
+    },
+    "artifact_name": {
+      "type": "string",
+      "minLength": 1,
+      "maxLength": 4096,
+      "pattern": "^[^/\\\\\\x00\\s](?:[^/\\\\\\x00]*[^/\\\\\\x00\\s])?$",
+      "not": {"pattern": "\\.\\.|%[0-9A-Fa-f]{2}"}
+    },
+    "devices": {
+      "type": "array",
+      "uniqueItems": true,
+      "items": {
+        "type": "string",
+        "pattern": "^[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}$"
+      }
+    },
+    "all_devices": {
+      "type": "boolean"
+    },
+    "force_installation": {
+      "type": "boolean"
+    },
+    "subgroup_names": {
+      "type": "array",
+      "items": {
+        "type": "string",
+        "minLength": 1,
+        "maxLength": 256
+      }
+    },
+    "tags": {
+      "type": "object",
+      "maxProperties": 32,
+      "propertyNames": {
+        "minLength": 1,
+        "maxLength": 256,
+        "pattern": "^[^.$]+$"
+      },
+      "additionalProperties": {
+        "type": "string",
+        "maxLength": 256
+      }
+    },
+    "device_tag_filter": {
+      "type": "object",
+      "propertyNames": {
+        "minLength": 1,
+        "pattern": "^[^.$]+$"
+      },
+      "additionalProperties": {
+        "type": "string",
+        "maxLength": 4096
+      }
+    },
+    "dry_run": {
+      "type": "boolean"
+    },
+    "comment": {
+      "type": "string",
+      "maxLength": 10000
+    },
+    "max_devices": {
+      "type": "integer",
+      "minimum": 0
+    },
+    "rollback_artifact_name": {
+      "type": "string",
+      "maxLength": 4096,
+      "pattern": "^[^/\\\\\\x00\\s](?:[^/\\\\\\x00]*[^/\\\\\\x00\\s])?$",
+      "not": {"pattern": "\\.\\.|%[0-9A-Fa-f]{2}"}
+    },
+    "failure_threshold_percent": {
+      "type": "number",
+      "minimum": 0,
+      "maximum": 100
+    },
+    "based_on_deployment_id": {
+      "type": "string",
+      "pattern": "^[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}$"
+    },
+    "scheduled_at": {
+      "type": "string",
+      "format": "date-time"
+    },
+    "phases": {
+      "type": "array",
+      "items": {
+        "type": "object",
+        "required": ["batch_size"],
+        "properties": {
+          "batch_size": {
+            "type": "integer",
+            "minimum": 1
+          },
+          "delay_hours": {
+            "type": "number",
+            "minimum": 0
+          },
+          "start_ts": {
+            "type": "string",
+            "format": "date-time"
+          }
+        }
+      }
+    }
+  },
+  "required": ["name", "artifact_name"],
+  "oneOf": [
+    {
+      "required": ["devices"],
+      "properties": {
+        "devices": {"minItems": 1},
+        "all_devices": {"const": false},
+        "subgroup_names": {"maxItems": 0},
+        "device_tag_filter": {"maxProperties": 0}
+      }
+    },
+    {
+      "required": ["all_devices"],
+      "properties": {
+        "devices": {"maxItems": 0},
+        "all_devices": {"const": true},
+        "subgroup_names": {"maxItems": 0},
+        "device_tag_filter": {"maxProperties": 0}
+      }
+    },
+    {
+      "required": ["subgroup_names"],
+      "properties": {
+        "devices": {"maxItems": 0},
+        "all_devices": {"const": false},
+        "subgroup_names": {"minItems": 1},
+        "device_tag_filter": {"maxProperties": 0}
+      }
+    },
+    {
+      "required": ["device_tag_filter"],
+      "properties": {
+        "devices": {"maxItems": 0},
+        "all_devices": {"const": false},
+        "subgroup_names": {"maxItems": 0},
+        "device_tag_filter": {"minProperties": 1}
+      }
+    }
+  ]
+}
+`
+
+// JSONSchema returns the JSON Schema (draft-07) describing the JSON
+// representation of a new deployment as accepted by ValidateNew.
+func (c DeploymentConstructor) JSONSchema() []byte {
+	return []byte(deploymentConstructorSchema)
+}
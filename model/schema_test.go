@@ -0,0 +1,339 @@
+// Copyright 2023 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// validateSchema validates value against the subset of JSON Schema keywords
+// used by the model schemas.
+func validateSchema(schema map[string]interface{}, value interface{}) error {
+	if typ, ok := schema["type"].(string); ok {
+		var match bool
+		switch typ {
+		case "object":
+			_, match = value.(map[string]interface{})
+		case "array":
+			_, match = value.([]interface{})
+		case "string":
+			_, match = value.(string)
+		case "boolean":
+			_, match = value.(bool)
+		case "integer":
+			n, ok := value.(float64)
+			match = ok && n == math.Trunc(n)
+		case "number":
+			_, match = value.(float64)
+		}
+		if !match {
+			return fmt.Errorf("expected type %s", typ)
+		}
+	}
+	if c, ok := schema["const"]; ok && c != value {
+		return fmt.Errorf("expected constant %v", c)
+	}
+	if not, ok := schema["not"].(map[string]interface{}); ok &&
+		validateSchema(not, value) == nil {
+		return fmt.Errorf("matches the negated schema")
+	}
+	if n, ok := value.(float64); ok {
+		if min, ok := schema["minimum"].(float64); ok && n < min {
+			return fmt.Errorf("number less than %v", min)
+		}
+		if max, ok := schema["maximum"].(float64); ok && n > max {
+			return fmt.Errorf("number greater than %v", max)
+		}
+	}
+	if str, ok := value.(string); ok {
+		length := float64(utf8.RuneCountInString(str))
+		if min, ok := schema["minLength"].(float64); ok && length < min {
+			return fmt.Errorf("string shorter than %v", min)
+		}
+		if max, ok := schema["maxLength"].(float64); ok && length > max {
+			return fmt.Errorf("string longer than %v", max)
+		}
+		if pattern, ok := schema["pattern"].(string); ok &&
+			!regexp.MustCompile(pattern).MatchString(str) {
+			return fmt.Errorf("string does not match %q", pattern)
+		}
+	}
+	if arr, ok := value.([]interface{}); ok {
+		length := float64(len(arr))
+		if min, ok := schema["minItems"].(float64); ok && length < min {
+			return fmt.Errorf("less than %v items", min)
+		}
+		if max, ok := schema["maxItems"].(float64); ok && length > max {
+			return fmt.Errorf("more than %v items", max)
+		}
+		if unique, _ := schema["uniqueItems"].(bool); unique {
+			seen := make(map[string]bool, len(arr))
+			for i, item := range arr {
+				key := fmt.Sprintf("%#v", item)
+				if seen[key] {
+					return fmt.Errorf("[%d]: duplicate item", i)
+				}
+				seen[key] = true
+			}
+		}
+		if items, ok := schema["items"].(map[string]interface{}); ok {
+			for i, item := range arr {
+				if err := validateSchema(items, item); err != nil {
+					return fmt.Errorf("[%d]: %w", i, err)
+				}
+			}
+		}
+	}
+	if obj, ok := value.(map[string]interface{}); ok {
+		length := float64(len(obj))
+		if min, ok := schema["minProperties"].(float64); ok && length < min {
+			return fmt.Errorf("less than %v properties", min)
+		}
+		if max, ok := schema["maxProperties"].(float64); ok && length > max {
+			return fmt.Errorf("more than %v properties", max)
+		}
+		if required, ok := schema["required"].([]interface{}); ok {
+			for _, key := range required {
+				if _, ok := obj[key.(string)]; !ok {
+					return fmt.Errorf("missing required property %q", key)
+				}
+			}
+		}
+		properties, _ := schema["properties"].(map[string]interface{})
+		for key, v := range obj {
+			if names, ok := schema["propertyNames"].(map[string]interface{}); ok {
+				if err := validateSchema(names, key); err != nil {
+					return fmt.Errorf("property name %q: %w", key, err)
+				}
+			}
+			if prop, ok := properties[key].(map[string]interface{}); ok {
+				if err := validateSchema(prop, v); err != nil {
+					return fmt.Errorf("%s: %w", key, err)
+				}
+			} else if additional, ok := schema["additionalProperties"].(map[string]interface{}); ok {
+				if err := validateSchema(additional, v); err != nil {
+					return fmt.Errorf("%s: %w", key, err)
+				}
+			}
+		}
+	}
+	if oneOf, ok := schema["oneOf"].([]interface{}); ok {
+		var matches int
+		for _, sub := range oneOf {
+			if validateSchema(sub.(map[string]interface{}), value) == nil {
+				matches++
+			}
+		}
+		if matches != 1 {
+			return fmt.Errorf("matches %d schemas in oneOf", matches)
+		}
+	}
+	return nil
+}
+
+func TestDeploymentConstructorJSONSchema(t *testing.T) {
+	t.Parallel()
+
+	var schema map[string]interface{}
+	err := json.Unmarshal(DeploymentConstructor{}.JSONSchema(), &schema)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, "http://json-schema.org/draft-07/schema#", schema["$schema"])
+
+	testCases := map[string]struct {
+		Payload string
+		Valid   bool
+	}{
+		"ok, devices": {
+			Payload: `{"name": "foo", "artifact_name": "bar", "devices": ["f826484e-1157-4109-af21-304e6d711560"]}`,
+			Valid:   true,
+		},
+		"ok, all devices": {
+			Payload: `{"name": "foo", "artifact_name": "bar", "all_devices": true,
+				"force_installation": true}`,
+			Valid: true,
+		},
+		"ok, subgroups and tags": {
+			Payload: `{"name": "foo", "artifact_name": "bar",
+				"subgroup_names": ["g1", "g2"], "tags": {"env": "prod"},
+				"dry_run": true}`,
+			Valid: true,
+		},
+		"ok, device tag filter": {
+			Payload: `{"name": "foo", "artifact_name": "bar",
+				"device_tag_filter": {"model": "RPi4"}}`,
+			Valid: true,
+		},
+		"error, device tag filter and all devices": {
+			Payload: `{"name": "foo", "artifact_name": "bar", "all_devices": true,
+				"device_tag_filter": {"model": "RPi4"}}`,
+		},
+		"error, invalid device tag filter key": {
+			Payload: `{"name": "foo", "artifact_name": "bar",
+				"device_tag_filter": {"a$b": "RPi4"}}`,
+		},
+		"ok, max devices": {
+			Payload: `{"name": "foo", "artifact_name": "bar", "all_devices": true,
+				"max_devices": 10}`,
+			Valid: true,
+		},
+		"error, negative max devices": {
+			Payload: `{"name": "foo", "artifact_name": "bar", "all_devices": true,
+				"max_devices": -1}`,
+		},
+		"ok, phases": {
+			Payload: `{"name": "foo", "artifact_name": "bar", "all_devices": true,
+				"phases": [{"batch_size": 1, "delay_hours": 0.5}, {"batch_size": 10}]}`,
+			Valid: true,
+		},
+		"error, phase without batch size": {
+			Payload: `{"name": "foo", "artifact_name": "bar", "all_devices": true,
+				"phases": [{"delay_hours": 2}]}`,
+		},
+		"error, invalid device ID": {
+			Payload: `{"name": "foo", "artifact_name": "bar", "devices": ["dev1"]}`,
+		},
+		"error, duplicate device ID": {
+			Payload: `{"name": "foo", "artifact_name": "bar", "devices": [
+				"f826484e-1157-4109-af21-304e6d711560",
+				"f826484e-1157-4109-af21-304e6d711560"]}`,
+		},
+		"ok, rollback": {
+			Payload: `{"name": "foo", "artifact_name": "bar", "all_devices": true,
+				"rollback_artifact_name": "baz", "failure_threshold_percent": 12.5}`,
+			Valid: true,
+		},
+		"ok, based on deployment": {
+			Payload: `{"name": "foo", "artifact_name": "bar", "all_devices": true,
+				"based_on_deployment_id": "f826484e-1157-4109-af21-304e6d711560"}`,
+			Valid: true,
+		},
+		"error, invalid based on deployment ID": {
+			Payload: `{"name": "foo", "artifact_name": "bar", "all_devices": true,
+				"based_on_deployment_id": "foo"}`,
+		},
+		"error, failure threshold too large": {
+			Payload: `{"name": "foo", "artifact_name": "bar", "all_devices": true,
+				"rollback_artifact_name": "baz", "failure_threshold_percent": 101}`,
+		},
+		"error, missing name": {
+			Payload: `{"artifact_name": "bar", "devices": ["f826484e-1157-4109-af21-304e6d711560"]}`,
+		},
+		"error, empty artifact name": {
+			Payload: `{"name": "foo", "artifact_name": "", "devices": ["f826484e-1157-4109-af21-304e6d711560"]}`,
+		},
+		"error, name too long": {
+			Payload: `{"name": "` + strings.Repeat("a", 4097) +
+				`", "artifact_name": "bar", "devices": ["f826484e-1157-4109-af21-304e6d711560"]}`,
+		},
+		"error, artifact name with slash": {
+			Payload: `{"name": "foo", "artifact_name": "bar/baz", "devices": ["f826484e-1157-4109-af21-304e6d711560"]}`,
+		},
+		"error, name with parent directory reference": {
+			Payload: `{"name": "foo..bar", "artifact_name": "bar", "all_devices": true}`,
+		},
+		"error, artifact name with encoded traversal": {
+			Payload: `{"name": "foo", "artifact_name": "%2e%2e%2fetc", "all_devices": true}`,
+		},
+		"error, rollback artifact name with encoded character": {
+			Payload: `{"name": "foo", "artifact_name": "bar", "all_devices": true,
+				"rollback_artifact_name": "baz%20qux"}`,
+		},
+		"ok, name with percent sign": {
+			Payload: `{"name": "100% rollout", "artifact_name": "bar.1.0", "all_devices": true}`,
+			Valid:   true,
+		},
+		"error, name with trailing space": {
+			Payload: `{"name": "foo ", "artifact_name": "bar", "devices": ["f826484e-1157-4109-af21-304e6d711560"]}`,
+		},
+		"error, no target": {
+			Payload: `{"name": "foo", "artifact_name": "bar"}`,
+		},
+		"error, devices and all devices": {
+			Payload: `{"name": "foo", "artifact_name": "bar",
+				"devices": ["f826484e-1157-4109-af21-304e6d711560"], "all_devices": true}`,
+		},
+		"error, subgroups and devices": {
+			Payload: `{"name": "foo", "artifact_name": "bar",
+				"devices": ["f826484e-1157-4109-af21-304e6d711560"], "subgroup_names": ["g1"]}`,
+		},
+		"error, empty device ID": {
+			Payload: `{"name": "foo", "artifact_name": "bar", "devices": [""]}`,
+		},
+		"error, subgroup name too long": {
+			Payload: `{"name": "foo", "artifact_name": "bar",
+				"subgroup_names": ["` + strings.Repeat("a", 257) + `"]}`,
+		},
+		"error, invalid tag key": {
+			Payload: `{"name": "foo", "artifact_name": "bar", "all_devices": true,
+				"tags": {"a.b": "c"}}`,
+		},
+		"error, wrong type": {
+			Payload: `{"name": "foo", "artifact_name": "bar", "all_devices": "yes"}`,
+		},
+	}
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			var payload interface{}
+			if !assert.NoError(t, json.Unmarshal([]byte(tc.Payload), &payload)) {
+				return
+			}
+			err := validateSchema(schema, payload)
+			if tc.Valid {
+				assert.NoError(t, err)
+			} else {
+				assert.Error(t, err)
+			}
+
+			// The schema must agree with the validation rules.
+			var constructor DeploymentConstructor
+			if json.Unmarshal([]byte(tc.Payload), &constructor) == nil {
+				assert.Equal(t, tc.Valid, constructor.ValidateNew() == nil)
+			}
+		})
+	}
+}
+
+func TestDeploymentConstructorJSONSchemaProperties(t *testing.T) {
+	t.Parallel()
+
+	var schema struct {
+		Properties map[string]interface{} `json:"properties"`
+	}
+	err := json.Unmarshal(DeploymentConstructor{}.JSONSchema(), &schema)
+	if !assert.NoError(t, err) {
+		return
+	}
+	typ := reflect.TypeOf(DeploymentConstructor{})
+	for i := 0; i < typ.NumField(); i++ {
+		name := strings.Split(typ.Field(i).Tag.Get("json"), ",")[0]
+		if name == "" || name == "-" {
+			continue
+		}
+		assert.Contains(t, schema.Properties, name,
+			"the schema does not describe the %q property", name)
+	}
+}
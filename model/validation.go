@@ -16,6 +16,7 @@ package model
 
 import (
 	validation "github.com/go-ozzo/ozzo-validation/v4"
+	"github.com/pkg/errors"
 )
 
 var (
@@ -24,8 +25,34 @@ var (
 	lengthIn1To4096 = validation.Length(1, 4096)
 
 	lengthLessThan4096 = validation.Length(0, 4096)
+
+	runeLengthLessThan10000 = validation.RuneLength(0, 10000)
+
+	uuidV4 = validation.NewStringRule(IsUUIDv4, "must be a valid UUID v4")
+
+	validArtifactName = validation.NewStringRule(IsValidArtifactName,
+		"must not contain '/', '\\', '..', null nor URL-encoded characters, "+
+			"nor start or end with whitespace")
 )
 
+// uniqueStrings checks that a string slice has no duplicate values and
+// returns err otherwise.
+type uniqueStrings struct {
+	err error
+}
+
+func (u uniqueStrings) Validate(v interface{}) error {
+	values, _ := v.([]string)
+	seen := make(map[string]struct{}, len(values))
+	for _, value := range values {
+		if _, ok := seen[value]; ok {
+			return errors.Wrapf(u.err, "duplicate value %q", value)
+		}
+		seen[value] = struct{}{}
+	}
+	return nil
+}
+
 type deviceDeploymentStatusValidator struct{}
 
 func (deviceDeploymentStatusValidator) Validate(v interface{}) error {
@@ -107,6 +107,12 @@ func SetupBlobStorage(
 			creds.URI = &uri
 		}
 		options.SetSharedKey(creds)
+	} else if c.GetBool(dconfig.SettingAzureManagedIdentityEnabled) {
+		options.SetUseManagedIdentity(true)
+		options.SetAccountName(c.GetString(dconfig.SettingAzureManagedIdentityAccountName))
+		if c.IsSet(dconfig.SettingAzureManagedIdentityURI) {
+			options.SetContainerURI(c.GetString(dconfig.SettingAzureManagedIdentityURI))
+		}
 	}
 	if c.IsSet(dconfig.SettingStorageProxyURI) {
 		rawURL := c.GetString(dconfig.SettingStorageProxyURI)
@@ -174,6 +180,9 @@ func RunServer(ctx context.Context) error {
 	if err != nil {
 		return errors.WithMessage(err, "main: failed to setup storage client")
 	}
+	defer func() {
+		_ = objStore.Close()
+	}()
 
 	app := app.NewDeployments(ds, objStore, 0, false)
 	if addr := c.GetString(dconfig.SettingReportingAddr); addr != "" {
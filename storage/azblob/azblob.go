@@ -15,19 +15,23 @@
 package azblob
 
 import (
+	"compress/gzip"
 	"context"
-	"crypto/tls"
 	"fmt"
 	"io"
 	"net/http"
 	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/mendersoftware/deployments/model"
 	"github.com/mendersoftware/deployments/storage"
 	"github.com/mendersoftware/deployments/utils"
 
-	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/runtime"
 	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
 	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob"
 	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/blob"
@@ -35,10 +39,12 @@ import (
 	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/blockblob"
 	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/container"
 	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/sas"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/service"
 )
 
 const (
-	headerBlobType = "x-ms-blob-type"
+	headerBlobType    = "x-ms-blob-type"
+	headerContentType = "Content-Type"
 
 	blobTypeBlock = "BlockBlob"
 )
@@ -49,6 +55,35 @@ type client struct {
 	contentType   *string
 	proxyURL      *url.URL
 	bufferSize    int64
+	prefix        string
+	fileSuffix    string
+	compression   CompressionAlgorithm
+
+	blockSize   int64
+	parallelism uint16
+
+	// transport overrides the HTTP client of the SDK if not nil.
+	transport policy.Transporter
+
+	maxRetries     int
+	retryBaseDelay time.Duration
+
+	// sasTokenTTL is the lifetime of the signed URLs requested with a
+	// zero duration.
+	sasTokenTTL time.Duration
+
+	// serviceClient requests the user delegation keys signing the URLs
+	// when authenticated with a managed identity.
+	serviceClient       *service.Client
+	delegationMu        sync.Mutex
+	delegationKey       *service.UserDelegationCredential
+	delegationKeyExpiry time.Time
+
+	// healthy holds the result of the last health check (1 if healthy).
+	healthy   int32
+	stop      chan struct{}
+	stopped   chan struct{}
+	closeOnce sync.Once
 }
 
 func NewEmpty(ctx context.Context, opts ...*Options) (storage.ObjectStorage, error) {
@@ -57,6 +92,18 @@ func NewEmpty(ctx context.Context, opts ...*Options) (storage.ObjectStorage, err
 		bufferSize:  opt.BufferSize,
 		contentType: opt.ContentType,
 		proxyURL:    opt.ProxyURI,
+		prefix:      strings.TrimSuffix(opt.Prefix, "/"),
+		fileSuffix:  opt.FileSuffix,
+		compression: opt.UploadCompression,
+
+		blockSize:   opt.BlockSize,
+		parallelism: opt.Parallelism,
+		transport:   opt.transport(),
+
+		maxRetries:     opt.MaxRetries,
+		retryBaseDelay: opt.RetryBaseDelay,
+
+		sasTokenTTL: opt.SASTokenTTL,
 	}
 	return objStore, nil
 }
@@ -68,25 +115,22 @@ func New(ctx context.Context, bucket string, opts ...*Options) (storage.ObjectSt
 		azCred *azblob.SharedKeyCredential
 	)
 	opt := NewOptions(opts...)
+	if err = opt.Validate(); err != nil {
+		return nil, err
+	}
 	objectStorage, err := NewEmpty(ctx, opt)
 	if err != nil {
 		return nil, err
 	}
-	clientOptions := &container.ClientOptions{
-		ClientOptions: azcore.ClientOptions{
-			Transport: &http.Client{
-				Transport: &http.Transport{
-					TLSClientConfig: &tls.Config{
-						RootCAs: storage.GetRootCAs(),
-					},
-				},
-			},
-		},
-	}
+	objectStorage.(*client).transport = opt.httpClient()
+	clientOptions := objectStorage.(*client).clientOptions()
 	if opt.ConnectionString != nil {
-		cc, err = container.NewClientFromConnectionString(
-			*opt.ConnectionString, bucket, clientOptions,
-		)
+		err = validateConnectionString(*opt.ConnectionString)
+		if err == nil {
+			cc, err = container.NewClientFromConnectionString(
+				*opt.ConnectionString, bucket, clientOptions,
+			)
+		}
 		if err == nil {
 			azCred, err = keyFromConnString(*opt.ConnectionString)
 		}
@@ -100,6 +144,12 @@ func New(ctx context.Context, bucket string, opts ...*Options) (storage.ObjectSt
 				clientOptions,
 			)
 		}
+	} else if opt.UseManagedIdentity {
+		var svc *service.Client
+		cc, svc, err = objectStorage.(*client).newManagedIdentityClients(
+			opt.containerURL(bucket),
+		)
+		objectStorage.(*client).serviceClient = svc
 	}
 	if err != nil {
 		return nil, err
@@ -109,9 +159,58 @@ func New(ctx context.Context, bucket string, opts ...*Options) (storage.ObjectSt
 	if err := objectStorage.HealthCheck(ctx); err != nil {
 		return nil, err
 	}
+	if interval := opt.HealthCheckInterval; interval != nil && *interval > 0 {
+		objectStorage.(*client).startHealthMonitor(*interval)
+	}
 	return objectStorage, nil
 }
 
+// startHealthMonitor runs HealthCheck on the given interval until Close is
+// called.
+func (c *client) startHealthMonitor(interval time.Duration) {
+	c.stop = make(chan struct{})
+	c.stopped = make(chan struct{})
+	go func() {
+		defer close(c.stopped)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-c.stop:
+				return
+			case <-ticker.C:
+				ctx, cancel := context.WithTimeout(context.Background(), interval)
+				_ = c.HealthCheck(ctx)
+				cancel()
+			}
+		}
+	}()
+}
+
+// IsHealthy returns the result of the last health check.
+func (c *client) IsHealthy() bool {
+	return atomic.LoadInt32(&c.healthy) == 1
+}
+
+func (c *client) setHealthy(healthy bool) {
+	var value int32
+	if healthy {
+		value = 1
+	}
+	atomic.StoreInt32(&c.healthy, value)
+}
+
+// Close stops the background health checks.
+func (c *client) Close() error {
+	c.closeOnce.Do(func() {
+		if c.stop != nil {
+			close(c.stop)
+			<-c.stopped
+		}
+	})
+	return nil
+}
+
 func (c *client) clientFromContext(
 	ctx context.Context,
 ) (client *container.Client, err error) {
@@ -120,10 +219,13 @@ func (c *client) clientFromContext(
 		if err = settings.Validate(); err != nil {
 			return nil, err
 		} else if settings.ConnectionString != nil {
+			if err = validateConnectionString(*settings.ConnectionString); err != nil {
+				return nil, err
+			}
 			client, err = container.NewClientFromConnectionString(
 				*settings.ConnectionString,
 				settings.Bucket,
-				&container.ClientOptions{},
+				c.clientOptions(),
 			)
 		} else {
 			var (
@@ -143,7 +245,7 @@ func (c *client) clientFromContext(
 				client, err = container.NewClientWithSharedKeyCredential(
 					containerURL,
 					azCreds,
-					&container.ClientOptions{},
+					c.clientOptions(),
 				)
 			}
 		}
@@ -154,6 +256,28 @@ func (c *client) clientFromContext(
 	return client, err
 }
 
+// clientOptions returns the options of a new container client. The options
+// must not be shared between clients: the SDK appends the credential policy
+// to them.
+func (c *client) clientOptions() *container.ClientOptions {
+	clientOptions := &container.ClientOptions{}
+	if c.transport != nil {
+		clientOptions.Transport = c.transport
+	}
+	return clientOptions
+}
+
+// blobPath returns the name of the blob storing the object at path: the
+// path scoped with the configured prefix, with the file suffix appended.
+// All the operations on a single object must resolve its blob with it.
+func (c *client) blobPath(path string) string {
+	path += c.fileSuffix
+	if c.prefix == "" {
+		return path
+	}
+	return c.prefix + "/" + path
+}
+
 func (c *client) HealthCheck(ctx context.Context) error {
 	azClient, err := c.clientFromContext(ctx)
 	if err != nil {
@@ -166,6 +290,9 @@ func (c *client) HealthCheck(ctx context.Context) error {
 		}
 	}
 	_, err = azClient.GetProperties(ctx, &container.GetPropertiesOptions{})
+	if _, ok := storage.SettingsFromContext(ctx); !ok {
+		c.setHealthy(err == nil)
+	}
 	if err != nil {
 		return OpError{
 			Op:     OpHealthCheck,
@@ -184,38 +311,122 @@ func (r objectReader) Length() int64 {
 	return r.length
 }
 
-func (c *client) GetObject(
+// gzipReader decompresses the body of a gzip-encoded blob.
+type gzipReader struct {
+	*gzip.Reader
+	body io.ReadCloser
+}
+
+func (r gzipReader) Close() error {
+	err := r.Reader.Close()
+	if errBody := r.body.Close(); err == nil {
+		err = errBody
+	}
+	return err
+}
+
+// download starts downloading the blob at objectPath.
+func (c *client) download(
 	ctx context.Context,
 	objectPath string,
-) (io.ReadCloser, error) {
+) (*blob.DownloadStreamResponse, error) {
 	azClient, err := c.clientFromContext(ctx)
 	if err != nil {
-		return nil, OpError{
-			Op:     OpGetObject,
-			Reason: err,
-		}
+		return nil, err
 	}
-	bc := azClient.NewBlockBlobClient(objectPath)
+	bc := azClient.NewBlockBlobClient(c.blobPath(objectPath))
 	out, err := bc.DownloadStream(ctx, &blob.DownloadStreamOptions{})
 	if bloberror.HasCode(err,
 		bloberror.BlobNotFound,
 		bloberror.ContainerNotFound,
 		bloberror.ResourceNotFound) {
-		err = storage.ErrObjectNotFound
+		err = &storage.ObjectNotFoundError{Path: objectPath}
 	}
 	if err != nil {
-		return nil, OpError{
-			Op:     OpGetObject,
-			Reason: err,
+		return nil, err
+	}
+	return &out, nil
+}
+
+func (c *client) downloadObject(
+	ctx context.Context,
+	objectPath string,
+) (*storage.ObjectInfo, io.ReadCloser, error) {
+	out, err := c.download(ctx, objectPath)
+	if err != nil {
+		return nil, nil, err
+	}
+	info := &storage.ObjectInfo{
+		Path:         objectPath,
+		LastModified: out.LastModified,
+		Size:         out.ContentLength,
+	}
+	if out.ContentEncoding != nil &&
+		*out.ContentEncoding == string(CompressionGzip) {
+		// The decompressed length is unknown.
+		zr, err := gzip.NewReader(out.Body)
+		if err != nil {
+			out.Body.Close()
+			return nil, nil, err
 		}
+		return info, gzipReader{Reader: zr, body: out.Body}, nil
 	}
 	if out.ContentLength != nil {
-		return objectReader{
+		return info, objectReader{
 			ReadCloser: out.Body,
 			length:     *out.ContentLength,
 		}, nil
 	}
-	return out.Body, nil
+	return info, out.Body, nil
+}
+
+func (c *client) GetObject(
+	ctx context.Context,
+	objectPath string,
+) (io.ReadCloser, error) {
+	_, body, err := c.downloadObject(ctx, objectPath)
+	if err != nil {
+		return nil, OpError{
+			Op:     OpGetObject,
+			Reason: err,
+		}
+	}
+	return body, nil
+}
+
+func (c *client) GetObjectWithMetadata(
+	ctx context.Context,
+	objectPath string,
+) (*storage.ObjectInfo, io.ReadCloser, error) {
+	info, body, err := c.downloadObject(ctx, objectPath)
+	if err != nil {
+		return nil, nil, OpError{
+			Op:     OpGetObjectWithMetadata,
+			Reason: err,
+		}
+	}
+	return info, body, nil
+}
+
+// GetObjectReader streams the blob content as stored: unlike GetObject,
+// compressed blobs are not decompressed.
+func (c *client) GetObjectReader(
+	ctx context.Context,
+	objectPath string,
+) (io.ReadCloser, error) {
+	// Setting Accept-Encoding explicitly prevents net/http from
+	// transparently decompressing gzip encoded blobs.
+	ctx = runtime.WithHTTPHeader(ctx, http.Header{
+		"Accept-Encoding": []string{string(CompressionGzip)},
+	})
+	out, err := c.download(ctx, objectPath)
+	if err != nil {
+		return nil, OpError{
+			Op:     OpGetObjectReader,
+			Reason: err,
+		}
+	}
+	return storage.DrainOnClose(out.Body), nil
 }
 
 func (c *client) PutObject(
@@ -230,14 +441,39 @@ func (c *client) PutObject(
 			Reason: err,
 		}
 	}
-	bc := azClient.NewBlockBlobClient(objectPath)
+	bc := azClient.NewBlockBlobClient(c.blobPath(objectPath))
 	var blobOpts = &blockblob.UploadStreamOptions{
 		HTTPHeaders: &blob.HTTPHeaders{
 			BlobContentType: c.contentType,
 		},
 	}
 	blobOpts.BlockSize = c.bufferSize
-	_, err = bc.UploadStream(ctx, src, blobOpts)
+	if c.blockSize > 0 {
+		blobOpts.BlockSize = c.blockSize
+	}
+	blobOpts.Concurrency = int(c.parallelism)
+	if c.compression == CompressionGzip {
+		blobOpts.HTTPHeaders.BlobContentEncoding = to.Ptr(
+			string(CompressionGzip),
+		)
+	}
+	upload := func() error {
+		body := src
+		if c.compression == CompressionGzip {
+			pr, stop := gzipStream(src)
+			// the source must no longer be read when the upload is
+			// retried from the start
+			defer stop()
+			body = pr
+		}
+		_, err := bc.UploadStream(ctx, body, blobOpts)
+		return err
+	}
+	if seeker, ok := src.(io.Seeker); ok && c.maxRetries > 0 {
+		err = c.uploadWithRetry(ctx, seeker, upload)
+	} else {
+		err = upload()
+	}
 	if err != nil {
 		return OpError{
 			Op:      OpPutObject,
@@ -248,6 +484,61 @@ func (c *client) PutObject(
 	return err
 }
 
+// uploadWithRetry retries the upload failing with a temporary error (see
+// storage.ClassifyError) with an exponential backoff, rewinding src before
+// each attempt. Only seekable sources can be retried. It gives up when the
+// next attempt would start after the context deadline.
+func (c *client) uploadWithRetry(
+	ctx context.Context,
+	src io.Seeker,
+	upload func() error,
+) error {
+	offset, err := src.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return upload()
+	}
+	err = upload()
+	delay := c.retryBaseDelay
+	for retry := 0; retry < c.maxRetries &&
+		storage.ClassifyError(err).Temporary(); retry++ {
+		if deadline, ok := ctx.Deadline(); ok && time.Now().Add(delay).After(deadline) {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(delay):
+		}
+		if _, errSeek := src.Seek(offset, io.SeekStart); errSeek != nil {
+			return err
+		}
+		err = upload()
+		delay *= 2
+	}
+	return err
+}
+
+// gzipStream returns a reader yielding the gzip-compressed content of src,
+// and a function stopping the compression: it closes the reader and waits
+// until src is no longer read.
+func gzipStream(src io.Reader) (io.Reader, func()) {
+	pr, pw := io.Pipe()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		zw := gzip.NewWriter(pw)
+		_, err := io.Copy(zw, src)
+		if errClose := zw.Close(); err == nil {
+			err = errClose
+		}
+		pw.CloseWithError(err)
+	}()
+	return pr, func() {
+		pr.Close()
+		<-done
+	}
+}
+
 func (c *client) DeleteObject(
 	ctx context.Context,
 	path string,
@@ -259,7 +550,7 @@ func (c *client) DeleteObject(
 			Reason: err,
 		}
 	}
-	bc := azClient.NewBlockBlobClient(path)
+	bc := azClient.NewBlockBlobClient(c.blobPath(path))
 	_, err = bc.Delete(ctx, &blob.DeleteOptions{
 		DeleteSnapshots: to.Ptr(azblob.DeleteSnapshotsOptionTypeInclude),
 	})
@@ -267,7 +558,7 @@ func (c *client) DeleteObject(
 		bloberror.BlobNotFound,
 		bloberror.ContainerNotFound,
 		bloberror.ResourceNotFound) {
-		err = storage.ErrObjectNotFound
+		err = &storage.ObjectNotFoundError{Path: path}
 	}
 	if err != nil {
 		return OpError{
@@ -290,7 +581,7 @@ func (c *client) StatObject(
 			Reason: err,
 		}
 	}
-	bc := azClient.NewBlockBlobClient(path)
+	bc := azClient.NewBlockBlobClient(c.blobPath(path))
 	if err != nil {
 		return nil, OpError{
 			Op:      OpStatObject,
@@ -304,7 +595,7 @@ func (c *client) StatObject(
 		bloberror.ContainerNotFound,
 		bloberror.ResourceNotFound,
 	) {
-		err = storage.ErrObjectNotFound
+		err = &storage.ObjectNotFoundError{Path: path}
 	}
 	if err != nil {
 		return nil, OpError{
@@ -313,11 +604,65 @@ func (c *client) StatObject(
 			Reason:  err,
 		}
 	}
-	return &storage.ObjectInfo{
+	info := &storage.ObjectInfo{
 		Path:         path,
 		LastModified: rsp.LastModified,
 		Size:         rsp.ContentLength,
-	}, nil
+		ContentMD5:   rsp.ContentMD5,
+	}
+	if rsp.ContentType != nil {
+		info.ContentType = *rsp.ContentType
+	}
+	if rsp.ETag != nil {
+		info.ETag = string(*rsp.ETag)
+	}
+	return info, nil
+}
+
+func (c *client) HeadObject(
+	ctx context.Context,
+	path string,
+) (bool, error) {
+	azClient, err := c.clientFromContext(ctx)
+	if err != nil {
+		return false, OpError{
+			Op:     OpHeadObject,
+			Reason: err,
+		}
+	}
+	bc := azClient.NewBlockBlobClient(c.blobPath(path))
+	_, err = bc.GetProperties(ctx, &blob.GetPropertiesOptions{})
+	if bloberror.HasCode(err,
+		bloberror.BlobNotFound,
+		bloberror.ContainerNotFound,
+		bloberror.ResourceNotFound,
+	) {
+		return false, nil
+	} else if err != nil {
+		return false, OpError{
+			Op:      OpHeadObject,
+			Message: "failed to retrieve object properties",
+			Reason:  err,
+		}
+	}
+	return true, nil
+}
+
+// sign signs the SAS with the shared key, or with a user delegation key
+// when authenticated with a managed identity.
+func (c *client) sign(
+	ctx context.Context,
+	sk *azblob.SharedKeyCredential,
+	values sas.BlobSignatureValues,
+) (sas.QueryParameters, error) {
+	if sk != nil || c.serviceClient == nil {
+		return values.SignWithSharedKey(sk)
+	}
+	key, err := c.userDelegationCredential(ctx, values.ExpiryTime)
+	if err != nil {
+		return sas.QueryParameters{}, err
+	}
+	return values.SignWithUserDelegation(key)
 }
 
 func (c *client) buildSignedURL(
@@ -326,6 +671,7 @@ func (c *client) buildSignedURL(
 	blobURL string,
 	expire time.Duration,
 	filename string,
+	ipRange sas.IPRange,
 ) (*model.Link, error) {
 	var permissions sas.BlobPermissions
 	switch method {
@@ -338,6 +684,9 @@ func (c *client) buildSignedURL(
 	default:
 		return nil, fmt.Errorf("invalid HTTP method %q", method)
 	}
+	if expire == 0 {
+		expire = c.sasTokenTTL
+	}
 	now := time.Now().UTC()
 	exp := now.Add(expire)
 	// HACK: We cannot use BlockBlobClient.GetSASToken because the API does
@@ -354,7 +703,7 @@ func (c *client) buildSignedURL(
 		)
 	}
 
-	qParams, err := sas.BlobSignatureValues{
+	qParams, err := c.sign(ctx, sk, sas.BlobSignatureValues{
 		ContainerName: urlParts.ContainerName,
 		BlobName:      urlParts.BlobName,
 
@@ -363,7 +712,8 @@ func (c *client) buildSignedURL(
 
 		StartTime:  now.UTC(),
 		ExpiryTime: exp.UTC(),
-	}.SignWithSharedKey(sk)
+		IPRange:    ipRange,
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -386,10 +736,15 @@ func (c *client) buildSignedURL(
 	if err != nil {
 		return nil, err
 	}
+	notBefore, sasStartTime := now, now
 	return &model.Link{
-		Expire: exp,
-		Method: method,
-		Uri:    baseURL.String(),
+		Expire:    exp,
+		NotBefore: &notBefore,
+		Method:    method,
+		Uri:       baseURL.String(),
+
+		SASPermissions: permissions.String(),
+		SASStartTime:   &sasStartTime,
 	}, nil
 }
 
@@ -399,6 +754,30 @@ func (c *client) GetRequest(
 	filename string,
 	duration time.Duration,
 ) (*model.Link, error) {
+	return c.GetRequestWithOptions(ctx, objectPath, filename, duration, nil)
+}
+
+// GetRequestWithOptions works like GetRequest and applies the additional
+// restrictions from opts to the signed URL.
+func (c *client) GetRequestWithOptions(
+	ctx context.Context,
+	objectPath string,
+	filename string,
+	duration time.Duration,
+	opts *GetRequestOptions,
+) (*model.Link, error) {
+	var allowedIPRange string
+	if opts != nil {
+		allowedIPRange = opts.AllowedIPRange
+	}
+	ipRange, err := parseIPRange(allowedIPRange)
+	if err != nil {
+		return nil, OpError{
+			Op:      OpGetRequest,
+			Message: "invalid IP range",
+			Reason:  err,
+		}
+	}
 	azClient, err := c.clientFromContext(ctx)
 	if err != nil {
 		return nil, OpError{
@@ -407,7 +786,7 @@ func (c *client) GetRequest(
 		}
 	}
 	// Check if object exists
-	bc := azClient.NewBlockBlobClient(objectPath)
+	bc := azClient.NewBlockBlobClient(c.blobPath(objectPath))
 	if err != nil {
 		return nil, OpError{
 			Op:      OpGetRequest,
@@ -415,13 +794,13 @@ func (c *client) GetRequest(
 			Reason:  err,
 		}
 	}
-	_, err = bc.GetProperties(ctx, &blob.GetPropertiesOptions{})
+	props, err := bc.GetProperties(ctx, &blob.GetPropertiesOptions{})
 	if bloberror.HasCode(err,
 		bloberror.BlobNotFound,
 		bloberror.ContainerNotFound,
 		bloberror.ResourceNotFound,
 	) {
-		err = storage.ErrObjectNotFound
+		err = &storage.ObjectNotFoundError{Path: objectPath}
 	}
 	if err != nil {
 		return nil, OpError{
@@ -436,6 +815,7 @@ func (c *client) GetRequest(
 		bc.URL(),
 		duration,
 		filename,
+		ipRange,
 	)
 	if err != nil {
 		return nil, OpError{
@@ -444,6 +824,9 @@ func (c *client) GetRequest(
 			Reason:  err,
 		}
 	}
+	if props.ContentLength != nil {
+		link.Size = *props.ContentLength
+	}
 	return link, nil
 }
 
@@ -459,7 +842,7 @@ func (c *client) DeleteRequest(
 			Reason: err,
 		}
 	}
-	bc := azClient.NewBlobClient(path)
+	bc := azClient.NewBlobClient(c.blobPath(path))
 	if err != nil {
 		return nil, OpError{
 			Op:      OpDeleteRequest,
@@ -467,7 +850,9 @@ func (c *client) DeleteRequest(
 			Reason:  err,
 		}
 	}
-	link, err := c.buildSignedURL(ctx, http.MethodDelete, bc.URL(), duration, "")
+	link, err := c.buildSignedURL(
+		ctx, http.MethodDelete, bc.URL(), duration, "", sas.IPRange{},
+	)
 	if err != nil {
 		return nil, OpError{
 			Op:      OpDeleteRequest,
@@ -484,6 +869,29 @@ func (c *client) PutRequest(
 	objectPath string,
 	duration time.Duration,
 ) (*model.Link, error) {
+	return c.PutRequestWithOptions(ctx, objectPath, duration, nil)
+}
+
+// PutRequestWithOptions works like PutRequest and applies the additional
+// restrictions from opts to the signed URL.
+func (c *client) PutRequestWithOptions(
+	ctx context.Context,
+	objectPath string,
+	duration time.Duration,
+	opts *PutRequestOptions,
+) (*model.Link, error) {
+	var allowedIPRange string
+	if opts != nil {
+		allowedIPRange = opts.AllowedIPRange
+	}
+	ipRange, err := parseIPRange(allowedIPRange)
+	if err != nil {
+		return nil, OpError{
+			Op:      OpPutRequest,
+			Message: "invalid IP range",
+			Reason:  err,
+		}
+	}
 	azClient, err := c.clientFromContext(ctx)
 	if err != nil {
 		return nil, OpError{
@@ -491,7 +899,7 @@ func (c *client) PutRequest(
 			Reason: err,
 		}
 	}
-	bc := azClient.NewBlobClient(objectPath)
+	bc := azClient.NewBlobClient(c.blobPath(objectPath))
 	if err != nil {
 		return nil, OpError{
 			Op:      OpPutRequest,
@@ -499,7 +907,9 @@ func (c *client) PutRequest(
 			Reason:  err,
 		}
 	}
-	link, err := c.buildSignedURL(ctx, http.MethodPut, bc.URL(), duration, "")
+	link, err := c.buildSignedURL(
+		ctx, http.MethodPut, bc.URL(), duration, "", ipRange,
+	)
 	if err != nil {
 		return nil, OpError{
 			Op:      OpPutRequest,
@@ -510,5 +920,8 @@ func (c *client) PutRequest(
 	link.Header = map[string]string{
 		headerBlobType: blobTypeBlock,
 	}
+	if c.contentType != nil {
+		link.Header[headerContentType] = *c.contentType
+	}
 	return link, nil
 }
@@ -15,21 +15,34 @@
 package azblob
 
 import (
+	"bytes"
+	"compress/gzip"
 	"context"
+	"crypto/md5"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/xml"
 	"flag"
 	"io"
+	"math/rand"
 	"net"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"os"
 	"path"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
 	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob"
 	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/blob"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/bloberror"
 	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/container"
 	validation "github.com/go-ozzo/ozzo-validation/v4"
 	"github.com/google/uuid"
@@ -197,6 +210,7 @@ func TestObjectStorage(t *testing.T) {
 			if assert.NoError(t, err) {
 				assert.WithinDuration(t, time.Now(), *stat.LastModified, time.Second*10,
 					"StatObject; last modified timestamp is not close to present time")
+				assert.NotEmpty(t, stat.ETag)
 			}
 
 			client := new(http.Client)
@@ -329,6 +343,62 @@ func TestKeyFromConnectionString(t *testing.T) {
 	}
 }
 
+func TestValidateConnectionString(t *testing.T) {
+	t.Parallel()
+	testCases := []struct {
+		Name string
+
+		ConnectionString string
+
+		Error error
+	}{{
+		Name: "ok",
+
+		ConnectionString: "DefaultEndpointsProtocol=https;AccountName=foobar;" +
+			"AccountKey=Zm9vYmFy;EndpointSuffix=core.windows.net",
+	}, {
+		Name: "ok/shared access signature and blob endpoint",
+
+		ConnectionString: "BlobEndpoint=http://localhost:10000/foobar;" +
+			"AccountName=foobar;SharedAccessSignature=sv=2020-02-10&sig=Zm9v",
+	}, {
+		Name: "error/empty string",
+
+		Error: ErrConnStrEmpty,
+	}, {
+		Name: "error/missing account name",
+
+		ConnectionString: "DefaultEndpointsProtocol=https;AccountKey=Zm9vYmFy",
+
+		Error: ErrConnStrNoName,
+	}, {
+		Name: "error/missing account key",
+
+		ConnectionString: "DefaultEndpointsProtocol=https;AccountName=foobar",
+
+		Error: ErrConnStrNoSecret,
+	}, {
+		Name: "error/missing protocol",
+
+		ConnectionString: "AccountName=foobar;AccountKey=Zm9vYmFy",
+
+		Error: ErrConnStrNoProtocol,
+	}}
+	for i := range testCases {
+		tc := testCases[i]
+		t.Run(tc.Name, func(t *testing.T) {
+			t.Parallel()
+
+			err := validateConnectionString(tc.ConnectionString)
+			if tc.Error != nil {
+				assert.ErrorIs(t, err, tc.Error)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
 func newTestStorageAndServer(handler http.Handler) (*client, *httptest.Server) {
 	srv := httptest.NewServer(handler)
 	contentType := "application/vnd-test"
@@ -361,6 +431,7 @@ func newTestStorageAndServer(handler http.Handler) (*client, *httptest.Server) {
 		url, cred, &container.ClientOptions{
 			ClientOptions: azcore.ClientOptions{
 				Transport: httpClient,
+				Retry:     policy.RetryOptions{MaxRetries: -1},
 			},
 		},
 	)
@@ -462,3 +533,1050 @@ func TestGetObject(t *testing.T) {
 		})
 	}
 }
+
+func TestGetObjectWithMetadata(t *testing.T) {
+	t.Parallel()
+
+	lastModified := time.Date(2023, 5, 4, 3, 2, 1, 0, time.UTC)
+	azClient, srv := newTestStorageAndServer(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path != "/container/foo/bar" {
+				w.Header().Set("x-ms-error-code", "BlobNotFound")
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			w.Header().Set("Last-Modified", lastModified.Format(http.TimeFormat))
+			w.Header().Set("Content-Length", "17")
+			w.WriteHeader(http.StatusOK)
+			w.Write([]byte("imagine artifacts"))
+		}),
+	)
+	defer srv.Close()
+	ctx := context.Background()
+
+	info, body, err := azClient.GetObjectWithMetadata(ctx, "foo/bar")
+	if assert.NoError(t, err) {
+		b, _ := io.ReadAll(body)
+		body.Close()
+		assert.Equal(t, []byte("imagine artifacts"), b)
+		assert.Equal(t, "foo/bar", info.Path)
+		if assert.NotNil(t, info.Size) {
+			assert.Equal(t, int64(len(b)), *info.Size)
+		}
+		if assert.NotNil(t, info.LastModified) {
+			assert.True(t, lastModified.Equal(*info.LastModified))
+		}
+	}
+
+	info, body, err = azClient.GetObjectWithMetadata(ctx, "foo/baz")
+	var opErr OpError
+	if assert.ErrorAs(t, err, &opErr) {
+		assert.Equal(t, OpGetObjectWithMetadata, opErr.Op)
+	}
+	assert.ErrorIs(t, err, storage.ErrObjectNotFound)
+	assert.ErrorIs(t, err, &storage.ObjectNotFoundError{Path: "foo/baz"})
+	assert.Nil(t, info)
+	assert.Nil(t, body)
+}
+
+func TestGetObjectReader(t *testing.T) {
+	t.Parallel()
+
+	var compressed bytes.Buffer
+	zw := gzip.NewWriter(&compressed)
+	zw.Write([]byte("imagine artifacts"))
+	zw.Close()
+	azClient, srv := newTestStorageAndServer(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path != "/container/foo/bar" {
+				w.Header().Set("x-ms-error-code", "BlobNotFound")
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			w.Header().Set("Content-Encoding", string(CompressionGzip))
+			w.WriteHeader(http.StatusOK)
+			w.Write(compressed.Bytes())
+		}),
+	)
+	defer srv.Close()
+	ctx := context.Background()
+
+	body, err := azClient.GetObjectReader(ctx, "foo/bar")
+	if assert.NoError(t, err) {
+		b, _ := io.ReadAll(body)
+		assert.NoError(t, body.Close())
+		assert.Equal(t, compressed.Bytes(), b,
+			"the content must not be decompressed")
+	}
+
+	body, err = azClient.GetObjectReader(ctx, "foo/baz")
+	var opErr OpError
+	if assert.ErrorAs(t, err, &opErr) {
+		assert.Equal(t, OpGetObjectReader, opErr.Op)
+	}
+	assert.ErrorIs(t, err, storage.ErrObjectNotFound)
+	assert.ErrorIs(t, err, &storage.ObjectNotFoundError{Path: "foo/baz"})
+	assert.Nil(t, body)
+}
+
+func TestHeadObject(t *testing.T) {
+	t.Parallel()
+
+	azClient, srv := newTestStorageAndServer(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, http.MethodHead, r.Method)
+			switch r.URL.Path {
+			case "/container/foo/bar":
+				w.WriteHeader(http.StatusOK)
+			case "/container/foo/forbidden":
+				w.Header().Set("x-ms-error-code", "AuthorizationFailure")
+				w.WriteHeader(http.StatusForbidden)
+			default:
+				w.Header().Set("x-ms-error-code", "BlobNotFound")
+				w.WriteHeader(http.StatusNotFound)
+			}
+		}),
+	)
+	defer srv.Close()
+	ctx := context.Background()
+
+	exists, err := azClient.HeadObject(ctx, "foo/bar")
+	assert.NoError(t, err)
+	assert.True(t, exists)
+
+	exists, err = azClient.HeadObject(ctx, "foo/baz")
+	assert.NoError(t, err)
+	assert.False(t, exists)
+
+	exists, err = azClient.HeadObject(ctx, "foo/forbidden")
+	var opErr OpError
+	if assert.ErrorAs(t, err, &opErr) {
+		assert.Equal(t, OpHeadObject, opErr.Op)
+	}
+	assert.False(t, exists)
+}
+
+func TestStatObject(t *testing.T) {
+	t.Parallel()
+
+	md5sum := md5.Sum([]byte("foobar"))
+	azClient, srv := newTestStorageAndServer(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path != "/container/foo/bar" {
+				w.Header().Set("x-ms-error-code", "BlobNotFound")
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			w.Header().Set("Content-Length", "6")
+			w.Header().Set("Content-Type", "application/vnd.mender")
+			w.Header().Set("Content-MD5",
+				base64.StdEncoding.EncodeToString(md5sum[:]))
+			w.Header().Set("ETag", `"0x8DB8A2F7F8F1A2B"`)
+			w.Header().Set("Last-Modified", "Mon, 17 Jul 2023 12:00:00 GMT")
+			w.WriteHeader(http.StatusOK)
+		}),
+	)
+	defer srv.Close()
+
+	ctx := context.Background()
+	stat, err := azClient.StatObject(ctx, "foo/bar")
+	if assert.NoError(t, err) {
+		assert.Equal(t, "foo/bar", stat.Path)
+		if assert.NotNil(t, stat.Size) {
+			assert.Equal(t, int64(6), *stat.Size)
+		}
+		assert.Equal(t, "application/vnd.mender", stat.ContentType)
+		assert.Equal(t, `"0x8DB8A2F7F8F1A2B"`, stat.ETag)
+		assert.Equal(t, md5sum[:], stat.ContentMD5)
+	}
+
+	_, err = azClient.StatObject(ctx, "foo/baz")
+	assert.ErrorIs(t, err, storage.ErrObjectNotFound)
+	assert.ErrorIs(t, err, &storage.ObjectNotFoundError{Path: "foo/baz"})
+	assert.Contains(t, err.Error(), "object not found: foo/baz")
+
+	err = azClient.DeleteObject(ctx, "foo/baz")
+	assert.ErrorIs(t, err, &storage.ObjectNotFoundError{Path: "foo/baz"})
+}
+
+func TestPrefix(t *testing.T) {
+	t.Parallel()
+
+	const expectedPath = "/container/tenant-abc/foo/bar"
+	azClient, srv := newTestStorageAndServer(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, expectedPath, r.URL.Path)
+			switch r.Method {
+			case http.MethodDelete:
+				w.WriteHeader(http.StatusAccepted)
+			case http.MethodPut:
+				w.WriteHeader(http.StatusCreated)
+			default:
+				w.WriteHeader(http.StatusOK)
+			}
+		}),
+	)
+	defer srv.Close()
+	opts := NewOptions(&Options{Prefix: "tenant-abc/"})
+	azClient.prefix = strings.TrimSuffix(opts.Prefix, "/")
+
+	ctx := context.Background()
+	obj, err := azClient.GetObject(ctx, "foo/bar")
+	if assert.NoError(t, err) {
+		obj.Close()
+	}
+	err = azClient.PutObject(ctx, "foo/bar", strings.NewReader("foobar"))
+	assert.NoError(t, err)
+	stat, err := azClient.StatObject(ctx, "foo/bar")
+	if assert.NoError(t, err) {
+		assert.Equal(t, "foo/bar", stat.Path)
+	}
+	err = azClient.DeleteObject(ctx, "foo/bar")
+	assert.NoError(t, err)
+
+	link, err := azClient.GetRequest(ctx, "foo/bar", "bar.mender", time.Minute)
+	if assert.NoError(t, err) {
+		u, err := url.Parse(link.Uri)
+		if assert.NoError(t, err) {
+			assert.Equal(t, expectedPath, u.Path)
+		}
+	}
+	link, err = azClient.PutRequest(ctx, "foo/bar", time.Minute)
+	if assert.NoError(t, err) {
+		u, err := url.Parse(link.Uri)
+		if assert.NoError(t, err) {
+			assert.Equal(t, expectedPath, u.Path)
+		}
+	}
+	link, err = azClient.DeleteRequest(ctx, "foo/bar", time.Minute)
+	if assert.NoError(t, err) {
+		u, err := url.Parse(link.Uri)
+		if assert.NoError(t, err) {
+			assert.Equal(t, expectedPath, u.Path)
+		}
+	}
+
+	// HealthCheck must not use the prefix
+	healthClient, srvHealth := newTestStorageAndServer(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, "/container", r.URL.Path)
+			w.WriteHeader(http.StatusOK)
+		}),
+	)
+	defer srvHealth.Close()
+	healthClient.prefix = "tenant-abc"
+	assert.NoError(t, healthClient.HealthCheck(ctx))
+}
+
+func TestFileSuffix(t *testing.T) {
+	t.Parallel()
+
+	const content = "foobar"
+	var (
+		mu    sync.Mutex
+		blobs = map[string]bool{}
+	)
+	azClient, srv := newTestStorageAndServer(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			mu.Lock()
+			defer mu.Unlock()
+			if r.Method == http.MethodPut {
+				if r.URL.Query().Get("comp") != "block" {
+					blobs[r.URL.Path] = true
+				}
+				w.WriteHeader(http.StatusCreated)
+				return
+			} else if !blobs[r.URL.Path] {
+				w.Header().Set("x-ms-error-code", string(bloberror.BlobNotFound))
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			switch r.Method {
+			case http.MethodDelete:
+				delete(blobs, r.URL.Path)
+				w.WriteHeader(http.StatusAccepted)
+			default:
+				w.Header().Set("Content-Length", strconv.Itoa(len(content)))
+				w.WriteHeader(http.StatusOK)
+				if r.Method == http.MethodGet {
+					_, _ = io.WriteString(w, content)
+				}
+			}
+		}),
+	)
+	defer srv.Close()
+	azClient.prefix = "tenant-abc"
+	azClient.fileSuffix = NewOptions().SetFileSuffix(".mender").FileSuffix
+	const blobPath = "/container/tenant-abc/foo/bar.mender"
+
+	ctx := context.Background()
+	err := azClient.PutObject(ctx, "foo/bar", strings.NewReader(content))
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	mu.Lock()
+	assert.Equal(t, map[string]bool{blobPath: true}, blobs)
+	mu.Unlock()
+
+	obj, err := azClient.GetObject(ctx, "foo/bar")
+	if assert.NoError(t, err) {
+		b, _ := io.ReadAll(obj)
+		obj.Close()
+		assert.Equal(t, content, string(b))
+	}
+	stat, err := azClient.StatObject(ctx, "foo/bar")
+	if assert.NoError(t, err) {
+		assert.Equal(t, "foo/bar", stat.Path)
+	}
+	exists, err := azClient.HeadObject(ctx, "foo/bar")
+	assert.NoError(t, err)
+	assert.True(t, exists)
+
+	for name, request := range map[string]func() (*model.Link, error){
+		"GetRequest": func() (*model.Link, error) {
+			return azClient.GetRequest(ctx, "foo/bar", "bar.mender", time.Minute)
+		},
+		"PutRequest": func() (*model.Link, error) {
+			return azClient.PutRequest(ctx, "foo/bar", time.Minute)
+		},
+		"DeleteRequest": func() (*model.Link, error) {
+			return azClient.DeleteRequest(ctx, "foo/bar", time.Minute)
+		},
+	} {
+		link, err := request()
+		if assert.NoError(t, err, name) {
+			u, err := url.Parse(link.Uri)
+			if assert.NoError(t, err) {
+				assert.Equal(t, blobPath, u.Path, name)
+			}
+		}
+	}
+
+	err = azClient.DeleteObject(ctx, "foo/bar")
+	assert.NoError(t, err)
+	_, err = azClient.StatObject(ctx, "foo/bar")
+	assert.ErrorIs(t, err, &storage.ObjectNotFoundError{Path: "foo/bar"})
+}
+
+func TestUploadCompression(t *testing.T) {
+	t.Parallel()
+
+	const payload = `{"foo": "bar"}`
+	testCases := map[string]struct {
+		Compression CompressionAlgorithm
+
+		ContentEncoding string
+	}{
+		"ok, gzip": {
+			Compression:     CompressionGzip,
+			ContentEncoding: "gzip",
+		},
+		"ok, no compression": {
+			Compression: CompressionNone,
+		},
+	}
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			var (
+				mu       sync.Mutex
+				stored   []byte
+				encoding string
+			)
+			azClient, srv := newTestStorageAndServer(
+				http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					mu.Lock()
+					defer mu.Unlock()
+					switch r.Method {
+					case http.MethodPut:
+						stored, _ = io.ReadAll(r.Body)
+						encoding = r.Header.Get("x-ms-blob-content-encoding")
+						w.WriteHeader(http.StatusCreated)
+					case http.MethodHead:
+						w.Header().Set("Content-Encoding", encoding)
+						w.WriteHeader(http.StatusOK)
+					case http.MethodGet:
+						w.Header().Set("Content-Encoding", encoding)
+						w.WriteHeader(http.StatusOK)
+						_, _ = w.Write(stored)
+					}
+				}),
+			)
+			defer srv.Close()
+			azClient.compression = tc.Compression
+			ctx := context.Background()
+
+			err := azClient.PutObject(ctx, "foo/bar", strings.NewReader(payload))
+			if !assert.NoError(t, err) {
+				return
+			}
+			mu.Lock()
+			assert.Equal(t, tc.ContentEncoding, encoding)
+			if tc.Compression == CompressionGzip {
+				zr, err := gzip.NewReader(bytes.NewReader(stored))
+				if assert.NoError(t, err) {
+					b, _ := io.ReadAll(zr)
+					assert.Equal(t, payload, string(b))
+				}
+			} else {
+				assert.Equal(t, payload, string(stored))
+			}
+			mu.Unlock()
+
+			body, err := azClient.GetObject(ctx, "foo/bar")
+			if assert.NoError(t, err) {
+				b, err := io.ReadAll(body)
+				assert.NoError(t, err)
+				assert.NoError(t, body.Close())
+				assert.Equal(t, payload, string(b))
+			}
+		})
+	}
+}
+
+// seekChecker records whether the reader is rewound while it is being read.
+type seekChecker struct {
+	*bytes.Reader
+	reading          int32
+	seekWhileReading int32
+}
+
+func (r *seekChecker) Read(b []byte) (int, error) {
+	atomic.AddInt32(&r.reading, 1)
+	defer atomic.AddInt32(&r.reading, -1)
+	// widen the window for a concurrent Seek
+	time.Sleep(time.Millisecond)
+	return r.Reader.Read(b)
+}
+
+func (r *seekChecker) Seek(offset int64, whence int) (int64, error) {
+	if atomic.LoadInt32(&r.reading) > 0 {
+		atomic.StoreInt32(&r.seekWhileReading, 1)
+	}
+	return r.Reader.Seek(offset, whence)
+}
+
+func TestPutObjectRetry(t *testing.T) {
+	t.Parallel()
+
+	const payload = "foobar"
+	testCases := map[string]struct {
+		MaxRetries int
+		Responses  []int
+		Source     func() io.Reader
+
+		Attempts int
+		Error    bool
+	}{
+		"ok, throttled twice": {
+			MaxRetries: 3,
+			Responses: []int{
+				http.StatusServiceUnavailable,
+				http.StatusServiceUnavailable,
+			},
+			Source: func() io.Reader { return strings.NewReader(payload) },
+
+			Attempts: 3,
+		},
+		"error, retries exhausted": {
+			MaxRetries: 1,
+			Responses: []int{
+				http.StatusServiceUnavailable,
+				http.StatusTooManyRequests,
+			},
+			Source: func() io.Reader { return strings.NewReader(payload) },
+
+			Attempts: 2,
+			Error:    true,
+		},
+		"ok, server error": {
+			MaxRetries: 3,
+			Responses:  []int{http.StatusInternalServerError},
+			Source:     func() io.Reader { return strings.NewReader(payload) },
+
+			Attempts: 2,
+		},
+		"error, not temporary": {
+			MaxRetries: 3,
+			Responses:  []int{http.StatusBadRequest},
+			Source:     func() io.Reader { return strings.NewReader(payload) },
+
+			Attempts: 1,
+			Error:    true,
+		},
+		"error, source not seekable": {
+			MaxRetries: 3,
+			Responses:  []int{http.StatusServiceUnavailable},
+			Source: func() io.Reader {
+				return io.MultiReader(strings.NewReader(payload))
+			},
+
+			Attempts: 1,
+			Error:    true,
+		},
+	}
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			var (
+				mu       sync.Mutex
+				attempts int
+				stored   []byte
+			)
+			azClient, srv := newTestStorageAndServer(
+				http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					mu.Lock()
+					defer mu.Unlock()
+					body, _ := io.ReadAll(r.Body)
+					if attempts < len(tc.Responses) {
+						w.WriteHeader(tc.Responses[attempts])
+					} else {
+						stored = body
+						w.WriteHeader(http.StatusCreated)
+					}
+					attempts++
+				}),
+			)
+			defer srv.Close()
+			azClient.maxRetries = tc.MaxRetries
+			azClient.retryBaseDelay = time.Millisecond
+
+			err := azClient.PutObject(context.Background(), "foo/bar", tc.Source())
+			mu.Lock()
+			defer mu.Unlock()
+			assert.Equal(t, tc.Attempts, attempts)
+			if tc.Error {
+				var opErr OpError
+				if assert.ErrorAs(t, err, &opErr) {
+					assert.Equal(t, OpPutObject, opErr.Op)
+				}
+			} else if assert.NoError(t, err) {
+				assert.Equal(t, payload, string(stored))
+			}
+		})
+	}
+
+	t.Run("error, context deadline", func(t *testing.T) {
+		t.Parallel()
+		var attempts int32
+		azClient, srv := newTestStorageAndServer(
+			http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				atomic.AddInt32(&attempts, 1)
+				w.WriteHeader(http.StatusServiceUnavailable)
+			}),
+		)
+		defer srv.Close()
+		azClient.maxRetries = 3
+		azClient.retryBaseDelay = time.Hour
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		defer cancel()
+		err := azClient.PutObject(ctx, "foo/bar", strings.NewReader(payload))
+		assert.Error(t, err)
+		assert.Equal(t, int32(1), atomic.LoadInt32(&attempts))
+	})
+
+	t.Run("ok, compressed source rewound", func(t *testing.T) {
+		t.Parallel()
+		// the first block fails while the source is still being
+		// compressed: the compression must stop reading the source before
+		// it is rewound (run with -race)
+		source := make([]byte, 4*BlockSizeMin)
+		_, _ = rand.Read(source)
+		src := &seekChecker{Reader: bytes.NewReader(source)}
+		var (
+			mu       sync.Mutex
+			failed   bool
+			blocks   = map[string][]byte{}
+			uploaded []byte
+		)
+		azClient, srv := newTestStorageAndServer(
+			http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				mu.Lock()
+				defer mu.Unlock()
+				body, _ := io.ReadAll(r.Body)
+				if !failed {
+					failed = true
+					w.WriteHeader(http.StatusServiceUnavailable)
+					return
+				}
+				switch r.URL.Query().Get("comp") {
+				case "block":
+					blocks[r.URL.Query().Get("blockid")] = body
+				case "blocklist":
+					var list struct {
+						Latest []string `xml:"Latest"`
+					}
+					_ = xml.Unmarshal(body, &list)
+					for _, id := range list.Latest {
+						uploaded = append(uploaded, blocks[id]...)
+					}
+				default:
+					uploaded = body
+				}
+				w.WriteHeader(http.StatusCreated)
+			}),
+		)
+		defer srv.Close()
+		azClient.compression = CompressionGzip
+		azClient.blockSize = BlockSizeMin
+		azClient.maxRetries = 1
+		azClient.retryBaseDelay = time.Millisecond
+
+		err := azClient.PutObject(context.Background(), "foo/bar", src)
+		if !assert.NoError(t, err) {
+			t.FailNow()
+		}
+		assert.Zero(t, atomic.LoadInt32(&src.seekWhileReading),
+			"source rewound while it was being read")
+		mu.Lock()
+		defer mu.Unlock()
+		zr, err := gzip.NewReader(bytes.NewReader(uploaded))
+		if assert.NoError(t, err) {
+			content, err := io.ReadAll(zr)
+			assert.NoError(t, err)
+			assert.Equal(t, source, content)
+		}
+	})
+}
+
+func TestOptionsValidateCompression(t *testing.T) {
+	t.Parallel()
+
+	assert.NoError(t, NewOptions().SetUploadCompression(CompressionGzip).Validate())
+	assert.ErrorIs(t,
+		NewOptions().SetUploadCompression("zstd").Validate(),
+		ErrUnknownCompression,
+	)
+}
+
+func TestOptionsValidateBlockSize(t *testing.T) {
+	t.Parallel()
+
+	assert.NoError(t, NewOptions().Validate())
+	assert.NoError(t, NewOptions().SetBlockSize(BlockSizeMin).Validate())
+	assert.ErrorIs(t,
+		NewOptions().SetBlockSize(BlockSizeMin-1).Validate(),
+		ErrInvalidBlockSize,
+	)
+	assert.ErrorIs(t,
+		NewOptions().SetBlockSize(5000*1024*1024).Validate(),
+		ErrInvalidBlockSize,
+	)
+}
+
+func TestPutObjectBlockSize(t *testing.T) {
+	t.Parallel()
+
+	const blockCount = 3
+	var (
+		mu          sync.Mutex
+		stagedSizes []int
+		committed   bool
+	)
+	azClient, srv := newTestStorageAndServer(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			mu.Lock()
+			defer mu.Unlock()
+			body, _ := io.ReadAll(r.Body)
+			switch r.URL.Query().Get("comp") {
+			case "block":
+				stagedSizes = append(stagedSizes, len(body))
+			case "blocklist":
+				committed = true
+			}
+			w.WriteHeader(http.StatusCreated)
+		}),
+	)
+	defer srv.Close()
+	azClient.blockSize = BlockSizeMin
+	azClient.parallelism = 2
+
+	err := azClient.PutObject(context.Background(), "foo/bar",
+		bytes.NewReader(make([]byte, blockCount*BlockSizeMin)),
+	)
+	assert.NoError(t, err)
+	mu.Lock()
+	defer mu.Unlock()
+	assert.True(t, committed)
+	assert.Equal(t,
+		[]int{BlockSizeMin, BlockSizeMin, BlockSizeMin},
+		stagedSizes,
+	)
+}
+
+func TestRequestAllowedIPRange(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		AllowedIPRange string
+
+		SignedIP string
+		Error    bool
+	}{
+		"ok, network": {
+			AllowedIPRange: "10.0.0.0/16",
+			SignedIP:       "10.0.0.0-10.0.255.255",
+		},
+		"ok, single host": {
+			AllowedIPRange: "192.168.1.10/32",
+			SignedIP:       "192.168.1.10",
+		},
+		"ok, no restriction": {
+			AllowedIPRange: "",
+			SignedIP:       "",
+		},
+		"error, invalid CIDR": {
+			AllowedIPRange: "10.0.0.0/33",
+			Error:          true,
+		},
+	}
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			azClient, srv := newTestStorageAndServer(
+				http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					w.WriteHeader(http.StatusOK)
+				}),
+			)
+			defer srv.Close()
+			ctx := context.Background()
+
+			getLink, err := azClient.GetRequestWithOptions(
+				ctx, "foo/bar", "bar.mender", time.Minute,
+				&GetRequestOptions{AllowedIPRange: tc.AllowedIPRange},
+			)
+			putLink, putErr := azClient.PutRequestWithOptions(
+				ctx, "foo/bar", time.Minute,
+				&PutRequestOptions{AllowedIPRange: tc.AllowedIPRange},
+			)
+			if tc.Error {
+				var opErr OpError
+				if assert.ErrorAs(t, err, &opErr) {
+					assert.Equal(t, OpGetRequest, opErr.Op)
+					assert.Equal(t, "invalid IP range", opErr.Message)
+				}
+				if assert.ErrorAs(t, putErr, &opErr) {
+					assert.Equal(t, OpPutRequest, opErr.Op)
+					assert.Equal(t, "invalid IP range", opErr.Message)
+				}
+				return
+			}
+			for _, res := range []struct {
+				link *model.Link
+				err  error
+			}{{getLink, err}, {putLink, putErr}} {
+				if assert.NoError(t, res.err) {
+					u, err := url.Parse(res.link.Uri)
+					if assert.NoError(t, err) {
+						assert.Equal(t, tc.SignedIP, u.Query().Get("sip"))
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestProxyURL(t *testing.T) {
+	t.Parallel()
+
+	var proxied int32
+	proxy := httptest.NewServer(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// Requests forwarded by a proxy use the absolute URI.
+			assert.Equal(t, "storage.example.com", r.URL.Host)
+			assert.Equal(t, "/container", r.URL.Path)
+			atomic.AddInt32(&proxied, 1)
+			w.WriteHeader(http.StatusOK)
+		}),
+	)
+	defer proxy.Close()
+	proxyURL, _ := url.Parse(proxy.URL)
+
+	uri := "http://storage.example.com/container"
+	opts := NewOptions().
+		SetSharedKey(SharedKeyCredentials{
+			AccountName: "test",
+			AccountKey:  "test",
+			URI:         &uri,
+		}).
+		SetProxyURL(proxyURL)
+
+	_, err := New(context.Background(), "container", opts)
+	assert.NoError(t, err)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&proxied),
+		"the health check must be routed through the proxy")
+
+	opts.SetHTTPClient(http.DefaultClient)
+	_, err = New(context.Background(), "container", opts)
+	assert.ErrorIs(t, err, ErrProxyURLWithHTTPClient)
+}
+
+// recordingTransport records the requests and responds with an empty body.
+type recordingTransport struct {
+	mu       sync.Mutex
+	requests []*http.Request
+}
+
+func (rt *recordingTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	rt.requests = append(rt.requests, r)
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Content-Length": []string{"0"}},
+		Body:       http.NoBody,
+		Request:    r,
+	}, nil
+}
+
+func (rt *recordingTransport) lastRequest() *http.Request {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	if len(rt.requests) == 0 {
+		return nil
+	}
+	return rt.requests[len(rt.requests)-1]
+}
+
+func TestHTTPClientTransport(t *testing.T) {
+	t.Parallel()
+
+	rt := &recordingTransport{}
+	uri := "https://storage.example.com/container"
+	objStore, err := New(context.Background(), "container", NewOptions().
+		SetSharedKey(SharedKeyCredentials{
+			AccountName: "test",
+			AccountKey:  "test",
+			URI:         &uri,
+		}).
+		SetHTTPClient(&http.Client{Transport: rt}))
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	if req := rt.lastRequest(); assert.NotNil(t, req, "health check") {
+		assert.Equal(t, "/container", req.URL.Path)
+	}
+
+	ctx := context.Background()
+	_, err = objStore.GetRequest(ctx, "foo/bar", "bar.mender", time.Minute)
+	assert.NoError(t, err)
+	if req := rt.lastRequest(); assert.NotNil(t, req) {
+		assert.Equal(t, http.MethodHead, req.Method)
+		assert.Equal(t, "storage.example.com", req.URL.Host)
+		assert.Equal(t, "/container/foo/bar", req.URL.Path)
+	}
+
+	// The clients created for the storage settings in the context use
+	// the same transport.
+	tenantURI := "https://tenant.example.com/tenant-container"
+	ctx = storage.SettingsWithContext(ctx, &model.StorageSettings{
+		Type:   model.StorageTypeAzure,
+		Bucket: "tenant-container",
+		Key:    "tenant",
+		Secret: "dGVuYW50LWtleQ==",
+		Uri:    tenantURI,
+	})
+	for i := 0; i < 2; i++ {
+		_, err = objStore.GetRequest(ctx, "foo/bar", "bar.mender", time.Minute)
+		assert.NoError(t, err)
+		if req := rt.lastRequest(); assert.NotNil(t, req) {
+			assert.Equal(t, "tenant.example.com", req.URL.Host)
+			assert.Equal(t, "/tenant-container/foo/bar", req.URL.Path)
+			assert.Len(t, req.Header.Values("Authorization"), 1)
+		}
+	}
+}
+
+func TestSignedURLSASProperties(t *testing.T) {
+	t.Parallel()
+
+	azClient, srv := newTestStorageAndServer(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		}),
+	)
+	defer srv.Close()
+	ctx := context.Background()
+
+	before := time.Now().Add(-time.Second)
+	getLink, err := azClient.GetRequest(ctx, "foo/bar", "bar.mender", time.Minute)
+	assert.NoError(t, err)
+	putLink, err := azClient.PutRequest(ctx, "foo/bar", time.Minute)
+	assert.NoError(t, err)
+	deleteLink, err := azClient.DeleteRequest(ctx, "foo/bar", time.Minute)
+	assert.NoError(t, err)
+
+	for permissions, link := range map[string]*model.Link{
+		"r":  getLink,
+		"cw": putLink,
+		"d":  deleteLink,
+	} {
+		if !assert.NotNil(t, link) {
+			continue
+		}
+		assert.Equal(t, permissions, link.SASPermissions)
+		u, err := url.Parse(link.Uri)
+		if assert.NoError(t, err) {
+			assert.Equal(t, permissions, u.Query().Get("sp"))
+		}
+		if assert.NotNil(t, link.SASStartTime) {
+			assert.True(t, link.SASStartTime.After(before))
+			assert.True(t, link.SASStartTime.Before(link.Expire))
+			assert.Equal(t, link.SASStartTime, link.NotBefore)
+			assert.NotSame(t, link.SASStartTime, link.NotBefore,
+				"the link times must not share memory")
+		}
+	}
+}
+
+func TestGetRequestSize(t *testing.T) {
+	t.Parallel()
+
+	azClient, srv := newTestStorageAndServer(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/container/foo/bar" {
+				w.Header().Set("Content-Length", "1337")
+			}
+			w.WriteHeader(http.StatusOK)
+		}),
+	)
+	defer srv.Close()
+	ctx := context.Background()
+
+	link, err := azClient.GetRequest(ctx, "foo/bar", "bar.mender", time.Minute)
+	if assert.NoError(t, err) {
+		assert.Equal(t, int64(1337), link.Size)
+	}
+
+	// the size is unknown: the link is generated nonetheless
+	link, err = azClient.GetRequest(ctx, "foo/baz", "baz.mender", time.Minute)
+	if assert.NoError(t, err) {
+		assert.Zero(t, link.Size)
+		b, _ := json.Marshal(link)
+		assert.Contains(t, string(b), `"size":0`)
+	}
+}
+
+func TestPutRequestContentType(t *testing.T) {
+	t.Parallel()
+
+	azClient, srv := newTestStorageAndServer(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		}),
+	)
+	defer srv.Close()
+	ctx := context.Background()
+
+	link, err := azClient.PutRequest(ctx, "foo/bar", time.Minute)
+	if assert.NoError(t, err) {
+		assert.Equal(t, map[string]string{
+			headerBlobType:    blobTypeBlock,
+			headerContentType: "application/vnd-test",
+		}, link.Header)
+	}
+
+	azClient.contentType = nil
+	link, err = azClient.PutRequest(ctx, "foo/bar", time.Minute)
+	if assert.NoError(t, err) {
+		assert.Equal(t, map[string]string{
+			headerBlobType: blobTypeBlock,
+		}, link.Header)
+	}
+}
+
+func TestSASTokenTTL(t *testing.T) {
+	t.Parallel()
+
+	srv := httptest.NewServer(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		}),
+	)
+	defer srv.Close()
+
+	uri := srv.URL + "/container"
+	objStore, err := New(context.Background(), "container", NewOptions().
+		SetSharedKey(SharedKeyCredentials{
+			AccountName: "test",
+			AccountKey:  "test",
+			URI:         &uri,
+		}).
+		SetSASTokenTTL(2*time.Hour))
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	ctx := context.Background()
+
+	now := time.Now()
+	link, err := objStore.GetRequest(ctx, "foo/bar", "bar.mender", 0)
+	if assert.NoError(t, err) {
+		assert.WithinDuration(t, now.Add(2*time.Hour), link.Expire, time.Minute)
+	}
+	link, err = objStore.PutRequest(ctx, "foo/bar", 0)
+	if assert.NoError(t, err) {
+		assert.WithinDuration(t, now.Add(2*time.Hour), link.Expire, time.Minute)
+	}
+	link, err = objStore.DeleteRequest(ctx, "foo/bar", 0)
+	if assert.NoError(t, err) {
+		assert.WithinDuration(t, now.Add(2*time.Hour), link.Expire, time.Minute)
+	}
+
+	// an explicit duration takes precedence
+	link, err = objStore.GetRequest(ctx, "foo/bar", "bar.mender", time.Minute)
+	if assert.NoError(t, err) {
+		assert.WithinDuration(t, now.Add(time.Minute), link.Expire, 30*time.Second)
+	}
+}
+
+func TestHealthCheckInterval(t *testing.T) {
+	t.Parallel()
+
+	var unavailable int32
+	srv := httptest.NewServer(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if atomic.LoadInt32(&unavailable) == 1 {
+				w.Header().Set("x-ms-error-code", "AuthenticationFailed")
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+			w.WriteHeader(http.StatusOK)
+		}),
+	)
+	defer srv.Close()
+
+	uri := srv.URL + "/container"
+	opts := NewOptions().
+		SetSharedKey(SharedKeyCredentials{
+			AccountName: "test",
+			AccountKey:  "test",
+			URI:         &uri,
+		}).
+		SetHealthCheckInterval(10 * time.Millisecond)
+	objStore, err := New(context.Background(), "container", opts)
+	if !assert.NoError(t, err) {
+		return
+	}
+	azClient := objStore.(*client)
+	assert.True(t, azClient.IsHealthy())
+
+	atomic.StoreInt32(&unavailable, 1)
+	assert.Eventually(t, func() bool {
+		return !azClient.IsHealthy()
+	}, 5*time.Second, 10*time.Millisecond)
+
+	atomic.StoreInt32(&unavailable, 0)
+	assert.Eventually(t, azClient.IsHealthy, 5*time.Second, 10*time.Millisecond)
+
+	assert.NoError(t, objStore.Close())
+	assert.NoError(t, objStore.Close(), "Close must be idempotent")
+
+	// No health checks after Close.
+	atomic.StoreInt32(&unavailable, 1)
+	time.Sleep(50 * time.Millisecond)
+	assert.True(t, azClient.IsHealthy())
+}
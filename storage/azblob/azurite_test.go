@@ -0,0 +1,103 @@
+// Copyright 2023 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+//go:build azurite
+// +build azurite
+
+package azblob
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/bloberror"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/blockblob"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/container"
+	"github.com/stretchr/testify/assert"
+)
+
+// azuriteConnectionString is the well-known connection string of the Azurite
+// emulator, used unless TEST_AZURE_CONNECTION_STRING is set.
+const azuriteConnectionString = "DefaultEndpointsProtocol=http;" +
+	"AccountName=devstoreaccount1;" +
+	"AccountKey=Eby8vdM02xNOcqFlqUwJPLlmEtlCDXJ1OUzFT50uSRZ6IFsuFq2UVErCz4I6tq/K1SZFPTOtr/KBHBeksoGMGw==;" +
+	"BlobEndpoint=http://127.0.0.1:10000/devstoreaccount1;"
+
+type zeroReader struct{}
+
+func (zeroReader) Read(b []byte) (int, error) {
+	for i := range b {
+		b[i] = 0
+	}
+	return len(b), nil
+}
+
+func TestAzuritePutObjectBlocks(t *testing.T) {
+	const (
+		objectSize  = 512 * 1024 * 1024
+		blockSize   = 8 * 1024 * 1024
+		parallelism = 4
+	)
+	connStr := *TEST_AZURE_CONNECTION_STRING
+	if connStr == "" {
+		connStr = azuriteConnectionString
+	}
+	containerName := *TEST_AZURE_CONTAINER_NAME
+	if containerName == "" {
+		containerName = "deployments-azurite"
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+	defer cancel()
+
+	cc, err := container.NewClientFromConnectionString(
+		connStr, containerName, &container.ClientOptions{},
+	)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	_, err = cc.Create(ctx, &container.CreateOptions{})
+	if err != nil && !bloberror.HasCode(err, bloberror.ContainerAlreadyExists) {
+		t.Fatalf("failed to create container: %s", err)
+	}
+
+	objStore, err := New(ctx, containerName, NewOptions().
+		SetConnectionString(connStr).
+		SetBlockSize(blockSize).
+		SetParallelism(parallelism))
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	defer objStore.Close()
+
+	const objectPath = "large/firmware.mender"
+	err = objStore.PutObject(ctx, objectPath,
+		io.LimitReader(zeroReader{}, objectSize),
+	)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	defer objStore.DeleteObject(ctx, objectPath)
+
+	blockList, err := cc.NewBlockBlobClient(objectPath).
+		GetBlockList(ctx, blockblob.BlockListTypeCommitted, nil)
+	if assert.NoError(t, err) {
+		assert.Len(t, blockList.CommittedBlocks, objectSize/blockSize)
+	}
+	info, err := objStore.StatObject(ctx, objectPath)
+	if assert.NoError(t, err) && assert.NotNil(t, info.Size) {
+		assert.Equal(t, int64(objectSize), *info.Size)
+	}
+}
@@ -0,0 +1,96 @@
+// Copyright 2023 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package azblob
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/mendersoftware/deployments/storage"
+)
+
+const BulkStatConcurrencyDefault = 8
+
+type BulkStatOptions struct {
+	// Concurrency is the maximum number of concurrent requests.
+	Concurrency int
+}
+
+// BulkStatObjects retrieves the properties of all the objects in paths
+// using up to BulkStatOptions.Concurrency concurrent requests. The returned
+// slice holds one entry per path in the same order, with nil entries for
+// the objects that do not exist.
+func (c *client) BulkStatObjects(
+	ctx context.Context,
+	paths []string,
+	opts ...*BulkStatOptions,
+) ([]*storage.ObjectInfo, error) {
+	concurrency := BulkStatConcurrencyDefault
+	for _, opt := range opts {
+		if opt != nil && opt.Concurrency > 0 {
+			concurrency = opt.Concurrency
+		}
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		wg       sync.WaitGroup
+		errOnce  sync.Once
+		firstErr error
+	)
+	setErr := func(err error) {
+		errOnce.Do(func() {
+			firstErr = err
+			cancel()
+		})
+	}
+	results := make([]*storage.ObjectInfo, len(paths))
+	sem := make(chan struct{}, concurrency)
+	for i := range paths {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if err := ctx.Err(); err != nil {
+			setErr(err)
+			break
+		}
+		wg.Add(1)
+		go func(i int) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			info, err := c.StatObject(ctx, paths[i])
+			if errors.Is(err, storage.ErrObjectNotFound) {
+				return
+			} else if err != nil {
+				setErr(err)
+				return
+			}
+			results[i] = info
+		}(i)
+	}
+	wg.Wait()
+	if firstErr != nil {
+		return nil, OpError{
+			Op:     OpBulkStatObjects,
+			Reason: firstErr,
+		}
+	}
+	return results, nil
+}
@@ -0,0 +1,114 @@
+// Copyright 2023 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package azblob
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func bulkStatHandler(delay time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(delay)
+		switch {
+		case strings.HasPrefix(r.URL.Path, "/container/missing"):
+			w.Header().Set("x-ms-error-code", "BlobNotFound")
+			w.WriteHeader(http.StatusNotFound)
+		case strings.HasPrefix(r.URL.Path, "/container/forbidden"):
+			w.Header().Set("x-ms-error-code", "AuthorizationFailure")
+			w.WriteHeader(http.StatusForbidden)
+		default:
+			w.Header().Set("Content-Length", "123")
+			w.WriteHeader(http.StatusOK)
+		}
+	}
+}
+
+func TestBulkStatObjects(t *testing.T) {
+	t.Parallel()
+
+	azClient, srv := newTestStorageAndServer(bulkStatHandler(0))
+	defer srv.Close()
+	ctx := context.Background()
+
+	paths := []string{"foo", "missing/bar", "baz", "missing/qux", "quux"}
+	for _, concurrency := range []int{0, 1, 2, 16} {
+		infos, err := azClient.BulkStatObjects(
+			ctx, paths, &BulkStatOptions{Concurrency: concurrency},
+		)
+		if assert.NoError(t, err) && assert.Len(t, infos, len(paths)) {
+			for i, path := range paths {
+				if strings.HasPrefix(path, "missing") {
+					assert.Nil(t, infos[i])
+				} else if assert.NotNil(t, infos[i]) {
+					assert.Equal(t, path, infos[i].Path)
+					if assert.NotNil(t, infos[i].Size) {
+						assert.Equal(t, int64(123), *infos[i].Size)
+					}
+				}
+			}
+		}
+	}
+
+	infos, err := azClient.BulkStatObjects(ctx, nil)
+	assert.NoError(t, err)
+	assert.Empty(t, infos)
+
+	infos, err = azClient.BulkStatObjects(
+		ctx, []string{"foo", "forbidden", "bar"},
+	)
+	var opErr OpError
+	if assert.ErrorAs(t, err, &opErr) {
+		assert.Equal(t, OpBulkStatObjects, opErr.Op)
+	}
+	assert.Nil(t, infos)
+
+	canceledCtx, cancel := context.WithCancel(ctx)
+	cancel()
+	_, err = azClient.BulkStatObjects(canceledCtx, paths)
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func BenchmarkBulkStatObjects(b *testing.B) {
+	azClient, srv := newTestStorageAndServer(bulkStatHandler(time.Millisecond))
+	defer srv.Close()
+	ctx := context.Background()
+
+	paths := make([]string, 100)
+	for i := range paths {
+		paths[i] = fmt.Sprintf("artifact-%d", i)
+	}
+	for _, concurrency := range []int{1, BulkStatConcurrencyDefault, 32} {
+		name := fmt.Sprintf("concurrency=%d", concurrency)
+		if concurrency == 1 {
+			name = "sequential"
+		}
+		b.Run(name, func(b *testing.B) {
+			opts := &BulkStatOptions{Concurrency: concurrency}
+			for i := 0; i < b.N; i++ {
+				_, err := azClient.BulkStatObjects(ctx, paths, opts)
+				if err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,219 @@
+// Copyright 2023 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package azblob
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/streaming"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/blob"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/bloberror"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/blockblob"
+
+	"github.com/mendersoftware/deployments/storage"
+)
+
+// ChecksumAlgorithm identifies the hash function used to compute a blob
+// checksum.
+type ChecksumAlgorithm string
+
+const (
+	ChecksumAlgorithmMD5    ChecksumAlgorithm = "md5"
+	ChecksumAlgorithmSHA256 ChecksumAlgorithm = "sha256"
+
+	// metadataKeyChecksumPrefix is the blob metadata key prefix holding
+	// the hex encoded checksum, e.g. "checksum_sha256".
+	metadataKeyChecksumPrefix = "checksum_"
+)
+
+// GetObjectChecksum returns the hex encoded checksum of the blob stored in
+// the blob metadata. For MD5, the Content-MD5 blob property is used if the
+// metadata is not present.
+func (c *client) GetObjectChecksum(
+	ctx context.Context,
+	path string,
+	algo ChecksumAlgorithm,
+) (string, error) {
+	azClient, err := c.clientFromContext(ctx)
+	if err != nil {
+		return "", OpError{
+			Op:     OpGetObjectChecksum,
+			Reason: err,
+		}
+	}
+	bc := azClient.NewBlockBlobClient(c.blobPath(path))
+	rsp, err := bc.GetProperties(ctx, &blob.GetPropertiesOptions{})
+	if bloberror.HasCode(err,
+		bloberror.BlobNotFound,
+		bloberror.ContainerNotFound,
+		bloberror.ResourceNotFound,
+	) {
+		err = &storage.ObjectNotFoundError{Path: path}
+	}
+	if err != nil {
+		return "", OpError{
+			Op:      OpGetObjectChecksum,
+			Message: "failed to retrieve object properties",
+			Reason:  err,
+		}
+	}
+	key := metadataKeyChecksumPrefix + string(algo)
+	for k, v := range rsp.Metadata {
+		// The SDK returns the metadata keys in canonical header format.
+		if strings.EqualFold(k, key) && v != nil {
+			return strings.ToLower(*v), nil
+		}
+	}
+	if algo == ChecksumAlgorithmMD5 && len(rsp.ContentMD5) > 0 {
+		return hex.EncodeToString(rsp.ContentMD5), nil
+	}
+	return "", OpError{
+		Op:     OpGetObjectChecksum,
+		Reason: ErrChecksumNotFound,
+	}
+}
+
+// ValidateBlob compares the checksum of the stored blob with
+// expectedChecksum (hex encoded) and returns storage.ErrChecksumMismatch if
+// they differ.
+func (c *client) ValidateBlob(
+	ctx context.Context,
+	path string,
+	expectedChecksum string,
+	algo ChecksumAlgorithm,
+) error {
+	checksum, err := c.GetObjectChecksum(ctx, path, algo)
+	if err != nil {
+		return err
+	}
+	if !strings.EqualFold(checksum, expectedChecksum) {
+		return OpError{
+			Op:     OpValidateBlob,
+			Reason: storage.ErrChecksumMismatch,
+		}
+	}
+	return nil
+}
+
+// PutObjectChecked uploads the object like PutObject and verifies its
+// integrity: every block is staged with its MD5 checksum, so that the storage
+// service rejects the blocks corrupted in transit, and the blocks are only
+// committed if the MD5 checksum of src matches expectedMD5. A mismatch is
+// reported with a *ChecksumMismatchError.
+func (c *client) PutObjectChecked(
+	ctx context.Context,
+	objectPath string,
+	src io.Reader,
+	expectedMD5 []byte,
+) error {
+	azClient, err := c.clientFromContext(ctx)
+	if err != nil {
+		return OpError{
+			Op:     OpPutObjectChecked,
+			Reason: err,
+		}
+	}
+	bc := azClient.NewBlockBlobClient(c.blobPath(objectPath))
+	srcHash := md5.New()
+	body := io.TeeReader(src, srcHash)
+	headers := &blob.HTTPHeaders{BlobContentType: c.contentType}
+	if c.compression == CompressionGzip {
+		headers.BlobContentEncoding = to.Ptr(string(CompressionGzip))
+		pr, stop := gzipStream(body)
+		defer stop()
+		body = pr
+	}
+	blockSize := c.blockSize
+	if blockSize <= 0 {
+		blockSize = c.bufferSize
+	}
+	if blockSize < BlockSizeMin {
+		blockSize = BlockSizeMin
+	}
+	var (
+		blockIDs []string
+		blobHash = md5.New()
+		buf      = make([]byte, blockSize)
+	)
+	for {
+		n, errRead := io.ReadFull(body, buf)
+		if n > 0 {
+			block := buf[:n]
+			blockMD5 := md5.Sum(block)
+			blobHash.Write(block)
+			// Block IDs must have the same length within a blob.
+			blockID := base64.StdEncoding.EncodeToString(
+				[]byte(fmt.Sprintf("%08d", len(blockIDs))),
+			)
+			_, err = bc.StageBlock(ctx, blockID,
+				streaming.NopCloser(bytes.NewReader(block)),
+				&blockblob.StageBlockOptions{
+					TransactionalValidation: blob.TransferValidationTypeMD5(
+						blockMD5[:],
+					),
+				},
+			)
+			if bloberror.HasCode(err, bloberror.MD5Mismatch) {
+				err = &ChecksumMismatchError{Expected: blockMD5[:]}
+			}
+			if err != nil {
+				return OpError{
+					Op:      OpPutObjectChecked,
+					Message: "failed to stage block",
+					Reason:  err,
+				}
+			}
+			blockIDs = append(blockIDs, blockID)
+		}
+		if errRead == io.EOF || errRead == io.ErrUnexpectedEOF {
+			break
+		} else if errRead != nil {
+			return OpError{
+				Op:      OpPutObjectChecked,
+				Message: "failed to read object",
+				Reason:  errRead,
+			}
+		}
+	}
+	if actual := srcHash.Sum(nil); !bytes.Equal(actual, expectedMD5) {
+		return OpError{
+			Op: OpPutObjectChecked,
+			Reason: &ChecksumMismatchError{
+				Expected: expectedMD5,
+				Actual:   actual,
+			},
+		}
+	}
+	headers.BlobContentMD5 = blobHash.Sum(nil)
+	_, err = bc.CommitBlockList(ctx, blockIDs, &blockblob.CommitBlockListOptions{
+		HTTPHeaders: headers,
+	})
+	if err != nil {
+		return OpError{
+			Op:      OpPutObjectChecked,
+			Message: "failed to commit blocks",
+			Reason:  err,
+		}
+	}
+	return nil
+}
@@ -0,0 +1,224 @@
+// Copyright 2023 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package azblob
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/base64"
+	"errors"
+	"io"
+	"net/http"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mendersoftware/deployments/storage"
+)
+
+func TestValidateBlob(t *testing.T) {
+	t.Parallel()
+
+	const checksum = "c0535e4be2b79ffd93291305436bf889314e4a3faec05ecffcbb7df31ad9e51a"
+
+	type testCase struct {
+		Name string
+
+		Algorithm ChecksumAlgorithm
+		Expected  string
+		Header    http.Header
+
+		Error   error
+		ErrorOp string
+	}
+	testCases := []testCase{{
+		Name: "ok",
+
+		Algorithm: ChecksumAlgorithmSHA256,
+		Expected:  checksum,
+		Header: http.Header{
+			"x-ms-meta-checksum_sha256": []string{checksum},
+		},
+	}, {
+		Name: "ok/md5 from blob properties",
+
+		Algorithm: ChecksumAlgorithmMD5,
+		Expected:  "3858f62230ac3c915f300c664312c63f",
+		Header: http.Header{
+			"Content-MD5": []string{
+				base64.StdEncoding.EncodeToString([]byte{
+					0x38, 0x58, 0xf6, 0x22, 0x30, 0xac, 0x3c, 0x91,
+					0x5f, 0x30, 0x0c, 0x66, 0x43, 0x12, 0xc6, 0x3f,
+				}),
+			},
+		},
+	}, {
+		Name: "error/checksum mismatch",
+
+		Algorithm: ChecksumAlgorithmSHA256,
+		Expected:  "deadbeef",
+		Header: http.Header{
+			"x-ms-meta-checksum_sha256": []string{checksum},
+		},
+		Error:   storage.ErrChecksumMismatch,
+		ErrorOp: OpValidateBlob,
+	}, {
+		Name: "error/missing metadata",
+
+		Algorithm: ChecksumAlgorithmSHA256,
+		Expected:  checksum,
+		Header:    http.Header{},
+		Error:     ErrChecksumNotFound,
+		ErrorOp:   OpGetObjectChecksum,
+	}}
+	for i := range testCases {
+		tc := testCases[i]
+		t.Run(tc.Name, func(t *testing.T) {
+			t.Parallel()
+			azClient, srv := newTestStorageAndServer(
+				http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					assert.Equal(t, http.MethodHead, r.Method)
+					assert.Equal(t, "/container/foo/bar", r.URL.Path)
+					for key, values := range tc.Header {
+						for _, value := range values {
+							w.Header().Add(key, value)
+						}
+					}
+					w.WriteHeader(http.StatusOK)
+				}),
+			)
+			defer srv.Close()
+
+			err := azClient.ValidateBlob(
+				context.Background(), "foo/bar", tc.Expected, tc.Algorithm,
+			)
+			if tc.Error != nil {
+				assert.ErrorIs(t, err, tc.Error)
+				var opErr OpError
+				if assert.ErrorAs(t, err, &opErr) {
+					assert.Equal(t, tc.ErrorOp, opErr.Op)
+					assert.False(t, errors.As(opErr.Reason, &OpError{}),
+						"the operation error must not be wrapped twice")
+				}
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestPutObjectChecked(t *testing.T) {
+	t.Parallel()
+
+	payload := bytes.Repeat([]byte("foobar"), BlockSizeMin/2)
+	payloadMD5 := md5.Sum(payload)
+
+	type testCase struct {
+		Name string
+
+		Expected     []byte
+		RejectBlocks bool
+
+		Blocks    int
+		Committed bool
+		Error     error
+	}
+	testCases := []testCase{{
+		Name: "ok",
+
+		Expected: payloadMD5[:],
+
+		Blocks:    3,
+		Committed: true,
+	}, {
+		Name: "error/checksum mismatch",
+
+		Expected: []byte("deadbeefdeadbeef"),
+
+		Blocks: 3,
+		Error:  storage.ErrChecksumMismatch,
+	}, {
+		Name: "error/block rejected by the service",
+
+		Expected:     payloadMD5[:],
+		RejectBlocks: true,
+
+		Blocks: 1,
+		Error:  storage.ErrChecksumMismatch,
+	}}
+	for i := range testCases {
+		tc := testCases[i]
+		t.Run(tc.Name, func(t *testing.T) {
+			t.Parallel()
+			var (
+				mu        sync.Mutex
+				blocks    int
+				committed bool
+			)
+			azClient, srv := newTestStorageAndServer(
+				http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					mu.Lock()
+					defer mu.Unlock()
+					body, _ := io.ReadAll(r.Body)
+					switch r.URL.Query().Get("comp") {
+					case "block":
+						blocks++
+						blockMD5 := md5.Sum(body)
+						assert.Equal(t,
+							base64.StdEncoding.EncodeToString(blockMD5[:]),
+							r.Header.Get("Content-MD5"),
+						)
+						if tc.RejectBlocks {
+							w.Header().Set("x-ms-error-code", "Md5Mismatch")
+							w.WriteHeader(http.StatusBadRequest)
+							return
+						}
+					case "blocklist":
+						committed = true
+						assert.Equal(t,
+							base64.StdEncoding.EncodeToString(payloadMD5[:]),
+							r.Header.Get("x-ms-blob-content-md5"),
+						)
+					}
+					w.WriteHeader(http.StatusCreated)
+				}),
+			)
+			defer srv.Close()
+			azClient.blockSize = BlockSizeMin
+
+			err := azClient.PutObjectChecked(
+				context.Background(), "foo/bar",
+				bytes.NewReader(payload), tc.Expected,
+			)
+			if tc.Error != nil {
+				assert.ErrorIs(t, err, tc.Error)
+				var mismatch *ChecksumMismatchError
+				if assert.True(t, errors.As(err, &mismatch)) &&
+					!tc.RejectBlocks {
+					assert.Equal(t, tc.Expected, mismatch.Expected)
+					assert.Equal(t, payloadMD5[:], mismatch.Actual)
+				}
+			} else {
+				assert.NoError(t, err)
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			assert.Equal(t, tc.Blocks, blocks)
+			assert.Equal(t, tc.Committed, committed)
+		})
+	}
+}
@@ -0,0 +1,142 @@
+// Copyright 2023 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package azblob
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/blob"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/bloberror"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/blockblob"
+
+	"github.com/mendersoftware/deployments/storage"
+)
+
+// copyPollInterval is the interval between two checks of the status of a
+// pending copy.
+var copyPollInterval = time.Second
+
+// CopyObjectFromURL copies the object at srcURL to dstPath and waits for the
+// copy to complete. The source is read by the Azure Storage service, not by
+// the client: srcURL must be reachable from the service, e.g. a blob URL
+// with a SAS token or a publicly accessible URL, and is passed unchanged.
+func (c *client) CopyObjectFromURL(
+	ctx context.Context,
+	dstPath string,
+	srcURL string,
+) error {
+	azClient, err := c.clientFromContext(ctx)
+	if err != nil {
+		return OpError{
+			Op:     OpCopyObjectFromURL,
+			Reason: err,
+		}
+	}
+	return copyFromURL(ctx, OpCopyObjectFromURL,
+		azClient.NewBlockBlobClient(c.blobPath(dstPath)), srcURL)
+}
+
+// CopyObject copies the object at srcPath to dstPath within the container
+// and waits for the copy to complete.
+func (c *client) CopyObject(ctx context.Context, srcPath, dstPath string) error {
+	azClient, err := c.clientFromContext(ctx)
+	if err != nil {
+		return OpError{
+			Op:     OpCopyObject,
+			Reason: err,
+		}
+	}
+	srcURL := azClient.NewBlobClient(c.blobPath(srcPath)).URL()
+	err = copyFromURL(ctx, OpCopyObject,
+		azClient.NewBlockBlobClient(c.blobPath(dstPath)), srcURL)
+	if isCopySourceNotFound(err) {
+		return OpError{
+			Op:      OpCopyObject,
+			Message: "failed to start copy",
+			Reason:  &storage.ObjectNotFoundError{Path: srcPath},
+		}
+	}
+	return err
+}
+
+// isCopySourceNotFound returns true if the copy failed because the source
+// blob does not exist: the service reports a missing source as a 404
+// CannotVerifyCopySource error.
+func isCopySourceNotFound(err error) bool {
+	if bloberror.HasCode(err,
+		bloberror.BlobNotFound,
+		bloberror.ContainerNotFound,
+		bloberror.ResourceNotFound,
+	) {
+		return true
+	}
+	var respErr *azcore.ResponseError
+	return bloberror.HasCode(err, bloberror.CannotVerifyCopySource) &&
+		errors.As(err, &respErr) && respErr.StatusCode == http.StatusNotFound
+}
+
+func copyFromURL(
+	ctx context.Context,
+	op string,
+	bc *blockblob.Client,
+	srcURL string,
+) error {
+	rsp, err := bc.StartCopyFromURL(ctx, srcURL, &blob.StartCopyFromURLOptions{})
+	if err != nil {
+		return OpError{
+			Op:      op,
+			Message: "failed to start copy",
+			Reason:  err,
+		}
+	}
+	status := rsp.CopyStatus
+	var description *string
+	for status != nil && *status == blob.CopyStatusTypePending {
+		select {
+		case <-ctx.Done():
+			return OpError{
+				Op:      op,
+				Message: "copy did not complete",
+				Reason:  ctx.Err(),
+			}
+		case <-time.After(copyPollInterval):
+		}
+		props, err := bc.GetProperties(ctx, &blob.GetPropertiesOptions{})
+		if err != nil {
+			return OpError{
+				Op:      op,
+				Message: "failed to retrieve copy status",
+				Reason:  err,
+			}
+		}
+		status, description = props.CopyStatus, props.CopyStatusDescription
+	}
+	if status != nil && *status != blob.CopyStatusTypeSuccess {
+		reason := string(*status)
+		if description != nil {
+			reason += ": " + *description
+		}
+		return OpError{
+			Op:      op,
+			Message: "copy failed",
+			Reason:  errors.New(reason),
+		}
+	}
+	return nil
+}
@@ -0,0 +1,211 @@
+// Copyright 2023 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package azblob
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mendersoftware/deployments/storage"
+)
+
+func TestCopyObjectFromURL(t *testing.T) {
+	copyPollInterval = time.Millisecond
+
+	// The source is only referenced by the copy request; the storage
+	// service is responsible for reading it.
+	src := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		},
+	))
+	defer src.Close()
+	srcURL := src.URL + "/artifacts/foo?sv=2020-10-02&sp=r&sig=c2lnbmF0dXJl"
+
+	testCases := map[string]struct {
+		StartStatus string
+		StartCode   int
+		// PollStatuses are returned by the successive status checks.
+		PollStatuses []string
+
+		Error string
+	}{
+		"ok": {
+			StartStatus: "success",
+		},
+		"ok, pending": {
+			StartStatus:  "pending",
+			PollStatuses: []string{"pending", "success"},
+		},
+		"error, copy failed": {
+			StartStatus:  "pending",
+			PollStatuses: []string{"failed"},
+			Error:        "azblob CopyObjectFromURL: copy failed: failed: 403 Forbidden",
+		},
+		"error, copy not started": {
+			StartCode: http.StatusForbidden,
+			Error:     "azblob CopyObjectFromURL: failed to start copy",
+		},
+	}
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			var polls int32
+			azClient, srv := newTestStorageAndServer(http.HandlerFunc(
+				func(w http.ResponseWriter, r *http.Request) {
+					assert.Equal(t, "/container/foo/bar", r.URL.Path)
+					switch r.Method {
+					case http.MethodPut:
+						assert.Equal(t, srcURL, r.Header.Get("x-ms-copy-source"))
+						if tc.StartCode != 0 {
+							w.Header().Set("x-ms-error-code", "CannotVerifyCopySource")
+							w.WriteHeader(tc.StartCode)
+							return
+						}
+						w.Header().Set("x-ms-copy-id", "copy-id")
+						w.Header().Set("x-ms-copy-status", tc.StartStatus)
+						w.WriteHeader(http.StatusAccepted)
+					case http.MethodHead:
+						i := int(atomic.AddInt32(&polls, 1)) - 1
+						if !assert.Less(t, i, len(tc.PollStatuses)) {
+							w.WriteHeader(http.StatusBadRequest)
+							return
+						}
+						w.Header().Set("x-ms-copy-status", tc.PollStatuses[i])
+						if tc.PollStatuses[i] == "failed" {
+							w.Header().Set("x-ms-copy-status-description",
+								"403 Forbidden")
+						}
+						w.WriteHeader(http.StatusOK)
+					default:
+						t.Errorf("unexpected request method %s", r.Method)
+					}
+				},
+			))
+			defer srv.Close()
+
+			err := azClient.CopyObjectFromURL(context.Background(), "foo/bar", srcURL)
+			if tc.Error != "" {
+				var opErr OpError
+				if assert.ErrorAs(t, err, &opErr) {
+					assert.Equal(t, OpCopyObjectFromURL, opErr.Op)
+				}
+				assert.Contains(t, err.Error(), tc.Error)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, len(tc.PollStatuses), int(atomic.LoadInt32(&polls)))
+		})
+	}
+}
+
+func TestCopyObject(t *testing.T) {
+	copyPollInterval = time.Millisecond
+
+	testCases := map[string]struct {
+		StartCode      int
+		StartErrorCode string
+		PollStatuses   []string
+
+		Error      error
+		ErrorClass storage.ErrorClass
+	}{
+		"ok": {},
+		"ok, pending": {
+			PollStatuses: []string{"pending", "success"},
+		},
+		"error, source not found": {
+			StartCode:      http.StatusNotFound,
+			StartErrorCode: "CannotVerifyCopySource",
+			Error:          storage.ErrObjectNotFound,
+			ErrorClass:     storage.ErrorClassNotFound,
+		},
+		"error, container not found": {
+			StartCode:      http.StatusNotFound,
+			StartErrorCode: "ContainerNotFound",
+			Error:          storage.ErrObjectNotFound,
+			ErrorClass:     storage.ErrorClassNotFound,
+		},
+		"error, permission denied": {
+			StartCode:      http.StatusForbidden,
+			StartErrorCode: "AuthorizationPermissionMismatch",
+			ErrorClass:     storage.ErrorClassPermission,
+		},
+	}
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			var polls int32
+			azClient, srv := newTestStorageAndServer(http.HandlerFunc(
+				func(w http.ResponseWriter, r *http.Request) {
+					assert.Equal(t, "/container/foo/dst", r.URL.Path)
+					switch r.Method {
+					case http.MethodPut:
+						src, err := url.Parse(r.Header.Get("x-ms-copy-source"))
+						if assert.NoError(t, err) {
+							assert.Equal(t, "/container/foo/src", src.Path)
+						}
+						if tc.StartCode != 0 {
+							w.Header().Set("x-ms-error-code", tc.StartErrorCode)
+							w.WriteHeader(tc.StartCode)
+							return
+						}
+						status := "success"
+						if len(tc.PollStatuses) > 0 {
+							status = "pending"
+						}
+						w.Header().Set("x-ms-copy-id", "copy-id")
+						w.Header().Set("x-ms-copy-status", status)
+						w.WriteHeader(http.StatusAccepted)
+					case http.MethodHead:
+						i := int(atomic.AddInt32(&polls, 1)) - 1
+						if !assert.Less(t, i, len(tc.PollStatuses)) {
+							w.WriteHeader(http.StatusBadRequest)
+							return
+						}
+						w.Header().Set("x-ms-copy-status", tc.PollStatuses[i])
+						w.WriteHeader(http.StatusOK)
+					default:
+						t.Errorf("unexpected request method %s", r.Method)
+					}
+				},
+			))
+			defer srv.Close()
+
+			err := azClient.CopyObject(context.Background(), "foo/src", "foo/dst")
+			if tc.ErrorClass == storage.ErrorClassUnknown {
+				assert.NoError(t, err)
+				return
+			}
+			var opErr OpError
+			if assert.ErrorAs(t, err, &opErr) {
+				assert.Equal(t, OpCopyObject, opErr.Op)
+			}
+			if tc.Error != nil {
+				assert.ErrorIs(t, err, tc.Error)
+			}
+			assert.Equal(t, tc.ErrorClass, storage.ClassifyError(err))
+		})
+	}
+}
@@ -14,7 +14,12 @@
 
 package azblob
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+
+	"github.com/mendersoftware/deployments/storage"
+)
 
 type OpError struct {
 	Op      string
@@ -49,9 +54,51 @@ const (
 	OpGetRequest    = "GetRequest"
 	OpDeleteRequest = "DeleteRequest"
 	OpPutRequest    = "PutRequest"
+
+	OpGetObjectWithMetadata = "GetObjectWithMetadata"
+	OpGetObjectReader       = "GetObjectReader"
+	OpGetObjectChecksum     = "GetObjectChecksum"
+	OpValidateBlob          = "ValidateBlob"
+	OpBulkStatObjects       = "BulkStatObjects"
+	OpHeadObject            = "HeadObject"
+	OpCopyObjectFromURL     = "CopyObjectFromURL"
+	OpCopyObject            = "CopyObject"
+	OpListObjects           = "ListObjects"
+	OpPutObjectChecked      = "PutObjectChecked"
+
+	OpSetImmutabilityPolicy    = "SetImmutabilityPolicy"
+	OpGetImmutabilityPolicy    = "GetImmutabilityPolicy"
+	OpDeleteImmutabilityPolicy = "DeleteImmutabilityPolicy"
 )
 
 var (
 	ErrStorageSettings = errors.New("storage settings invalid")
 	ErrEmptyClient     = errors.New("storage client not configured")
+
+	ErrChecksumNotFound = errors.New("object checksum not found in metadata")
+
+	ErrImmutabilityPolicyExists = errors.New("object already has an immutability policy")
 )
+
+// ChecksumMismatchError is returned by PutObjectChecked when the uploaded
+// content does not match its MD5 checksum. Actual is nil if the storage
+// service rejected a block corrupted in transit.
+type ChecksumMismatchError struct {
+	Expected []byte
+	Actual   []byte
+}
+
+func (err *ChecksumMismatchError) Error() string {
+	if err.Actual == nil {
+		return fmt.Sprintf(
+			"checksum mismatch: block with md5 %x rejected by the storage service",
+			err.Expected,
+		)
+	}
+	return fmt.Sprintf("checksum mismatch: expected md5 %x, got %x",
+		err.Expected, err.Actual)
+}
+
+func (err *ChecksumMismatchError) Is(target error) bool {
+	return target == storage.ErrChecksumMismatch
+}
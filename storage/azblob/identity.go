@@ -0,0 +1,131 @@
+// Copyright 2023 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package azblob
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/blob"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/container"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/sas"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/service"
+)
+
+const (
+	// userDelegationKeyTTL is how long a user delegation key outlives the
+	// signed URL it was requested for, so that it can sign the following
+	// URLs too.
+	userDelegationKeyTTL = time.Hour
+	// userDelegationKeyMaxTTL is the longest validity of a user delegation
+	// key accepted by Azure.
+	userDelegationKeyMaxTTL = 7 * 24 * time.Hour
+)
+
+// newTokenCredential returns the Azure AD credential used with the
+// UseManagedIdentity option.
+var newTokenCredential = func(
+	opts *azidentity.DefaultAzureCredentialOptions,
+) (azcore.TokenCredential, error) {
+	return azidentity.NewDefaultAzureCredential(opts)
+}
+
+// containerURL returns the URL of the container used with the
+// UseManagedIdentity option.
+func (opts *Options) containerURL(containerName string) string {
+	if opts.ContainerURI != nil {
+		return *opts.ContainerURI
+	}
+	return fmt.Sprintf(
+		"https://%s.blob.core.windows.net/%s",
+		opts.AccountName, containerName,
+	)
+}
+
+// newManagedIdentityClients creates the container client authenticated
+// with the Azure AD credential, and the service client requesting the user
+// delegation keys which sign the URLs.
+func (c *client) newManagedIdentityClients(
+	containerURL string,
+) (*container.Client, *service.Client, error) {
+	credOptions := &azidentity.DefaultAzureCredentialOptions{}
+	if c.transport != nil {
+		credOptions.ClientOptions.Transport = c.transport
+	}
+	cred, err := newTokenCredential(credOptions)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get the Azure AD credential: %w", err)
+	}
+	urlParts, err := blob.ParseURL(containerURL)
+	if err != nil {
+		return nil, nil, err
+	}
+	serviceURL := urlParts.Scheme + "://" + urlParts.Host
+	if urlParts.IPEndpointStyleInfo.AccountName != "" {
+		// e.g. Azurite: http://127.0.0.1:10000/<account>/<container>
+		serviceURL += "/" + urlParts.IPEndpointStyleInfo.AccountName
+	}
+	cc, err := container.NewClient(containerURL, cred, c.clientOptions())
+	if err != nil {
+		return nil, nil, err
+	}
+	svcOptions := &service.ClientOptions{}
+	if c.transport != nil {
+		svcOptions.Transport = c.transport
+	}
+	svc, err := service.NewClient(serviceURL, cred, svcOptions)
+	if err != nil {
+		return nil, nil, err
+	}
+	return cc, svc, nil
+}
+
+// userDelegationCredential returns a user delegation key valid until at
+// least expiry to sign the URLs with. The key is reused until it expires.
+func (c *client) userDelegationCredential(
+	ctx context.Context,
+	expiry time.Time,
+) (*sas.UserDelegationCredential, error) {
+	c.delegationMu.Lock()
+	defer c.delegationMu.Unlock()
+	if c.delegationKey != nil && !c.delegationKeyExpiry.Before(expiry) {
+		return c.delegationKey, nil
+	}
+	start := time.Now().UTC()
+	keyExpiry := expiry.Add(userDelegationKeyTTL).UTC()
+	if keyExpiry.Sub(start) > userDelegationKeyMaxTTL {
+		keyExpiry = start.Add(userDelegationKeyMaxTTL)
+	}
+	if keyExpiry.Before(expiry) {
+		return nil, fmt.Errorf(
+			"signed URLs with managed identity cannot be valid for more than %s",
+			userDelegationKeyMaxTTL,
+		)
+	}
+	key, err := c.serviceClient.GetUserDelegationCredential(ctx, service.KeyInfo{
+		Start:  to.Ptr(start.Format(sas.TimeFormat)),
+		Expiry: to.Ptr(keyExpiry.Format(sas.TimeFormat)),
+	}, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get a user delegation key: %w", err)
+	}
+	c.delegationKey = key
+	c.delegationKeyExpiry = keyExpiry
+	return key, nil
+}
@@ -0,0 +1,142 @@
+// Copyright 2023 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package azblob
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeTokenCredential struct {
+	token string
+}
+
+func (cred fakeTokenCredential) GetToken(
+	ctx context.Context,
+	opts policy.TokenRequestOptions,
+) (azcore.AccessToken, error) {
+	return azcore.AccessToken{
+		Token:     cred.token,
+		ExpiresOn: time.Now().Add(time.Hour),
+	}, nil
+}
+
+const testUserDelegationKey = `<?xml version="1.0" encoding="utf-8"?>
+<UserDelegationKey>
+  <SignedOid>f826484e-1157-4109-af21-304e6d711560</SignedOid>
+  <SignedTid>b532b01a-9313-404f-8d19-e7fcbe5cc347</SignedTid>
+  <SignedStart>2023-01-01T00:00:00Z</SignedStart>
+  <SignedExpiry>2023-01-02T00:00:00Z</SignedExpiry>
+  <SignedService>b</SignedService>
+  <SignedVersion>2020-10-02</SignedVersion>
+  <Value>dGVzdC1rZXk=</Value>
+</UserDelegationKey>`
+
+func TestManagedIdentity(t *testing.T) {
+	var (
+		unauthorized int32
+		keyRequests  int32
+	)
+	srv := httptest.NewTLSServer(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("Authorization") != "Bearer managed-identity" {
+				atomic.AddInt32(&unauthorized, 1)
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			if r.URL.Query().Get("comp") == "userdelegationkey" {
+				atomic.AddInt32(&keyRequests, 1)
+				assert.Equal(t, http.MethodPost, r.Method)
+				assert.Equal(t, "/account", r.URL.Path)
+				w.Header().Set("Content-Type", "application/xml")
+				w.WriteHeader(http.StatusOK)
+				_, _ = w.Write([]byte(testUserDelegationKey))
+				return
+			}
+			w.WriteHeader(http.StatusOK)
+		}),
+	)
+	defer srv.Close()
+
+	var credOptions *azidentity.DefaultAzureCredentialOptions
+	newTokenCredentialOrig := newTokenCredential
+	defer func() { newTokenCredential = newTokenCredentialOrig }()
+	newTokenCredential = func(
+		opts *azidentity.DefaultAzureCredentialOptions,
+	) (azcore.TokenCredential, error) {
+		credOptions = opts
+		return fakeTokenCredential{token: "managed-identity"}, nil
+	}
+
+	ctx := context.Background()
+	objStore, err := New(ctx, "container", NewOptions().
+		SetUseManagedIdentity(true).
+		SetContainerURI(srv.URL+"/account/container").
+		SetHTTPClient(srv.Client()))
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	if assert.NotNil(t, credOptions) {
+		assert.NotNil(t, credOptions.ClientOptions.Transport,
+			"the credential must use the configured HTTP client")
+	}
+
+	for i := 0; i < 2; i++ {
+		link, err := objStore.GetRequest(ctx, "foo/bar", "bar.mender", time.Minute)
+		if !assert.NoError(t, err) {
+			t.FailNow()
+		}
+		uri, err := url.Parse(link.Uri)
+		if assert.NoError(t, err) {
+			q := uri.Query()
+			assert.Equal(t, "f826484e-1157-4109-af21-304e6d711560", q.Get("skoid"))
+			assert.NotEmpty(t, q.Get("sig"))
+		}
+	}
+	assert.Equal(t, int32(1), atomic.LoadInt32(&keyRequests),
+		"the user delegation key must be reused")
+	assert.Zero(t, atomic.LoadInt32(&unauthorized))
+
+	_, err = objStore.GetRequest(ctx, "foo/bar", "bar.mender", 8*24*time.Hour)
+	assert.Error(t, err, "user delegation keys expire within 7 days")
+}
+
+func TestOptionsValidateManagedIdentity(t *testing.T) {
+	t.Parallel()
+
+	assert.ErrorIs(t,
+		NewOptions().SetUseManagedIdentity(true).Validate(),
+		ErrManagedIdentityNoAccount)
+	assert.NoError(t, NewOptions().
+		SetUseManagedIdentity(true).
+		SetAccountName("account").
+		Validate())
+	assert.NoError(t, NewOptions().
+		SetUseManagedIdentity(true).
+		SetContainerURI("https://account.example.com/container").
+		Validate())
+	assert.Equal(t, "https://account.blob.core.windows.net/container",
+		NewOptions().SetAccountName("account").containerURL("container"))
+}
@@ -0,0 +1,182 @@
+// Copyright 2023 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package azblob
+
+import (
+	"context"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/blob"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/bloberror"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/blockblob"
+
+	"github.com/mendersoftware/deployments/storage"
+)
+
+// ImmutabilityMode is the mode of a blob immutability policy.
+//
+// Immutability policies require version-level immutability support to be
+// enabled on the storage account or on the container, otherwise the storage
+// service rejects the policy requests, see
+// https://learn.microsoft.com/en-us/azure/storage/blobs/immutable-storage-overview
+type ImmutabilityMode string
+
+const (
+	// ImmutabilityModeUnlocked policies can be extended, shortened or
+	// deleted.
+	ImmutabilityModeUnlocked ImmutabilityMode = "Unlocked"
+	// ImmutabilityModeLocked policies can only be extended; the blob
+	// cannot be modified nor deleted until the policy expires.
+	ImmutabilityModeLocked ImmutabilityMode = "Locked"
+)
+
+// ImmutabilityPolicyInfo describes the immutability policy of a blob.
+type ImmutabilityPolicyInfo struct {
+	ExpiresOn time.Time
+	Mode      ImmutabilityMode
+}
+
+func (c *client) blobClient(ctx context.Context, op, path string) (*blockblob.Client, error) {
+	azClient, err := c.clientFromContext(ctx)
+	if err != nil {
+		return nil, OpError{
+			Op:     op,
+			Reason: err,
+		}
+	}
+	return azClient.NewBlockBlobClient(c.blobPath(path)), nil
+}
+
+func (c *client) getImmutabilityPolicy(
+	ctx context.Context,
+	bc *blockblob.Client,
+	path string,
+) (*ImmutabilityPolicyInfo, error) {
+	rsp, err := bc.GetProperties(ctx, &blob.GetPropertiesOptions{})
+	if bloberror.HasCode(err,
+		bloberror.BlobNotFound,
+		bloberror.ContainerNotFound,
+		bloberror.ResourceNotFound,
+	) {
+		return nil, &storage.ObjectNotFoundError{Path: path}
+	} else if err != nil {
+		return nil, err
+	}
+	if rsp.ImmutabilityPolicyExpiresOn == nil ||
+		rsp.ImmutabilityPolicyMode == nil ||
+		*rsp.ImmutabilityPolicyMode == blob.ImmutabilityPolicyModeMutable {
+		return nil, nil
+	}
+	return &ImmutabilityPolicyInfo{
+		ExpiresOn: *rsp.ImmutabilityPolicyExpiresOn,
+		Mode:      ImmutabilityMode(*rsp.ImmutabilityPolicyMode),
+	}, nil
+}
+
+// SetImmutabilityPolicy prevents the object from being modified or deleted
+// for retentionDays days. ErrImmutabilityPolicyExists is returned if the
+// object already has a policy.
+func (c *client) SetImmutabilityPolicy(
+	ctx context.Context,
+	path string,
+	retentionDays int,
+	mode ImmutabilityMode,
+) error {
+	if retentionDays <= 0 {
+		return OpError{
+			Op:      OpSetImmutabilityPolicy,
+			Message: "retention period must be positive",
+		}
+	} else if mode != ImmutabilityModeLocked && mode != ImmutabilityModeUnlocked {
+		return OpError{
+			Op:      OpSetImmutabilityPolicy,
+			Message: "invalid immutability mode " + string(mode),
+		}
+	}
+	bc, err := c.blobClient(ctx, OpSetImmutabilityPolicy, path)
+	if err != nil {
+		return err
+	}
+	policy, err := c.getImmutabilityPolicy(ctx, bc, path)
+	if err == nil && policy != nil {
+		err = ErrImmutabilityPolicyExists
+	}
+	if err != nil {
+		return OpError{
+			Op:      OpSetImmutabilityPolicy,
+			Message: "failed to check the current policy",
+			Reason:  err,
+		}
+	}
+	expiry := time.Now().Add(time.Duration(retentionDays) * 24 * time.Hour)
+	setting := blob.ImmutabilityPolicySetting(mode)
+	_, err = bc.SetImmutabilityPolicy(ctx, expiry, &blob.SetImmutabilityPolicyOptions{
+		Mode: &setting,
+	})
+	if err != nil {
+		return OpError{
+			Op:      OpSetImmutabilityPolicy,
+			Message: "failed to set immutability policy",
+			Reason:  err,
+		}
+	}
+	return nil
+}
+
+// GetImmutabilityPolicy returns the immutability policy of the object, or
+// nil if the object has no policy.
+func (c *client) GetImmutabilityPolicy(
+	ctx context.Context,
+	path string,
+) (*ImmutabilityPolicyInfo, error) {
+	bc, err := c.blobClient(ctx, OpGetImmutabilityPolicy, path)
+	if err != nil {
+		return nil, err
+	}
+	policy, err := c.getImmutabilityPolicy(ctx, bc, path)
+	if err != nil {
+		return nil, OpError{
+			Op:      OpGetImmutabilityPolicy,
+			Message: "failed to retrieve object properties",
+			Reason:  err,
+		}
+	}
+	return policy, nil
+}
+
+// DeleteImmutabilityPolicy removes the immutability policy of the object.
+// Locked policies cannot be deleted.
+func (c *client) DeleteImmutabilityPolicy(ctx context.Context, path string) error {
+	bc, err := c.blobClient(ctx, OpDeleteImmutabilityPolicy, path)
+	if err != nil {
+		return err
+	}
+	_, err = bc.DeleteImmutabilityPolicy(ctx, &blob.DeleteImmutabilityPolicyOptions{})
+	if bloberror.HasCode(err,
+		bloberror.BlobNotFound,
+		bloberror.ContainerNotFound,
+		bloberror.ResourceNotFound,
+	) {
+		err = &storage.ObjectNotFoundError{Path: path}
+	}
+	if err != nil {
+		return OpError{
+			Op:      OpDeleteImmutabilityPolicy,
+			Message: "failed to delete immutability policy",
+			Reason:  err,
+		}
+	}
+	return nil
+}
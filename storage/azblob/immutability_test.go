@@ -0,0 +1,114 @@
+// Copyright 2023 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package azblob
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mendersoftware/deployments/storage"
+)
+
+// immutabilityHandler emulates the immutability policy of a single blob
+// named "foo/bar".
+type immutabilityHandler struct {
+	mu     sync.Mutex
+	expiry string
+	mode   string
+}
+
+func (h *immutabilityHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if r.URL.Path != "/container/foo/bar" {
+		w.Header().Set("x-ms-error-code", "BlobNotFound")
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	switch r.Method {
+	case http.MethodHead:
+		if h.expiry != "" {
+			w.Header().Set("x-ms-immutability-policy-until-date", h.expiry)
+			w.Header().Set("x-ms-immutability-policy-mode", h.mode)
+		}
+	case http.MethodPut:
+		if r.URL.Query().Get("comp") != "immutabilityPolicies" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		h.expiry = r.Header.Get("x-ms-immutability-policy-until-date")
+		h.mode = r.Header.Get("x-ms-immutability-policy-mode")
+	case http.MethodDelete:
+		if r.URL.Query().Get("comp") != "immutabilityPolicies" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		h.expiry, h.mode = "", ""
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+func TestImmutabilityPolicy(t *testing.T) {
+	t.Parallel()
+
+	azClient, srv := newTestStorageAndServer(&immutabilityHandler{})
+	defer srv.Close()
+	ctx := context.Background()
+
+	policy, err := azClient.GetImmutabilityPolicy(ctx, "foo/bar")
+	assert.NoError(t, err)
+	assert.Nil(t, policy)
+
+	err = azClient.SetImmutabilityPolicy(ctx, "foo/bar", 0, ImmutabilityModeLocked)
+	assert.Error(t, err)
+	err = azClient.SetImmutabilityPolicy(ctx, "foo/bar", 1, "Mutable")
+	assert.Error(t, err)
+
+	err = azClient.SetImmutabilityPolicy(ctx, "foo/bar", 30, ImmutabilityModeUnlocked)
+	assert.NoError(t, err)
+
+	policy, err = azClient.GetImmutabilityPolicy(ctx, "foo/bar")
+	if assert.NoError(t, err) && assert.NotNil(t, policy) {
+		assert.Equal(t, ImmutabilityModeUnlocked, policy.Mode)
+		assert.WithinDuration(t,
+			time.Now().Add(30*24*time.Hour), policy.ExpiresOn, time.Minute)
+	}
+
+	err = azClient.SetImmutabilityPolicy(ctx, "foo/bar", 30, ImmutabilityModeLocked)
+	assert.ErrorIs(t, err, ErrImmutabilityPolicyExists)
+	var opErr OpError
+	if assert.ErrorAs(t, err, &opErr) {
+		assert.Equal(t, OpSetImmutabilityPolicy, opErr.Op)
+	}
+
+	err = azClient.DeleteImmutabilityPolicy(ctx, "foo/bar")
+	assert.NoError(t, err)
+	policy, err = azClient.GetImmutabilityPolicy(ctx, "foo/bar")
+	assert.NoError(t, err)
+	assert.Nil(t, policy)
+
+	notFound := &storage.ObjectNotFoundError{Path: "foo/baz"}
+	_, err = azClient.GetImmutabilityPolicy(ctx, "foo/baz")
+	assert.ErrorIs(t, err, notFound)
+	err = azClient.SetImmutabilityPolicy(ctx, "foo/baz", 1, ImmutabilityModeLocked)
+	assert.ErrorIs(t, err, notFound)
+	err = azClient.DeleteImmutabilityPolicy(ctx, "foo/baz")
+	assert.ErrorIs(t, err, notFound)
+}
@@ -0,0 +1,92 @@
+// Copyright 2023 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package azblob
+
+import (
+	"context"
+	"strings"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/container"
+
+	"github.com/mendersoftware/deployments/storage"
+)
+
+// listPageSizeMax is the maximum number of blobs the service returns per
+// page.
+const listPageSizeMax = 5000
+
+// ListObjects returns up to maxResults objects whose path starts with
+// prefix; if maxResults is not positive, up to storage.ListObjectsMaxResults
+// objects are returned.
+func (c *client) ListObjects(
+	ctx context.Context,
+	prefix string,
+	maxResults int,
+) ([]storage.ObjectInfo, error) {
+	azClient, err := c.clientFromContext(ctx)
+	if err != nil {
+		return nil, OpError{
+			Op:     OpListObjects,
+			Reason: err,
+		}
+	}
+	if maxResults <= 0 {
+		maxResults = storage.ListObjectsMaxResults
+	}
+	blobPrefix := prefix
+	if c.prefix != "" {
+		blobPrefix = c.prefix + "/" + prefix
+	}
+	pageSize := int32(listPageSizeMax)
+	if maxResults < listPageSizeMax {
+		pageSize = int32(maxResults)
+	}
+	pager := azClient.NewListBlobsFlatPager(&container.ListBlobsFlatOptions{
+		Prefix:     &blobPrefix,
+		MaxResults: &pageSize,
+	})
+	objects := make([]storage.ObjectInfo, 0)
+	for pager.More() && len(objects) < maxResults {
+		rsp, err := pager.NextPage(ctx)
+		if err != nil {
+			return nil, OpError{
+				Op:      OpListObjects,
+				Message: "failed to list objects",
+				Reason:  err,
+			}
+		}
+		if rsp.Segment == nil {
+			continue
+		}
+		for _, item := range rsp.Segment.BlobItems {
+			if item.Name == nil {
+				continue
+			} else if len(objects) == maxResults {
+				break
+			}
+			info := storage.ObjectInfo{Path: *item.Name}
+			if c.prefix != "" {
+				info.Path = strings.TrimPrefix(info.Path, c.prefix+"/")
+			}
+			info.Path = strings.TrimSuffix(info.Path, c.fileSuffix)
+			if item.Properties != nil {
+				info.Size = item.Properties.ContentLength
+				info.LastModified = item.Properties.LastModified
+			}
+			objects = append(objects, info)
+		}
+	}
+	return objects, nil
+}
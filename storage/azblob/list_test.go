@@ -0,0 +1,141 @@
+// Copyright 2023 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package azblob
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mendersoftware/deployments/storage"
+)
+
+// listPages serves the blobs in pages of pageSize blobs, using the index
+// of the first blob of the page as marker.
+func listPages(t *testing.T, names []string, pageSize int, requests *int) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		*requests++
+		q := r.URL.Query()
+		assert.Equal(t, "/container", r.URL.Path)
+		assert.Equal(t, "list", q.Get("comp"))
+		assert.Equal(t, "tenant/foo/", q.Get("prefix"))
+		start, _ := strconv.Atoi(q.Get("marker"))
+		end := start + pageSize
+		if end > len(names) {
+			end = len(names)
+		}
+		var b strings.Builder
+		b.WriteString(`<?xml version="1.0" encoding="utf-8"?>`)
+		b.WriteString(`<EnumerationResults ContainerName="container"><Blobs>`)
+		for i, name := range names[start:end] {
+			fmt.Fprintf(&b, "<Blob><Name>%s</Name><Properties>"+
+				"<Last-Modified>Mon, 02 Jan 2023 15:04:05 GMT</Last-Modified>"+
+				"<Content-Length>%d</Content-Length>"+
+				"</Properties></Blob>", name, start+i)
+		}
+		b.WriteString(`</Blobs>`)
+		if end < len(names) {
+			fmt.Fprintf(&b, "<NextMarker>%d</NextMarker>", end)
+		}
+		b.WriteString(`</EnumerationResults>`)
+		w.Header().Set("Content-Type", "application/xml")
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte(b.String()))
+	}
+}
+
+func TestListObjects(t *testing.T) {
+	t.Parallel()
+
+	names := make([]string, 5)
+	for i := range names {
+		names[i] = fmt.Sprintf("tenant/foo/artifact-%d", i)
+	}
+	lastModified := time.Date(2023, 1, 2, 15, 4, 5, 0, time.UTC)
+
+	testCases := map[string]struct {
+		MaxResults int
+
+		Paths    []string
+		Requests int
+	}{
+		"ok, multiple pages": {
+			Paths: []string{
+				"foo/artifact-0", "foo/artifact-1", "foo/artifact-2",
+				"foo/artifact-3", "foo/artifact-4",
+			},
+			Requests: 3,
+		},
+		"ok, max results": {
+			MaxResults: 3,
+			Paths: []string{
+				"foo/artifact-0", "foo/artifact-1", "foo/artifact-2",
+			},
+			Requests: 2,
+		},
+	}
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			var requests int
+			azClient, srv := newTestStorageAndServer(listPages(t, names, 2, &requests))
+			defer srv.Close()
+			azClient.prefix = "tenant"
+
+			objects, err := azClient.ListObjects(context.Background(), "foo/", tc.MaxResults)
+			if !assert.NoError(t, err) {
+				return
+			}
+			paths := make([]string, len(objects))
+			for i, obj := range objects {
+				paths[i] = obj.Path
+				if assert.NotNil(t, obj.Size) {
+					assert.Equal(t, int64(i), *obj.Size)
+				}
+				if assert.NotNil(t, obj.LastModified) {
+					assert.True(t, lastModified.Equal(*obj.LastModified))
+				}
+			}
+			assert.Equal(t, tc.Paths, paths)
+			assert.Equal(t, tc.Requests, requests)
+		})
+	}
+
+	t.Run("error", func(t *testing.T) {
+		t.Parallel()
+		azClient, srv := newTestStorageAndServer(http.HandlerFunc(
+			func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("x-ms-error-code", "AuthorizationFailure")
+				w.WriteHeader(http.StatusForbidden)
+			},
+		))
+		defer srv.Close()
+		azClient.prefix = "tenant"
+
+		_, err := azClient.ListObjects(context.Background(), "foo/", 0)
+		var opErr OpError
+		if assert.ErrorAs(t, err, &opErr) {
+			assert.Equal(t, OpListObjects, opErr.Op)
+		}
+		assert.Equal(t, storage.ErrorClassPermission, storage.ClassifyError(err))
+	})
+}
@@ -15,15 +15,41 @@
 package azblob
 
 import (
+	"crypto/tls"
+	"errors"
 	"fmt"
+	"net"
+	"net/http"
 	"net/url"
+	"time"
 
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
 	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/blockblob"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/sas"
+
+	"github.com/mendersoftware/deployments/storage"
 )
 
 const (
 	BufferSizeMin     = 4 * 1024          // 4KiB
 	BufferSizeDefault = 8 * BufferSizeMin // 32KiB - same default as used in io.Copy
+
+	// BlockSizeMin is the smallest block staged by the upload.
+	BlockSizeMin = 1024 * 1024 // 1MiB
+
+	RetryBaseDelayDefault = time.Second
+)
+
+// CompressionAlgorithm selects how the objects are encoded when uploaded.
+type CompressionAlgorithm string
+
+const (
+	// CompressionNone stores the objects as they are (default).
+	CompressionNone CompressionAlgorithm = ""
+	// CompressionGzip compresses the objects with gzip and sets the
+	// Content-Encoding of the blobs to "gzip".
+	CompressionGzip CompressionAlgorithm = "gzip"
 )
 
 type SharedKeyCredentials struct {
@@ -55,16 +81,100 @@ type Options struct {
 	ConnectionString *string
 	SharedKey        *SharedKeyCredentials
 
+	// UseManagedIdentity authenticates with Azure AD when neither
+	// ConnectionString nor SharedKey is set, using the credentials
+	// available in the environment (see azidentity.DefaultAzureCredential),
+	// e.g. the managed identity of the host. The URLs are signed with user
+	// delegation keys, which requires the identity to be allowed to
+	// delegate access to the storage account.
+	UseManagedIdentity bool
+	// AccountName is the storage account used with UseManagedIdentity;
+	// the container URL defaults to
+	// https://<AccountName>.blob.core.windows.net/<container>.
+	AccountName string
+	// ContainerURI overrides the container URL used with
+	// UseManagedIdentity.
+	ContainerURI *string
+
 	ProxyURI *url.URL
 
 	BufferSize int64
 
+	// BlockSize is the size of the blocks staged by PutObject; if unset,
+	// the blocks are BufferSize large (at least BlockSizeMin).
+	BlockSize int64
+	// Parallelism is the number of blocks PutObject stages concurrently
+	// (default: 1). Every concurrent block is buffered in memory.
+	Parallelism uint16
+
 	ContentType *string
+
+	// FileSuffix is appended to the name of the blobs of all the objects,
+	// e.g. ".mender"; the object paths exchanged with the callers do not
+	// include it. Setting it changes the layout of the container: the
+	// blobs stored without the suffix are no longer reachable.
+	FileSuffix string
+
+	// UploadCompression compresses the objects on PutObject. GetObject
+	// transparently decompresses the objects stored with a gzip
+	// Content-Encoding; the clients of the signed URLs receive the
+	// compressed content with the blob's Content-Encoding header and
+	// must decompress it themselves.
+	UploadCompression CompressionAlgorithm
+
+	// Prefix scopes all object paths to a virtual directory inside the
+	// container.
+	Prefix string
+
+	// ProxyURL is the HTTP proxy used for the requests to the storage API.
+	// Unlike ProxyURI, it does not affect the generated signed URLs.
+	ProxyURL *url.URL
+	// TLSConfig overrides the TLS configuration used for the requests to
+	// the storage API.
+	TLSConfig *tls.Config
+	// HTTPClient overrides the HTTP client used for the requests to the
+	// storage API; it cannot be combined with ProxyURL.
+	HTTPClient *http.Client
+
+	// HealthCheckInterval enables periodic health checks of the container
+	// in the background, see client.IsHealthy.
+	HealthCheckInterval *time.Duration
+
+	// MaxRetries is the number of times PutObject retries an upload
+	// failing with a temporary error (throttling, server and network
+	// errors) on top of the retries of the Azure SDK pipeline. Only the
+	// sources implementing io.Seeker are retried; the others, such as the
+	// streamed artifact uploads, are uploaded once.
+	MaxRetries int
+	// RetryBaseDelay is the delay before the first retry, doubled on each
+	// subsequent retry (default: RetryBaseDelayDefault).
+	RetryBaseDelay time.Duration
+
+	// SASTokenTTL is the lifetime of the signed URLs generated with a zero
+	// duration by GetRequest, PutRequest and DeleteRequest.
+	SASTokenTTL time.Duration
 }
 
+var (
+	ErrProxyURLWithHTTPClient = errors.New(
+		"azblob: ProxyURL cannot be used together with HTTPClient",
+	)
+	ErrManagedIdentityNoAccount = errors.New(
+		"azblob: UseManagedIdentity requires AccountName or ContainerURI",
+	)
+	ErrUnknownCompression = errors.New(
+		"azblob: unknown UploadCompression algorithm",
+	)
+	ErrInvalidBlockSize = fmt.Errorf(
+		"azblob: BlockSize must be between %d and %d bytes",
+		BlockSizeMin, int64(blockblob.MaxStageBlockBytes),
+	)
+)
+
 func NewOptions(opts ...*Options) *Options {
 	opt := &Options{
-		BufferSize: BufferSizeDefault,
+		BufferSize:     BufferSizeDefault,
+		RetryBaseDelay: RetryBaseDelayDefault,
 	}
 	for _, o := range opts {
 		if o == nil {
@@ -76,19 +186,117 @@ func NewOptions(opts ...*Options) *Options {
 		if o.SharedKey != nil {
 			opt.SharedKey = o.SharedKey
 		}
+		if o.UseManagedIdentity {
+			opt.UseManagedIdentity = o.UseManagedIdentity
+		}
+		if o.AccountName != "" {
+			opt.AccountName = o.AccountName
+		}
+		if o.ContainerURI != nil {
+			opt.ContainerURI = o.ContainerURI
+		}
 		if o.ProxyURI != nil {
 			opt.ProxyURI = o.ProxyURI
 		}
 		if o.ContentType != nil {
 			opt.ContentType = o.ContentType
 		}
+		if o.FileSuffix != "" {
+			opt.FileSuffix = o.FileSuffix
+		}
+		if o.UploadCompression != CompressionNone {
+			opt.UploadCompression = o.UploadCompression
+		}
 		if o.BufferSize >= BufferSizeMin {
 			opt.BufferSize = o.BufferSize
 		}
+		if o.BlockSize != 0 {
+			opt.BlockSize = o.BlockSize
+		}
+		if o.Parallelism > 0 {
+			opt.Parallelism = o.Parallelism
+		}
+		if o.Prefix != "" {
+			opt.Prefix = o.Prefix
+		}
+		if o.ProxyURL != nil {
+			opt.ProxyURL = o.ProxyURL
+		}
+		if o.TLSConfig != nil {
+			opt.TLSConfig = o.TLSConfig
+		}
+		if o.HTTPClient != nil {
+			opt.HTTPClient = o.HTTPClient
+		}
+		if o.HealthCheckInterval != nil {
+			opt.HealthCheckInterval = o.HealthCheckInterval
+		}
+		if o.MaxRetries > 0 {
+			opt.MaxRetries = o.MaxRetries
+		}
+		if o.RetryBaseDelay > 0 {
+			opt.RetryBaseDelay = o.RetryBaseDelay
+		}
+		if o.SASTokenTTL > 0 {
+			opt.SASTokenTTL = o.SASTokenTTL
+		}
 	}
 	return opt
 }
 
+// Validate checks that the options are neither ambiguous nor invalid.
+func (opts *Options) Validate() error {
+	if opts.ProxyURL != nil && opts.HTTPClient != nil {
+		return ErrProxyURLWithHTTPClient
+	}
+	if opts.UseManagedIdentity &&
+		opts.ConnectionString == nil && opts.SharedKey == nil &&
+		opts.AccountName == "" && opts.ContainerURI == nil {
+		return ErrManagedIdentityNoAccount
+	}
+	switch opts.UploadCompression {
+	case CompressionNone, CompressionGzip:
+	default:
+		return ErrUnknownCompression
+	}
+	if opts.BlockSize != 0 && (opts.BlockSize < BlockSizeMin ||
+		opts.BlockSize > blockblob.MaxStageBlockBytes) {
+		return ErrInvalidBlockSize
+	}
+	return nil
+}
+
+// httpClient returns the HTTP client used for the requests to the storage
+// API.
+func (opts *Options) httpClient() *http.Client {
+	if opts.HTTPClient != nil {
+		return opts.HTTPClient
+	}
+	tlsConfig := opts.TLSConfig
+	if tlsConfig == nil {
+		tlsConfig = &tls.Config{
+			RootCAs: storage.GetRootCAs(),
+		}
+	}
+	transport := &http.Transport{
+		TLSClientConfig: tlsConfig,
+	}
+	if opts.ProxyURL != nil {
+		transport.Proxy = http.ProxyURL(opts.ProxyURL)
+	}
+	return &http.Client{Transport: transport}
+}
+
+// transport returns the HTTP client used by the container clients created
+// for the storage settings in the request context, or nil to use the
+// default transport of the SDK.
+func (opts *Options) transport() policy.Transporter {
+	if opts.HTTPClient == nil && opts.ProxyURL == nil && opts.TLSConfig == nil {
+		return nil
+	}
+	return opts.httpClient()
+}
+
 func (opts *Options) SetConnectionString(connStr string) *Options {
 	opts.ConnectionString = &connStr
 	return opts
@@ -99,6 +307,21 @@ func (opts *Options) SetSharedKey(sk SharedKeyCredentials) *Options {
 	return opts
 }
 
+func (opts *Options) SetUseManagedIdentity(useManagedIdentity bool) *Options {
+	opts.UseManagedIdentity = useManagedIdentity
+	return opts
+}
+
+func (opts *Options) SetAccountName(accountName string) *Options {
+	opts.AccountName = accountName
+	return opts
+}
+
+func (opts *Options) SetContainerURI(containerURI string) *Options {
+	opts.ContainerURI = &containerURI
+	return opts
+}
+
 func (opts *Options) SetProxyURI(proxyURI *url.URL) *Options {
 	opts.ProxyURI = proxyURI
 	return opts
@@ -109,7 +332,105 @@ func (opts *Options) SetContentType(typ string) *Options {
 	return opts
 }
 
+func (opts *Options) SetFileSuffix(suffix string) *Options {
+	opts.FileSuffix = suffix
+	return opts
+}
+
+func (opts *Options) SetUploadCompression(alg CompressionAlgorithm) *Options {
+	opts.UploadCompression = alg
+	return opts
+}
+
 func (opts *Options) SetBufferSize(size int64) *Options {
 	opts.BufferSize = size
 	return opts
 }
+
+func (opts *Options) SetBlockSize(size int64) *Options {
+	opts.BlockSize = size
+	return opts
+}
+
+func (opts *Options) SetParallelism(parallelism uint16) *Options {
+	opts.Parallelism = parallelism
+	return opts
+}
+
+func (opts *Options) SetPrefix(prefix string) *Options {
+	opts.Prefix = prefix
+	return opts
+}
+
+func (opts *Options) SetProxyURL(proxyURL *url.URL) *Options {
+	opts.ProxyURL = proxyURL
+	return opts
+}
+
+func (opts *Options) SetTLSConfig(tlsConfig *tls.Config) *Options {
+	opts.TLSConfig = tlsConfig
+	return opts
+}
+
+func (opts *Options) SetHTTPClient(client *http.Client) *Options {
+	opts.HTTPClient = client
+	return opts
+}
+
+func (opts *Options) SetHealthCheckInterval(interval time.Duration) *Options {
+	opts.HealthCheckInterval = &interval
+	return opts
+}
+
+func (opts *Options) SetMaxRetries(maxRetries int) *Options {
+	opts.MaxRetries = maxRetries
+	return opts
+}
+
+func (opts *Options) SetRetryBaseDelay(delay time.Duration) *Options {
+	opts.RetryBaseDelay = delay
+	return opts
+}
+
+func (opts *Options) SetSASTokenTTL(ttl time.Duration) *Options {
+	opts.SASTokenTTL = ttl
+	return opts
+}
+
+// GetRequestOptions holds the optional restrictions applied to the signed
+// URLs generated by GetRequestWithOptions.
+type GetRequestOptions struct {
+	// AllowedIPRange restricts the signed URL to the client addresses
+	// within the given CIDR (e.g. "10.0.0.0/16").
+	AllowedIPRange string
+}
+
+// PutRequestOptions holds the optional restrictions applied to the signed
+// URLs generated by PutRequestWithOptions.
+type PutRequestOptions struct {
+	// AllowedIPRange restricts the signed URL to the client addresses
+	// within the given CIDR (e.g. "10.0.0.0/16").
+	AllowedIPRange string
+}
+
+// parseIPRange converts a CIDR to the SAS IP range spanning all the
+// addresses in the network. An empty string yields an unrestricted range.
+func parseIPRange(cidr string) (sas.IPRange, error) {
+	if cidr == "" {
+		return sas.IPRange{}, nil
+	}
+	_, ipNet, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return sas.IPRange{}, err
+	}
+	start := ipNet.IP
+	end := make(net.IP, len(start))
+	for i := range start {
+		end[i] = start[i] | ^ipNet.Mask[i]
+	}
+	ipRange := sas.IPRange{Start: start}
+	if !start.Equal(end) {
+		ipRange.End = end
+	}
+	return ipRange, nil
+}
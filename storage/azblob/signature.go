@@ -26,8 +26,15 @@ import (
 )
 
 var (
-	ErrConnStrNoName = errors.New("connection string does not contain an account name")
-	ErrConnStrNoKey  = errors.New("connection string does not contain an account key")
+	ErrConnStrEmpty    = errors.New("connection string is empty")
+	ErrConnStrNoName   = errors.New("connection string does not contain an account name")
+	ErrConnStrNoKey    = errors.New("connection string does not contain an account key")
+	ErrConnStrNoSecret = errors.New(
+		"connection string missing AccountKey or SharedAccessSignature",
+	)
+	ErrConnStrNoProtocol = errors.New(
+		"connection string missing DefaultEndpointsProtocol",
+	)
 )
 
 func (c *client) signParamsFromContext(
@@ -72,6 +79,36 @@ func connStringAttr(cs, key string) (string, bool) {
 	return cs[start:end], true
 }
 
+// validateConnectionString checks that the connection string contains the
+// attributes required to connect to the storage account.
+func validateConnectionString(cs string) error {
+	const (
+		attrName     = "AccountName="
+		attrKey      = "AccountKey="
+		attrSAS      = "SharedAccessSignature="
+		attrProtocol = "DefaultEndpointsProtocol="
+		attrEndpoint = "BlobEndpoint="
+	)
+	if strings.TrimSpace(cs) == "" {
+		return ErrConnStrEmpty
+	}
+	if name, ok := connStringAttr(cs, attrName); !ok || name == "" {
+		return ErrConnStrNoName
+	}
+	key, hasKey := connStringAttr(cs, attrKey)
+	sas, hasSAS := connStringAttr(cs, attrSAS)
+	if (!hasKey || key == "") && (!hasSAS || sas == "") {
+		return ErrConnStrNoSecret
+	}
+	// An explicit blob endpoint already defines the protocol.
+	if _, ok := connStringAttr(cs, attrProtocol); !ok {
+		if _, ok := connStringAttr(cs, attrEndpoint); !ok {
+			return ErrConnStrNoProtocol
+		}
+	}
+	return nil
+}
+
 func keyFromConnString(cs string) (*azblob.SharedKeyCredential, error) {
 	const (
 		attrName = "AccountName="
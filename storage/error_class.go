@@ -0,0 +1,146 @@
+// Copyright 2023 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package storage
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/bloberror"
+)
+
+// ErrorClass groups storage errors by the way they should be handled.
+type ErrorClass int
+
+const (
+	ErrorClassUnknown ErrorClass = iota
+	ErrorClassNotFound
+	ErrorClassPermission
+	ErrorClassRateLimit
+	ErrorClassQuotaExceeded
+	ErrorClassNetworkTransient
+	ErrorClassServerError
+)
+
+func (c ErrorClass) String() string {
+	switch c {
+	case ErrorClassNotFound:
+		return "not found"
+	case ErrorClassPermission:
+		return "permission"
+	case ErrorClassRateLimit:
+		return "rate limit"
+	case ErrorClassQuotaExceeded:
+		return "quota exceeded"
+	case ErrorClassNetworkTransient:
+		return "network transient"
+	case ErrorClassServerError:
+		return "server error"
+	default:
+		return "unknown"
+	}
+}
+
+// Temporary returns true if an operation failing with an error of this
+// class may succeed when retried.
+func (c ErrorClass) Temporary() bool {
+	switch c {
+	case ErrorClassRateLimit,
+		ErrorClassNetworkTransient,
+		ErrorClassServerError:
+		return true
+	default:
+		return false
+	}
+}
+
+// ClassifyError returns the class of the error returned by an ObjectStorage
+// implementation. A canceled context is never considered transient and is
+// classified as ErrorClassUnknown.
+func ClassifyError(err error) ErrorClass {
+	if err == nil {
+		return ErrorClassUnknown
+	}
+	switch {
+	case errors.Is(err, ErrObjectNotFound):
+		return ErrorClassNotFound
+	case errors.Is(err, context.Canceled):
+		return ErrorClassUnknown
+	case errors.Is(err, context.DeadlineExceeded):
+		return ErrorClassNetworkTransient
+	}
+
+	var respErr *azcore.ResponseError
+	if errors.As(err, &respErr) {
+		return classifyResponseError(respErr)
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return ErrorClassNetworkTransient
+	}
+	return ErrorClassUnknown
+}
+
+func classifyResponseError(err *azcore.ResponseError) ErrorClass {
+	switch bloberror.Code(err.ErrorCode) {
+	case bloberror.BlobNotFound,
+		bloberror.ContainerNotFound,
+		bloberror.ResourceNotFound:
+		return ErrorClassNotFound
+
+	case bloberror.AuthenticationFailed,
+		bloberror.AuthorizationFailure,
+		bloberror.AuthorizationPermissionMismatch,
+		bloberror.AuthorizationSourceIPMismatch,
+		bloberror.InsufficientAccountPermissions,
+		bloberror.InvalidAuthenticationInfo,
+		bloberror.NoAuthenticationInformation,
+		bloberror.AccountIsDisabled:
+		return ErrorClassPermission
+
+	case bloberror.ServerBusy:
+		return ErrorClassRateLimit
+
+	case bloberror.BlockCountExceedsLimit,
+		bloberror.ContentLengthLargerThanTierLimit:
+		return ErrorClassQuotaExceeded
+
+	case bloberror.OperationTimedOut:
+		return ErrorClassNetworkTransient
+	}
+
+	switch code := err.StatusCode; {
+	case code == http.StatusNotFound:
+		return ErrorClassNotFound
+	case code == http.StatusUnauthorized,
+		code == http.StatusForbidden:
+		return ErrorClassPermission
+	case code == http.StatusTooManyRequests:
+		return ErrorClassRateLimit
+	case code == http.StatusRequestEntityTooLarge,
+		code == http.StatusInsufficientStorage:
+		return ErrorClassQuotaExceeded
+	case code == http.StatusRequestTimeout,
+		code == http.StatusGatewayTimeout:
+		return ErrorClassNetworkTransient
+	case code >= http.StatusInternalServerError:
+		return ErrorClassServerError
+	}
+	return ErrorClassUnknown
+}
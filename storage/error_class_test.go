@@ -0,0 +1,143 @@
+// Copyright 2023 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/runtime"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/bloberror"
+	"github.com/stretchr/testify/assert"
+)
+
+func newResponseError(statusCode int, code bloberror.Code) error {
+	hdr := http.Header{}
+	if code != "" {
+		hdr.Set("x-ms-error-code", string(code))
+	}
+	return runtime.NewResponseError(&http.Response{
+		StatusCode: statusCode,
+		Status:     http.StatusText(statusCode),
+		Header:     hdr,
+		Body:       io.NopCloser(strings.NewReader("")),
+		Request: &http.Request{
+			Method: http.MethodGet,
+			URL:    &url.URL{Scheme: "https", Host: "localhost", Path: "/blob"},
+		},
+	})
+}
+
+func TestClassifyError(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		Err   error
+		Class ErrorClass
+	}{
+		"nil": {
+			Class: ErrorClassUnknown,
+		},
+		"object not found": {
+			Err:   fmt.Errorf("wrapped: %w", ErrObjectNotFound),
+			Class: ErrorClassNotFound,
+		},
+		"blob not found": {
+			Err:   newResponseError(http.StatusNotFound, bloberror.BlobNotFound),
+			Class: ErrorClassNotFound,
+		},
+		"authentication failed": {
+			Err: newResponseError(
+				http.StatusForbidden, bloberror.AuthenticationFailed,
+			),
+			Class: ErrorClassPermission,
+		},
+		"forbidden without code": {
+			Err:   newResponseError(http.StatusForbidden, ""),
+			Class: ErrorClassPermission,
+		},
+		"server busy": {
+			Err:   newResponseError(http.StatusServiceUnavailable, bloberror.ServerBusy),
+			Class: ErrorClassRateLimit,
+		},
+		"too many requests": {
+			Err:   newResponseError(http.StatusTooManyRequests, ""),
+			Class: ErrorClassRateLimit,
+		},
+		"quota exceeded": {
+			Err: newResponseError(
+				http.StatusConflict, bloberror.BlockCountExceedsLimit,
+			),
+			Class: ErrorClassQuotaExceeded,
+		},
+		"operation timed out": {
+			Err: newResponseError(
+				http.StatusInternalServerError, bloberror.OperationTimedOut,
+			),
+			Class: ErrorClassNetworkTransient,
+		},
+		"internal error": {
+			Err: newResponseError(
+				http.StatusInternalServerError, bloberror.InternalError,
+			),
+			Class: ErrorClassServerError,
+		},
+		"bad request": {
+			Err:   newResponseError(http.StatusBadRequest, bloberror.InvalidHeaderValue),
+			Class: ErrorClassUnknown,
+		},
+		"deadline exceeded": {
+			Err:   fmt.Errorf("wrapped: %w", context.DeadlineExceeded),
+			Class: ErrorClassNetworkTransient,
+		},
+		"context canceled": {
+			Err:   context.Canceled,
+			Class: ErrorClassUnknown,
+		},
+		"network error": {
+			Err:   &net.OpError{Op: "dial", Err: errors.New("connection refused")},
+			Class: ErrorClassNetworkTransient,
+		},
+		"other error": {
+			Err:   errors.New("something went wrong"),
+			Class: ErrorClassUnknown,
+		},
+	}
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			class := ClassifyError(tc.Err)
+			assert.Equal(t, tc.Class, class, "expected %s, got %s", tc.Class, class)
+		})
+	}
+}
+
+func TestErrorClassTemporary(t *testing.T) {
+	assert.True(t, ErrorClassRateLimit.Temporary())
+	assert.True(t, ErrorClassNetworkTransient.Temporary())
+	assert.True(t, ErrorClassServerError.Temporary())
+	assert.False(t, ErrorClassNotFound.Temporary())
+	assert.False(t, ErrorClassPermission.Temporary())
+	assert.False(t, ErrorClassQuotaExceeded.Temporary())
+	assert.False(t, ErrorClassUnknown.Temporary())
+}
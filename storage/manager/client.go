@@ -72,6 +72,21 @@ func (c *client) clientFromContext(
 	return objStore, err
 }
 
+// Close closes the default storage and the storage providers; it returns
+// the first error encountered.
+func (c *client) Close() error {
+	var err error
+	if c.defaultStorage != nil {
+		err = c.defaultStorage.Close()
+	}
+	for _, objStore := range c.providerMap {
+		if closeErr := objStore.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	return err
+}
+
 func (c *client) HealthCheck(ctx context.Context) (err error) {
 	var objStore storage.ObjectStorage
 	objStore, err = c.clientFromContext(ctx)
@@ -89,6 +104,25 @@ func (c *client) GetObject(ctx context.Context, path string) (io.ReadCloser, err
 	return objStore.GetObject(ctx, path)
 }
 
+func (c *client) GetObjectWithMetadata(
+	ctx context.Context,
+	path string,
+) (*storage.ObjectInfo, io.ReadCloser, error) {
+	objStore, err := c.clientFromContext(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	return objStore.GetObjectWithMetadata(ctx, path)
+}
+
+func (c *client) GetObjectReader(ctx context.Context, path string) (io.ReadCloser, error) {
+	objStore, err := c.clientFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return objStore.GetObjectReader(ctx, path)
+}
+
 func (c *client) PutObject(ctx context.Context, path string, src io.Reader) error {
 	objStore, err := c.clientFromContext(ctx)
 	if err != nil {
@@ -98,6 +132,14 @@ func (c *client) PutObject(ctx context.Context, path string, src io.Reader) erro
 	return objStore.PutObject(ctx, path, src)
 }
 
+func (c *client) CopyObject(ctx context.Context, srcPath, dstPath string) error {
+	objStore, err := c.clientFromContext(ctx)
+	if err != nil {
+		return err
+	}
+	return objStore.CopyObject(ctx, srcPath, dstPath)
+}
+
 func (c *client) DeleteObject(ctx context.Context, path string) error {
 	objStore, err := c.clientFromContext(ctx)
 	if err != nil {
@@ -114,6 +156,26 @@ func (c *client) StatObject(ctx context.Context, path string) (*storage.ObjectIn
 	return objStore.StatObject(ctx, path)
 }
 
+func (c *client) HeadObject(ctx context.Context, path string) (bool, error) {
+	objStore, err := c.clientFromContext(ctx)
+	if err != nil {
+		return false, err
+	}
+	return objStore.HeadObject(ctx, path)
+}
+
+func (c *client) ListObjects(
+	ctx context.Context,
+	prefix string,
+	maxResults int,
+) ([]storage.ObjectInfo, error) {
+	objStore, err := c.clientFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return objStore.ListObjects(ctx, prefix, maxResults)
+}
+
 func (c *client) GetRequest(
 	ctx context.Context,
 	path string,
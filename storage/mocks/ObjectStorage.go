@@ -34,6 +34,34 @@ type ObjectStorage struct {
 	mock.Mock
 }
 
+// Close provides a mock function with given fields:
+func (_m *ObjectStorage) Close() error {
+	ret := _m.Called()
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func() error); ok {
+		r0 = rf()
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// CopyObject provides a mock function with given fields: ctx, srcPath, dstPath
+func (_m *ObjectStorage) CopyObject(ctx context.Context, srcPath string, dstPath string) error {
+	ret := _m.Called(ctx, srcPath, dstPath)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) error); ok {
+		r0 = rf(ctx, srcPath, dstPath)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // DeleteObject provides a mock function with given fields: ctx, path
 func (_m *ObjectStorage) DeleteObject(ctx context.Context, path string) error {
 	ret := _m.Called(ctx, path)
@@ -94,6 +122,61 @@ func (_m *ObjectStorage) GetObject(ctx context.Context, path string) (io.ReadClo
 	return r0, r1
 }
 
+// GetObjectReader provides a mock function with given fields: ctx, path
+func (_m *ObjectStorage) GetObjectReader(ctx context.Context, path string) (io.ReadCloser, error) {
+	ret := _m.Called(ctx, path)
+
+	var r0 io.ReadCloser
+	if rf, ok := ret.Get(0).(func(context.Context, string) io.ReadCloser); ok {
+		r0 = rf(ctx, path)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(io.ReadCloser)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, path)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetObjectWithMetadata provides a mock function with given fields: ctx, path
+func (_m *ObjectStorage) GetObjectWithMetadata(ctx context.Context, path string) (*storage.ObjectInfo, io.ReadCloser, error) {
+	ret := _m.Called(ctx, path)
+
+	var r0 *storage.ObjectInfo
+	if rf, ok := ret.Get(0).(func(context.Context, string) *storage.ObjectInfo); ok {
+		r0 = rf(ctx, path)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*storage.ObjectInfo)
+		}
+	}
+
+	var r1 io.ReadCloser
+	if rf, ok := ret.Get(1).(func(context.Context, string) io.ReadCloser); ok {
+		r1 = rf(ctx, path)
+	} else {
+		if ret.Get(1) != nil {
+			r1 = ret.Get(1).(io.ReadCloser)
+		}
+	}
+
+	var r2 error
+	if rf, ok := ret.Get(2).(func(context.Context, string) error); ok {
+		r2 = rf(ctx, path)
+	} else {
+		r2 = ret.Error(2)
+	}
+
+	return r0, r1, r2
+}
+
 // GetRequest provides a mock function with given fields: ctx, path, filename, duration
 func (_m *ObjectStorage) GetRequest(ctx context.Context, path string, filename string, duration time.Duration) (*model.Link, error) {
 	ret := _m.Called(ctx, path, filename, duration)
@@ -117,6 +200,27 @@ func (_m *ObjectStorage) GetRequest(ctx context.Context, path string, filename s
 	return r0, r1
 }
 
+// HeadObject provides a mock function with given fields: ctx, path
+func (_m *ObjectStorage) HeadObject(ctx context.Context, path string) (bool, error) {
+	ret := _m.Called(ctx, path)
+
+	var r0 bool
+	if rf, ok := ret.Get(0).(func(context.Context, string) bool); ok {
+		r0 = rf(ctx, path)
+	} else {
+		r0 = ret.Get(0).(bool)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, path)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // HealthCheck provides a mock function with given fields: ctx
 func (_m *ObjectStorage) HealthCheck(ctx context.Context) error {
 	ret := _m.Called(ctx)
@@ -131,6 +235,29 @@ func (_m *ObjectStorage) HealthCheck(ctx context.Context) error {
 	return r0
 }
 
+// ListObjects provides a mock function with given fields: ctx, prefix, maxResults
+func (_m *ObjectStorage) ListObjects(ctx context.Context, prefix string, maxResults int) ([]storage.ObjectInfo, error) {
+	ret := _m.Called(ctx, prefix, maxResults)
+
+	var r0 []storage.ObjectInfo
+	if rf, ok := ret.Get(0).(func(context.Context, string, int) []storage.ObjectInfo); ok {
+		r0 = rf(ctx, prefix, maxResults)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]storage.ObjectInfo)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string, int) error); ok {
+		r1 = rf(ctx, prefix, maxResults)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // PutObject provides a mock function with given fields: ctx, path, src
 func (_m *ObjectStorage) PutObject(ctx context.Context, path string, src io.Reader) error {
 	ret := _m.Called(ctx, path, src)
@@ -0,0 +1,304 @@
+// Copyright 2023 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/errgroup"
+
+	"github.com/mendersoftware/deployments/model"
+)
+
+// multiBackend mirrors the objects written to the primary storage to the
+// replicas; see NewMultiBackend.
+type multiBackend struct {
+	primary  ObjectStorage
+	replicas []ObjectStorage
+}
+
+// NewMultiBackend returns an object storage writing the objects to the
+// primary and all the replicas concurrently, e.g. to mirror the artifacts
+// to another cloud provider. The objects are read from the primary only.
+//
+// If writing an object fails for any of the backends, the object is removed
+// from the backends it was created on; the backends on which the object
+// already existed, or on which its existence could not be checked, keep the
+// new content. The signed URLs are generated by the
+// primary, hence objects uploaded directly with a PutRequest link are not
+// mirrored to the replicas.
+func NewMultiBackend(primary ObjectStorage, replicas ...ObjectStorage) ObjectStorage {
+	return &multiBackend{
+		primary:  primary,
+		replicas: replicas,
+	}
+}
+
+func (m *multiBackend) backends() []ObjectStorage {
+	return append([]ObjectStorage{m.primary}, m.replicas...)
+}
+
+// forEach calls fn concurrently for all the backends and returns the first
+// error.
+func (m *multiBackend) forEach(
+	ctx context.Context,
+	fn func(ctx context.Context, backend ObjectStorage) error,
+) error {
+	g, ctx := errgroup.WithContext(ctx)
+	for _, backend := range m.backends() {
+		backend := backend
+		g.Go(func() error {
+			return fn(ctx, backend)
+		})
+	}
+	return g.Wait()
+}
+
+// write calls fn concurrently for all the backends and returns the backends
+// on which fn created the object at path, i.e. it did not exist before. The
+// first failure cancels the context of the other calls, and the object is
+// then deleted from the backends it was created on: deleting it from the
+// others would lose the content it overwrote.
+func (m *multiBackend) write(
+	ctx context.Context,
+	path string,
+	fn func(ctx context.Context, i int, backend ObjectStorage) error,
+) ([]ObjectStorage, error) {
+	backends := m.backends()
+	var (
+		mu      sync.Mutex
+		created []ObjectStorage
+	)
+	g, gctx := errgroup.WithContext(ctx)
+	for i, backend := range backends {
+		i, backend := i, backend
+		g.Go(func() error {
+			exists, errHead := backend.HeadObject(gctx, path)
+			if err := fn(gctx, i, backend); err != nil {
+				return err
+			}
+			if errHead == nil && !exists {
+				mu.Lock()
+				created = append(created, backend)
+				mu.Unlock()
+			}
+			return nil
+		})
+	}
+	err := g.Wait()
+	if err != nil {
+		rollback(ctx, path, created)
+	}
+	return created, err
+}
+
+// rollback deletes the object at path from the backends. It is best
+// effort: the error which caused the rollback is more relevant than the
+// rollback errors.
+func rollback(ctx context.Context, path string, backends []ObjectStorage) {
+	for _, backend := range backends {
+		_ = backend.DeleteObject(ctx, path)
+	}
+}
+
+// backendErrors holds the errors returned by several backends.
+type backendErrors []error
+
+func (errs backendErrors) Error() string {
+	msgs := make([]string, len(errs))
+	for i, err := range errs {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// Is returns true if any of the errors matches target.
+func (errs backendErrors) Is(target error) bool {
+	for _, err := range errs {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *multiBackend) HealthCheck(ctx context.Context) error {
+	return m.forEach(ctx, func(ctx context.Context, backend ObjectStorage) error {
+		return backend.HealthCheck(ctx)
+	})
+}
+
+func (m *multiBackend) GetObject(ctx context.Context, path string) (io.ReadCloser, error) {
+	return m.primary.GetObject(ctx, path)
+}
+
+func (m *multiBackend) GetObjectWithMetadata(
+	ctx context.Context,
+	path string,
+) (*ObjectInfo, io.ReadCloser, error) {
+	return m.primary.GetObjectWithMetadata(ctx, path)
+}
+
+func (m *multiBackend) GetObjectReader(ctx context.Context, path string) (io.ReadCloser, error) {
+	return m.primary.GetObjectReader(ctx, path)
+}
+
+// fanOutWriter writes to all the writers, dropping the failing ones, so
+// that a backend failing to consume the source does not block the others.
+// It fails once all the writers failed.
+type fanOutWriter struct {
+	writers []io.Writer
+}
+
+func (w *fanOutWriter) Write(b []byte) (int, error) {
+	live := w.writers[:0]
+	for _, writer := range w.writers {
+		if _, err := writer.Write(b); err == nil {
+			live = append(live, writer)
+		}
+	}
+	w.writers = live
+	if len(live) == 0 {
+		return 0, io.ErrClosedPipe
+	}
+	return len(b), nil
+}
+
+// PutObject streams src to all the backends concurrently.
+func (m *multiBackend) PutObject(ctx context.Context, path string, src io.Reader) error {
+	backends := m.backends()
+	readers := make([]*io.PipeReader, len(backends))
+	writers := make([]*io.PipeWriter, len(backends))
+	fanOut := &fanOutWriter{writers: make([]io.Writer, len(backends))}
+	for i := range backends {
+		readers[i], writers[i] = io.Pipe()
+		fanOut.writers[i] = writers[i]
+	}
+	chCopy := make(chan error, 1)
+	go func() {
+		_, err := io.Copy(fanOut, src)
+		for _, w := range writers {
+			w.CloseWithError(err)
+		}
+		chCopy <- err
+	}()
+	created, err := m.write(ctx, path,
+		func(ctx context.Context, i int, backend ObjectStorage) error {
+			err := backend.PutObject(ctx, path, readers[i])
+			// drop the backend from the fan out if it did not consume the
+			// whole source
+			readers[i].CloseWithError(io.ErrClosedPipe)
+			return err
+		})
+	if errCopy := <-chCopy; err == nil && errCopy != nil {
+		// the backends ignored the source read error
+		err = errCopy
+		rollback(ctx, path, created)
+	}
+	return err
+}
+
+func (m *multiBackend) CopyObject(ctx context.Context, srcPath, dstPath string) error {
+	_, err := m.write(ctx, dstPath,
+		func(ctx context.Context, _ int, backend ObjectStorage) error {
+			return backend.CopyObject(ctx, srcPath, dstPath)
+		})
+	return err
+}
+
+// DeleteObject deletes the object from all the backends concurrently. A
+// failure does not cancel the other deletions, so that the backends stay
+// in sync as much as possible; the errors of all the failing backends are
+// returned.
+func (m *multiBackend) DeleteObject(ctx context.Context, path string) error {
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		errs backendErrors
+	)
+	for _, backend := range m.backends() {
+		backend := backend
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := backend.DeleteObject(ctx, path); err != nil {
+				mu.Lock()
+				errs = append(errs, err)
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func (m *multiBackend) StatObject(ctx context.Context, path string) (*ObjectInfo, error) {
+	return m.primary.StatObject(ctx, path)
+}
+
+func (m *multiBackend) HeadObject(ctx context.Context, path string) (bool, error) {
+	return m.primary.HeadObject(ctx, path)
+}
+
+func (m *multiBackend) ListObjects(
+	ctx context.Context,
+	prefix string,
+	maxResults int,
+) ([]ObjectInfo, error) {
+	return m.primary.ListObjects(ctx, prefix, maxResults)
+}
+
+func (m *multiBackend) GetRequest(
+	ctx context.Context,
+	path string,
+	filename string,
+	duration time.Duration,
+) (*model.Link, error) {
+	return m.primary.GetRequest(ctx, path, filename, duration)
+}
+
+func (m *multiBackend) DeleteRequest(
+	ctx context.Context,
+	path string,
+	duration time.Duration,
+) (*model.Link, error) {
+	return m.primary.DeleteRequest(ctx, path, duration)
+}
+
+func (m *multiBackend) PutRequest(
+	ctx context.Context,
+	path string,
+	duration time.Duration,
+) (*model.Link, error) {
+	return m.primary.PutRequest(ctx, path, duration)
+}
+
+func (m *multiBackend) Close() error {
+	var err error
+	for _, backend := range m.backends() {
+		if errClose := backend.Close(); err == nil {
+			err = errClose
+		}
+	}
+	return err
+}
@@ -0,0 +1,250 @@
+// Copyright 2023 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package storage_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/mendersoftware/deployments/storage"
+	"github.com/mendersoftware/deployments/storage/mocks"
+)
+
+// fakeBackend records the objects written and deleted; the mocks cannot be
+// used for PutObject as they read the pipe fields concurrently.
+type fakeBackend struct {
+	storage.ObjectStorage
+
+	putErr error
+	exists bool
+
+	mu      sync.Mutex
+	content string
+	deleted bool
+}
+
+func (b *fakeBackend) PutObject(ctx context.Context, path string, src io.Reader) error {
+	if b.putErr != nil {
+		return b.putErr
+	}
+	content, err := io.ReadAll(src)
+	if err != nil {
+		return err
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.content = string(content)
+	return nil
+}
+
+func (b *fakeBackend) HeadObject(ctx context.Context, path string) (bool, error) {
+	return b.exists, nil
+}
+
+func (b *fakeBackend) DeleteObject(ctx context.Context, path string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.deleted = true
+	return nil
+}
+
+func TestMultiBackendPutObject(t *testing.T) {
+	t.Parallel()
+
+	const content = "imagine artifacts"
+	errPut := errors.New("put error")
+
+	testCases := map[string]struct {
+		PrimaryError  error
+		PrimaryExists bool
+		ReplicaErrors []error
+
+		// Rollback lists the backends the object is deleted from:
+		// the primary is 0, the replicas follow.
+		Rollback []int
+		Error    error
+	}{
+		"ok": {
+			ReplicaErrors: []error{nil, nil},
+		},
+		"ok, no replicas": {},
+		"error, replica": {
+			ReplicaErrors: []error{nil, errPut},
+			Rollback:      []int{0, 1},
+			Error:         errPut,
+		},
+		"error, replica, overwriting the primary": {
+			PrimaryExists: true,
+			ReplicaErrors: []error{nil, errPut},
+			Rollback:      []int{1},
+			Error:         errPut,
+		},
+		"error, primary": {
+			PrimaryError:  errPut,
+			ReplicaErrors: []error{nil},
+			Rollback:      []int{1},
+			Error:         errPut,
+		},
+		"error, all backends": {
+			PrimaryError:  errPut,
+			ReplicaErrors: []error{errPut},
+			Error:         errPut,
+		},
+	}
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			primary := &fakeBackend{
+				putErr: tc.PrimaryError,
+				exists: tc.PrimaryExists,
+			}
+			backends := []*fakeBackend{primary}
+			replicas := make([]storage.ObjectStorage, 0, len(tc.ReplicaErrors))
+			for _, err := range tc.ReplicaErrors {
+				replica := &fakeBackend{putErr: err}
+				backends = append(backends, replica)
+				replicas = append(replicas, replica)
+			}
+
+			multi := storage.NewMultiBackend(primary, replicas...)
+			err := multi.PutObject(context.Background(), "foo/bar",
+				strings.NewReader(content))
+			if tc.Error != nil {
+				assert.ErrorIs(t, err, tc.Error)
+			} else {
+				assert.NoError(t, err)
+			}
+
+			rollback := map[int]bool{}
+			for _, i := range tc.Rollback {
+				rollback[i] = true
+			}
+			for i, backend := range backends {
+				assert.Equal(t, rollback[i], backend.deleted,
+					"unexpected rollback of backend %d", i)
+				if backend.putErr == nil && tc.Error == nil {
+					assert.Equal(t, content, backend.content)
+				}
+			}
+		})
+	}
+}
+
+func TestMultiBackendCopyObject(t *testing.T) {
+	t.Parallel()
+
+	errCopy := errors.New("copy error")
+	primary := new(mocks.ObjectStorage)
+	defer primary.AssertExpectations(t)
+	replica := new(mocks.ObjectStorage)
+	defer replica.AssertExpectations(t)
+
+	primary.On("HeadObject", mock.Anything, "foo/baz").
+		Return(false, nil).
+		Once()
+	primary.On("CopyObject", mock.Anything, "foo/bar", "foo/baz").
+		Return(nil).
+		Once()
+	replica.On("HeadObject", mock.Anything, "foo/baz").
+		Return(false, nil).
+		Once()
+	replica.On("CopyObject", mock.Anything, "foo/bar", "foo/baz").
+		Return(errCopy).
+		Once()
+	primary.On("DeleteObject", mock.Anything, "foo/baz").
+		Return(nil).
+		Once()
+
+	err := storage.NewMultiBackend(primary, replica).
+		CopyObject(context.Background(), "foo/bar", "foo/baz")
+	assert.ErrorIs(t, err, errCopy)
+}
+
+func TestMultiBackendDeleteObject(t *testing.T) {
+	t.Parallel()
+
+	errPrimary := errors.New("primary delete error")
+	errReplica := errors.New("replica delete error")
+	primary := new(mocks.ObjectStorage)
+	defer primary.AssertExpectations(t)
+	replica := new(mocks.ObjectStorage)
+	defer replica.AssertExpectations(t)
+	replicaOK := new(mocks.ObjectStorage)
+	defer replicaOK.AssertExpectations(t)
+
+	// a failure does not cancel the other deletions
+	primary.On("DeleteObject", mock.Anything, "foo/bar").
+		Return(errPrimary).
+		Once()
+	replica.On("DeleteObject", mock.Anything, "foo/bar").
+		Return(errReplica).
+		Once()
+	replicaOK.On("DeleteObject",
+		mock.MatchedBy(func(ctx context.Context) bool {
+			return ctx.Err() == nil
+		}), "foo/bar").
+		Return(nil).
+		Once()
+
+	err := storage.NewMultiBackend(primary, replica, replicaOK).
+		DeleteObject(context.Background(), "foo/bar")
+	assert.ErrorIs(t, err, errPrimary)
+	assert.ErrorIs(t, err, errReplica)
+}
+
+func TestMultiBackendReadsFromPrimary(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	primary := new(mocks.ObjectStorage)
+	defer primary.AssertExpectations(t)
+	// the replica must not be called
+	replica := new(mocks.ObjectStorage)
+	defer replica.AssertExpectations(t)
+
+	info := &storage.ObjectInfo{Path: "foo/bar"}
+	primary.On("StatObject", ctx, "foo/bar").
+		Return(info, nil).
+		Once()
+	primary.On("GetObjectReader", ctx, "foo/bar").
+		Return(io.NopCloser(strings.NewReader("imagine artifacts")), nil).
+		Once()
+	primary.On("GetRequest", ctx, "foo/bar", "bar.mender", mock.Anything).
+		Return(nil, storage.ErrObjectNotFound).
+		Once()
+
+	multi := storage.NewMultiBackend(primary, replica)
+	res, err := multi.StatObject(ctx, "foo/bar")
+	assert.NoError(t, err)
+	assert.Equal(t, info, res)
+
+	body, err := multi.GetObjectReader(ctx, "foo/bar")
+	if assert.NoError(t, err) {
+		b, _ := io.ReadAll(body)
+		assert.Equal(t, "imagine artifacts", string(b))
+	}
+
+	_, err = multi.GetRequest(ctx, "foo/bar", "bar.mender", 0)
+	assert.ErrorIs(t, err, storage.ErrObjectNotFound)
+}
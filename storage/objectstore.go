@@ -17,6 +17,7 @@ package storage
 import (
 	"context"
 	"errors"
+	"fmt"
 	"io"
 	"time"
 
@@ -24,18 +25,60 @@ import (
 )
 
 var (
-	ErrObjectNotFound = errors.New("object not found")
+	// ErrObjectNotFound matches (errors.Is) all the ObjectNotFoundError.
+	ErrObjectNotFound   error = &ObjectNotFoundError{}
+	ErrChecksumMismatch       = errors.New("checksum mismatch")
 )
 
+// ObjectNotFoundError is returned when the object at Path does not exist.
+type ObjectNotFoundError struct {
+	Path string
+}
+
+func (err *ObjectNotFoundError) Error() string {
+	if err.Path == "" {
+		return "object not found"
+	}
+	return fmt.Sprintf("object not found: %s", err.Path)
+}
+
+// Is matches the errors about the same path, or any path if the target
+// has none (e.g. ErrObjectNotFound).
+func (err *ObjectNotFoundError) Is(target error) bool {
+	notFound, ok := target.(*ObjectNotFoundError)
+	return ok && (notFound.Path == "" || notFound.Path == err.Path)
+}
+
+// ListObjectsMaxResults is the number of objects returned by ListObjects
+// when maxResults is not positive.
+const ListObjectsMaxResults = 10000
+
 // ObjectStorage allows to store and manage large files
 //
 //go:generate ../utils/mockgen.sh
 type ObjectStorage interface {
 	HealthCheck(ctx context.Context) error
 	GetObject(ctx context.Context, path string) (io.ReadCloser, error)
+	// GetObjectWithMetadata downloads the object and returns its
+	// properties from the same response.
+	GetObjectWithMetadata(ctx context.Context, path string) (*ObjectInfo, io.ReadCloser, error)
+	// GetObjectReader streams the object content as stored, without
+	// decoding any content encoding (e.g. to validate its checksum).
+	// Closing the reader drains and closes the response body.
+	GetObjectReader(ctx context.Context, path string) (io.ReadCloser, error)
 	PutObject(ctx context.Context, path string, src io.Reader) error
+	// CopyObject copies the object at srcPath to dstPath without
+	// downloading it; it returns ErrObjectNotFound if the source does not
+	// exist.
+	CopyObject(ctx context.Context, srcPath, dstPath string) error
 	DeleteObject(ctx context.Context, path string) error
 	StatObject(ctx context.Context, path string) (*ObjectInfo, error)
+	// HeadObject checks if the object exists; a missing object is not
+	// an error.
+	HeadObject(ctx context.Context, path string) (bool, error)
+	// ListObjects returns up to maxResults objects whose path starts with
+	// prefix (ListObjectsMaxResults if maxResults is not positive).
+	ListObjects(ctx context.Context, prefix string, maxResults int) ([]ObjectInfo, error)
 
 	// The following interface generates signed URLs.
 	GetRequest(ctx context.Context, path string, filename string,
@@ -44,6 +87,9 @@ type ObjectStorage interface {
 		duration time.Duration) (*model.Link, error)
 	PutRequest(ctx context.Context, path string,
 		duration time.Duration) (*model.Link, error)
+
+	// Close releases the resources held by the storage client.
+	Close() error
 }
 
 type ObjectInfo struct {
@@ -52,6 +98,12 @@ type ObjectInfo struct {
 	Size *int64
 
 	LastModified *time.Time
+
+	// ContentType, ETag and ContentMD5 are only set by StatObject, when
+	// the backend returns them.
+	ContentType string
+	ETag        string
+	ContentMD5  []byte
 }
 
 type ObjectReader interface {
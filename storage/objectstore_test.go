@@ -0,0 +1,89 @@
+// Copyright 2023 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestObjectNotFoundError(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		Err    error
+		Target error
+
+		Is      bool
+		Message string
+	}{
+		"ok, sentinel": {
+			Err:    ErrObjectNotFound,
+			Target: ErrObjectNotFound,
+
+			Is:      true,
+			Message: "object not found",
+		},
+		"ok, path matches sentinel": {
+			Err:    &ObjectNotFoundError{Path: "foo/bar"},
+			Target: ErrObjectNotFound,
+
+			Is:      true,
+			Message: "object not found: foo/bar",
+		},
+		"ok, same path": {
+			Err:    &ObjectNotFoundError{Path: "foo/bar"},
+			Target: &ObjectNotFoundError{Path: "foo/bar"},
+
+			Is:      true,
+			Message: "object not found: foo/bar",
+		},
+		"ok, wrapped": {
+			Err:    fmt.Errorf("failed to stat: %w", &ObjectNotFoundError{Path: "foo"}),
+			Target: ErrObjectNotFound,
+
+			Is:      true,
+			Message: "failed to stat: object not found: foo",
+		},
+		"other path": {
+			Err:    &ObjectNotFoundError{Path: "foo/bar"},
+			Target: &ObjectNotFoundError{Path: "foo/baz"},
+
+			Message: "object not found: foo/bar",
+		},
+		"sentinel does not match a path": {
+			Err:    ErrObjectNotFound,
+			Target: &ObjectNotFoundError{Path: "foo/bar"},
+
+			Message: "object not found",
+		},
+		"other error": {
+			Err:    ErrChecksumMismatch,
+			Target: ErrObjectNotFound,
+
+			Message: "checksum mismatch",
+		},
+	}
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.Is, errors.Is(tc.Err, tc.Target))
+			assert.EqualError(t, tc.Err, tc.Message)
+		})
+	}
+}
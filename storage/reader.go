@@ -0,0 +1,35 @@
+// Copyright 2023 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package storage
+
+import "io"
+
+type drainingReadCloser struct {
+	io.ReadCloser
+}
+
+// DrainOnClose returns a reader which reads the remaining content of rc
+// before closing it, so that the underlying HTTP connection can be reused.
+func DrainOnClose(rc io.ReadCloser) io.ReadCloser {
+	return drainingReadCloser{ReadCloser: rc}
+}
+
+func (r drainingReadCloser) Close() error {
+	_, err := io.Copy(io.Discard, r.ReadCloser)
+	if errClose := r.ReadCloser.Close(); errClose != nil {
+		err = errClose
+	}
+	return err
+}
@@ -0,0 +1,63 @@
+// Copyright 2023 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package storage
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type trackingReadCloser struct {
+	io.Reader
+	closed bool
+}
+
+func (r *trackingReadCloser) Close() error {
+	r.closed = true
+	return nil
+}
+
+type errReader struct{}
+
+func (errReader) Read([]byte) (int, error) {
+	return 0, errors.New("read error")
+}
+
+func TestDrainOnClose(t *testing.T) {
+	t.Parallel()
+
+	body := &trackingReadCloser{Reader: strings.NewReader("imagine artifacts")}
+	rc := DrainOnClose(body)
+	b := make([]byte, 7)
+	_, err := io.ReadFull(rc, b)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Equal(t, "imagine", string(b))
+
+	assert.NoError(t, rc.Close())
+	assert.True(t, body.closed)
+	n, _ := body.Read(b)
+	assert.Zero(t, n, "the remaining content must be drained")
+
+	body = &trackingReadCloser{Reader: errReader{}}
+	err = DrainOnClose(body).Close()
+	assert.EqualError(t, err, "read error")
+	assert.True(t, body.closed, "the body must be closed on drain error")
+}
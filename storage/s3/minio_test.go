@@ -0,0 +1,189 @@
+// Copyright 2023 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+//go:build minio
+// +build minio
+
+package s3
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mendersoftware/deployments/storage"
+)
+
+const (
+	minioImage     = "minio/minio:latest"
+	minioAccessKey = "minioadmin"
+	minioSecretKey = "minioadmin"
+	minioRegion    = "us-east-1"
+)
+
+// startMinIO starts a MinIO container and returns its endpoint, or the
+// endpoint from TEST_MINIO_ENDPOINT if set (using the default credentials).
+func startMinIO(t *testing.T) string {
+	if endpoint := os.Getenv("TEST_MINIO_ENDPOINT"); endpoint != "" {
+		return endpoint
+	}
+	out, err := exec.Command("docker", "run", "--detach", "--rm",
+		"--publish", "127.0.0.1::9000",
+		"--env", "MINIO_ROOT_USER="+minioAccessKey,
+		"--env", "MINIO_ROOT_PASSWORD="+minioSecretKey,
+		minioImage, "server", "/data",
+	).Output()
+	if err != nil {
+		t.Fatalf("failed to start the MinIO container: %s", err)
+	}
+	containerID := strings.TrimSpace(string(out))
+	t.Cleanup(func() {
+		_ = exec.Command("docker", "rm", "--force", containerID).Run()
+	})
+
+	out, err = exec.Command("docker", "port", containerID, "9000/tcp").Output()
+	if err != nil {
+		t.Fatalf("failed to get the MinIO port: %s", err)
+	}
+	// docker port may list several bindings, one per line
+	hostPort := strings.SplitN(strings.TrimSpace(string(out)), "\n", 2)[0]
+	endpoint := "http://" + hostPort
+
+	deadline := time.Now().Add(time.Minute)
+	for {
+		rsp, err := http.Get(endpoint + "/minio/health/live")
+		if err == nil {
+			rsp.Body.Close()
+			if rsp.StatusCode == http.StatusOK {
+				return endpoint
+			}
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("MinIO did not become ready in time")
+		}
+		time.Sleep(500 * time.Millisecond)
+	}
+}
+
+func doLink(t *testing.T, method, uri string, body io.Reader) *http.Response {
+	req, err := http.NewRequest(method, uri, body)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	rsp, err := http.DefaultClient.Do(req)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	return rsp
+}
+
+func TestMinIO(t *testing.T) {
+	endpoint := startMinIO(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	bucket := fmt.Sprintf("deployments-minio-%d", time.Now().UnixNano())
+	s, err := New(ctx, NewOptions().
+		SetURI(endpoint).
+		SetForcePathStyle(true).
+		SetRegion(minioRegion).
+		SetStaticCredentials(minioAccessKey, minioSecretKey, "").
+		SetBucketName(bucket))
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	defer s.Close()
+
+	assert.NoError(t, s.HealthCheck(ctx))
+
+	content := []byte("imagine artifacts")
+	err = s.PutObject(ctx, "foo/bar", bytes.NewReader(content))
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	body, err := s.GetObject(ctx, "foo/bar")
+	if assert.NoError(t, err) {
+		b, _ := io.ReadAll(body)
+		body.Close()
+		assert.Equal(t, content, b)
+	}
+
+	info, err := s.StatObject(ctx, "foo/bar")
+	if assert.NoError(t, err) && assert.NotNil(t, info.Size) {
+		assert.Equal(t, int64(len(content)), *info.Size)
+	}
+
+	exists, err := s.HeadObject(ctx, "foo/bar")
+	assert.NoError(t, err)
+	assert.True(t, exists)
+
+	err = s.CopyObject(ctx, "foo/bar", "foo/baz")
+	assert.NoError(t, err)
+
+	objects, err := s.ListObjects(ctx, "foo/", 0)
+	if assert.NoError(t, err) {
+		paths := make([]string, 0, len(objects))
+		for _, obj := range objects {
+			paths = append(paths, obj.Path)
+		}
+		assert.ElementsMatch(t, []string{"foo/bar", "foo/baz"}, paths)
+	}
+
+	// signed requests
+	putLink, err := s.PutRequest(ctx, "signed/bar", time.Minute)
+	if assert.NoError(t, err) {
+		rsp := doLink(t, putLink.Method, putLink.Uri, bytes.NewReader(content))
+		rsp.Body.Close()
+		assert.Equal(t, http.StatusOK, rsp.StatusCode)
+	}
+
+	getLink, err := s.GetRequest(ctx, "signed/bar", "bar.mender", time.Minute)
+	if assert.NoError(t, err) {
+		rsp := doLink(t, getLink.Method, getLink.Uri, nil)
+		b, _ := io.ReadAll(rsp.Body)
+		rsp.Body.Close()
+		assert.Equal(t, http.StatusOK, rsp.StatusCode)
+		assert.Equal(t, content, b)
+		assert.Equal(t, `attachment; filename="bar.mender"`,
+			rsp.Header.Get("Content-Disposition"))
+	}
+
+	deleteLink, err := s.DeleteRequest(ctx, "signed/bar", time.Minute)
+	if assert.NoError(t, err) {
+		rsp := doLink(t, deleteLink.Method, deleteLink.Uri, nil)
+		rsp.Body.Close()
+		assert.Equal(t, http.StatusNoContent, rsp.StatusCode)
+	}
+	exists, err = s.HeadObject(ctx, "signed/bar")
+	assert.NoError(t, err)
+	assert.False(t, exists)
+
+	for _, path := range []string{"foo/bar", "foo/baz"} {
+		assert.NoError(t, s.DeleteObject(ctx, path))
+	}
+	_, err = s.GetObject(ctx, "foo/bar")
+	assert.True(t, errors.Is(err, storage.ErrObjectNotFound),
+		"unexpected error: %v", err)
+}
@@ -22,6 +22,7 @@ import (
 	"io"
 	"net/http"
 	"net/url"
+	"strings"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
@@ -191,7 +192,7 @@ func (s *SimpleStorageService) init(ctx context.Context) error {
 	_, err = s.client.CreateBucket(ctx, cparams, disableAccelerate)
 	if err != nil {
 		var errBucket *types.BucketAlreadyOwnedByYou
-		if !errors.As(err, errBucket) {
+		if !errors.As(err, &errBucket) {
 			return errors.WithMessage(err, "s3: error creating bucket")
 		}
 	}
@@ -222,6 +223,11 @@ func (s *SimpleStorageService) optionsFromContext(
 	return settings, err
 }
 
+// Close is a no-op, the s3 client holds no resources to release.
+func (s *SimpleStorageService) Close() error {
+	return nil
+}
+
 func (s *SimpleStorageService) HealthCheck(ctx context.Context) error {
 	opts, err := s.optionsFromContext(ctx)
 	if err != nil {
@@ -246,10 +252,29 @@ func (s *SimpleStorageService) GetObject(
 	ctx context.Context,
 	path string,
 ) (io.ReadCloser, error) {
-	opts, err := s.optionsFromContext(ctx)
+	_, body, err := s.GetObjectWithMetadata(ctx, path)
+	return body, err
+}
+
+func (s *SimpleStorageService) GetObjectReader(
+	ctx context.Context,
+	path string,
+) (io.ReadCloser, error) {
+	body, err := s.GetObject(ctx, path)
 	if err != nil {
 		return nil, err
 	}
+	return storage.DrainOnClose(body), nil
+}
+
+func (s *SimpleStorageService) GetObjectWithMetadata(
+	ctx context.Context,
+	path string,
+) (*storage.ObjectInfo, io.ReadCloser, error) {
+	opts, err := s.optionsFromContext(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
 	params := &s3.GetObjectInput{
 		Bucket: opts.BucketName,
 		Key:    aws.String(path),
@@ -261,21 +286,67 @@ func (s *SimpleStorageService) GetObject(
 	var rspErr *awsHttp.ResponseError
 	if errors.As(err, &rspErr) {
 		if rspErr.Response.StatusCode == http.StatusNotFound {
-			err = storage.ErrObjectNotFound
+			err = &storage.ObjectNotFoundError{Path: path}
 		}
 	}
 	if err != nil {
-		return nil, errors.WithMessage(
+		return nil, nil, errors.WithMessage(
 			err,
 			"s3: failed to get object",
 		)
 	}
-	return objectReader{
+	info := &storage.ObjectInfo{
+		Path:         path,
+		LastModified: out.LastModified,
+		Size:         &out.ContentLength,
+	}
+	return info, objectReader{
 		ReadCloser: out.Body,
 		length:     out.ContentLength,
 	}, nil
 }
 
+// CopyObject copies the object at srcPath to dstPath within the bucket.
+func (s *SimpleStorageService) CopyObject(
+	ctx context.Context,
+	srcPath, dstPath string,
+) error {
+	opts, err := s.optionsFromContext(ctx)
+	if err != nil {
+		return err
+	}
+	params := &s3.CopyObjectInput{
+		Bucket: opts.BucketName,
+		Key:    aws.String(dstPath),
+		CopySource: aws.String(
+			url.PathEscape(*opts.BucketName) + "/" + escapeKey(srcPath),
+		),
+
+		RequestPayer: types.RequestPayerRequester,
+	}
+	_, err = s.client.CopyObject(ctx, params, opts.options)
+	var rspErr *awsHttp.ResponseError
+	if errors.As(err, &rspErr) {
+		if rspErr.Response.StatusCode == http.StatusNotFound {
+			err = &storage.ObjectNotFoundError{Path: srcPath}
+		}
+	}
+	if err != nil {
+		return errors.WithMessage(err, "s3: failed to copy object")
+	}
+	return nil
+}
+
+// escapeKey escapes each segment of the object key for use in the
+// x-amz-copy-source header.
+func escapeKey(key string) string {
+	segments := strings.Split(key, "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+	return strings.Join(segments, "/")
+}
+
 // Delete removes deleted file from storage.
 // Noop if ID does not exist.
 func (s *SimpleStorageService) DeleteObject(ctx context.Context, path string) error {
@@ -299,7 +370,7 @@ func (s *SimpleStorageService) DeleteObject(ctx context.Context, path string) er
 	var rspErr *awsHttp.ResponseError
 	if errors.As(err, &rspErr) {
 		if rspErr.Response.StatusCode == http.StatusNotFound {
-			err = storage.ErrObjectNotFound
+			err = &storage.ObjectNotFoundError{Path: path}
 		}
 	}
 	if err != nil {
@@ -328,7 +399,7 @@ func (s *SimpleStorageService) StatObject(
 	var rspErr *awsHttp.ResponseError
 	if errors.As(err, &rspErr) {
 		if rspErr.Response.StatusCode == http.StatusNotFound {
-			err = storage.ErrObjectNotFound
+			err = &storage.ObjectNotFoundError{Path: path}
 		}
 	}
 	if err != nil {
@@ -342,6 +413,64 @@ func (s *SimpleStorageService) StatObject(
 	}, nil
 }
 
+func (s *SimpleStorageService) HeadObject(
+	ctx context.Context,
+	path string,
+) (bool, error) {
+	_, err := s.StatObject(ctx, path)
+	if errors.Is(err, storage.ErrObjectNotFound) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// ListObjects returns up to maxResults objects whose key starts with
+// prefix.
+func (s *SimpleStorageService) ListObjects(
+	ctx context.Context,
+	prefix string,
+	maxResults int,
+) ([]storage.ObjectInfo, error) {
+	opts, err := s.optionsFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if maxResults <= 0 {
+		maxResults = storage.ListObjectsMaxResults
+	}
+	params := &s3.ListObjectsV2Input{
+		Bucket: opts.BucketName,
+		Prefix: aws.String(prefix),
+
+		RequestPayer: types.RequestPayerRequester,
+	}
+	if maxResults < 1000 {
+		params.MaxKeys = int32(maxResults)
+	}
+	objects := make([]storage.ObjectInfo, 0)
+	pager := s3.NewListObjectsV2Paginator(s.client, params)
+	for pager.HasMorePages() && len(objects) < maxResults {
+		page, err := pager.NextPage(ctx, opts.options)
+		if err != nil {
+			return nil, errors.WithMessage(err, "s3: failed to list objects")
+		}
+		for i := range page.Contents {
+			if len(objects) == maxResults {
+				break
+			}
+			item := page.Contents[i]
+			objects = append(objects, storage.ObjectInfo{
+				Path:         aws.ToString(item.Key),
+				Size:         &item.Size,
+				LastModified: item.LastModified,
+			})
+		}
+	}
+	return objects, nil
+}
+
 func fillBuffer(b []byte, r io.Reader) (int, error) {
 	var offset int
 	var err error
@@ -548,10 +677,12 @@ func buildLink(
 		return nil, fmt.Errorf("s3: failed to rewrite signed URL to proxy: %w", err)
 	}
 
+	notBefore := signDate.UTC()
 	return &model.Link{
-		Uri:    signURL.String(),
-		Expire: signDate.Add(expireAfter),
-		Method: req.Method,
+		Uri:       signURL.String(),
+		Expire:    signDate.Add(expireAfter),
+		NotBefore: &notBefore,
+		Method:    req.Method,
 	}, nil
 }
 
@@ -600,7 +731,8 @@ func (s *SimpleStorageService) GetRequest(
 		return nil, err
 	}
 
-	if _, err := s.StatObject(ctx, objectPath); err != nil {
+	info, err := s.StatObject(ctx, objectPath)
+	if err != nil {
 		return nil, errors.WithMessage(err, "s3: head object")
 	}
 
@@ -623,7 +755,11 @@ func (s *SimpleStorageService) GetRequest(
 	if err != nil {
 		return nil, errors.WithMessage(err, "s3: failed to sign GET request")
 	}
-	return buildLink(req, signDate, expireAfter, opts.ProxyURI)
+	link, err := buildLink(req, signDate, expireAfter, opts.ProxyURI)
+	if err == nil && info.Size != nil {
+		link.Size = *info.Size
+	}
+	return link, err
 }
 
 // DeleteRequest returns a presigned deletion request
@@ -381,3 +381,66 @@ func TestGetObject(t *testing.T) {
 		})
 	}
 }
+
+func TestCopyObject(t *testing.T) {
+	t.Parallel()
+
+	type testCase struct {
+		Name string
+
+		Handler func(t *testing.T) http.HandlerFunc
+		Error   assert.ErrorAssertionFunc
+	}
+
+	testCases := []testCase{{
+		Name: "ok",
+
+		Handler: func(t *testing.T) http.HandlerFunc {
+			return func(w http.ResponseWriter, r *http.Request) {
+				assert.Equal(t, http.MethodPut, r.Method)
+				assert.Equal(t, "/foo/dst", r.URL.Path)
+				assert.Equal(t, "bucket/foo/src%20file",
+					r.Header.Get("x-amz-copy-source"))
+
+				w.WriteHeader(http.StatusOK)
+				_, _ = w.Write([]byte(`<CopyObjectResult></CopyObjectResult>`))
+			}
+		},
+	}, {
+		Name: "error/object not found",
+
+		Handler: func(t *testing.T) http.HandlerFunc {
+			return func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusNotFound)
+			}
+		},
+		Error: func(t assert.TestingT, err error, _ ...interface{}) bool {
+			return assert.ErrorIs(t, err, storage.ErrObjectNotFound)
+		},
+	}, {
+		Name: "error/access denied",
+
+		Handler: func(t *testing.T) http.HandlerFunc {
+			return func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusForbidden)
+			}
+		},
+		Error: func(t assert.TestingT, err error, _ ...interface{}) bool {
+			return assert.ErrorContains(t, err, "s3: failed to copy object")
+		},
+	}}
+
+	for i := range testCases {
+		tc := testCases[i]
+		t.Run(tc.Name, func(t *testing.T) {
+			s3c, srv := newTestServerAndClient(tc.Handler(t))
+			defer srv.Close()
+			err := s3c.CopyObject(context.Background(), "foo/src file", "foo/dst")
+			if tc.Error != nil {
+				tc.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
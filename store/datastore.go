@@ -152,12 +152,27 @@ type DataStore interface {
 		id string, stats model.Stats) error
 	Find(ctx context.Context,
 		query model.Query) ([]*model.Deployment, int64, error)
+	FindWithCursor(ctx context.Context,
+		query model.Query) ([]*model.Deployment, string, error)
+	FindDeployments(ctx context.Context,
+		filter model.DeploymentFilter,
+		page model.Pagination) ([]*model.Deployment, int64, error)
+	CountDeploymentsByType(ctx context.Context,
+		query model.Query) (model.DeploymentCountByType, error)
+	CountDeploymentsByStatus(ctx context.Context,
+		query model.Query) (model.DeploymentCountByStatus, error)
 	SetDeploymentStatus(
 		ctx context.Context,
 		id string,
 		status model.DeploymentStatus,
 		now time.Time,
 	) error
+	SetDeploymentsStatus(
+		ctx context.Context,
+		ids []string,
+		status model.DeploymentStatus,
+		now time.Time,
+	) error
 	FindNewerActiveDeployments(ctx context.Context,
 		createdAfter *time.Time, skip, limit int) ([]*model.Deployment, error)
 	ExistUnfinishedByArtifactId(ctx context.Context, id string) (bool, error)
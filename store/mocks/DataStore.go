@@ -54,9 +54,7 @@ func (_m *DataStore) AggregateDeviceDeploymentByStatus(ctx context.Context, id s
 	if rf, ok := ret.Get(0).(func(context.Context, string) model.Stats); ok {
 		r0 = rf(ctx, id)
 	} else {
-		if ret.Get(0) != nil {
-			r0 = ret.Get(0).(model.Stats)
-		}
+		r0 = ret.Get(0).(model.Stats)
 	}
 
 	var r1 error
@@ -83,6 +81,52 @@ func (_m *DataStore) AssignArtifact(ctx context.Context, deviceID string, deploy
 	return r0
 }
 
+// CountDeploymentsByStatus provides a mock function with given fields: ctx, query
+func (_m *DataStore) CountDeploymentsByStatus(ctx context.Context, query model.Query) (model.DeploymentCountByStatus, error) {
+	ret := _m.Called(ctx, query)
+
+	var r0 model.DeploymentCountByStatus
+	if rf, ok := ret.Get(0).(func(context.Context, model.Query) model.DeploymentCountByStatus); ok {
+		r0 = rf(ctx, query)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(model.DeploymentCountByStatus)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, model.Query) error); ok {
+		r1 = rf(ctx, query)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// CountDeploymentsByType provides a mock function with given fields: ctx, query
+func (_m *DataStore) CountDeploymentsByType(ctx context.Context, query model.Query) (model.DeploymentCountByType, error) {
+	ret := _m.Called(ctx, query)
+
+	var r0 model.DeploymentCountByType
+	if rf, ok := ret.Get(0).(func(context.Context, model.Query) model.DeploymentCountByType); ok {
+		r0 = rf(ctx, query)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(model.DeploymentCountByType)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, model.Query) error); ok {
+		r1 = rf(ctx, query)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // DecommissionDeviceDeployments provides a mock function with given fields: ctx, deviceId
 func (_m *DataStore) DecommissionDeviceDeployments(ctx context.Context, deviceId string) error {
 	ret := _m.Called(ctx, deviceId)
@@ -355,6 +399,36 @@ func (_m *DataStore) FindDeploymentStatsByIDs(ctx context.Context, ids ...string
 	return r0, r1
 }
 
+// FindDeployments provides a mock function with given fields: ctx, filter, page
+func (_m *DataStore) FindDeployments(ctx context.Context, filter model.DeploymentFilter, page model.Pagination) ([]*model.Deployment, int64, error) {
+	ret := _m.Called(ctx, filter, page)
+
+	var r0 []*model.Deployment
+	if rf, ok := ret.Get(0).(func(context.Context, model.DeploymentFilter, model.Pagination) []*model.Deployment); ok {
+		r0 = rf(ctx, filter, page)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]*model.Deployment)
+		}
+	}
+
+	var r1 int64
+	if rf, ok := ret.Get(1).(func(context.Context, model.DeploymentFilter, model.Pagination) int64); ok {
+		r1 = rf(ctx, filter, page)
+	} else {
+		r1 = ret.Get(1).(int64)
+	}
+
+	var r2 error
+	if rf, ok := ret.Get(2).(func(context.Context, model.DeploymentFilter, model.Pagination) error); ok {
+		r2 = rf(ctx, filter, page)
+	} else {
+		r2 = ret.Error(2)
+	}
+
+	return r0, r1, r2
+}
+
 // FindImageByID provides a mock function with given fields: ctx, id
 func (_m *DataStore) FindImageByID(ctx context.Context, id string) (*model.Image, error) {
 	ret := _m.Called(ctx, id)
@@ -493,6 +567,36 @@ func (_m *DataStore) FindUploadLinks(ctx context.Context, expired time.Time) (st
 	return r0, r1
 }
 
+// FindWithCursor provides a mock function with given fields: ctx, query
+func (_m *DataStore) FindWithCursor(ctx context.Context, query model.Query) ([]*model.Deployment, string, error) {
+	ret := _m.Called(ctx, query)
+
+	var r0 []*model.Deployment
+	if rf, ok := ret.Get(0).(func(context.Context, model.Query) []*model.Deployment); ok {
+		r0 = rf(ctx, query)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]*model.Deployment)
+		}
+	}
+
+	var r1 string
+	if rf, ok := ret.Get(1).(func(context.Context, model.Query) string); ok {
+		r1 = rf(ctx, query)
+	} else {
+		r1 = ret.Get(1).(string)
+	}
+
+	var r2 error
+	if rf, ok := ret.Get(2).(func(context.Context, model.Query) error); ok {
+		r2 = rf(ctx, query)
+	} else {
+		r2 = ret.Error(2)
+	}
+
+	return r0, r1, r2
+}
+
 // GetDeviceDeployment provides a mock function with given fields: ctx, deploymentID, deviceID, includeDeleted
 func (_m *DataStore) GetDeviceDeployment(ctx context.Context, deploymentID string, deviceID string, includeDeleted bool) (*model.DeviceDeployment, error) {
 	ret := _m.Called(ctx, deploymentID, deviceID, includeDeleted)
@@ -1185,6 +1289,20 @@ func (_m *DataStore) SetDeploymentStatus(ctx context.Context, id string, status
 	return r0
 }
 
+// SetDeploymentsStatus provides a mock function with given fields: ctx, ids, status, now
+func (_m *DataStore) SetDeploymentsStatus(ctx context.Context, ids []string, status model.DeploymentStatus, now time.Time) error {
+	ret := _m.Called(ctx, ids, status, now)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, []string, model.DeploymentStatus, time.Time) error); ok {
+		r0 = rf(ctx, ids, status, now)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // SetStorageSettings provides a mock function with given fields: ctx, storageSettings
 func (_m *DataStore) SetStorageSettings(ctx context.Context, storageSettings *model.StorageSettings) error {
 	ret := _m.Called(ctx, storageSettings)
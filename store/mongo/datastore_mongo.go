@@ -107,6 +107,9 @@ var (
 	// Indexes 1.2.18
 	IndexNameAggregatedUpdateTypes = "aggregated_release_update_types"
 
+	// Indexes 1.2.19
+	IndexDeploymentDeviceListName = "deployment_device_list"
+
 	_false         = false
 	_true          = true
 	StorageIndexes = mongo.IndexModel{
@@ -367,6 +370,16 @@ var (
 			Name:       &IndexArtifactProvidesName,
 		},
 	}
+
+	// Index 1.2.19
+	IndexDeploymentDeviceListModel = mongo.IndexModel{
+		Keys: bson.D{
+			{Key: StorageKeyDeploymentDeviceList, Value: 1},
+			{Key: StorageKeyDeploymentCreated, Value: -1},
+		},
+		Options: mopts.Index().
+			SetName(IndexDeploymentDeviceListName),
+	}
 )
 
 // Errors
@@ -384,6 +397,7 @@ var (
 	ErrStorageNotFound                    = errors.New("Not found")
 	ErrDeploymentStorageInvalidQuery      = errors.New("Invalid query")
 	ErrDeploymentStorageCannotExecQuery   = errors.New("Cannot execute query")
+	ErrDeploymentStorageInvalidCursor     = errors.New("Invalid cursor")
 	ErrStorageInvalidInput                = errors.New("invalid input")
 
 	ErrLimitNotFound      = errors.New("limit not found")
@@ -453,7 +467,10 @@ const (
 
 	StorageKeyDeploymentName         = "deploymentconstructor.name"
 	StorageKeyDeploymentArtifactName = "deploymentconstructor.artifactname"
+	StorageKeyDeploymentComment      = "deploymentconstructor.comment"
+	StorageKeyDeploymentTags         = "deploymentconstructor.tags"
 	StorageKeyDeploymentStats        = "stats"
+	StorageKeyDeploymentUpdatedAt    = "updated_at"
 	StorageKeyDeploymentActive       = "active"
 	StorageKeyDeploymentStatus       = "status"
 	StorageKeyDeploymentCreated      = "created"
@@ -462,6 +479,7 @@ const (
 	StorageKeyDeploymentArtifacts    = "artifacts"
 	StorageKeyDeploymentDeviceCount  = "device_count"
 	StorageKeyDeploymentMaxDevices   = "max_devices"
+	StorageKeyDeploymentDeviceList   = "device_list"
 	StorageKeyDeploymentType         = "type"
 	StorageKeyDeploymentTotalSize    = "statistics.total_size"
 
@@ -1713,7 +1731,7 @@ func (db *DataStoreMongo) AggregateDeviceDeploymentByStatus(ctx context.Context,
 	id string) (model.Stats, error) {
 
 	if len(id) == 0 {
-		return nil, ErrStorageInvalidID
+		return model.Stats{}, ErrStorageInvalidID
 	}
 
 	database := db.client.Database(mstore.DbFromContext(ctx, DatabaseName))
@@ -1745,13 +1763,13 @@ func (db *DataStoreMongo) AggregateDeviceDeploymentByStatus(ctx context.Context,
 	}
 	cursor, err := collDevs.Aggregate(ctx, pipeline)
 	if err != nil {
-		return nil, err
+		return model.Stats{}, err
 	}
 	if err := cursor.All(ctx, &results); err != nil {
 		if err == mongo.ErrNoDocuments {
-			return nil, nil
+			return model.Stats{}, nil
 		}
-		return nil, err
+		return model.Stats{}, err
 	}
 
 	raw := model.NewDeviceDeploymentStats()
@@ -2478,20 +2496,21 @@ func (db *DataStoreMongo) UpdateStats(ctx context.Context,
 	}
 
 	deployment.Stats = stats
+	now := time.Now()
 	var update bson.M
 	if deployment.IsFinished() {
-		now := time.Now()
-
 		update = bson.M{
 			"$set": bson.M{
-				StorageKeyDeploymentStats:    stats,
-				StorageKeyDeploymentFinished: &now,
+				StorageKeyDeploymentStats:     stats,
+				StorageKeyDeploymentFinished:  &now,
+				StorageKeyDeploymentUpdatedAt: &now,
 			},
 		}
 	} else {
 		update = bson.M{
 			"$set": bson.M{
-				StorageKeyDeploymentStats: stats,
+				StorageKeyDeploymentStats:     stats,
+				StorageKeyDeploymentUpdatedAt: &now,
 			},
 		}
 	}
@@ -2541,6 +2560,7 @@ func (db *DataStoreMongo) UpdateStatsInc(ctx context.Context, id string,
 			},
 		}
 	}
+	update["$set"] = bson.M{StorageKeyDeploymentUpdatedAt: time.Now()}
 
 	res, err := collDpl.UpdateOne(ctx, bson.M{"_id": id}, update)
 
@@ -2573,11 +2593,10 @@ func (db *DataStoreMongo) IncrementDeploymentTotalSize(
 	return err
 }
 
-func (db *DataStoreMongo) Find(ctx context.Context,
-	match model.Query) ([]*model.Deployment, int64, error) {
-
-	database := db.client.Database(mstore.DbFromContext(ctx, DatabaseName))
-	collDpl := database.Collection(CollectionDeployments)
+// deploymentsFilter builds the deployments collection filter matching the
+// deployment filter.
+func (db *DataStoreMongo) deploymentsFilter(ctx context.Context,
+	match model.DeploymentFilter) (bson.M, error) {
 
 	andq := []bson.M{}
 
@@ -2595,7 +2614,7 @@ func (db *DataStoreMongo) Find(ctx context.Context,
 	if match.SearchText != "" {
 		// we must have indexing for text search
 		if !db.hasIndexing(ctx, db.client) {
-			return nil, 0, ErrDeploymentStorageCannotExecQuery
+			return nil, ErrDeploymentStorageCannotExecQuery
 		}
 
 		tq := bson.M{
@@ -2614,6 +2633,10 @@ func (db *DataStoreMongo) Find(ctx context.Context,
 			status = model.DeploymentStatusPending
 		} else if match.Status == model.StatusQueryInProgress {
 			status = model.DeploymentStatusInProgress
+		} else if match.Status == model.StatusQueryCancelling {
+			status = model.DeploymentStatusCancelling
+		} else if match.Status == model.StatusQueryScheduled {
+			status = model.DeploymentStatusScheduled
 		} else {
 			status = model.DeploymentStatusFinished
 		}
@@ -2621,17 +2644,57 @@ func (db *DataStoreMongo) Find(ctx context.Context,
 		andq = append(andq, stq)
 	}
 
+	// build deployment by active state part of the query
+	if statuses := match.IsActiveStatuses(); statuses != nil {
+		andq = append(andq, bson.M{
+			StorageKeyDeploymentStatus: bson.M{"$in": statuses},
+		})
+	}
+
+	// build deployment by comment part of the query; null matches the
+	// deployments without the comment field
+	if match.HasComment != nil {
+		op := "$in"
+		if *match.HasComment {
+			op = "$nin"
+		}
+		andq = append(andq, bson.M{
+			StorageKeyDeploymentComment: bson.M{op: bson.A{nil, ""}},
+		})
+	}
+
+	// build deployment by tags part of the query
+	for key, value := range match.Tags {
+		andq = append(andq, bson.M{
+			StorageKeyDeploymentTags + "." + key: value,
+		})
+	}
+
+	// build deployment by artifact name part of the query
+	if match.ArtifactName != "" {
+		andq = append(andq, bson.M{
+			StorageKeyDeploymentArtifactName: match.ArtifactName,
+		})
+	}
+
+	// build deployment by device part of the query
+	if match.DeviceID != "" {
+		andq = append(andq, bson.M{
+			StorageKeyDeploymentDeviceList: match.DeviceID,
+		})
+	}
+
 	// build deployment by type part of the query
 	if match.Type != "" {
-		if match.Type == model.DeploymentTypeConfiguration {
-			andq = append(andq, bson.M{StorageKeyDeploymentType: match.Type})
-		} else if match.Type == model.DeploymentTypeSoftware {
+		if match.Type == model.DeploymentTypeSoftware {
 			andq = append(andq, bson.M{
 				"$or": []bson.M{
 					{StorageKeyDeploymentType: match.Type},
 					{StorageKeyDeploymentType: ""},
 				},
 			})
+		} else {
+			andq = append(andq, bson.M{StorageKeyDeploymentType: match.Type})
 		}
 	}
 
@@ -2658,50 +2721,317 @@ func (db *DataStoreMongo) Find(ctx context.Context,
 		}
 	}
 
-	options := db.findOptions(match)
+	updatedAt := bson.M{}
+	if match.UpdatedAfter != nil {
+		updatedAt["$gte"] = match.UpdatedAfter
+	}
+	if match.UpdatedBefore != nil {
+		updatedAt["$lte"] = match.UpdatedBefore
+	}
+	if len(updatedAt) > 0 {
+		query[StorageKeyDeploymentUpdatedAt] = updatedAt
+	}
 
-	var deployments []*model.Deployment
-	cursor, err := collDpl.Find(ctx, query, options)
+	return query, nil
+}
+
+// Find returns a page of the deployments matching the query and the total
+// number of deployments matching it, unless the query disables the count.
+func (db *DataStoreMongo) Find(ctx context.Context,
+	match model.Query) ([]*model.Deployment, int64, error) {
+
+	return db.findAndCount(ctx, match.Filter(), match.Pagination(),
+		!match.DisableCount)
+}
+
+// FindDeployments returns the page of the deployments matching the filter
+// and the total number of deployments matching it.
+func (db *DataStoreMongo) FindDeployments(ctx context.Context,
+	filter model.DeploymentFilter,
+	page model.Pagination,
+) ([]*model.Deployment, int64, error) {
+
+	return db.findAndCount(ctx, filter, page, true)
+}
+
+func (db *DataStoreMongo) findAndCount(ctx context.Context,
+	filter model.DeploymentFilter,
+	page model.Pagination,
+	withCount bool,
+) ([]*model.Deployment, int64, error) {
+
+	database := db.client.Database(mstore.DbFromContext(ctx, DatabaseName))
+	collDpl := database.Collection(CollectionDeployments)
+
+	deployments, query, err := db.findDeployments(ctx, filter, page)
 	if err != nil {
 		return nil, 0, err
 	}
-	if err := cursor.All(ctx, &deployments); err != nil {
-		return nil, 0, err
-	}
 	// Count documents if we didn't find all already.
 	count := int64(0)
-	if !match.DisableCount {
+	if withCount {
 		count = int64(len(deployments))
-		if count >= int64(match.Limit) {
-			count, err = collDpl.CountDocuments(ctx, query)
+		if count >= int64(page.Limit) || page.IsCursor() {
+			countOptions := mopts.Count()
+			if hint := deploymentsHint(filter); hint != nil {
+				countOptions.SetHint(hint)
+			}
+			count, err = collDpl.CountDocuments(ctx, query, countOptions)
 			if err != nil {
 				return nil, 0, err
 			}
 		} else {
 			// Don't forget to add the skipped documents
-			count += int64(match.Skip)
+			count += int64(page.Skip)
 		}
 	}
 
 	return deployments, count, nil
 }
 
-func (db *DataStoreMongo) findOptions(match model.Query) *mopts.FindOptions {
+// FindWithCursor returns a page of the deployments matching the query and
+// the cursor to the following page: the ID to pass as AfterID (BeforeID if
+// the query paginates backwards) to get the next page. The cursor is empty
+// if the page is the last one.
+func (db *DataStoreMongo) FindWithCursor(ctx context.Context,
+	match model.Query) ([]*model.Deployment, string, error) {
+
+	page := match.Pagination()
+	deployments, _, err := db.findDeployments(ctx, match.Filter(), page)
+	if err != nil {
+		return nil, "", err
+	}
+	var nextCursor string
+	if page.Limit > 0 && len(deployments) == page.Limit {
+		if page.BeforeID != "" {
+			nextCursor = deployments[0].Id
+		} else {
+			nextCursor = deployments[len(deployments)-1].Id
+		}
+	}
+	return deployments, nextCursor, nil
+}
+
+// findDeployments returns the page of the deployments matching the filter
+// along with the query matching all the pages.
+func (db *DataStoreMongo) findDeployments(ctx context.Context,
+	filter model.DeploymentFilter,
+	page model.Pagination,
+) ([]*model.Deployment, bson.M, error) {
+
+	database := db.client.Database(mstore.DbFromContext(ctx, DatabaseName))
+	collDpl := database.Collection(CollectionDeployments)
+
+	query, err := db.deploymentsFilter(ctx, filter)
+	if err != nil {
+		return nil, nil, err
+	}
+	pageQuery := query
+	if page.IsCursor() {
+		cursorFilter, err := deploymentsCursorFilter(ctx, collDpl, page)
+		if err != nil {
+			return nil, nil, err
+		}
+		pageQuery = bson.M{"$and": []bson.M{query, cursorFilter}}
+	}
+
+	options := db.findOptions(filter, page)
+
+	var deployments []*model.Deployment
+	cursor, err := collDpl.Find(ctx, pageQuery, options)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := cursor.All(ctx, &deployments); err != nil {
+		return nil, nil, err
+	}
+	if page.BeforeID != "" {
+		// the page was fetched in reverse order
+		for i, j := 0, len(deployments)-1; i < j; i, j = i+1, j-1 {
+			deployments[i], deployments[j] = deployments[j], deployments[i]
+		}
+	}
+	return deployments, query, nil
+}
+
+// deploymentsCursorFilter matches the deployments sorted after the
+// page's AfterID cursor, or before its BeforeID cursor. The deployments
+// are sorted by creation date, then by ID to break ties.
+func deploymentsCursorFilter(ctx context.Context,
+	collDpl *mongo.Collection, match model.Pagination) (bson.M, error) {
+
+	id := match.AfterID
+	if id == "" {
+		id = match.BeforeID
+	}
+	var cursor struct {
+		Created time.Time `bson:"created"`
+	}
+	err := collDpl.FindOne(ctx, bson.M{StorageKeyId: id},
+		mopts.FindOne().SetProjection(bson.M{StorageKeyDeploymentCreated: 1}),
+	).Decode(&cursor)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrDeploymentStorageInvalidCursor
+	} else if err != nil {
+		return nil, errors.Wrap(err, "failed to get the cursor deployment")
+	}
+
+	op := "$lt"
+	if (match.Sort == model.SortDirectionAscending) != (match.BeforeID != "") {
+		op = "$gt"
+	}
+	return bson.M{"$or": []bson.M{
+		{StorageKeyDeploymentCreated: bson.M{op: cursor.Created}},
+		{
+			StorageKeyDeploymentCreated: cursor.Created,
+			StorageKeyId:                bson.M{op: id},
+		},
+	}}, nil
+}
+
+// countDeploymentsBy counts the deployments matching the filter grouped by
+// the value of the given key.
+func (db *DataStoreMongo) countDeploymentsBy(ctx context.Context,
+	match model.DeploymentFilter, key string) (map[string]int, error) {
+
+	database := db.client.Database(mstore.DbFromContext(ctx, DatabaseName))
+	collDpl := database.Collection(CollectionDeployments)
+
+	filter, err := db.deploymentsFilter(ctx, match)
+	if err != nil {
+		return nil, err
+	}
+	pipeline := []bson.D{
+		{{Key: "$match", Value: filter}},
+		{{Key: "$group", Value: bson.D{
+			{Key: "_id", Value: "$" + key},
+			{Key: "count", Value: bson.D{{Key: "$sum", Value: 1}}},
+		}}},
+	}
+	cursor, err := collDpl.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	var results []struct {
+		Value string `bson:"_id"`
+		Count int    `bson:"count"`
+	}
+	if err := cursor.All(ctx, &results); err != nil {
+		return nil, err
+	}
+	counts := make(map[string]int, len(results))
+	for _, result := range results {
+		counts[result.Value] += result.Count
+	}
+	return counts, nil
+}
+
+// CountDeploymentsByType returns the number of deployments matching the query
+// for each deployment type.
+func (db *DataStoreMongo) CountDeploymentsByType(ctx context.Context,
+	match model.Query) (model.DeploymentCountByType, error) {
+
+	counts, err := db.countDeploymentsBy(ctx, match.Filter(), StorageKeyDeploymentType)
+	if err != nil {
+		return nil, err
+	}
+	result := make(model.DeploymentCountByType, len(counts))
+	for typ, count := range counts {
+		// deployments without type are software deployments
+		if typ == "" {
+			typ = string(model.DeploymentTypeSoftware)
+		}
+		result[model.DeploymentType(typ)] += count
+	}
+	return result, nil
+}
+
+// CountDeploymentsByStatus returns the number of deployments matching the
+// query for each deployment status.
+func (db *DataStoreMongo) CountDeploymentsByStatus(ctx context.Context,
+	match model.Query) (model.DeploymentCountByStatus, error) {
+
+	counts, err := db.countDeploymentsBy(
+		ctx, match.Filter(), StorageKeyDeploymentStatus,
+	)
+	if err != nil {
+		return nil, err
+	}
+	result := make(model.DeploymentCountByStatus, len(counts))
+	for status, count := range counts {
+		result[model.DeploymentStatus(status)] += count
+	}
+	return result, nil
+}
+
+// deploymentSortKeys maps the deployment sort fields to the storage keys.
+var deploymentSortKeys = map[model.SortField]string{
+	model.SortFieldName:         StorageKeyDeploymentName,
+	model.SortFieldArtifactName: StorageKeyDeploymentArtifactName,
+	model.SortFieldStatus:       StorageKeyDeploymentStatus,
+	model.SortFieldCreated:      StorageKeyDeploymentCreated,
+	model.SortFieldFinished:     StorageKeyDeploymentFinished,
+	model.SortFieldDeviceCount:  StorageKeyDeploymentDeviceCount,
+}
+
+// deploymentsSort builds the sort document for the page; unknown fields
+// are skipped.
+func deploymentsSort(match model.Pagination) bson.D {
+	sortFields := match.GetSortFields()
+	sortDoc := make(bson.D, 0, len(sortFields))
+	for _, field := range sortFields {
+		key, ok := deploymentSortKeys[field.Field]
+		if !ok {
+			continue
+		}
+		order := -1
+		if field.Direction == model.SortOrderAscending {
+			order = 1
+		}
+		sortDoc = append(sortDoc, bson.E{Key: key, Value: order})
+	}
+	// break the ties by ID so that the order is stable across pages
+	order := -1
+	if len(sortDoc) > 0 {
+		order = sortDoc[len(sortDoc)-1].Value.(int)
+	}
+	sortDoc = append(sortDoc, bson.E{Key: StorageKeyId, Value: order})
+	if match.BeforeID != "" {
+		// paginating backwards: fetch the page in reverse order
+		for i := range sortDoc {
+			sortDoc[i].Value = -sortDoc[i].Value.(int)
+		}
+	}
+	return sortDoc
+}
+
+func (db *DataStoreMongo) findOptions(
+	filter model.DeploymentFilter,
+	page model.Pagination,
+) *mopts.FindOptions {
 	options := &mopts.FindOptions{}
-	if match.Sort == model.SortDirectionAscending {
-		options.SetSort(bson.D{{Key: "created", Value: 1}})
-	} else {
-		options.SetSort(bson.D{{Key: "created", Value: -1}})
+	options.SetSort(deploymentsSort(page))
+	if page.Skip > 0 {
+		options.SetSkip(int64(page.Skip))
 	}
-	if match.Skip > 0 {
-		options.SetSkip(int64(match.Skip))
+	if page.Limit > 0 {
+		options.SetLimit(int64(page.Limit))
 	}
-	if match.Limit > 0 {
-		options.SetLimit(int64(match.Limit))
+	if hint := deploymentsHint(filter); hint != nil {
+		options.SetHint(hint)
 	}
 	return options
 }
 
+// deploymentsHint returns the index to use for the filter, or nil to let
+// the query planner decide. Text search queries cannot be hinted.
+func deploymentsHint(match model.DeploymentFilter) interface{} {
+	if match.DeviceID != "" && match.SearchText == "" {
+		return IndexDeploymentDeviceListName
+	}
+	return nil
+}
+
 // FindNewerActiveDeployments finds active deployments which were created
 // after createdAfter
 func (db *DataStoreMongo) FindNewerActiveDeployments(ctx context.Context,
@@ -2752,31 +3082,59 @@ func (db *DataStoreMongo) SetDeploymentStatus(
 	database := db.client.Database(mstore.DbFromContext(ctx, DatabaseName))
 	collDpl := database.Collection(CollectionDeployments)
 
-	var update bson.M
+	res, err := collDpl.UpdateOne(ctx, bson.M{"_id": id},
+		deploymentStatusUpdate(status, now))
+
+	if res != nil && res.MatchedCount == 0 {
+		return ErrStorageInvalidID
+	}
+
+	return err
+}
+
+// SetDeploymentsStatus sets the status of all the deployments with the
+// given IDs in a single update, see SetDeploymentStatus.
+func (db *DataStoreMongo) SetDeploymentsStatus(
+	ctx context.Context,
+	ids []string,
+	status model.DeploymentStatus,
+	now time.Time,
+) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	for _, id := range ids {
+		if len(id) == 0 {
+			return ErrStorageInvalidID
+		}
+	}
+
+	database := db.client.Database(mstore.DbFromContext(ctx, DatabaseName))
+	collDpl := database.Collection(CollectionDeployments)
+
+	_, err := collDpl.UpdateMany(ctx, bson.M{"_id": bson.M{"$in": ids}},
+		deploymentStatusUpdate(status, now))
+	return err
+}
+
+// deploymentStatusUpdate returns the update setting the deployment status,
+// and the finished time if the deployment is finished.
+func deploymentStatusUpdate(status model.DeploymentStatus, now time.Time) bson.M {
 	if status == model.DeploymentStatusFinished {
-		update = bson.M{
+		return bson.M{
 			"$set": bson.M{
 				StorageKeyDeploymentActive:   false,
 				StorageKeyDeploymentStatus:   status,
 				StorageKeyDeploymentFinished: &now,
 			},
 		}
-	} else {
-		update = bson.M{
-			"$set": bson.M{
-				StorageKeyDeploymentActive: true,
-				StorageKeyDeploymentStatus: status,
-			},
-		}
 	}
-
-	res, err := collDpl.UpdateOne(ctx, bson.M{"_id": id}, update)
-
-	if res != nil && res.MatchedCount == 0 {
-		return ErrStorageInvalidID
+	return bson.M{
+		"$set": bson.M{
+			StorageKeyDeploymentActive: true,
+			StorageKeyDeploymentStatus: status,
+		},
 	}
-
-	return err
 }
 
 // ExistUnfinishedByArtifactId checks if there is an active deployment that uses
@@ -21,6 +21,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/google/uuid"
 	"github.com/mendersoftware/go-lib-micro/identity"
 	ctxstore "github.com/mendersoftware/go-lib-micro/store"
 	mstore "github.com/mendersoftware/go-lib-micro/store"
@@ -282,17 +283,17 @@ func TestDeploymentStorageFindByID(t *testing.T) {
 						Devices:      []string{"b532b01a-9313-404f-8d19-e7fcbe5cc347"},
 					},
 					Id: "a108ae14-bb4e-455f-9b40-2ef4bab97bb7",
-					Stats: model.Stats{
-						model.DeviceDeploymentStatusDownloadingStr: 0,
-						model.DeviceDeploymentStatusInstallingStr:  0,
-						model.DeviceDeploymentStatusRebootingStr:   0,
-						model.DeviceDeploymentStatusPendingStr:     10,
-						model.DeviceDeploymentStatusSuccessStr:     15,
-						model.DeviceDeploymentStatusFailureStr:     1,
-						model.DeviceDeploymentStatusNoArtifactStr:  0,
-						model.DeviceDeploymentStatusAlreadyInstStr: 0,
-						model.DeviceDeploymentStatusAbortedStr:     0,
-					},
+					Stats: model.NewStats(map[model.DeviceDeploymentStatus]int{
+						model.DeviceDeploymentStatusDownloading: 0,
+						model.DeviceDeploymentStatusInstalling:  0,
+						model.DeviceDeploymentStatusRebooting:   0,
+						model.DeviceDeploymentStatusPending:     10,
+						model.DeviceDeploymentStatusSuccess:     15,
+						model.DeviceDeploymentStatusFailure:     1,
+						model.DeviceDeploymentStatusNoArtifact:  0,
+						model.DeviceDeploymentStatusAlreadyInst: 0,
+						model.DeviceDeploymentStatusAborted:     0,
+					}),
 				},
 				&model.Deployment{
 					DeploymentConstructor: &model.DeploymentConstructor{
@@ -301,17 +302,17 @@ func TestDeploymentStorageFindByID(t *testing.T) {
 						Devices:      []string{"b532b01a-9313-404f-8d19-e7fcbe5cc347"},
 					},
 					Id: "d1804903-5caa-4a73-a3ae-0efcc3205405",
-					Stats: model.Stats{
-						model.DeviceDeploymentStatusDownloadingStr: 0,
-						model.DeviceDeploymentStatusInstallingStr:  0,
-						model.DeviceDeploymentStatusRebootingStr:   0,
-						model.DeviceDeploymentStatusPendingStr:     5,
-						model.DeviceDeploymentStatusSuccessStr:     10,
-						model.DeviceDeploymentStatusFailureStr:     3,
-						model.DeviceDeploymentStatusNoArtifactStr:  0,
-						model.DeviceDeploymentStatusAlreadyInstStr: 0,
-						model.DeviceDeploymentStatusAbortedStr:     0,
-					},
+					Stats: model.NewStats(map[model.DeviceDeploymentStatus]int{
+						model.DeviceDeploymentStatusDownloading: 0,
+						model.DeviceDeploymentStatusInstalling:  0,
+						model.DeviceDeploymentStatusRebooting:   0,
+						model.DeviceDeploymentStatusPending:     5,
+						model.DeviceDeploymentStatusSuccess:     10,
+						model.DeviceDeploymentStatusFailure:     3,
+						model.DeviceDeploymentStatusNoArtifact:  0,
+						model.DeviceDeploymentStatusAlreadyInst: 0,
+						model.DeviceDeploymentStatusAborted:     0,
+					}),
 				},
 			},
 			OutputError: nil,
@@ -323,17 +324,17 @@ func TestDeploymentStorageFindByID(t *testing.T) {
 				},
 				Id:     "a108ae14-bb4e-455f-9b40-2ef4bab97bb7",
 				Active: true,
-				Stats: model.Stats{
-					model.DeviceDeploymentStatusDownloadingStr: 0,
-					model.DeviceDeploymentStatusInstallingStr:  0,
-					model.DeviceDeploymentStatusRebootingStr:   0,
-					model.DeviceDeploymentStatusPendingStr:     10,
-					model.DeviceDeploymentStatusSuccessStr:     15,
-					model.DeviceDeploymentStatusFailureStr:     1,
-					model.DeviceDeploymentStatusNoArtifactStr:  0,
-					model.DeviceDeploymentStatusAlreadyInstStr: 0,
-					model.DeviceDeploymentStatusAbortedStr:     0,
-				},
+				Stats: model.NewStats(map[model.DeviceDeploymentStatus]int{
+					model.DeviceDeploymentStatusDownloading: 0,
+					model.DeviceDeploymentStatusInstalling:  0,
+					model.DeviceDeploymentStatusRebooting:   0,
+					model.DeviceDeploymentStatusPending:     10,
+					model.DeviceDeploymentStatusSuccess:     15,
+					model.DeviceDeploymentStatusFailure:     1,
+					model.DeviceDeploymentStatusNoArtifact:  0,
+					model.DeviceDeploymentStatusAlreadyInst: 0,
+					model.DeviceDeploymentStatusAborted:     0,
+				}),
 			},
 		},
 		"ok with tenant": {
@@ -471,17 +472,17 @@ func TestDeploymentStorageFindUnfinishedByID(t *testing.T) {
 						Devices:      []string{"b532b01a-9313-404f-8d19-e7fcbe5cc347"},
 					},
 					Id: "a108ae14-bb4e-455f-9b40-2ef4bab97bb7",
-					Stats: model.Stats{
-						model.DeviceDeploymentStatusDownloadingStr: 0,
-						model.DeviceDeploymentStatusInstallingStr:  0,
-						model.DeviceDeploymentStatusRebootingStr:   0,
-						model.DeviceDeploymentStatusPendingStr:     10,
-						model.DeviceDeploymentStatusSuccessStr:     15,
-						model.DeviceDeploymentStatusFailureStr:     1,
-						model.DeviceDeploymentStatusNoArtifactStr:  0,
-						model.DeviceDeploymentStatusAlreadyInstStr: 0,
-						model.DeviceDeploymentStatusAbortedStr:     0,
-					},
+					Stats: model.NewStats(map[model.DeviceDeploymentStatus]int{
+						model.DeviceDeploymentStatusDownloading: 0,
+						model.DeviceDeploymentStatusInstalling:  0,
+						model.DeviceDeploymentStatusRebooting:   0,
+						model.DeviceDeploymentStatusPending:     10,
+						model.DeviceDeploymentStatusSuccess:     15,
+						model.DeviceDeploymentStatusFailure:     1,
+						model.DeviceDeploymentStatusNoArtifact:  0,
+						model.DeviceDeploymentStatusAlreadyInst: 0,
+						model.DeviceDeploymentStatusAborted:     0,
+					}),
 				},
 				&model.Deployment{
 					DeploymentConstructor: &model.DeploymentConstructor{
@@ -490,17 +491,17 @@ func TestDeploymentStorageFindUnfinishedByID(t *testing.T) {
 						Devices:      []string{"b532b01a-9313-404f-8d19-e7fcbe5cc347"},
 					},
 					Id: "d1804903-5caa-4a73-a3ae-0efcc3205405",
-					Stats: model.Stats{
-						model.DeviceDeploymentStatusDownloadingStr: 0,
-						model.DeviceDeploymentStatusInstallingStr:  0,
-						model.DeviceDeploymentStatusRebootingStr:   0,
-						model.DeviceDeploymentStatusPendingStr:     5,
-						model.DeviceDeploymentStatusSuccessStr:     10,
-						model.DeviceDeploymentStatusFailureStr:     3,
-						model.DeviceDeploymentStatusNoArtifactStr:  0,
-						model.DeviceDeploymentStatusAlreadyInstStr: 0,
-						model.DeviceDeploymentStatusAbortedStr:     0,
-					},
+					Stats: model.NewStats(map[model.DeviceDeploymentStatus]int{
+						model.DeviceDeploymentStatusDownloading: 0,
+						model.DeviceDeploymentStatusInstalling:  0,
+						model.DeviceDeploymentStatusRebooting:   0,
+						model.DeviceDeploymentStatusPending:     5,
+						model.DeviceDeploymentStatusSuccess:     10,
+						model.DeviceDeploymentStatusFailure:     3,
+						model.DeviceDeploymentStatusNoArtifact:  0,
+						model.DeviceDeploymentStatusAlreadyInst: 0,
+						model.DeviceDeploymentStatusAborted:     0,
+					}),
 				},
 			},
 			OutputError: nil,
@@ -512,17 +513,17 @@ func TestDeploymentStorageFindUnfinishedByID(t *testing.T) {
 				},
 				Id:     "a108ae14-bb4e-455f-9b40-2ef4bab97bb7",
 				Active: true,
-				Stats: model.Stats{
-					model.DeviceDeploymentStatusDownloadingStr: 0,
-					model.DeviceDeploymentStatusInstallingStr:  0,
-					model.DeviceDeploymentStatusRebootingStr:   0,
-					model.DeviceDeploymentStatusPendingStr:     10,
-					model.DeviceDeploymentStatusSuccessStr:     15,
-					model.DeviceDeploymentStatusFailureStr:     1,
-					model.DeviceDeploymentStatusNoArtifactStr:  0,
-					model.DeviceDeploymentStatusAlreadyInstStr: 0,
-					model.DeviceDeploymentStatusAbortedStr:     0,
-				},
+				Stats: model.NewStats(map[model.DeviceDeploymentStatus]int{
+					model.DeviceDeploymentStatusDownloading: 0,
+					model.DeviceDeploymentStatusInstalling:  0,
+					model.DeviceDeploymentStatusRebooting:   0,
+					model.DeviceDeploymentStatusPending:     10,
+					model.DeviceDeploymentStatusSuccess:     15,
+					model.DeviceDeploymentStatusFailure:     1,
+					model.DeviceDeploymentStatusNoArtifact:  0,
+					model.DeviceDeploymentStatusAlreadyInst: 0,
+					model.DeviceDeploymentStatusAborted:     0,
+				}),
 			},
 		},
 		"deployment already finished": {
@@ -559,11 +560,11 @@ func TestDeploymentStorageFindUnfinishedByID(t *testing.T) {
 						Devices:      []string{"b532b01a-9313-404f-8d19-e7fcbe5cc347"},
 					},
 					Id: "a108ae14-bb4e-455f-9b40-2ef4bab97bb7",
-					Stats: model.Stats{
-						model.DeviceDeploymentStatusPendingStr: 10,
-						model.DeviceDeploymentStatusSuccessStr: 15,
-						model.DeviceDeploymentStatusFailureStr: 1,
-					},
+					Stats: model.NewStats(map[model.DeviceDeploymentStatus]int{
+						model.DeviceDeploymentStatusPending: 10,
+						model.DeviceDeploymentStatusSuccess: 15,
+						model.DeviceDeploymentStatusFailure: 1,
+					}),
 				},
 			},
 			InputTenant: "acme",
@@ -576,11 +577,11 @@ func TestDeploymentStorageFindUnfinishedByID(t *testing.T) {
 				},
 				Id:     "a108ae14-bb4e-455f-9b40-2ef4bab97bb7",
 				Active: true,
-				Stats: model.Stats{
-					model.DeviceDeploymentStatusPendingStr: 10,
-					model.DeviceDeploymentStatusSuccessStr: 15,
-					model.DeviceDeploymentStatusFailureStr: 1,
-				},
+				Stats: model.NewStats(map[model.DeviceDeploymentStatus]int{
+					model.DeviceDeploymentStatusPending: 10,
+					model.DeviceDeploymentStatusSuccess: 15,
+					model.DeviceDeploymentStatusFailure: 1,
+				}),
 			},
 		},
 	}
@@ -656,65 +657,65 @@ func TestDeploymentStorageUpdateStatsInc(t *testing.T) {
 			InputID: "a108ae14-bb4e-455f-9b40-2ef4bab97bb7",
 			InputDeployment: &model.Deployment{
 				Id: "a108ae14-bb4e-455f-9b40-2ef4bab97bb7",
-				Stats: model.Stats{
-					model.DeviceDeploymentStatusDownloadingStr: 1,
-					model.DeviceDeploymentStatusInstallingStr:  2,
-					model.DeviceDeploymentStatusRebootingStr:   3,
-					model.DeviceDeploymentStatusPendingStr:     10,
-					model.DeviceDeploymentStatusSuccessStr:     15,
-					model.DeviceDeploymentStatusFailureStr:     4,
-					model.DeviceDeploymentStatusNoArtifactStr:  5,
-					model.DeviceDeploymentStatusAlreadyInstStr: 0,
-					model.DeviceDeploymentStatusAbortedStr:     0,
-				},
+				Stats: model.NewStats(map[model.DeviceDeploymentStatus]int{
+					model.DeviceDeploymentStatusDownloading: 1,
+					model.DeviceDeploymentStatusInstalling:  2,
+					model.DeviceDeploymentStatusRebooting:   3,
+					model.DeviceDeploymentStatusPending:     10,
+					model.DeviceDeploymentStatusSuccess:     15,
+					model.DeviceDeploymentStatusFailure:     4,
+					model.DeviceDeploymentStatusNoArtifact:  5,
+					model.DeviceDeploymentStatusAlreadyInst: 0,
+					model.DeviceDeploymentStatusAborted:     0,
+				}),
 			},
 			InputStateFrom: model.DeviceDeploymentStatusPending,
 			InputStateTo:   model.DeviceDeploymentStatusSuccess,
 
 			OutputError: nil,
-			OutputStats: model.Stats{
-				model.DeviceDeploymentStatusDownloadingStr: 1,
-				model.DeviceDeploymentStatusInstallingStr:  2,
-				model.DeviceDeploymentStatusRebootingStr:   3,
-				model.DeviceDeploymentStatusPendingStr:     9,
-				model.DeviceDeploymentStatusSuccessStr:     16,
-				model.DeviceDeploymentStatusFailureStr:     4,
-				model.DeviceDeploymentStatusNoArtifactStr:  5,
-				model.DeviceDeploymentStatusAlreadyInstStr: 0,
-				model.DeviceDeploymentStatusAbortedStr:     0,
-			},
+			OutputStats: model.NewStats(map[model.DeviceDeploymentStatus]int{
+				model.DeviceDeploymentStatusDownloading: 1,
+				model.DeviceDeploymentStatusInstalling:  2,
+				model.DeviceDeploymentStatusRebooting:   3,
+				model.DeviceDeploymentStatusPending:     9,
+				model.DeviceDeploymentStatusSuccess:     16,
+				model.DeviceDeploymentStatusFailure:     4,
+				model.DeviceDeploymentStatusNoArtifact:  5,
+				model.DeviceDeploymentStatusAlreadyInst: 0,
+				model.DeviceDeploymentStatusAborted:     0,
+			}),
 		},
 		"rebooting -> failed": {
 			InputID: "a108ae14-bb4e-455f-9b40-2ef4bab97bb7",
 			InputDeployment: &model.Deployment{
 				Id: "a108ae14-bb4e-455f-9b40-2ef4bab97bb7",
-				Stats: model.Stats{
-					model.DeviceDeploymentStatusDownloadingStr: 1,
-					model.DeviceDeploymentStatusInstallingStr:  2,
-					model.DeviceDeploymentStatusRebootingStr:   3,
-					model.DeviceDeploymentStatusPendingStr:     10,
-					model.DeviceDeploymentStatusSuccessStr:     15,
-					model.DeviceDeploymentStatusFailureStr:     4,
-					model.DeviceDeploymentStatusNoArtifactStr:  5,
-					model.DeviceDeploymentStatusAlreadyInstStr: 0,
-					model.DeviceDeploymentStatusAbortedStr:     0,
-				},
+				Stats: model.NewStats(map[model.DeviceDeploymentStatus]int{
+					model.DeviceDeploymentStatusDownloading: 1,
+					model.DeviceDeploymentStatusInstalling:  2,
+					model.DeviceDeploymentStatusRebooting:   3,
+					model.DeviceDeploymentStatusPending:     10,
+					model.DeviceDeploymentStatusSuccess:     15,
+					model.DeviceDeploymentStatusFailure:     4,
+					model.DeviceDeploymentStatusNoArtifact:  5,
+					model.DeviceDeploymentStatusAlreadyInst: 0,
+					model.DeviceDeploymentStatusAborted:     0,
+				}),
 			},
 			InputStateFrom: model.DeviceDeploymentStatusRebooting,
 			InputStateTo:   model.DeviceDeploymentStatusFailure,
 
 			OutputError: nil,
-			OutputStats: model.Stats{
-				model.DeviceDeploymentStatusDownloadingStr: 1,
-				model.DeviceDeploymentStatusInstallingStr:  2,
-				model.DeviceDeploymentStatusRebootingStr:   2,
-				model.DeviceDeploymentStatusPendingStr:     10,
-				model.DeviceDeploymentStatusSuccessStr:     15,
-				model.DeviceDeploymentStatusFailureStr:     5,
-				model.DeviceDeploymentStatusNoArtifactStr:  5,
-				model.DeviceDeploymentStatusAlreadyInstStr: 0,
-				model.DeviceDeploymentStatusAbortedStr:     0,
-			},
+			OutputStats: model.NewStats(map[model.DeviceDeploymentStatus]int{
+				model.DeviceDeploymentStatusDownloading: 1,
+				model.DeviceDeploymentStatusInstalling:  2,
+				model.DeviceDeploymentStatusRebooting:   2,
+				model.DeviceDeploymentStatusPending:     10,
+				model.DeviceDeploymentStatusSuccess:     15,
+				model.DeviceDeploymentStatusFailure:     5,
+				model.DeviceDeploymentStatusNoArtifact:  5,
+				model.DeviceDeploymentStatusAlreadyInst: 0,
+				model.DeviceDeploymentStatusAborted:     0,
+			}),
 		},
 		"invalid deployment id": {
 			InputID:         "",
@@ -723,7 +724,7 @@ func TestDeploymentStorageUpdateStatsInc(t *testing.T) {
 			InputStateTo:    model.DeviceDeploymentStatusFailure,
 
 			OutputError: ErrStorageInvalidID,
-			OutputStats: nil,
+			OutputStats: model.Stats{},
 		},
 		"wrong deployment id": {
 			InputID:         "a108ae14-bb4e-455f-9b40-2ef4bab97bb7",
@@ -732,86 +733,86 @@ func TestDeploymentStorageUpdateStatsInc(t *testing.T) {
 			InputStateTo:    model.DeviceDeploymentStatusFailure,
 
 			OutputError: ErrStorageInvalidID,
-			OutputStats: nil,
+			OutputStats: model.Stats{},
 		},
 		"no old state": {
 			InputID: "a108ae14-bb4e-455f-9b40-2ef4bab97bb7",
 			InputDeployment: &model.Deployment{
 				Id: "a108ae14-bb4e-455f-9b40-2ef4bab97bb7",
-				Stats: model.Stats{
-					model.DeviceDeploymentStatusDownloadingStr: 1,
-					model.DeviceDeploymentStatusInstallingStr:  2,
-					model.DeviceDeploymentStatusRebootingStr:   3,
-					model.DeviceDeploymentStatusPendingStr:     10,
-					model.DeviceDeploymentStatusSuccessStr:     15,
-					model.DeviceDeploymentStatusFailureStr:     4,
-					model.DeviceDeploymentStatusNoArtifactStr:  5,
-					model.DeviceDeploymentStatusAlreadyInstStr: 0,
-					model.DeviceDeploymentStatusAbortedStr:     0,
-				},
+				Stats: model.NewStats(map[model.DeviceDeploymentStatus]int{
+					model.DeviceDeploymentStatusDownloading: 1,
+					model.DeviceDeploymentStatusInstalling:  2,
+					model.DeviceDeploymentStatusRebooting:   3,
+					model.DeviceDeploymentStatusPending:     10,
+					model.DeviceDeploymentStatusSuccess:     15,
+					model.DeviceDeploymentStatusFailure:     4,
+					model.DeviceDeploymentStatusNoArtifact:  5,
+					model.DeviceDeploymentStatusAlreadyInst: 0,
+					model.DeviceDeploymentStatusAborted:     0,
+				}),
 			},
 			InputStateFrom: model.DeviceDeploymentStatusNull,
 			InputStateTo:   model.DeviceDeploymentStatusPending,
 
-			OutputStats: model.Stats{
-				model.DeviceDeploymentStatusDownloadingStr: 1,
-				model.DeviceDeploymentStatusInstallingStr:  2,
-				model.DeviceDeploymentStatusRebootingStr:   3,
-				model.DeviceDeploymentStatusPendingStr:     11,
-				model.DeviceDeploymentStatusSuccessStr:     15,
-				model.DeviceDeploymentStatusFailureStr:     4,
-				model.DeviceDeploymentStatusNoArtifactStr:  5,
-				model.DeviceDeploymentStatusAlreadyInstStr: 0,
-				model.DeviceDeploymentStatusAbortedStr:     0,
-			},
+			OutputStats: model.NewStats(map[model.DeviceDeploymentStatus]int{
+				model.DeviceDeploymentStatusDownloading: 1,
+				model.DeviceDeploymentStatusInstalling:  2,
+				model.DeviceDeploymentStatusRebooting:   3,
+				model.DeviceDeploymentStatusPending:     11,
+				model.DeviceDeploymentStatusSuccess:     15,
+				model.DeviceDeploymentStatusFailure:     4,
+				model.DeviceDeploymentStatusNoArtifact:  5,
+				model.DeviceDeploymentStatusAlreadyInst: 0,
+				model.DeviceDeploymentStatusAborted:     0,
+			}),
 		},
 		"install install": {
 			InputID: "a108ae14-bb4e-455f-9b40-2ef4bab97bb7",
 			InputDeployment: &model.Deployment{
 				Id: "a108ae14-bb4e-455f-9b40-2ef4bab97bb7",
-				Stats: model.Stats{
-					model.DeviceDeploymentStatusDownloadingStr: 1,
-					model.DeviceDeploymentStatusInstallingStr:  2,
-					model.DeviceDeploymentStatusRebootingStr:   3,
-					model.DeviceDeploymentStatusPendingStr:     10,
-					model.DeviceDeploymentStatusSuccessStr:     15,
-					model.DeviceDeploymentStatusFailureStr:     4,
-					model.DeviceDeploymentStatusNoArtifactStr:  5,
-					model.DeviceDeploymentStatusAlreadyInstStr: 0,
-					model.DeviceDeploymentStatusAbortedStr:     0,
-				},
+				Stats: model.NewStats(map[model.DeviceDeploymentStatus]int{
+					model.DeviceDeploymentStatusDownloading: 1,
+					model.DeviceDeploymentStatusInstalling:  2,
+					model.DeviceDeploymentStatusRebooting:   3,
+					model.DeviceDeploymentStatusPending:     10,
+					model.DeviceDeploymentStatusSuccess:     15,
+					model.DeviceDeploymentStatusFailure:     4,
+					model.DeviceDeploymentStatusNoArtifact:  5,
+					model.DeviceDeploymentStatusAlreadyInst: 0,
+					model.DeviceDeploymentStatusAborted:     0,
+				}),
 			},
 			InputStateFrom: model.DeviceDeploymentStatusInstalling,
 			InputStateTo:   model.DeviceDeploymentStatusInstalling,
 
 			OutputError: nil,
-			OutputStats: model.Stats{
-				model.DeviceDeploymentStatusDownloadingStr: 1,
-				model.DeviceDeploymentStatusInstallingStr:  2,
-				model.DeviceDeploymentStatusRebootingStr:   3,
-				model.DeviceDeploymentStatusPendingStr:     10,
-				model.DeviceDeploymentStatusSuccessStr:     15,
-				model.DeviceDeploymentStatusFailureStr:     4,
-				model.DeviceDeploymentStatusNoArtifactStr:  5,
-				model.DeviceDeploymentStatusAlreadyInstStr: 0,
-				model.DeviceDeploymentStatusAbortedStr:     0,
-			},
+			OutputStats: model.NewStats(map[model.DeviceDeploymentStatus]int{
+				model.DeviceDeploymentStatusDownloading: 1,
+				model.DeviceDeploymentStatusInstalling:  2,
+				model.DeviceDeploymentStatusRebooting:   3,
+				model.DeviceDeploymentStatusPending:     10,
+				model.DeviceDeploymentStatusSuccess:     15,
+				model.DeviceDeploymentStatusFailure:     4,
+				model.DeviceDeploymentStatusNoArtifact:  5,
+				model.DeviceDeploymentStatusAlreadyInst: 0,
+				model.DeviceDeploymentStatusAborted:     0,
+			}),
 		},
 		"tenant, pending -> finished": {
 			InputID: "a108ae14-bb4e-455f-9b40-2ef4bab97bb7",
 			InputDeployment: &model.Deployment{
 				Id: "a108ae14-bb4e-455f-9b40-2ef4bab97bb7",
-				Stats: model.Stats{
-					model.DeviceDeploymentStatusDownloadingStr: 1,
-					model.DeviceDeploymentStatusInstallingStr:  2,
-					model.DeviceDeploymentStatusRebootingStr:   3,
-					model.DeviceDeploymentStatusPendingStr:     10,
-					model.DeviceDeploymentStatusSuccessStr:     15,
-					model.DeviceDeploymentStatusFailureStr:     4,
-					model.DeviceDeploymentStatusNoArtifactStr:  5,
-					model.DeviceDeploymentStatusAlreadyInstStr: 0,
-					model.DeviceDeploymentStatusAbortedStr:     0,
-				},
+				Stats: model.NewStats(map[model.DeviceDeploymentStatus]int{
+					model.DeviceDeploymentStatusDownloading: 1,
+					model.DeviceDeploymentStatusInstalling:  2,
+					model.DeviceDeploymentStatusRebooting:   3,
+					model.DeviceDeploymentStatusPending:     10,
+					model.DeviceDeploymentStatusSuccess:     15,
+					model.DeviceDeploymentStatusFailure:     4,
+					model.DeviceDeploymentStatusNoArtifact:  5,
+					model.DeviceDeploymentStatusAlreadyInst: 0,
+					model.DeviceDeploymentStatusAborted:     0,
+				}),
 			},
 			InputTenant: "acme",
 
@@ -819,17 +820,17 @@ func TestDeploymentStorageUpdateStatsInc(t *testing.T) {
 			InputStateTo:   model.DeviceDeploymentStatusSuccess,
 
 			OutputError: nil,
-			OutputStats: model.Stats{
-				model.DeviceDeploymentStatusDownloadingStr: 1,
-				model.DeviceDeploymentStatusInstallingStr:  2,
-				model.DeviceDeploymentStatusRebootingStr:   3,
-				model.DeviceDeploymentStatusPendingStr:     9,
-				model.DeviceDeploymentStatusSuccessStr:     16,
-				model.DeviceDeploymentStatusFailureStr:     4,
-				model.DeviceDeploymentStatusNoArtifactStr:  5,
-				model.DeviceDeploymentStatusAlreadyInstStr: 0,
-				model.DeviceDeploymentStatusAbortedStr:     0,
-			},
+			OutputStats: model.NewStats(map[model.DeviceDeploymentStatus]int{
+				model.DeviceDeploymentStatusDownloading: 1,
+				model.DeviceDeploymentStatusInstalling:  2,
+				model.DeviceDeploymentStatusRebooting:   3,
+				model.DeviceDeploymentStatusPending:     9,
+				model.DeviceDeploymentStatusSuccess:     16,
+				model.DeviceDeploymentStatusFailure:     4,
+				model.DeviceDeploymentStatusNoArtifact:  5,
+				model.DeviceDeploymentStatusAlreadyInst: 0,
+				model.DeviceDeploymentStatusAborted:     0,
+			}),
 		},
 	}
 
@@ -907,6 +908,77 @@ func TestDeploymentStorageUpdateStatsInc(t *testing.T) {
 	}
 }
 
+func TestDeploymentStorageUpdatedAt(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping TestDeploymentStorageUpdatedAt in short mode.")
+	}
+
+	db.Wipe()
+	ctx := context.Background()
+	store := NewDataStoreMongoWithClient(db.Client())
+
+	created := time.Now().Add(-time.Hour).UTC().Truncate(time.Millisecond)
+	ids := []string{
+		"a108ae14-bb4e-455f-9b40-2ef4bab97bb7",
+		"b108ae14-bb4e-455f-9b40-2ef4bab97bb7",
+		"c108ae14-bb4e-455f-9b40-2ef4bab97bb7",
+	}
+	for _, id := range ids {
+		err := store.InsertDeployment(ctx, &model.Deployment{
+			DeploymentConstructor: &model.DeploymentConstructor{
+				Name:         "deployment",
+				ArtifactName: "artifact",
+				Devices:      []string{"b532b01a-9313-404f-8d19-e7fcbe5cc399"},
+			},
+			Id:        id,
+			Stats:     newTestStats(model.Stats{}),
+			Created:   TimeToPointer(created),
+			UpdatedAt: TimeToPointer(created),
+		})
+		if !assert.NoError(t, err) {
+			t.FailNow()
+		}
+	}
+
+	before := time.Now().UTC().Truncate(time.Millisecond)
+	err := store.UpdateStatsInc(ctx, ids[0],
+		model.DeviceDeploymentStatusNull, model.DeviceDeploymentStatusPending)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	stats := model.NewDeviceDeploymentStats()
+	stats.Set(model.DeviceDeploymentStatusDownloading, 1)
+	err = store.UpdateStats(ctx, ids[1], stats)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	for i, id := range ids {
+		dep, err := store.FindDeploymentByID(ctx, id)
+		if !assert.NoError(t, err) || !assert.NotNil(t, dep.UpdatedAt) {
+			t.FailNow()
+		}
+		if i < 2 {
+			assert.False(t, dep.UpdatedAt.Before(before),
+				"updated_at must be set on stats changes")
+		} else {
+			assert.True(t, dep.UpdatedAt.Equal(created))
+		}
+	}
+
+	deps, _, err := store.Find(ctx, model.Query{
+		UpdatedAfter: &before,
+		Sort:         model.SortDirectionAscending,
+	})
+	if assert.NoError(t, err) && assert.Len(t, deps, 2) {
+		assert.ElementsMatch(t, ids[:2], []string{deps[0].Id, deps[1].Id})
+	}
+	deps, _, err = store.Find(ctx, model.Query{UpdatedBefore: &before})
+	if assert.NoError(t, err) && assert.Len(t, deps, 1) {
+		assert.Equal(t, ids[2], deps[0].Id)
+	}
+}
+
 func TestDeploymentStorageUpdateStats(t *testing.T) {
 	if testing.Short() {
 		t.Skip("skipping TestDeploymentStorageUpdateStats in short mode.")
@@ -924,41 +996,41 @@ func TestDeploymentStorageUpdateStats(t *testing.T) {
 			id: "a108ae14-bb4e-455f-9b40-2ef4bab97bb7",
 			dep: &model.Deployment{
 				Id: "a108ae14-bb4e-455f-9b40-2ef4bab97bb7",
-				Stats: model.Stats{
-					model.DeviceDeploymentStatusDownloadingStr: 1,
-					model.DeviceDeploymentStatusInstallingStr:  2,
-					model.DeviceDeploymentStatusRebootingStr:   3,
-					model.DeviceDeploymentStatusPendingStr:     3,
-					model.DeviceDeploymentStatusSuccessStr:     6,
-					model.DeviceDeploymentStatusFailureStr:     8,
-					model.DeviceDeploymentStatusNoArtifactStr:  4,
-					model.DeviceDeploymentStatusAlreadyInstStr: 2,
-					model.DeviceDeploymentStatusAbortedStr:     5,
-				},
-			},
-			stats: model.Stats{
-				model.DeviceDeploymentStatusDownloadingStr: 1,
-				model.DeviceDeploymentStatusInstallingStr:  2,
-				model.DeviceDeploymentStatusRebootingStr:   3,
-				model.DeviceDeploymentStatusPendingStr:     10,
-				model.DeviceDeploymentStatusSuccessStr:     15,
-				model.DeviceDeploymentStatusFailureStr:     4,
-				model.DeviceDeploymentStatusNoArtifactStr:  5,
-				model.DeviceDeploymentStatusAlreadyInstStr: 0,
-				model.DeviceDeploymentStatusAbortedStr:     5,
+				Stats: model.NewStats(map[model.DeviceDeploymentStatus]int{
+					model.DeviceDeploymentStatusDownloading: 1,
+					model.DeviceDeploymentStatusInstalling:  2,
+					model.DeviceDeploymentStatusRebooting:   3,
+					model.DeviceDeploymentStatusPending:     3,
+					model.DeviceDeploymentStatusSuccess:     6,
+					model.DeviceDeploymentStatusFailure:     8,
+					model.DeviceDeploymentStatusNoArtifact:  4,
+					model.DeviceDeploymentStatusAlreadyInst: 2,
+					model.DeviceDeploymentStatusAborted:     5,
+				}),
 			},
+			stats: model.NewStats(map[model.DeviceDeploymentStatus]int{
+				model.DeviceDeploymentStatusDownloading: 1,
+				model.DeviceDeploymentStatusInstalling:  2,
+				model.DeviceDeploymentStatusRebooting:   3,
+				model.DeviceDeploymentStatusPending:     10,
+				model.DeviceDeploymentStatusSuccess:     15,
+				model.DeviceDeploymentStatusFailure:     4,
+				model.DeviceDeploymentStatusNoArtifact:  5,
+				model.DeviceDeploymentStatusAlreadyInst: 0,
+				model.DeviceDeploymentStatusAborted:     5,
+			}),
 		},
 		"invalid deployment id": {
 			id:    "",
 			dep:   nil,
-			stats: nil,
+			stats: model.Stats{},
 
 			err: ErrStorageInvalidID,
 		},
 		"wrong deployment id": {
 			id:    "a108ae14-bb4e-455f-9b40-2ef4bab97bb7",
 			dep:   nil,
-			stats: nil,
+			stats: model.Stats{},
 
 			err: ErrStorageInvalidID,
 		},
@@ -966,13 +1038,13 @@ func TestDeploymentStorageUpdateStats(t *testing.T) {
 			id: "a108ae14-bb4e-455f-9b40-2ef4bab97bb7",
 			dep: &model.Deployment{
 				Id: "a108ae14-bb4e-455f-9b40-2ef4bab97bb7",
-				Stats: newTestStats(model.Stats{
-					model.DeviceDeploymentStatusRebootingStr: 3,
-				}),
+				Stats: newTestStats(model.NewStats(map[model.DeviceDeploymentStatus]int{
+					model.DeviceDeploymentStatusRebooting: 3,
+				})),
 			},
-			stats: newTestStats(model.Stats{
-				model.DeviceDeploymentStatusRebootingStr: 3,
-			}),
+			stats: newTestStats(model.NewStats(map[model.DeviceDeploymentStatus]int{
+				model.DeviceDeploymentStatusRebooting: 3,
+			})),
 			tenant: "acme",
 		},
 	}
@@ -1026,9 +1098,9 @@ func TestDeploymentStorageUpdateStats(t *testing.T) {
 
 func newTestStats(stats model.Stats) model.Stats {
 	st := model.NewDeviceDeploymentStats()
-	for k, v := range stats {
-		st[k] = v
-	}
+	stats.ForEachStatus(func(status model.DeviceDeploymentStatus, count int) {
+		st.Set(status, count)
+	})
 	return st
 }
 
@@ -1048,9 +1120,9 @@ func TestDeploymentStorageFindBy(t *testing.T) {
 				Devices:      []string{"b532b01a-9313-404f-8d19-e7fcbe5cc347"},
 			},
 			Id: "a108ae14-bb4e-455f-9b40-000000000001",
-			Stats: newTestStats(model.Stats{
-				model.DeviceDeploymentStatusNoArtifactStr: 1,
-			}),
+			Stats: newTestStats(model.NewStats(map[model.DeviceDeploymentStatus]int{
+				model.DeviceDeploymentStatusNoArtifact: 1,
+			})),
 			Status:   model.DeploymentStatusFinished,
 			Finished: &now,
 		},
@@ -1061,9 +1133,9 @@ func TestDeploymentStorageFindBy(t *testing.T) {
 				Devices:      []string{"b532b01a-9313-404f-8d19-e7fcbe5cc347"},
 			},
 			Id: "a108ae14-bb4e-455f-9b40-000000000002",
-			Stats: newTestStats(model.Stats{
-				model.DeviceDeploymentStatusNoArtifactStr: 1,
-			}),
+			Stats: newTestStats(model.NewStats(map[model.DeviceDeploymentStatus]int{
+				model.DeviceDeploymentStatusNoArtifact: 1,
+			})),
 			Status:   model.DeploymentStatusFinished,
 			Finished: &now,
 		},
@@ -1074,9 +1146,9 @@ func TestDeploymentStorageFindBy(t *testing.T) {
 				Devices:      []string{"b532b01a-9313-404f-8d19-e7fcbe5cc347"},
 			},
 			Id: "a108ae14-bb4e-455f-9b40-000000000003",
-			Stats: newTestStats(model.Stats{
-				model.DeviceDeploymentStatusFailureStr: 2,
-			}),
+			Stats: newTestStats(model.NewStats(map[model.DeviceDeploymentStatus]int{
+				model.DeviceDeploymentStatusFailure: 2,
+			})),
 			Status:   model.DeploymentStatusFinished,
 			Finished: &now,
 		},
@@ -1087,9 +1159,9 @@ func TestDeploymentStorageFindBy(t *testing.T) {
 				Devices:      []string{"b532b01a-9313-404f-8d19-e7fcbe5cc347"},
 			},
 			Id: "a108ae14-bb4e-455f-9b40-000000000004",
-			Stats: newTestStats(model.Stats{
-				model.DeviceDeploymentStatusNoArtifactStr: 1,
-			}),
+			Stats: newTestStats(model.NewStats(map[model.DeviceDeploymentStatus]int{
+				model.DeviceDeploymentStatusNoArtifact: 1,
+			})),
 			Status:   model.DeploymentStatusFinished,
 			Finished: &now,
 		},
@@ -1100,9 +1172,9 @@ func TestDeploymentStorageFindBy(t *testing.T) {
 				Devices:      []string{"b532b01a-9313-404f-8d19-e7fcbe5cc347"},
 			},
 			Id: "a108ae14-bb4e-455f-9b40-000000000005",
-			Stats: newTestStats(model.Stats{
-				model.DeviceDeploymentStatusDownloadingStr: 1,
-			}),
+			Stats: newTestStats(model.NewStats(map[model.DeviceDeploymentStatus]int{
+				model.DeviceDeploymentStatusDownloading: 1,
+			})),
 			Status: model.DeploymentStatusInProgress,
 		},
 		{
@@ -1112,10 +1184,10 @@ func TestDeploymentStorageFindBy(t *testing.T) {
 				Devices:      []string{"b532b01a-9313-404f-8d19-e7fcbe5cc347"},
 			},
 			Id: "a108ae14-bb4e-455f-9b40-000000000006",
-			Stats: newTestStats(model.Stats{
-				model.DeviceDeploymentStatusDownloadingStr: 1,
-				model.DeviceDeploymentStatusPendingStr:     1,
-			}),
+			Stats: newTestStats(model.NewStats(map[model.DeviceDeploymentStatus]int{
+				model.DeviceDeploymentStatusDownloading: 1,
+				model.DeviceDeploymentStatusPending:     1,
+			})),
 			Status: model.DeploymentStatusInProgress,
 		},
 		{
@@ -1125,9 +1197,9 @@ func TestDeploymentStorageFindBy(t *testing.T) {
 				Devices:      []string{"b532b01a-9313-404f-8d19-e7fcbe5cc347"},
 			},
 			Id: "a108ae14-bb4e-455f-9b40-000000000007",
-			Stats: newTestStats(model.Stats{
-				model.DeviceDeploymentStatusPendingStr: 1,
-			}),
+			Stats: newTestStats(model.NewStats(map[model.DeviceDeploymentStatus]int{
+				model.DeviceDeploymentStatusPending: 1,
+			})),
 			Status: model.DeploymentStatusPending,
 		},
 		{
@@ -1137,10 +1209,10 @@ func TestDeploymentStorageFindBy(t *testing.T) {
 				Devices:      []string{"b532b01a-9313-404f-8d19-e7fcbe5cc347"},
 			},
 			Id: "a108ae14-bb4e-455f-9b40-000000000008",
-			Stats: newTestStats(model.Stats{
-				model.DeviceDeploymentStatusNoArtifactStr: 1,
-				model.DeviceDeploymentStatusSuccessStr:    1,
-			}),
+			Stats: newTestStats(model.NewStats(map[model.DeviceDeploymentStatus]int{
+				model.DeviceDeploymentStatusNoArtifact: 1,
+				model.DeviceDeploymentStatusSuccess:    1,
+			})),
 			Status:   model.DeploymentStatusFinished,
 			Finished: &now,
 		},
@@ -1151,9 +1223,9 @@ func TestDeploymentStorageFindBy(t *testing.T) {
 				Devices:      []string{"b532b01a-9313-404f-8d19-e7fcbe5cc34a"},
 			},
 			Id: "a108ae14-bb4e-455f-9b40-000000000009",
-			Stats: newTestStats(model.Stats{
-				model.DeviceDeploymentStatusAbortedStr: 1,
-			}),
+			Stats: newTestStats(model.NewStats(map[model.DeviceDeploymentStatus]int{
+				model.DeviceDeploymentStatusAborted: 1,
+			})),
 			Status:   model.DeploymentStatusFinished,
 			Finished: &now,
 		},
@@ -1166,10 +1238,10 @@ func TestDeploymentStorageFindBy(t *testing.T) {
 				Devices:      []string{"b532b01a-9313-404f-8d19-e7fcbe5cc347"},
 			},
 			Id: "a108ae14-bb4e-455f-9b40-000000000010",
-			Stats: newTestStats(model.Stats{
-				model.DeviceDeploymentStatusPendingStr:     1,
-				model.DeviceDeploymentStatusAlreadyInstStr: 1,
-			}),
+			Stats: newTestStats(model.NewStats(map[model.DeviceDeploymentStatus]int{
+				model.DeviceDeploymentStatusPending:     1,
+				model.DeviceDeploymentStatusAlreadyInst: 1,
+			})),
 			Status: model.DeploymentStatusInProgress,
 		},
 		//in progress deployment, with only pending and success counters > 0
@@ -1180,10 +1252,10 @@ func TestDeploymentStorageFindBy(t *testing.T) {
 				Devices:      []string{"b532b01a-9313-404f-8d19-e7fcbe5cc347"},
 			},
 			Id: "a108ae14-bb4e-455f-9b40-000000000011",
-			Stats: newTestStats(model.Stats{
-				model.DeviceDeploymentStatusPendingStr: 1,
-				model.DeviceDeploymentStatusSuccessStr: 1,
-			}),
+			Stats: newTestStats(model.NewStats(map[model.DeviceDeploymentStatus]int{
+				model.DeviceDeploymentStatusPending: 1,
+				model.DeviceDeploymentStatusSuccess: 1,
+			})),
 			Status: model.DeploymentStatusInProgress,
 		},
 		//in progress deployment, with only pending and failure counters > 0
@@ -1194,10 +1266,10 @@ func TestDeploymentStorageFindBy(t *testing.T) {
 				Devices:      []string{"b532b01a-9313-404f-8d19-e7fcbe5cc347"},
 			},
 			Id: "a108ae14-bb4e-455f-9b40-000000000012",
-			Stats: newTestStats(model.Stats{
-				model.DeviceDeploymentStatusPendingStr: 1,
-				model.DeviceDeploymentStatusFailureStr: 1,
-			}),
+			Stats: newTestStats(model.NewStats(map[model.DeviceDeploymentStatus]int{
+				model.DeviceDeploymentStatusPending: 1,
+				model.DeviceDeploymentStatusFailure: 1,
+			})),
 			Status: model.DeploymentStatusInProgress,
 		},
 		//in progress deployment, with only pending and noartifact counters > 0
@@ -1208,10 +1280,10 @@ func TestDeploymentStorageFindBy(t *testing.T) {
 				Devices:      []string{"b532b01a-9313-404f-8d19-e7fcbe5cc347"},
 			},
 			Id: "a108ae14-bb4e-455f-9b40-000000000013",
-			Stats: newTestStats(model.Stats{
-				model.DeviceDeploymentStatusPendingStr:    1,
-				model.DeviceDeploymentStatusNoArtifactStr: 1,
-			}),
+			Stats: newTestStats(model.NewStats(map[model.DeviceDeploymentStatus]int{
+				model.DeviceDeploymentStatusPending:    1,
+				model.DeviceDeploymentStatusNoArtifact: 1,
+			})),
 			Status: model.DeploymentStatusInProgress,
 		},
 		//finished deployment, with only already installed counter > 0
@@ -1222,9 +1294,9 @@ func TestDeploymentStorageFindBy(t *testing.T) {
 				Devices:      []string{"b532b01a-9313-404f-8d19-e7fcbe5cc347"},
 			},
 			Id: "a108ae14-bb4e-455f-9b40-000000000014",
-			Stats: newTestStats(model.Stats{
-				model.DeviceDeploymentStatusAlreadyInstStr: 1,
-			}),
+			Stats: newTestStats(model.NewStats(map[model.DeviceDeploymentStatus]int{
+				model.DeviceDeploymentStatusAlreadyInst: 1,
+			})),
 			Status:   model.DeploymentStatusFinished,
 			Finished: &now,
 		},
@@ -1234,16 +1306,20 @@ func TestDeploymentStorageFindBy(t *testing.T) {
 				Name:         "zed",
 				ArtifactName: "daz",
 				Devices:      []string{"b532b01a-9313-404f-8d19-e7fcbe5cc347"},
+				Comment:      "CHG-1234",
+				Tags:         map[string]string{"env": "production"},
 			},
 			Id: "a108ae14-bb4e-455f-9b40-000000000015",
-			Stats: newTestStats(model.Stats{
-				model.DeviceDeploymentStatusPendingStr: 1,
-			}),
+			Stats: newTestStats(model.NewStats(map[model.DeviceDeploymentStatus]int{
+				model.DeviceDeploymentStatusPending: 1,
+			})),
 			Status: model.DeploymentStatusPending,
 			Type:   model.DeploymentTypeConfiguration,
 		},
 	}
 
+	isActive, isInactive := true, false
+
 	testCases := []struct {
 		InputModelQuery            model.Query
 		InputDeploymentsCollection []*model.Deployment
@@ -1375,6 +1451,97 @@ func TestDeploymentStorageFindBy(t *testing.T) {
 				"a108ae14-bb4e-455f-9b40-000000000001",
 			},
 		},
+		{
+			InputModelQuery: model.Query{
+				IsActive: &isActive,
+			},
+			InputDeploymentsCollection: someDeployments,
+			OutputError:                nil,
+			OutputID: []string{
+				"a108ae14-bb4e-455f-9b40-000000000015",
+				"a108ae14-bb4e-455f-9b40-000000000013",
+				"a108ae14-bb4e-455f-9b40-000000000012",
+				"a108ae14-bb4e-455f-9b40-000000000011",
+				"a108ae14-bb4e-455f-9b40-000000000010",
+				"a108ae14-bb4e-455f-9b40-000000000007",
+				"a108ae14-bb4e-455f-9b40-000000000006",
+				"a108ae14-bb4e-455f-9b40-000000000005",
+			},
+		},
+		{
+			InputModelQuery: model.Query{
+				HasComment: &isActive,
+			},
+			InputDeploymentsCollection: someDeployments,
+			OutputError:                nil,
+			OutputID: []string{
+				"a108ae14-bb4e-455f-9b40-000000000015",
+			},
+		},
+		{
+			InputModelQuery: model.Query{
+				SortFields: []model.QuerySortField{{
+					Field:     model.SortFieldName,
+					Direction: model.SortOrderAscending,
+				}, {
+					Field:     model.SortFieldCreated,
+					Direction: model.SortOrderDescending,
+				}},
+				Limit: 3,
+			},
+			InputDeploymentsCollection: someDeployments,
+			OutputError:                nil,
+			OutputID: []string{
+				"a108ae14-bb4e-455f-9b40-000000000009",
+				"a108ae14-bb4e-455f-9b40-000000000002",
+				"a108ae14-bb4e-455f-9b40-000000000001",
+			},
+		},
+		{
+			InputModelQuery: model.Query{
+				Tags: map[string]string{"env": "production"},
+			},
+			InputDeploymentsCollection: someDeployments,
+			OutputError:                nil,
+			OutputID: []string{
+				"a108ae14-bb4e-455f-9b40-000000000015",
+			},
+		},
+		{
+			InputModelQuery: model.Query{
+				Tags: map[string]string{"env": "staging"},
+			},
+			InputDeploymentsCollection: someDeployments,
+			OutputError:                nil,
+		},
+		{
+			InputModelQuery: model.Query{
+				HasComment: &isInactive,
+				Limit:      2,
+			},
+			InputDeploymentsCollection: someDeployments,
+			OutputError:                nil,
+			OutputID: []string{
+				"a108ae14-bb4e-455f-9b40-000000000014",
+				"a108ae14-bb4e-455f-9b40-000000000013",
+			},
+		},
+		{
+			InputModelQuery: model.Query{
+				IsActive: &isInactive,
+			},
+			InputDeploymentsCollection: someDeployments,
+			OutputError:                nil,
+			OutputID: []string{
+				"a108ae14-bb4e-455f-9b40-000000000014",
+				"a108ae14-bb4e-455f-9b40-000000000009",
+				"a108ae14-bb4e-455f-9b40-000000000008",
+				"a108ae14-bb4e-455f-9b40-000000000004",
+				"a108ae14-bb4e-455f-9b40-000000000003",
+				"a108ae14-bb4e-455f-9b40-000000000002",
+				"a108ae14-bb4e-455f-9b40-000000000001",
+			},
+		},
 		{
 			InputModelQuery: model.Query{
 				// whatever name
@@ -1561,6 +1728,417 @@ func TestDeploymentStorageFindBy(t *testing.T) {
 	}
 }
 
+func TestDeploymentStorageFindByDeviceID(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping TestDeploymentStorageFindByDeviceID in short mode.")
+	}
+
+	const (
+		deviceShared = "b532b01a-9313-404f-8d19-e7fcbe5cc399"
+		deviceFirst  = "d4f4a4b0-0a34-4d4b-a7f4-2a3c1e1f2b01"
+		deviceSecond = "d4f4a4b0-0a34-4d4b-a7f4-2a3c1e1f2b02"
+	)
+
+	db.Wipe()
+	ctx := context.Background()
+	store := NewDataStoreMongoWithClient(db.Client())
+	err := store.EnsureIndexes(DatabaseName, CollectionDeployments,
+		StorageIndexes, IndexDeploymentDeviceListModel)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	created := time.Now().UTC()
+	first := &model.Deployment{
+		DeploymentConstructor: &model.DeploymentConstructor{
+			Name:         "first",
+			ArtifactName: "artifact",
+			Devices:      []string{deviceShared, deviceFirst},
+		},
+		Id:         "a108ae14-bb4e-455f-9b40-2ef4bab97bb7",
+		DeviceList: []string{deviceShared, deviceFirst},
+		Stats:      newTestStats(model.Stats{}),
+		Created:    &created,
+	}
+	createdSecond := created.Add(time.Minute)
+	second := &model.Deployment{
+		DeploymentConstructor: &model.DeploymentConstructor{
+			Name:         "second",
+			ArtifactName: "artifact",
+			Devices:      []string{deviceShared, deviceSecond},
+		},
+		Id:         "b108ae14-bb4e-455f-9b40-2ef4bab97bb7",
+		DeviceList: []string{deviceShared, deviceSecond},
+		Stats:      newTestStats(model.Stats{}),
+		Created:    &createdSecond,
+	}
+	for _, d := range []*model.Deployment{first, second} {
+		if !assert.NoError(t, store.InsertDeployment(ctx, d)) {
+			t.FailNow()
+		}
+	}
+
+	testCases := map[string]struct {
+		DeviceID string
+		Limit    int
+
+		OutputIDs []string
+		Count     int64
+	}{
+		"device in the first deployment only": {
+			DeviceID:  deviceFirst,
+			OutputIDs: []string{first.Id},
+			Count:     1,
+		},
+		"device in the second deployment only": {
+			DeviceID:  deviceSecond,
+			OutputIDs: []string{second.Id},
+			Count:     1,
+		},
+		"device in both deployments": {
+			DeviceID:  deviceShared,
+			OutputIDs: []string{second.Id, first.Id},
+			Count:     2,
+		},
+		"device in both deployments, counted": {
+			DeviceID:  deviceShared,
+			Limit:     1,
+			OutputIDs: []string{second.Id},
+			Count:     2,
+		},
+		"device in no deployment": {
+			DeviceID: "d4f4a4b0-0a34-4d4b-a7f4-2a3c1e1f2b03",
+		},
+	}
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			deps, count, err := store.Find(ctx, model.Query{
+				DeviceID: tc.DeviceID,
+				Limit:    tc.Limit,
+			})
+			if !assert.NoError(t, err) {
+				t.FailNow()
+			}
+			ids := make([]string, 0, len(deps))
+			for _, dep := range deps {
+				ids = append(ids, dep.Id)
+			}
+			if tc.OutputIDs == nil {
+				tc.OutputIDs = []string{}
+			}
+			assert.Equal(t, tc.OutputIDs, ids)
+			assert.Equal(t, tc.Count, count)
+		})
+	}
+}
+
+func TestDeploymentStorageFindByArtifactName(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping TestDeploymentStorageFindByArtifactName in short mode.")
+	}
+
+	db.Wipe()
+	ctx := context.Background()
+	store := NewDataStoreMongoWithClient(db.Client())
+	err := store.EnsureIndexes(DatabaseName, CollectionDeployments, StorageIndexes)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	ids := map[string]string{
+		"release-1.0":     "a108ae14-bb4e-455f-9b40-2ef4bab97bb7",
+		"pre-release-1.0": "b108ae14-bb4e-455f-9b40-2ef4bab97bb7",
+		"release-1.0.1":   "c108ae14-bb4e-455f-9b40-2ef4bab97bb7",
+	}
+	for artifactName, id := range ids {
+		err := store.InsertDeployment(ctx, &model.Deployment{
+			DeploymentConstructor: &model.DeploymentConstructor{
+				Name:         "deployment",
+				ArtifactName: artifactName,
+				Devices:      []string{"b532b01a-9313-404f-8d19-e7fcbe5cc399"},
+			},
+			Id:      id,
+			Stats:   newTestStats(model.Stats{}),
+			Created: TimeToPointer(time.Now().UTC()),
+		})
+		if !assert.NoError(t, err) {
+			t.FailNow()
+		}
+	}
+
+	testCases := map[string]struct {
+		ArtifactName string
+
+		OutputIDs []string
+	}{
+		"exact match": {
+			ArtifactName: "release-1.0",
+			OutputIDs:    []string{ids["release-1.0"]},
+		},
+		"exact match with common prefix": {
+			ArtifactName: "pre-release-1.0",
+			OutputIDs:    []string{ids["pre-release-1.0"]},
+		},
+		"suffix does not match": {
+			ArtifactName: "1.0",
+		},
+		"wildcard is not expanded": {
+			ArtifactName: "release-1.0*",
+		},
+	}
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			deps, count, err := store.Find(ctx, model.Query{
+				ArtifactName: tc.ArtifactName,
+			})
+			if !assert.NoError(t, err) {
+				t.FailNow()
+			}
+			found := make([]string, 0, len(deps))
+			for _, dep := range deps {
+				found = append(found, dep.Id)
+			}
+			if tc.OutputIDs == nil {
+				tc.OutputIDs = []string{}
+			}
+			assert.Equal(t, tc.OutputIDs, found)
+			assert.Equal(t, int64(len(tc.OutputIDs)), count)
+		})
+	}
+}
+
+func TestDeploymentStorageFindWithCursor(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping TestDeploymentStorageFindWithCursor in short mode.")
+	}
+
+	db.Wipe()
+	ctx := context.Background()
+	store := NewDataStoreMongoWithClient(db.Client())
+	err := store.EnsureIndexes(DatabaseName, CollectionDeployments, StorageIndexes)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	const numDeployments = 100
+	// several deployments share the same creation date to exercise the
+	// tie-breaking on the ID
+	created := time.Now().UTC().Truncate(time.Second)
+	for i := 0; i < numDeployments; i++ {
+		err := store.InsertDeployment(ctx, &model.Deployment{
+			DeploymentConstructor: &model.DeploymentConstructor{
+				Name:         "deployment",
+				ArtifactName: "artifact",
+				Devices:      []string{"b532b01a-9313-404f-8d19-e7fcbe5cc399"},
+			},
+			Id:      uuid.NewString(),
+			Stats:   newTestStats(model.Stats{}),
+			Created: TimeToPointer(created.Add(time.Duration(i/3) * time.Second)),
+		})
+		if !assert.NoError(t, err) {
+			t.FailNow()
+		}
+	}
+
+	all, _, err := store.Find(ctx, model.Query{})
+	if !assert.NoError(t, err) || !assert.Len(t, all, numDeployments) {
+		t.FailNow()
+	}
+	expected := make([]string, 0, numDeployments)
+	for _, dep := range all {
+		expected = append(expected, dep.Id)
+	}
+
+	t.Run("forward", func(t *testing.T) {
+		seen := map[string]bool{}
+		found := make([]string, 0, numDeployments)
+		query := model.Query{Limit: 10}
+		for {
+			deps, next, err := store.FindWithCursor(ctx, query)
+			if !assert.NoError(t, err) {
+				t.FailNow()
+			}
+			for _, dep := range deps {
+				assert.False(t, seen[dep.Id], "duplicate deployment %s", dep.Id)
+				seen[dep.Id] = true
+				found = append(found, dep.Id)
+			}
+			if next == "" {
+				break
+			}
+			if !assert.Len(t, deps, 10) || len(found) > numDeployments {
+				t.FailNow()
+			}
+			query.AfterID = next
+		}
+		assert.Equal(t, expected, found)
+	})
+
+	t.Run("backward", func(t *testing.T) {
+		found := []string{}
+		query := model.Query{Limit: 10, BeforeID: expected[numDeployments-1]}
+		for {
+			deps, next, err := store.FindWithCursor(ctx, query)
+			if !assert.NoError(t, err) {
+				t.FailNow()
+			}
+			page := make([]string, 0, len(deps))
+			for _, dep := range deps {
+				page = append(page, dep.Id)
+			}
+			found = append(page, found...)
+			if next == "" {
+				break
+			} else if len(found) > numDeployments {
+				t.FailNow()
+			}
+			query.BeforeID = next
+		}
+		assert.Equal(t, expected[:numDeployments-1], found)
+	})
+
+	t.Run("count", func(t *testing.T) {
+		deps, count, err := store.Find(ctx, model.Query{
+			Limit:   10,
+			AfterID: expected[94],
+		})
+		if assert.NoError(t, err) {
+			assert.Len(t, deps, 5)
+			assert.Equal(t, int64(numDeployments), count)
+		}
+	})
+
+	t.Run("error, unknown cursor", func(t *testing.T) {
+		_, _, err := store.FindWithCursor(ctx, model.Query{
+			AfterID: uuid.NewString(),
+		})
+		assert.ErrorIs(t, err, ErrDeploymentStorageInvalidCursor)
+	})
+}
+
+func TestDeploymentStorageFindDeployments(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping TestDeploymentStorageFindDeployments in short mode.")
+	}
+
+	db.Wipe()
+	ctx := context.Background()
+	store := NewDataStoreMongoWithClient(db.Client())
+	err := store.EnsureIndexes(DatabaseName, CollectionDeployments, StorageIndexes)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	created := time.Now().UTC().Truncate(time.Second)
+	for i := 0; i < 10; i++ {
+		artifactName := "foo"
+		if i%2 == 1 {
+			artifactName = "bar"
+		}
+		err := store.InsertDeployment(ctx, &model.Deployment{
+			DeploymentConstructor: &model.DeploymentConstructor{
+				Name:         "deployment",
+				ArtifactName: artifactName,
+				Devices:      []string{"b532b01a-9313-404f-8d19-e7fcbe5cc399"},
+			},
+			Id:      uuid.NewString(),
+			Stats:   newTestStats(model.Stats{}),
+			Created: TimeToPointer(created.Add(time.Duration(i) * time.Second)),
+		})
+		if !assert.NoError(t, err) {
+			t.FailNow()
+		}
+	}
+
+	query := model.Query{
+		ArtifactName: "foo",
+		Limit:        2,
+		Skip:         1,
+		Sort:         model.SortDirectionAscending,
+	}
+	expected, expectedCount, err := store.Find(ctx, query)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	deployments, count, err := store.FindDeployments(ctx,
+		query.Filter(), query.Pagination())
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Equal(t, int64(5), count)
+	assert.Equal(t, expectedCount, count)
+	if assert.Len(t, deployments, 2) {
+		assert.Equal(t, expected[0].Id, deployments[0].Id)
+		assert.Equal(t, expected[1].Id, deployments[1].Id)
+	}
+}
+
+func TestDeploymentStorageCountDeployments(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping TestDeploymentStorageCountDeployments in short mode.")
+	}
+
+	deployments := []*model.Deployment{{
+		DeploymentConstructor: &model.DeploymentConstructor{
+			Name:         "foo",
+			ArtifactName: "bar",
+		},
+		Id:     "a108ae14-bb4e-455f-9b40-000000000001",
+		Stats:  newTestStats(model.Stats{}),
+		Status: model.DeploymentStatusPending,
+	}, {
+		DeploymentConstructor: &model.DeploymentConstructor{
+			Name:         "foo",
+			ArtifactName: "bar",
+		},
+		Id:     "a108ae14-bb4e-455f-9b40-000000000002",
+		Stats:  newTestStats(model.Stats{}),
+		Status: model.DeploymentStatusFinished,
+		Type:   model.DeploymentTypeSoftware,
+	}, {
+		DeploymentConstructor: &model.DeploymentConstructor{
+			Name:         "foo",
+			ArtifactName: "bar",
+		},
+		Id:     "a108ae14-bb4e-455f-9b40-000000000003",
+		Stats:  newTestStats(model.Stats{}),
+		Status: model.DeploymentStatusFinished,
+		Type:   model.DeploymentTypeConfiguration,
+	}}
+
+	db.Wipe()
+	store := NewDataStoreMongoWithClient(db.Client())
+	ctx := context.Background()
+	for _, d := range deployments {
+		d.Created = TimeToPointer(time.Now().UTC())
+		assert.NoError(t, store.InsertDeployment(ctx, d))
+	}
+
+	byType, err := store.CountDeploymentsByType(ctx, model.Query{})
+	assert.NoError(t, err)
+	assert.Equal(t, model.DeploymentCountByType{
+		model.DeploymentTypeSoftware:      2,
+		model.DeploymentTypeConfiguration: 1,
+	}, byType)
+
+	byStatus, err := store.CountDeploymentsByStatus(ctx, model.Query{})
+	assert.NoError(t, err)
+	assert.Equal(t, model.DeploymentCountByStatus{
+		model.DeploymentStatusPending:  1,
+		model.DeploymentStatusFinished: 2,
+	}, byStatus)
+
+	byStatus, err = store.CountDeploymentsByStatus(ctx, model.Query{
+		Type: model.DeploymentTypeConfiguration,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, model.DeploymentCountByStatus{
+		model.DeploymentStatusFinished: 1,
+	}, byStatus)
+}
+
 func TestDeviceDeploymentCounting(t *testing.T) {
 	testCases := []struct {
 		InputDeploymentID     string
@@ -1702,3 +2280,57 @@ func TestDeploymentSetStatus(t *testing.T) {
 		})
 	}
 }
+
+func TestDeploymentSetStatusMany(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping TestDeploymentSetStatusMany in short mode.")
+	}
+
+	ids := []string{
+		"a108ae14-bb4e-455f-9b40-2ef4bab97bb7",
+		"b108ae14-bb4e-455f-9b40-2ef4bab97bb7",
+	}
+	const otherID = "c108ae14-bb4e-455f-9b40-2ef4bab97bb7"
+
+	db.Wipe()
+	client := db.Client()
+	store := NewDataStoreMongoWithClient(client)
+	ctx := context.Background()
+	collDep := client.Database(DatabaseName).Collection(CollectionDeployments)
+	for _, id := range append([]string{otherID}, ids...) {
+		_, err := collDep.InsertOne(ctx, &model.Deployment{
+			Id:     id,
+			Status: model.DeploymentStatusInProgress,
+		})
+		if !assert.NoError(t, err) {
+			t.FailNow()
+		}
+	}
+
+	now := time.Now().UTC()
+	err := store.SetDeploymentsStatus(ctx, ids, model.DeploymentStatusFinished, now)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	for _, id := range ids {
+		var deployment *model.Deployment
+		err = collDep.FindOne(ctx, bson.M{"_id": id}).Decode(&deployment)
+		if assert.NoError(t, err) {
+			assert.Equal(t, model.DeploymentStatusFinished, deployment.Status)
+			assert.False(t, deployment.Active)
+			if assert.NotNil(t, deployment.Finished) {
+				// mongo trims time, no true equality
+				assert.WithinDuration(t, now, *deployment.Finished, time.Second)
+			}
+		}
+	}
+	var other *model.Deployment
+	err = collDep.FindOne(ctx, bson.M{"_id": otherID}).Decode(&other)
+	if assert.NoError(t, err) {
+		assert.Equal(t, model.DeploymentStatusInProgress, other.Status)
+	}
+
+	err = store.SetDeploymentsStatus(ctx, []string{ids[0], ""},
+		model.DeploymentStatusFinished, now)
+	assert.EqualError(t, err, ErrStorageInvalidID.Error())
+}
@@ -485,7 +485,7 @@ func TestAggregateDeviceDeploymentByStatus(t *testing.T) {
 			InputDeploymentID:     "ee13ea8b-a6d3-4d4c-99a6-bcfcaebc7ec3",
 			InputDeviceDeployment: nil,
 			OutputError:           nil,
-			OutputStats:           nil,
+			OutputStats:           newTestStats(model.Stats{}),
 		},
 		{
 			InputDeploymentID: "30b3e62c-9ec2-4312-a7fa-cff24cc7397a",
@@ -507,21 +507,21 @@ func TestAggregateDeviceDeploymentByStatus(t *testing.T) {
 					model.DeviceDeploymentStatusPending),
 			},
 			OutputError: nil,
-			OutputStats: model.Stats{
-				model.DeviceDeploymentStatusPendingStr:            1,
-				model.DeviceDeploymentStatusSuccessStr:            1,
-				model.DeviceDeploymentStatusFailureStr:            2,
-				model.DeviceDeploymentStatusRebootingStr:          1,
-				model.DeviceDeploymentStatusDownloadingStr:        1,
-				model.DeviceDeploymentStatusInstallingStr:         0,
-				model.DeviceDeploymentStatusNoArtifactStr:         0,
-				model.DeviceDeploymentStatusAlreadyInstStr:        0,
-				model.DeviceDeploymentStatusAbortedStr:            0,
-				model.DeviceDeploymentStatusDecommissionedStr:     0,
-				model.DeviceDeploymentStatusPauseBeforeCommitStr:  0,
-				model.DeviceDeploymentStatusPauseBeforeInstallStr: 0,
-				model.DeviceDeploymentStatusPauseBeforeRebootStr:  0,
-			},
+			OutputStats: model.NewStats(map[model.DeviceDeploymentStatus]int{
+				model.DeviceDeploymentStatusPending:            1,
+				model.DeviceDeploymentStatusSuccess:            1,
+				model.DeviceDeploymentStatusFailure:            2,
+				model.DeviceDeploymentStatusRebooting:          1,
+				model.DeviceDeploymentStatusDownloading:        1,
+				model.DeviceDeploymentStatusInstalling:         0,
+				model.DeviceDeploymentStatusNoArtifact:         0,
+				model.DeviceDeploymentStatusAlreadyInst:        0,
+				model.DeviceDeploymentStatusAborted:            0,
+				model.DeviceDeploymentStatusDecommissioned:     0,
+				model.DeviceDeploymentStatusPauseBeforeCommit:  0,
+				model.DeviceDeploymentStatusPauseBeforeInstall: 0,
+				model.DeviceDeploymentStatusPauseBeforeReboot:  0,
+			}),
 		},
 		{
 			InputDeploymentID: "30b3e62c-9ec2-4312-a7fa-cff24cc7397a",
@@ -536,21 +536,21 @@ func TestAggregateDeviceDeploymentByStatus(t *testing.T) {
 					model.DeviceDeploymentStatusPauseBeforeReboot),
 			},
 			OutputError: nil,
-			OutputStats: model.Stats{
-				model.DeviceDeploymentStatusPendingStr:            0,
-				model.DeviceDeploymentStatusSuccessStr:            0,
-				model.DeviceDeploymentStatusFailureStr:            0,
-				model.DeviceDeploymentStatusRebootingStr:          0,
-				model.DeviceDeploymentStatusDownloadingStr:        0,
-				model.DeviceDeploymentStatusInstallingStr:         0,
-				model.DeviceDeploymentStatusNoArtifactStr:         0,
-				model.DeviceDeploymentStatusAlreadyInstStr:        0,
-				model.DeviceDeploymentStatusAbortedStr:            0,
-				model.DeviceDeploymentStatusDecommissionedStr:     0,
-				model.DeviceDeploymentStatusPauseBeforeCommitStr:  1,
-				model.DeviceDeploymentStatusPauseBeforeInstallStr: 1,
-				model.DeviceDeploymentStatusPauseBeforeRebootStr:  1,
-			},
+			OutputStats: model.NewStats(map[model.DeviceDeploymentStatus]int{
+				model.DeviceDeploymentStatusPending:            0,
+				model.DeviceDeploymentStatusSuccess:            0,
+				model.DeviceDeploymentStatusFailure:            0,
+				model.DeviceDeploymentStatusRebooting:          0,
+				model.DeviceDeploymentStatusDownloading:        0,
+				model.DeviceDeploymentStatusInstalling:         0,
+				model.DeviceDeploymentStatusNoArtifact:         0,
+				model.DeviceDeploymentStatusAlreadyInst:        0,
+				model.DeviceDeploymentStatusAborted:            0,
+				model.DeviceDeploymentStatusDecommissioned:     0,
+				model.DeviceDeploymentStatusPauseBeforeCommit:  1,
+				model.DeviceDeploymentStatusPauseBeforeInstall: 1,
+				model.DeviceDeploymentStatusPauseBeforeReboot:  1,
+			}),
 		},
 		{
 			InputDeploymentID: "30b3e62c-9ec2-4312-a7fa-cff24cc7397a",
@@ -561,10 +561,10 @@ func TestAggregateDeviceDeploymentByStatus(t *testing.T) {
 					model.DeviceDeploymentStatusSuccess),
 			},
 			InputTenant: "acme",
-			OutputStats: newTestStats(model.Stats{
-				model.DeviceDeploymentStatusSuccessStr: 1,
-				model.DeviceDeploymentStatusFailureStr: 1,
-			}),
+			OutputStats: newTestStats(model.NewStats(map[model.DeviceDeploymentStatus]int{
+				model.DeviceDeploymentStatusSuccess: 1,
+				model.DeviceDeploymentStatusFailure: 1,
+			})),
 		},
 	}
 
@@ -607,10 +607,6 @@ func TestAggregateDeviceDeploymentByStatus(t *testing.T) {
 					assert.NoError(t, err)
 					assert.Equal(t, newTestStats(model.Stats{}), stats)
 				}
-			}
-
-			if testCase.OutputStats != nil {
-				assert.NotNil(t, stats)
 				assert.Equal(t, testCase.OutputStats, stats)
 			}
 		})
@@ -134,10 +134,10 @@ func (m *migration_1_2_4) Up(from migrate.Version) error {
 }
 
 func isFinished(d *model.Deployment) bool {
-	if d.Stats[model.DeviceDeploymentStatusPendingStr] == 0 &&
-		d.Stats[model.DeviceDeploymentStatusDownloadingStr] == 0 &&
-		d.Stats[model.DeviceDeploymentStatusInstallingStr] == 0 &&
-		d.Stats[model.DeviceDeploymentStatusRebootingStr] == 0 {
+	if d.Stats.Get(model.DeviceDeploymentStatusPending) == 0 &&
+		d.Stats.Get(model.DeviceDeploymentStatusDownloading) == 0 &&
+		d.Stats.Get(model.DeviceDeploymentStatusInstalling) == 0 &&
+		d.Stats.Get(model.DeviceDeploymentStatusRebooting) == 0 {
 		return true
 	}
 
@@ -146,14 +146,14 @@ func isFinished(d *model.Deployment) bool {
 
 func isPending(d *model.Deployment) bool {
 	//pending > 0, evt else == 0
-	if d.Stats[model.DeviceDeploymentStatusPendingStr] > 0 &&
-		d.Stats[model.DeviceDeploymentStatusDownloadingStr] == 0 &&
-		d.Stats[model.DeviceDeploymentStatusInstallingStr] == 0 &&
-		d.Stats[model.DeviceDeploymentStatusRebootingStr] == 0 &&
-		d.Stats[model.DeviceDeploymentStatusSuccessStr] == 0 &&
-		d.Stats[model.DeviceDeploymentStatusAlreadyInstStr] == 0 &&
-		d.Stats[model.DeviceDeploymentStatusFailureStr] == 0 &&
-		d.Stats[model.DeviceDeploymentStatusNoArtifactStr] == 0 {
+	if d.Stats.Get(model.DeviceDeploymentStatusPending) > 0 &&
+		d.Stats.Get(model.DeviceDeploymentStatusDownloading) == 0 &&
+		d.Stats.Get(model.DeviceDeploymentStatusInstalling) == 0 &&
+		d.Stats.Get(model.DeviceDeploymentStatusRebooting) == 0 &&
+		d.Stats.Get(model.DeviceDeploymentStatusSuccess) == 0 &&
+		d.Stats.Get(model.DeviceDeploymentStatusAlreadyInst) == 0 &&
+		d.Stats.Get(model.DeviceDeploymentStatusFailure) == 0 &&
+		d.Stats.Get(model.DeviceDeploymentStatusNoArtifact) == 0 {
 
 		return true
 	}
@@ -49,18 +49,18 @@ func TestMigration_1_2_4(t *testing.T) {
 			id: "dep-pending-1",
 			deployment: model.Deployment{
 				Id: "dep-pending-1",
-				Stats: model.Stats{
-					model.DeviceDeploymentStatusPendingStr:        3,
-					model.DeviceDeploymentStatusDownloadingStr:    0,
-					model.DeviceDeploymentStatusInstallingStr:     0,
-					model.DeviceDeploymentStatusRebootingStr:      0,
-					model.DeviceDeploymentStatusSuccessStr:        0,
-					model.DeviceDeploymentStatusAlreadyInstStr:    0,
-					model.DeviceDeploymentStatusFailureStr:        0,
-					model.DeviceDeploymentStatusNoArtifactStr:     0,
-					model.DeviceDeploymentStatusAbortedStr:        0,
-					model.DeviceDeploymentStatusDecommissionedStr: 0,
-				},
+				Stats: model.NewStats(map[model.DeviceDeploymentStatus]int{
+					model.DeviceDeploymentStatusPending:        3,
+					model.DeviceDeploymentStatusDownloading:    0,
+					model.DeviceDeploymentStatusInstalling:     0,
+					model.DeviceDeploymentStatusRebooting:      0,
+					model.DeviceDeploymentStatusSuccess:        0,
+					model.DeviceDeploymentStatusAlreadyInst:    0,
+					model.DeviceDeploymentStatusFailure:        0,
+					model.DeviceDeploymentStatusNoArtifact:     0,
+					model.DeviceDeploymentStatusAborted:        0,
+					model.DeviceDeploymentStatusDecommissioned: 0,
+				}),
 			},
 			devices: []interface{}{
 				model.DeviceDeployment{
@@ -86,18 +86,18 @@ func TestMigration_1_2_4(t *testing.T) {
 			id: "dep-pending-1",
 			deployment: model.Deployment{
 				Id: "dep-pending-1",
-				Stats: model.Stats{
-					model.DeviceDeploymentStatusPendingStr:        1,
-					model.DeviceDeploymentStatusDownloadingStr:    0,
-					model.DeviceDeploymentStatusInstallingStr:     0,
-					model.DeviceDeploymentStatusRebootingStr:      0,
-					model.DeviceDeploymentStatusSuccessStr:        0,
-					model.DeviceDeploymentStatusAlreadyInstStr:    0,
-					model.DeviceDeploymentStatusFailureStr:        0,
-					model.DeviceDeploymentStatusNoArtifactStr:     0,
-					model.DeviceDeploymentStatusAbortedStr:        0,
-					model.DeviceDeploymentStatusDecommissionedStr: 0,
-				},
+				Stats: model.NewStats(map[model.DeviceDeploymentStatus]int{
+					model.DeviceDeploymentStatusPending:        1,
+					model.DeviceDeploymentStatusDownloading:    0,
+					model.DeviceDeploymentStatusInstalling:     0,
+					model.DeviceDeploymentStatusRebooting:      0,
+					model.DeviceDeploymentStatusSuccess:        0,
+					model.DeviceDeploymentStatusAlreadyInst:    0,
+					model.DeviceDeploymentStatusFailure:        0,
+					model.DeviceDeploymentStatusNoArtifact:     0,
+					model.DeviceDeploymentStatusAborted:        0,
+					model.DeviceDeploymentStatusDecommissioned: 0,
+				}),
 			},
 			devices: []interface{}{
 				model.DeviceDeployment{
@@ -113,18 +113,18 @@ func TestMigration_1_2_4(t *testing.T) {
 			id: "dep-inprog-1",
 			deployment: model.Deployment{
 				Id: "dep-inprog-1",
-				Stats: model.Stats{
-					model.DeviceDeploymentStatusPendingStr:        2,
-					model.DeviceDeploymentStatusDownloadingStr:    1,
-					model.DeviceDeploymentStatusInstallingStr:     0,
-					model.DeviceDeploymentStatusRebootingStr:      0,
-					model.DeviceDeploymentStatusSuccessStr:        0,
-					model.DeviceDeploymentStatusAlreadyInstStr:    0,
-					model.DeviceDeploymentStatusFailureStr:        1,
-					model.DeviceDeploymentStatusNoArtifactStr:     0,
-					model.DeviceDeploymentStatusAbortedStr:        0,
-					model.DeviceDeploymentStatusDecommissionedStr: 1,
-				},
+				Stats: model.NewStats(map[model.DeviceDeploymentStatus]int{
+					model.DeviceDeploymentStatusPending:        2,
+					model.DeviceDeploymentStatusDownloading:    1,
+					model.DeviceDeploymentStatusInstalling:     0,
+					model.DeviceDeploymentStatusRebooting:      0,
+					model.DeviceDeploymentStatusSuccess:        0,
+					model.DeviceDeploymentStatusAlreadyInst:    0,
+					model.DeviceDeploymentStatusFailure:        1,
+					model.DeviceDeploymentStatusNoArtifact:     0,
+					model.DeviceDeploymentStatusAborted:        0,
+					model.DeviceDeploymentStatusDecommissioned: 1,
+				}),
 			},
 			devices: []interface{}{
 				model.DeviceDeployment{
@@ -155,18 +155,18 @@ func TestMigration_1_2_4(t *testing.T) {
 			id: "dep-inprog-2",
 			deployment: model.Deployment{
 				Id: "dep-inprog-2",
-				Stats: model.Stats{
-					model.DeviceDeploymentStatusPendingStr:        0,
-					model.DeviceDeploymentStatusDownloadingStr:    1,
-					model.DeviceDeploymentStatusInstallingStr:     1,
-					model.DeviceDeploymentStatusRebootingStr:      1,
-					model.DeviceDeploymentStatusSuccessStr:        1,
-					model.DeviceDeploymentStatusAlreadyInstStr:    0,
-					model.DeviceDeploymentStatusFailureStr:        1,
-					model.DeviceDeploymentStatusNoArtifactStr:     0,
-					model.DeviceDeploymentStatusAbortedStr:        0,
-					model.DeviceDeploymentStatusDecommissionedStr: 0,
-				},
+				Stats: model.NewStats(map[model.DeviceDeploymentStatus]int{
+					model.DeviceDeploymentStatusPending:        0,
+					model.DeviceDeploymentStatusDownloading:    1,
+					model.DeviceDeploymentStatusInstalling:     1,
+					model.DeviceDeploymentStatusRebooting:      1,
+					model.DeviceDeploymentStatusSuccess:        1,
+					model.DeviceDeploymentStatusAlreadyInst:    0,
+					model.DeviceDeploymentStatusFailure:        1,
+					model.DeviceDeploymentStatusNoArtifact:     0,
+					model.DeviceDeploymentStatusAborted:        0,
+					model.DeviceDeploymentStatusDecommissioned: 0,
+				}),
 			},
 			devices: []interface{}{
 				model.DeviceDeployment{
@@ -197,18 +197,18 @@ func TestMigration_1_2_4(t *testing.T) {
 			id: "dep-inprog-3",
 			deployment: model.Deployment{
 				Id: "dep-inprog-3",
-				Stats: model.Stats{
-					model.DeviceDeploymentStatusPendingStr:        1,
-					model.DeviceDeploymentStatusDownloadingStr:    0,
-					model.DeviceDeploymentStatusInstallingStr:     0,
-					model.DeviceDeploymentStatusRebootingStr:      0,
-					model.DeviceDeploymentStatusSuccessStr:        2,
-					model.DeviceDeploymentStatusAlreadyInstStr:    0,
-					model.DeviceDeploymentStatusFailureStr:        1,
-					model.DeviceDeploymentStatusNoArtifactStr:     0,
-					model.DeviceDeploymentStatusAbortedStr:        0,
-					model.DeviceDeploymentStatusDecommissionedStr: 0,
-				},
+				Stats: model.NewStats(map[model.DeviceDeploymentStatus]int{
+					model.DeviceDeploymentStatusPending:        1,
+					model.DeviceDeploymentStatusDownloading:    0,
+					model.DeviceDeploymentStatusInstalling:     0,
+					model.DeviceDeploymentStatusRebooting:      0,
+					model.DeviceDeploymentStatusSuccess:        2,
+					model.DeviceDeploymentStatusAlreadyInst:    0,
+					model.DeviceDeploymentStatusFailure:        1,
+					model.DeviceDeploymentStatusNoArtifact:     0,
+					model.DeviceDeploymentStatusAborted:        0,
+					model.DeviceDeploymentStatusDecommissioned: 0,
+				}),
 			},
 			devices: []interface{}{
 				model.DeviceDeployment{
@@ -239,18 +239,18 @@ func TestMigration_1_2_4(t *testing.T) {
 			id: "finished-1",
 			deployment: model.Deployment{
 				Id: "finished-1",
-				Stats: model.Stats{
-					model.DeviceDeploymentStatusPendingStr:        0,
-					model.DeviceDeploymentStatusDownloadingStr:    0,
-					model.DeviceDeploymentStatusInstallingStr:     0,
-					model.DeviceDeploymentStatusRebootingStr:      0,
-					model.DeviceDeploymentStatusSuccessStr:        2,
-					model.DeviceDeploymentStatusAlreadyInstStr:    0,
-					model.DeviceDeploymentStatusFailureStr:        3,
-					model.DeviceDeploymentStatusNoArtifactStr:     0,
-					model.DeviceDeploymentStatusAbortedStr:        0,
-					model.DeviceDeploymentStatusDecommissionedStr: 0,
-				},
+				Stats: model.NewStats(map[model.DeviceDeploymentStatus]int{
+					model.DeviceDeploymentStatusPending:        0,
+					model.DeviceDeploymentStatusDownloading:    0,
+					model.DeviceDeploymentStatusInstalling:     0,
+					model.DeviceDeploymentStatusRebooting:      0,
+					model.DeviceDeploymentStatusSuccess:        2,
+					model.DeviceDeploymentStatusAlreadyInst:    0,
+					model.DeviceDeploymentStatusFailure:        3,
+					model.DeviceDeploymentStatusNoArtifact:     0,
+					model.DeviceDeploymentStatusAborted:        0,
+					model.DeviceDeploymentStatusDecommissioned: 0,
+				}),
 			},
 			devices: []interface{}{
 				model.DeviceDeployment{
@@ -281,18 +281,18 @@ func TestMigration_1_2_4(t *testing.T) {
 			id: "finished-2",
 			deployment: model.Deployment{
 				Id: "finished-2",
-				Stats: model.Stats{
-					model.DeviceDeploymentStatusPendingStr:        0,
-					model.DeviceDeploymentStatusDownloadingStr:    0,
-					model.DeviceDeploymentStatusInstallingStr:     0,
-					model.DeviceDeploymentStatusRebootingStr:      0,
-					model.DeviceDeploymentStatusSuccessStr:        1,
-					model.DeviceDeploymentStatusAlreadyInstStr:    0,
-					model.DeviceDeploymentStatusFailureStr:        2,
-					model.DeviceDeploymentStatusNoArtifactStr:     1,
-					model.DeviceDeploymentStatusAbortedStr:        0,
-					model.DeviceDeploymentStatusDecommissionedStr: 1,
-				},
+				Stats: model.NewStats(map[model.DeviceDeploymentStatus]int{
+					model.DeviceDeploymentStatusPending:        0,
+					model.DeviceDeploymentStatusDownloading:    0,
+					model.DeviceDeploymentStatusInstalling:     0,
+					model.DeviceDeploymentStatusRebooting:      0,
+					model.DeviceDeploymentStatusSuccess:        1,
+					model.DeviceDeploymentStatusAlreadyInst:    0,
+					model.DeviceDeploymentStatusFailure:        2,
+					model.DeviceDeploymentStatusNoArtifact:     1,
+					model.DeviceDeploymentStatusAborted:        0,
+					model.DeviceDeploymentStatusDecommissioned: 1,
+				}),
 			},
 			devices: []interface{}{
 				model.DeviceDeployment{
@@ -328,18 +328,18 @@ func TestMigration_1_2_4(t *testing.T) {
 			id: "finished-3",
 			deployment: model.Deployment{
 				Id: "finished-3",
-				Stats: model.Stats{
-					model.DeviceDeploymentStatusPendingStr:        0,
-					model.DeviceDeploymentStatusDownloadingStr:    0,
-					model.DeviceDeploymentStatusInstallingStr:     0,
-					model.DeviceDeploymentStatusRebootingStr:      0,
-					model.DeviceDeploymentStatusSuccessStr:        1,
-					model.DeviceDeploymentStatusAlreadyInstStr:    0,
-					model.DeviceDeploymentStatusFailureStr:        2,
-					model.DeviceDeploymentStatusNoArtifactStr:     1,
-					model.DeviceDeploymentStatusAbortedStr:        0,
-					model.DeviceDeploymentStatusDecommissionedStr: 1,
-				},
+				Stats: model.NewStats(map[model.DeviceDeploymentStatus]int{
+					model.DeviceDeploymentStatusPending:        0,
+					model.DeviceDeploymentStatusDownloading:    0,
+					model.DeviceDeploymentStatusInstalling:     0,
+					model.DeviceDeploymentStatusRebooting:      0,
+					model.DeviceDeploymentStatusSuccess:        1,
+					model.DeviceDeploymentStatusAlreadyInst:    0,
+					model.DeviceDeploymentStatusFailure:        2,
+					model.DeviceDeploymentStatusNoArtifact:     1,
+					model.DeviceDeploymentStatusAborted:        0,
+					model.DeviceDeploymentStatusDecommissioned: 1,
+				}),
 			},
 			devices: []interface{}{
 				model.DeviceDeployment{
@@ -376,18 +376,18 @@ func TestMigration_1_2_4(t *testing.T) {
 			deployment: model.Deployment{
 				Id:       "finished-4",
 				Finished: &now,
-				Stats: model.Stats{
-					model.DeviceDeploymentStatusPendingStr:        0,
-					model.DeviceDeploymentStatusDownloadingStr:    0,
-					model.DeviceDeploymentStatusInstallingStr:     0,
-					model.DeviceDeploymentStatusRebootingStr:      0,
-					model.DeviceDeploymentStatusSuccessStr:        1,
-					model.DeviceDeploymentStatusAlreadyInstStr:    0,
-					model.DeviceDeploymentStatusFailureStr:        0,
-					model.DeviceDeploymentStatusNoArtifactStr:     0,
-					model.DeviceDeploymentStatusAbortedStr:        0,
-					model.DeviceDeploymentStatusDecommissionedStr: 0,
-				},
+				Stats: model.NewStats(map[model.DeviceDeploymentStatus]int{
+					model.DeviceDeploymentStatusPending:        0,
+					model.DeviceDeploymentStatusDownloading:    0,
+					model.DeviceDeploymentStatusInstalling:     0,
+					model.DeviceDeploymentStatusRebooting:      0,
+					model.DeviceDeploymentStatusSuccess:        1,
+					model.DeviceDeploymentStatusAlreadyInst:    0,
+					model.DeviceDeploymentStatusFailure:        0,
+					model.DeviceDeploymentStatusNoArtifact:     0,
+					model.DeviceDeploymentStatusAborted:        0,
+					model.DeviceDeploymentStatusDecommissioned: 0,
+				}),
 			},
 			devices: []interface{}{
 				model.DeviceDeployment{
@@ -24,7 +24,7 @@ import (
 )
 
 const (
-	DbVersion        = "1.2.18"
+	DbVersion        = "1.2.19"
 	DbMinimumVersion = "1.2.14"
 	DbName           = "deployment_service"
 )
@@ -146,6 +146,10 @@ func MigrateSingle(ctx context.Context,
 			client: client,
 			db:     db,
 		},
+		&migration_1_2_19{
+			client: client,
+			db:     db,
+		},
 	}
 
 	err = m.Apply(ctx, *ver, migrations)